@@ -0,0 +1,199 @@
+// Package abelite provides simple attribute-based access control on top of a
+// HIBE hierarchy, by reserving fixed levels for attributes, for example
+// tenant, role and clearance below the identity of an organization:
+//
+//	schema := &abelite.Schema{Prefix: org, Levels: []string{"tenant", "role", "clearance"}}
+//	ciphertext, err := abelite.EncryptForAttributes(rand.Reader, params, schema,
+//		abelite.Attributes{"tenant": "acme", "clearance": "secret"}, message)
+//
+// A ciphertext can be decrypted by the key of any attribute set that has all
+// of the attributes it was encrypted for, whatever its other attributes: an
+// AND of attributes. Unnamed levels are wildcards (see
+// hibe_sm9.WildcardEncrypt). Keys follow the order of the levels, so the key
+// for a tenant can derive the keys for the roles within it.
+//
+// This is not a full attribute-based encryption scheme: policies are
+// conjunctions of attribute values at their own levels, and there is no OR,
+// threshold, or comparison. Anonymous hierarchies are not supported, since
+// they do not support wildcards.
+package abelite
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bn256"
+	"hibe_sm9"
+	"io"
+	"math/big"
+)
+
+var (
+	// ErrUnknownAttribute is returned for attributes that the schema has no
+	// level for.
+	ErrUnknownAttribute = errors.New("abelite: unknown attribute")
+
+	// ErrMissingAttribute is returned when a key is requested for
+	// attributes that skip a level.
+	ErrMissingAttribute = errors.New("abelite: attributes must fill the levels in order")
+
+	// ErrNotSatisfied is returned when a key does not have the attributes a
+	// ciphertext was encrypted for.
+	ErrNotSatisfied = errors.New("abelite: key does not have the attributes of the ciphertext")
+)
+
+// Attributes maps attribute names to values.
+type Attributes map[string]string
+
+// Schema assigns attributes to the levels of the hierarchy below Prefix, in
+// the order of Levels.
+type Schema struct {
+	Prefix []*big.Int
+	Levels []string
+}
+
+// Depth returns the depth of the hierarchy the schema needs.
+func (schema *Schema) Depth() int {
+	return len(schema.Prefix) + len(schema.Levels)
+}
+
+// pattern maps attributes onto the identity levels of the schema, leaving nil
+// at the levels of missing attributes.
+func (schema *Schema) pattern(params *hibe_sm9.Params, attributes Attributes) (hibe_sm9.Pattern, error) {
+	if schema.Depth() > params.MaximumDepth() {
+		return nil, hibe_sm9.ErrDepthExceeded
+	}
+	pattern := make(hibe_sm9.Pattern, schema.Depth())
+	copy(pattern, schema.Prefix)
+	found := 0
+	for i, name := range schema.Levels {
+		if value, ok := attributes[name]; ok {
+			level := len(schema.Prefix) + i
+			pattern[level] = params.HashComponent(level+1, []byte(value))
+			found++
+		}
+	}
+	if found != len(attributes) {
+		for name := range attributes {
+			if !schema.has(name) {
+				return nil, fmt.Errorf("%w: %q", ErrUnknownAttribute, name)
+			}
+		}
+	}
+	return pattern, nil
+}
+
+func (schema *Schema) has(name string) bool {
+	for _, level := range schema.Levels {
+		if level == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Identity returns the identity in the hierarchy for attributes, which must
+// fill the levels of the schema in order: a tenant alone, a tenant and a role,
+// and so on.
+func (schema *Schema) Identity(params *hibe_sm9.Params, attributes Attributes) ([]*big.Int, error) {
+	pattern, err := schema.pattern(params, attributes)
+	if err != nil {
+		return nil, err
+	}
+	depth := len(schema.Prefix) + len(attributes)
+	for _, component := range pattern[:depth] {
+		if component == nil {
+			return nil, ErrMissingAttribute
+		}
+	}
+	return pattern[:depth], nil
+}
+
+// Key is the private key for a set of attributes.
+type Key struct {
+	Attributes Attributes
+	*hibe_sm9.PrivateKey
+}
+
+// KeyGen generates the key for attributes using the master key.
+func KeyGen(random io.Reader, params *hibe_sm9.Params, master hibe_sm9.MasterKey, schema *Schema, attributes Attributes) (*Key, error) {
+	id, err := schema.Identity(params, attributes)
+	if err != nil {
+		return nil, err
+	}
+	key, err := hibe_sm9.KeyGenFromMaster(random, params, master, id)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{Attributes: copyAttributes(attributes), PrivateKey: key}, nil
+}
+
+// Derive generates the key for attributes from the key of a subset of them,
+// one or more levels up: a tenant key derives the key for a role in the
+// tenant, and so on.
+func Derive(random io.Reader, params *hibe_sm9.Params, parent *Key, schema *Schema, attributes Attributes) (*Key, error) {
+	for name, value := range parent.Attributes {
+		if attributes[name] != value {
+			return nil, ErrNotSatisfied
+		}
+	}
+	id, err := schema.Identity(params, attributes)
+	if err != nil {
+		return nil, err
+	}
+	key := parent.PrivateKey
+	for depth := len(schema.Prefix) + len(parent.Attributes) + 1; depth <= len(id); depth++ {
+		if key, err = hibe_sm9.KeyGenFromParent(random, params, key, id[:depth]); err != nil {
+			return nil, err
+		}
+	}
+	return &Key{Attributes: copyAttributes(attributes), PrivateKey: key}, nil
+}
+
+func copyAttributes(attributes Attributes) Attributes {
+	copied := make(Attributes, len(attributes))
+	for name, value := range attributes {
+		copied[name] = value
+	}
+	return copied
+}
+
+// Ciphertext is a message encrypted for a set of attributes.
+type Ciphertext struct {
+	Attributes Attributes
+	*hibe_sm9.WildcardCiphertext
+}
+
+// EncryptForAttributes encrypts message for every key that has all of the
+// given attributes.
+func EncryptForAttributes(random io.Reader, params *hibe_sm9.Params, schema *Schema, attributes Attributes, message *bn256.GT) (*Ciphertext, error) {
+	pattern, err := schema.pattern(params, attributes)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := hibe_sm9.WildcardEncrypt(random, params, pattern, message)
+	if err != nil {
+		return nil, err
+	}
+	return &Ciphertext{Attributes: copyAttributes(attributes), WildcardCiphertext: ciphertext}, nil
+}
+
+// Decrypt recovers the message with a key for a full set of attributes, one
+// for every level of the schema; keys for fewer attributes must be completed
+// with Derive first. ErrNotSatisfied is returned if the key lacks one of the
+// attributes of the ciphertext.
+func Decrypt(params *hibe_sm9.Params, schema *Schema, key *Key, ciphertext *Ciphertext) (*bn256.GT, error) {
+	for name, value := range ciphertext.Attributes {
+		if key.Attributes[name] != value {
+			return nil, ErrNotSatisfied
+		}
+	}
+	id, err := schema.Identity(params, key.Attributes)
+	if err != nil {
+		return nil, err
+	}
+	ordinary, err := ciphertext.For(id)
+	if err != nil {
+		return nil, ErrNotSatisfied
+	}
+	return hibe_sm9.Decrypt(key.PrivateKey, ordinary)
+}
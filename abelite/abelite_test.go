@@ -0,0 +1,69 @@
+package abelite
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"hibe_sm9"
+	"math/big"
+	"testing"
+)
+
+func TestAttributes(t *testing.T) {
+	params, master, err := hibe_sm9.Setup(rand.Reader, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := &Schema{Prefix: []*big.Int{big.NewInt(7)}, Levels: []string{"tenant", "role", "clearance"}}
+
+	tenant, err := KeyGen(rand.Reader, params, master, schema, Attributes{"tenant": "acme"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := Derive(rand.Reader, params, tenant, schema, Attributes{"tenant": "acme", "role": "admin", "clearance": "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	auditor, err := KeyGen(rand.Reader, params, master, schema, Attributes{"tenant": "acme", "role": "auditor", "clearance": "public"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := KeyGen(rand.Reader, params, master, schema, Attributes{"tenant": "globex", "role": "admin", "clearance": "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := hibe_sm9.HashToGT([]byte("message"))
+	ciphertext, err := EncryptForAttributes(rand.Reader, params, schema, Attributes{"tenant": "acme", "clearance": "secret"}, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := Decrypt(params, schema, admin, ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(message.Marshal(), decrypted.Marshal()) {
+		t.Fatal("Original and decrypted messages differ")
+	}
+	for _, key := range []*Key{auditor, other} {
+		if _, err = Decrypt(params, schema, key, ciphertext); !errors.Is(err, ErrNotSatisfied) {
+			t.Fatal("Key without the attributes was accepted")
+		}
+	}
+
+	// Claiming attributes does not help a key decrypt
+	forged := &Key{Attributes: admin.Attributes, PrivateKey: auditor.PrivateKey}
+	if decrypted, err = Decrypt(params, schema, forged, ciphertext); err == nil && bytes.Equal(message.Marshal(), decrypted.Marshal()) {
+		t.Fatal("Key with forged attributes decrypted the message")
+	}
+
+	if _, err = KeyGen(rand.Reader, params, master, schema, Attributes{"tenant": "acme", "clearance": "secret"}); !errors.Is(err, ErrMissingAttribute) {
+		t.Fatal("Key skipping a level was issued")
+	}
+	if _, err = EncryptForAttributes(rand.Reader, params, schema, Attributes{"team": "red"}, message); !errors.Is(err, ErrUnknownAttribute) {
+		t.Fatal("Unknown attribute was accepted")
+	}
+	if _, err = Derive(rand.Reader, params, tenant, schema, Attributes{"tenant": "globex", "role": "admin"}); !errors.Is(err, ErrNotSatisfied) {
+		t.Fatal("Key was derived for another tenant")
+	}
+}
@@ -0,0 +1,290 @@
+package hibe_sm9
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"golang.org/x/crypto/bn256"
+	"golang.org/x/crypto/hkdf"
+	"io"
+	"math/big"
+)
+
+// SessionKeySize is the size in bytes of session keys agreed by the key
+// exchange.
+const SessionKeySize = 32
+
+// akeSalt separates the keys derived by the key exchange from other uses of
+// the same secrets.
+var akeSalt = []byte("HIBE-AKE")
+
+var (
+	errAKEMalformed = wrapError(ErrMalformedCiphertext, "hibe: malformed key exchange message")
+	errAKEConfirm   = wrapError(ErrDecryptFailed, "hibe: key exchange confirmation failed")
+	errAKEState     = errors.New("hibe: key exchange is not at the expected step")
+)
+
+// KeyAgreement is one side of an authenticated key exchange between two
+// members of a hierarchy, each holding the private key for its identity:
+//
+//	initiator                               responder
+//	Initiate      ---- request ---->
+//	                                        Respond
+//	              <--- response ----
+//	Complete      -- confirmation ->
+//	                                        Complete
+//
+// Each side encapsulates a secret to the other's identity and sends an
+// ephemeral Diffie-Hellman share in G1. The session key is derived from both
+// secrets, the Diffie-Hellman result, and the transcript, so only the holders
+// of keys for the two identities can compute it, and compromising their
+// private keys later does not reveal it. Both sides confirm the key with a MAC
+// over the transcript before Complete succeeds.
+type KeyAgreement struct {
+	params    *Params
+	key       *PrivateKey
+	initiator bool
+	peer      []*big.Int
+
+	ephemeral  *big.Int
+	secret     []byte
+	request    []byte
+	transcript []byte
+
+	sessionKey []byte
+	confirmKey []byte
+	done       bool
+}
+
+// Initiate starts a key exchange from initiator, whose private key is key, to
+// responder. The returned request must be sent to the responder.
+func Initiate(random io.Reader, params *Params, key *PrivateKey, initiator []*big.Int, responder []*big.Int) (*KeyAgreement, []byte, error) {
+	ephemeral, share, err := akeShare(random)
+	if err != nil {
+		return nil, nil, err
+	}
+	secret, encapsulation, err := Encapsulate(random, params, responder)
+	if err != nil {
+		zeroizeScalar(ephemeral)
+		return nil, nil, err
+	}
+
+	request := encodeID(initiator)
+	request = append(request, encodeID(responder)...)
+	request = append(request, share.Marshal()...)
+	request = appendEncapsulation(request, encapsulation)
+
+	return &KeyAgreement{
+		params:    params,
+		key:       key,
+		initiator: true,
+		peer:      responder,
+		ephemeral: ephemeral,
+		secret:    secret,
+		request:   request,
+	}, request, nil
+}
+
+// Respond answers a request from Initiate with the private key of responder,
+// which must be the identity the request is addressed to. The returned
+// response must be sent back to the initiator, whose identity is available
+// from Peer.
+func Respond(random io.Reader, params *Params, key *PrivateKey, responder []*big.Int, request []byte) (*KeyAgreement, []byte, error) {
+	initiator, rest, ok := decodeID(request)
+	if !ok {
+		return nil, nil, errAKEMalformed
+	}
+	addressee, rest, ok := decodeID(rest)
+	if !ok || len(addressee) != len(responder) {
+		return nil, nil, errAKEMalformed
+	}
+	for i := range responder {
+		if new(big.Int).Mod(responder[i], bn256.Order).Cmp(addressee[i]) != 0 {
+			return nil, nil, errAKEMalformed
+		}
+	}
+	peerShare, encapsulation, ok := parseAKEMessage(rest)
+	if !ok {
+		return nil, nil, errAKEMalformed
+	}
+	peerSecret, err := Decapsulate(key, encapsulation)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer zeroizeBytes(peerSecret)
+
+	ephemeral, share, err := akeShare(random)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer zeroizeScalar(ephemeral)
+	secret, encapsulation, err := Encapsulate(random, params, initiator)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer zeroizeBytes(secret)
+
+	response := share.Marshal()
+	response = appendEncapsulation(response, encapsulation)
+
+	agreement := &KeyAgreement{
+		params:     params,
+		key:        key,
+		peer:       initiator,
+		transcript: append(append([]byte{}, request...), response...),
+	}
+	if err = agreement.deriveKeys(peerSecret, secret, peerShare, ephemeral); err != nil {
+		return nil, nil, err
+	}
+	return agreement, append(response, agreement.confirmation(false)...), nil
+}
+
+// Complete processes the next message of the exchange. On the initiator, the
+// message is the response, and the returned confirmation must be sent to the
+// responder. On the responder, the message is the confirmation, and nothing
+// is returned. Once Complete succeeds, SessionKey is available.
+func (agreement *KeyAgreement) Complete(message []byte) ([]byte, error) {
+	if agreement.done {
+		return nil, errAKEState
+	}
+	if !agreement.initiator {
+		if !hmac.Equal(message, agreement.confirmation(true)) {
+			return nil, errAKEConfirm
+		}
+		agreement.done = true
+		zeroizeBytes(agreement.confirmKey)
+		return nil, nil
+	}
+
+	if len(message) < sha256.Size {
+		return nil, errAKEMalformed
+	}
+	response, tag := message[:len(message)-sha256.Size], message[len(message)-sha256.Size:]
+	peerShare, encapsulation, ok := parseAKEMessage(response)
+	if !ok {
+		return nil, errAKEMalformed
+	}
+	peerSecret, err := Decapsulate(agreement.key, encapsulation)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroizeBytes(peerSecret)
+
+	agreement.transcript = append(append([]byte{}, agreement.request...), response...)
+	defer zeroizeScalar(agreement.ephemeral)
+	defer zeroizeBytes(agreement.secret)
+	if err = agreement.deriveKeys(agreement.secret, peerSecret, peerShare, agreement.ephemeral); err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(tag, agreement.confirmation(false)) {
+		zeroizeBytes(agreement.sessionKey)
+		agreement.sessionKey = nil
+		return nil, errAKEConfirm
+	}
+	agreement.done = true
+	confirmation := agreement.confirmation(true)
+	zeroizeBytes(agreement.confirmKey)
+	return confirmation, nil
+}
+
+// SessionKey returns the agreed key, or nil if Complete has not succeeded.
+func (agreement *KeyAgreement) SessionKey() []byte {
+	if !agreement.done {
+		return nil
+	}
+	return agreement.sessionKey
+}
+
+// Peer returns the identity of the other side of the exchange.
+func (agreement *KeyAgreement) Peer() []*big.Int {
+	return agreement.peer
+}
+
+// Transcript returns the request and response (without the responder's
+// confirmation tag), which the session key is bound to. It is nil on the
+// initiator until Complete has been called.
+func (agreement *KeyAgreement) Transcript() []byte {
+	return agreement.transcript
+}
+
+// akeShare generates an ephemeral Diffie-Hellman share in G1.
+func akeShare(random io.Reader) (*big.Int, *bn256.G1, error) {
+	random = randomSource(random)
+	ephemeral, err := rand.Int(random, bn256.Order)
+	if err != nil {
+		return nil, nil, err
+	}
+	share, err := secretMultG1(new(bn256.G1).ScalarBaseMult(big.NewInt(1)), ephemeral)
+	if err != nil {
+		zeroizeScalar(ephemeral)
+		return nil, nil, err
+	}
+	return ephemeral, share, nil
+}
+
+// appendEncapsulation appends an encapsulation, prefixed with its length.
+func appendEncapsulation(message []byte, encapsulation *Ciphertext) []byte {
+	encoded := encapsulation.Marshal()
+	var length [2]byte
+	binary.BigEndian.PutUint16(length[:], uint16(len(encoded)))
+	return append(append(message, length[:]...), encoded...)
+}
+
+// parseAKEMessage parses a Diffie-Hellman share followed by an encapsulation,
+// which must take up the rest of the message.
+func parseAKEMessage(message []byte) (*bn256.G1, *Ciphertext, bool) {
+	if len(message) < geSize+2 {
+		return nil, nil, false
+	}
+	share, err := unmarshalG1(message[:geSize])
+	if err != nil {
+		return nil, nil, false
+	}
+	message = message[geSize:]
+	if int(binary.BigEndian.Uint16(message)) != len(message)-2 {
+		return nil, nil, false
+	}
+	encapsulation, ok := new(Ciphertext).Unmarshal(message[2:])
+	if !ok {
+		return nil, nil, false
+	}
+	return share, encapsulation, true
+}
+
+// deriveKeys derives the session key and the confirmation key from the
+// initiator's and responder's secrets, the Diffie-Hellman result, and the
+// transcript.
+func (agreement *KeyAgreement) deriveKeys(initiatorSecret []byte, responderSecret []byte, peerShare *bn256.G1, ephemeral *big.Int) error {
+	shared, err := secretMultG1(peerShare, ephemeral)
+	if err != nil {
+		return err
+	}
+	defer zeroizeG1(shared)
+
+	ikm := append(append(append([]byte{}, initiatorSecret...), responderSecret...), shared.Marshal()...)
+	defer zeroizeBytes(ikm)
+	digest := sha256.Sum256(agreement.transcript)
+	kdf := hkdf.New(sha256.New, ikm, akeSalt, digest[:])
+
+	keys := make([]byte, SessionKeySize+sha256.Size)
+	if _, err = io.ReadFull(kdf, keys); err != nil {
+		return err
+	}
+	agreement.sessionKey, agreement.confirmKey = keys[:SessionKeySize], keys[SessionKeySize:]
+	return nil
+}
+
+// confirmation computes the MAC over the transcript sent by the initiator
+// (fromInitiator) or the responder.
+func (agreement *KeyAgreement) confirmation(fromInitiator bool) []byte {
+	mac := hmac.New(sha256.New, agreement.confirmKey)
+	if fromInitiator {
+		mac.Write([]byte{1})
+	} else {
+		mac.Write([]byte{0})
+	}
+	mac.Write(agreement.transcript)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,111 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func newAKEPair(t *testing.T) (*Params, *PrivateKey, []*big.Int, *PrivateKey, []*big.Int) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := []*big.Int{big.NewInt(1), big.NewInt(2)}
+	bob := []*big.Int{big.NewInt(1), big.NewInt(3)}
+	aliceKey, err := KeyGenFromMaster(rand.Reader, params, master, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobKey, err := KeyGenFromMaster(rand.Reader, params, master, bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return params, aliceKey, alice, bobKey, bob
+}
+
+func TestKeyAgreement(t *testing.T) {
+	params, aliceKey, alice, bobKey, bob := newAKEPair(t)
+
+	initiator, request, err := Initiate(rand.Reader, params, aliceKey, alice, bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	responder, response, err := Respond(rand.Reader, params, bobKey, bob, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !idsAgree(responder.Peer(), alice) || len(responder.Peer()) != len(alice) {
+		t.Fatal("Responder does not see the initiator's identity")
+	}
+	if responder.SessionKey() != nil {
+		t.Fatal("Session key available before confirmation")
+	}
+	confirmation, err := initiator.Complete(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = responder.Complete(confirmation); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(initiator.SessionKey()) != SessionKeySize || !bytes.Equal(initiator.SessionKey(), responder.SessionKey()) {
+		t.Fatal("Session keys differ")
+	}
+	if !bytes.Equal(initiator.Transcript(), responder.Transcript()) {
+		t.Fatal("Transcripts differ")
+	}
+	if _, err = initiator.Complete(response); err != errAKEState {
+		t.Fatal("Completed a finished exchange")
+	}
+}
+
+func TestKeyAgreementWrongKey(t *testing.T) {
+	params, aliceKey, alice, bobKey, bob := newAKEPair(t)
+
+	// Alice pretends to be Bob's peer with Bob's identity but her own key.
+	_, request, err := Initiate(rand.Reader, params, aliceKey, alice, bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = Respond(rand.Reader, params, aliceKey, alice, request); err != errAKEMalformed {
+		t.Fatal("Responded to a request addressed to someone else")
+	}
+	if _, _, err = Respond(rand.Reader, params, aliceKey, bob, request); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without Bob's key, the confirmation does not match.
+	initiator, request, err := Initiate(rand.Reader, params, aliceKey, alice, bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	impostor, response, err := Respond(rand.Reader, params, aliceKey, bob, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = initiator.Complete(response); err != errAKEConfirm {
+		t.Fatal("Initiator accepted a responder without the key for its identity")
+	}
+
+	// Tampering with the response is detected.
+	initiator, request, err = Initiate(rand.Reader, params, aliceKey, alice, bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	responder, response, err := Respond(rand.Reader, params, bobKey, bob, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response[len(response)-1] ^= 1
+	if _, err = initiator.Complete(response); err != errAKEConfirm {
+		t.Fatal("Initiator accepted a tampered response")
+	}
+	if _, err = responder.Complete(make([]byte, 32)); err != errAKEConfirm {
+		t.Fatal("Responder accepted a forged confirmation")
+	}
+	if impostor.SessionKey() != nil || responder.SessionKey() != nil {
+		t.Fatal("Session key available without confirmation")
+	}
+}
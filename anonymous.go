@@ -0,0 +1,102 @@
+package hibe_sm9
+
+import (
+	"context"
+	"crypto/rand"
+	"golang.org/x/crypto/bn256"
+	"io"
+	"math/big"
+)
+
+// anonymousDomain separates the private generator of an anonymous hierarchy
+// from other hashes of the master key.
+var anonymousDomain = []byte("HIBE-ANONYMOUS")
+
+// Anonymous returns true if the parameters belong to an anonymous hierarchy.
+func (params *Params) Anonymous() bool {
+	return params.G3Hat != nil
+}
+
+// privateGenerator returns the secret generator of G1 of an anonymous
+// hierarchy. It is derived from the master key so that the master key remains
+// the only secret the PKG has to keep.
+func privateGenerator(master MasterKey) *bn256.G1 {
+	return HashToG1(append(append([]byte{}, anonymousDomain...), (*bn256.G1)(master).Marshal()...))
+}
+
+// setupAnonymous turns freshly generated parameters into those of an anonymous
+// hierarchy, following "Anonymity from Asymmetry" (Ducas, 2010). Ciphertexts
+// have their C component in G2, computed from mirrors of g3 and h1 ... hl in
+// G2, and keys have their A1 component in G1, computed from a generator g' of
+// G1 that only the PKG knows. Testing whether a ciphertext is for a given
+// identity then requires a pairing between two elements of G2, which the
+// asymmetric bn256 pairing does not allow (this is the SXDH assumption).
+//
+// Because g' is secret, keys cannot be re-randomized by anyone but the PKG.
+// Keys in anonymous hierarchies therefore have no delegation components, and
+// every key must be generated from the master key.
+func setupAnonymous(ctx context.Context, random io.Reader, params *Params, master MasterKey) error {
+	generator := privateGenerator(master)
+
+	exponent, err := rand.Int(random, bn256.Order)
+	if err != nil {
+		return err
+	}
+	params.G3 = new(bn256.G1).ScalarMult(generator, exponent)
+	params.G3Hat = new(bn256.G2).ScalarMult(params.G, exponent)
+
+	params.HHat = make([]*bn256.G2, len(params.H))
+	for i := range params.H {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		exponent, err = rand.Int(random, bn256.Order)
+		if err != nil {
+			return err
+		}
+		params.H[i] = new(bn256.G1).ScalarMult(generator, exponent)
+		params.HHat[i] = new(bn256.G2).ScalarMult(params.G, exponent)
+	}
+
+	return nil
+}
+
+// idProductHat computes the mirror of idProduct in G2, for anonymous
+// hierarchies.
+func idProductHat(params *Params, id []*big.Int) *bn256.G2 {
+	if len(id) >= multiExpThreshold {
+		return multiExpG2(params.G3Hat, params.HHat[:len(id)], id)
+	}
+	product := deepCloneG2(params.G3Hat)
+	h := new(bn256.G2)
+	for i := range id {
+		product.Add(product, h.ScalarMult(params.HHat[i], id[i]))
+	}
+	return product
+}
+
+// keyGenAnonymous generates a key for an ID in an anonymous hierarchy.
+func keyGenAnonymous(random io.Reader, params *Params, master MasterKey, id []*big.Int) (*PrivateKey, error) {
+	key := &PrivateKey{ParamsFingerprint: params.Fingerprint()}
+
+	// Randomly choose r in Zp.
+	r, err := rand.Int(random, bn256.Order)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroizeScalar(r)
+
+	product, err := secretMultG1(idProduct(params, id), r)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroizeG1(product)
+
+	key.A0 = new(bn256.G1).Add(master, product)
+	key.A1Hat, err = secretMultG1(privateGenerator(master), r)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
@@ -0,0 +1,114 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func TestAnonymous(t *testing.T) {
+	// Set up parameters for an anonymous hierarchy
+	params, key, err := Setup(rand.Reader, 10, WithAnonymity())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !params.Anonymous() {
+		t.Fatal("Hierarchy is not anonymous")
+	}
+
+	// Parameters, keys, and ciphertexts survive serialization
+	params, ok := new(Params).Unmarshal(params.Marshal())
+	if !ok || !params.Anonymous() {
+		t.Fatal("Could not unmarshal anonymous parameters")
+	}
+
+	// Come up with a message to encrypt
+	message := NewMessage()
+
+	ciphertext, err := Encrypt(rand.Reader, params, LINEAR_HIERARCHY[:2], message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ciphertext.C != nil || ciphertext.CHat == nil {
+		t.Fatal("Ciphertext is not anonymous")
+	}
+	ciphertext, ok = new(Ciphertext).Unmarshal(ciphertext.Marshal())
+	if !ok {
+		t.Fatal("Could not unmarshal anonymous ciphertext")
+	}
+
+	secondlevelkey, err := KeyGenFromMaster(rand.Reader, params, key, LINEAR_HIERARCHY[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = secondlevelkey.Validate(params); err != nil {
+		t.Fatal(err)
+	}
+	secondlevelkey, ok = new(PrivateKey).Unmarshal(secondlevelkey.Marshal())
+	if !ok {
+		t.Fatal("Could not unmarshal anonymous private key")
+	}
+
+	decrypted := mustDecrypt(t, secondlevelkey, ciphertext)
+	if !bytes.Equal(message.Marshal(), decrypted.Marshal()) {
+		t.Fatal("Original and decrypted messages differ")
+	}
+
+	// Keys for other identities do not decrypt
+	toplevelkey, err := KeyGenFromMaster(rand.Reader, params, key, LINEAR_HIERARCHY[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted = mustDecrypt(t, toplevelkey, ciphertext)
+	if bytes.Equal(message.Marshal(), decrypted.Marshal()) {
+		t.Fatal("Key for another identity decrypted the message")
+	}
+}
+
+func TestAnonymousMulti(t *testing.T) {
+	params, key, err := Setup(rand.Reader, 5, WithAnonymity())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := NewMessage()
+	ids := [][]*big.Int{LINEAR_HIERARCHY[:1], LINEAR_HIERARCHY}
+	ciphertext, err := EncryptMulti(rand.Reader, params, ids, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, id := range ids {
+		privkey, err := KeyGenFromMaster(rand.Reader, params, key, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decrypted := mustDecrypt(t, privkey, ciphertext.Ciphertext(i))
+		if !bytes.Equal(message.Marshal(), decrypted.Marshal()) {
+			t.Fatal("Original and decrypted messages differ")
+		}
+	}
+
+	// Recipients are not linked: a pair of recipients only passes the
+	// pairing test e(idProduct(a), CHat[j]) = e(idProduct(b), CHat[i]) when
+	// the ciphertexts share their randomness
+	lhs := pair(idProduct(params, ids[0]), ciphertext.Ciphertext(1).CHat)
+	rhs := pair(idProduct(params, ids[1]), ciphertext.Ciphertext(0).CHat)
+	if bytes.Equal(lhs.Marshal(), rhs.Marshal()) {
+		t.Fatal("Multi-recipient ciphertext links its recipients")
+	}
+}
+
+func TestHashToG1(t *testing.T) {
+	a := HashToG1([]byte("a"))
+	if checkG1(a) != nil {
+		t.Fatal("Hash is not a valid element of G1")
+	}
+	if !bytes.Equal(a.Marshal(), HashToG1([]byte("a")).Marshal()) {
+		t.Fatal("Hash is not deterministic")
+	}
+	if bytes.Equal(a.Marshal(), HashToG1([]byte("b")).Marshal()) {
+		t.Fatal("Different inputs hash to the same point")
+	}
+}
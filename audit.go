@@ -0,0 +1,43 @@
+package hibe_sm9
+
+import (
+	"log"
+	"math/big"
+	"time"
+)
+
+// Auditor receives an event for every key issued or denied, and every
+// decryption, by the components that are given one: pkgserver.Server for key
+// issuance and Decrypter for decryption. Deployments implement it for
+// structured audit logging, export to a SIEM, or alerting. The calls are
+// synchronous, so implementations that do slow I/O should queue the events.
+//
+// The requester is the authenticated name of whoever asked for the operation,
+// and err is nil if it succeeded, or the reason it was denied or failed.
+type Auditor interface {
+	OnKeyGen(id []*big.Int, requester string, at time.Time, err error)
+	OnDecrypt(id []*big.Int, requester string, at time.Time, err error)
+}
+
+// LogAuditor is an Auditor that writes one line per event to a log.Logger.
+type LogAuditor struct {
+	Logger *log.Logger
+}
+
+func (auditor LogAuditor) event(kind string, id []*big.Int, requester string, at time.Time, err error) {
+	outcome := "ok"
+	if err != nil {
+		outcome = err.Error()
+	}
+	auditor.Logger.Printf("event=%s at=%s requester=%q id=%v outcome=%q", kind, at.UTC().Format(time.RFC3339Nano), requester, id, outcome)
+}
+
+// OnKeyGen implements Auditor.
+func (auditor LogAuditor) OnKeyGen(id []*big.Int, requester string, at time.Time, err error) {
+	auditor.event("keygen", id, requester, at, err)
+}
+
+// OnDecrypt implements Auditor.
+func (auditor LogAuditor) OnDecrypt(id []*big.Int, requester string, at time.Time, err error) {
+	auditor.event("decrypt", id, requester, at, err)
+}
@@ -0,0 +1,55 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogAuditor(t *testing.T) {
+	var buffer bytes.Buffer
+	auditor := LogAuditor{log.New(&buffer, "", 0)}
+	at := time.Date(2025, time.June, 11, 10, 0, 0, 0, time.UTC)
+	auditor.OnKeyGen(LINEAR_HIERARCHY[:2], "alice", at, nil)
+	auditor.OnKeyGen(LINEAR_HIERARCHY[:1], "bob", at, errors.New("forbidden"))
+	expected := `event=keygen at=2025-06-11T10:00:00Z requester="alice" id=[1 2] outcome="ok"
+event=keygen at=2025-06-11T10:00:00Z requester="bob" id=[1] outcome="forbidden"
+`
+	if buffer.String() != expected {
+		t.Fatalf("Unexpected log:\n%s", buffer.String())
+	}
+
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypter, err := NewDecrypter(params, key, LINEAR_HIERARCHY[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	buffer.Reset()
+	decrypter.SetAuditor(auditor, "service")
+	ciphertext, err := EncryptBytes(rand.Reader, params, LINEAR_HIERARCHY[:2], []byte("message"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = decrypter.Decrypt(nil, ciphertext, nil); err != nil {
+		t.Fatal(err)
+	}
+	ciphertext[len(ciphertext)-1] ^= 1
+	if _, err = decrypter.Decrypt(nil, ciphertext, nil); err == nil {
+		t.Fatal("Decrypted a tampered ciphertext")
+	}
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `event=decrypt`) || !strings.Contains(lines[0], `requester="service" id=[1 2] outcome="ok"`) || strings.Contains(lines[1], `outcome="ok"`) {
+		t.Fatalf("Unexpected log:\n%s", buffer.String())
+	}
+}
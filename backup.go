@@ -0,0 +1,183 @@
+package hibe_sm9
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"io"
+)
+
+// backupVersion is the first byte of every encoded key share.
+const backupVersion = 2
+
+// backupHeaderSize is the size of the fixed part of an encoded key share: the
+// version, the threshold, the index, and a tag of the key.
+const backupHeaderSize = 3 + backupTagSize
+
+// backupTagSize is the size of the truncated HMAC-SHA256 of the encoded key
+// that every share carries, so that recovering from mismatched or corrupted
+// shares fails instead of yielding a wrong key. The HMAC key is shared along
+// with the private key, so fewer than the threshold number of shares cannot be
+// used to test guesses of the private key against the tag.
+const backupTagSize = 16
+
+// backupMACKeySize is the size of the random HMAC key that is shared in front
+// of the encoded private key.
+const backupMACKeySize = 32
+
+var (
+	errShareMalformed = errors.New("hibe: malformed key share")
+	errShareMismatch  = errors.New("hibe: key shares are from different backups or corrupted")
+	errShareCount     = errors.New("hibe: too many key shares for GF(256)")
+)
+
+// gf256Exp and gf256Log are the exponential and logarithm tables of GF(2^8)
+// with the AES polynomial x^8 + x^4 + x^3 + x + 1, for the generator 3.
+var gf256Exp, gf256Log = func() ([510]byte, [256]byte) {
+	var exp [510]byte
+	var log [256]byte
+	x := byte(1)
+	for i := 0; i != 255; i++ {
+		exp[i], exp[i+255] = x, x
+		log[x] = byte(i)
+		// Multiply by 3 = x + 1
+		x2 := x << 1
+		if x&0x80 != 0 {
+			x2 ^= 0x1b
+		}
+		x ^= x2
+	}
+	return exp, log
+}()
+
+func gf256Mul(a byte, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gf256Exp[int(gf256Log[a])+int(gf256Log[b])]
+}
+
+func gf256Div(a byte, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gf256Exp[int(gf256Log[a])+255-int(gf256Log[b])]
+}
+
+// ExportShares splits the private key into n shares for backup, any t of
+// which recover it with RecoverFromShares, while fewer reveal nothing about
+// it. This is Shamir's secret sharing over GF(2^8), applied to each byte of
+// the key's encoding, together with a random key for the tag that detects
+// mismatched or corrupted shares. Each encoded share is self-describing, and
+// can be stored with a different device or custodian.
+//
+// Unlike SplitMaster, the shares are never used for anything but recovery:
+// the key is reassembled in one place before use.
+func ExportShares(random io.Reader, key *PrivateKey, n int, t int) ([][]byte, error) {
+	random = randomSource(random)
+	if err := checkThreshold(n, t); err != nil {
+		return nil, err
+	}
+	if n > 255 {
+		return nil, errShareCount
+	}
+	encoded := key.Marshal()
+	defer zeroizeBytes(encoded)
+	secret := make([]byte, backupMACKeySize, backupMACKeySize+len(encoded))
+	defer zeroizeBytes(secret)
+	if _, err := io.ReadFull(random, secret); err != nil {
+		return nil, err
+	}
+	secret = append(secret, encoded...)
+	tag := backupTag(secret)
+
+	coefficients := make([]byte, (t-1)*len(secret))
+	defer zeroizeBytes(coefficients)
+	if _, err := io.ReadFull(random, coefficients); err != nil {
+		return nil, err
+	}
+
+	shares := make([][]byte, n)
+	for i := range shares {
+		x := byte(i + 1)
+		share := make([]byte, backupHeaderSize, backupHeaderSize+len(secret))
+		share[0], share[1], share[2] = backupVersion, byte(t), x
+		copy(share[3:], tag)
+		for j, s := range secret {
+			// Horner's rule, from the highest coefficient down to s
+			y := byte(0)
+			for k := t - 2; k >= 0; k-- {
+				y = gf256Mul(y, x) ^ coefficients[k*len(secret)+j]
+			}
+			share = append(share, gf256Mul(y, x)^s)
+		}
+		shares[i] = share
+	}
+	return shares, nil
+}
+
+// backupTag computes the tag of a shared secret, which is the HMAC key followed
+// by the encoded private key.
+func backupTag(secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret[:backupMACKeySize])
+	mac.Write(secret[backupMACKeySize:])
+	return mac.Sum(nil)[:backupTagSize]
+}
+
+// RecoverFromShares recovers a private key from at least the threshold number
+// of the shares produced by ExportShares. Extra shares are ignored.
+func RecoverFromShares(shares [][]byte) (*PrivateKey, error) {
+	if len(shares) == 0 {
+		return nil, errShareMalformed
+	}
+	first := shares[0]
+	if len(first) <= backupHeaderSize || first[0] != backupVersion || first[1] == 0 {
+		return nil, errShareMalformed
+	}
+	t := int(first[1])
+	if len(shares) < t {
+		return nil, errors.New("hibe: not enough key shares")
+	}
+	shares = shares[:t]
+	for i, share := range shares {
+		if len(share) != len(first) || share[0] != backupVersion || share[2] == 0 {
+			return nil, errShareMalformed
+		}
+		if share[1] != first[1] || subtle.ConstantTimeCompare(share[3:backupHeaderSize], first[3:backupHeaderSize]) != 1 {
+			return nil, errShareMismatch
+		}
+		for _, other := range shares[:i] {
+			if other[2] == share[2] {
+				return nil, errors.New("hibe: duplicate share index")
+			}
+		}
+	}
+
+	// Lagrange interpolation at zero; subtraction is addition in GF(2^8)
+	secret := make([]byte, len(first)-backupHeaderSize)
+	defer zeroizeBytes(secret)
+	for i, share := range shares {
+		coefficient := byte(1)
+		for j, other := range shares {
+			if i != j {
+				coefficient = gf256Mul(coefficient, gf256Div(other[2], other[2]^share[2]))
+			}
+		}
+		for k, y := range share[backupHeaderSize:] {
+			secret[k] ^= gf256Mul(coefficient, y)
+		}
+	}
+
+	if len(secret) <= backupMACKeySize {
+		return nil, errShareMalformed
+	}
+	if !hmac.Equal(backupTag(secret), first[3:backupHeaderSize]) {
+		return nil, errShareMismatch
+	}
+	key, ok := new(PrivateKey).Unmarshal(secret[backupMACKeySize:])
+	if !ok {
+		return nil, errShareMalformed
+	}
+	return key, nil
+}
@@ -0,0 +1,51 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestKeyBackup(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	shares, err := ExportShares(rand.Reader, key, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recovered, err := RecoverFromShares([][]byte{shares[4], shares[0], shares[2]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(recovered.Marshal(), key.Marshal()) {
+		t.Fatal("Recovered key differs")
+	}
+	if _, err = RecoverFromShares(shares[:2]); err == nil {
+		t.Fatal("Recovered from fewer shares than the threshold")
+	}
+
+	corrupted := append([]byte{}, shares[1]...)
+	corrupted[len(corrupted)-1] ^= 1
+	if _, err = RecoverFromShares([][]byte{shares[0], corrupted, shares[2]}); err != errShareMismatch {
+		t.Fatal("Recovered from a corrupted share")
+	}
+	other, err := ExportShares(rand.Reader, key, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Shares of the same key from different backups have different tags, so
+	// that the tag cannot be used to test guesses of the key
+	if bytes.Equal(shares[0][:backupHeaderSize], other[0][:backupHeaderSize]) {
+		t.Fatal("Backups of the same key have the same tag")
+	}
+	if _, err = RecoverFromShares([][]byte{shares[0], other[1], shares[2]}); err != errShareMismatch {
+		t.Fatal("Recovered from shares of different backups")
+	}
+}
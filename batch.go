@@ -0,0 +1,218 @@
+package hibe_sm9
+
+import (
+	"context"
+	"golang.org/x/crypto/bn256"
+	"io"
+	"math/big"
+	"runtime"
+	"sync"
+)
+
+// DecryptBatch decrypts many ciphertexts with the same private key, returning
+// the plaintexts and errors in the same order as the ciphertexts. Each
+// ciphertext goes through the checks of Decrypt, including its integrity tag
+// and the options, and one that fails only affects its own entry.
+//
+// Each decryption still costs two pairings: x/crypto/bn256 does not expose its
+// Miller loop, so the pairings cannot share a final exponentiation. Instead,
+// the ciphertexts are spread over GOMAXPROCS workers, and the inversion in GT
+// is avoided by pairing with -A0 rather than inverting e(A0, B).
+func DecryptBatch(key *PrivateKey, ciphertexts []*Ciphertext, opts ...DecryptOption) ([]*bn256.GT, []error) {
+	plaintexts := make([]*bn256.GT, len(ciphertexts))
+	errs := make([]error, len(ciphertexts))
+	config := decryptOptions(opts)
+	negA0 := new(bn256.G1)
+	if key != nil && key.A0 != nil {
+		negA0.Neg(key.A0)
+	}
+
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(ciphertexts) {
+		workers = len(ciphertexts)
+	}
+
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w != workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				if errs[i] = checkDecrypt(key, ciphertexts[i], config); errs[i] != nil {
+					continue
+				}
+				plaintext := decryptWithNegatedA0(key, negA0, ciphertexts[i])
+				if errs[i] = checkIntegrity(plaintext, ciphertexts[i]); errs[i] != nil {
+					zeroizeGT(plaintext)
+					continue
+				}
+				plaintexts[i] = plaintext
+			}
+		}()
+	}
+	for i := range ciphertexts {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	return plaintexts, errs
+}
+
+// decryptWithNegatedA0 is Decrypt, given -A0 precomputed.
+func decryptWithNegatedA0(key *PrivateKey, negA0 *bn256.G1, ciphertext *Ciphertext) *bn256.GT {
+	var plaintext *bn256.GT
+	if ciphertext.CHat != nil {
+		plaintext = pair(key.A1Hat, ciphertext.CHat)
+	} else {
+		plaintext = pair(ciphertext.C, key.A1)
+	}
+	plaintext.Add(plaintext, pair(negA0, ciphertext.B))
+	return plaintext.Add(ciphertext.A, plaintext)
+}
+
+// lockedReader serializes reads from a random source shared by workers, since
+// an arbitrary io.Reader need not be safe for concurrent use.
+type lockedReader struct {
+	mu sync.Mutex
+	r  io.Reader
+}
+
+func (reader *lockedReader) Read(p []byte) (int, error) {
+	reader.mu.Lock()
+	defer reader.mu.Unlock()
+	return reader.r.Read(p)
+}
+
+// KeyGenBatch generates keys for many identities with the master key, spread
+// over the given number of workers (GOMAXPROCS if workers <= 0). Keys and
+// errors are returned in the same order as ids; an identity that fails only
+// affects its own entry. The parameters are precached (and, if Precompute was
+// called, the tables are shared by all workers).
+func KeyGenBatch(random io.Reader, params *Params, master MasterKey, ids [][]*big.Int, workers int) ([]*PrivateKey, []error) {
+	return KeyGenBatchContext(context.Background(), random, params, master, ids, workers)
+}
+
+// KeyGenBatchContext is like KeyGenBatch, but once ctx is done, the identities
+// that have not been started yet get ctx.Err() as their error.
+func KeyGenBatchContext(ctx context.Context, random io.Reader, params *Params, master MasterKey, ids [][]*big.Int, workers int) ([]*PrivateKey, []error) {
+	keys := make([]*PrivateKey, len(ids))
+	errs := make([]error, len(ids))
+	params.Precache()
+	random = &lockedReader{r: randomSource(random)}
+
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(ids) {
+		workers = len(ids)
+	}
+
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w != workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				if errs[i] = ctx.Err(); errs[i] != nil {
+					continue
+				}
+				if len(ids[i]) > params.MaximumDepth() {
+					errs[i] = ErrDepthExceeded
+					continue
+				}
+				keys[i], errs[i] = KeyGenFromMaster(random, params, master, ids[i])
+			}
+		}()
+	}
+	for i := range ids {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	return keys, errs
+}
+
+// encryptAllChunk is the number of consecutive items an EncryptAll worker
+// takes at once, so that millions of small messages do not each cost a
+// handoff between goroutines.
+const encryptAllChunk = 256
+
+// EncryptItem is a message for EncryptAll and the identity to encrypt it for.
+type EncryptItem struct {
+	ID      []*big.Int
+	Message []byte
+}
+
+// EncryptAll encrypts many messages with EncryptBytes, spread over the given
+// number of workers (GOMAXPROCS if workers <= 0), for instance the telemetry
+// records of a fleet of devices. Ciphertexts and errors are returned in the
+// same order as items; an item that fails only affects its own entry. The
+// parameters are precached and shared by all workers, and a worker reuses the
+// Encryptor of an identity across consecutive items for it, so grouping the
+// items by identity makes the batch faster. opts apply to every item.
+func EncryptAll(random io.Reader, params *Params, items []EncryptItem, workers int, opts ...EncryptOption) ([][]byte, []error) {
+	ciphertexts := make([][]byte, len(items))
+	errs := make([]error, len(items))
+	params.Precache()
+	random = &lockedReader{r: randomSource(random)}
+
+	chunks := (len(items) + encryptAllChunk - 1) / encryptAllChunk
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > chunks {
+		workers = chunks
+	}
+
+	starts := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w != workers; w++ {
+		go func() {
+			defer wg.Done()
+			var encryptor *Encryptor
+			var id []*big.Int
+			for start := range starts {
+				end := start + encryptAllChunk
+				if end > len(items) {
+					end = len(items)
+				}
+				for i := start; i != end; i++ {
+					if encryptor == nil || !sameID(id, items[i].ID) {
+						if encryptor, errs[i] = EncryptorFor(params, items[i].ID); errs[i] != nil {
+							continue
+						}
+						id = encryptor.ID()
+					}
+					itemOpts := append(opts[:len(opts):len(opts)], WithPrecomputed(encryptor))
+					ciphertexts[i], errs[i] = EncryptBytes(random, params, items[i].ID, items[i].Message, itemOpts...)
+				}
+			}
+		}()
+	}
+	for start := 0; start < len(items); start += encryptAllChunk {
+		starts <- start
+	}
+	close(starts)
+	wg.Wait()
+
+	return ciphertexts, errs
+}
+
+// sameID reports whether a and b are the same identity. Missing components of
+// b make it differ from anything.
+func sameID(a []*big.Int, b []*big.Int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if b[i] == nil || a[i].Cmp(b[i]) != 0 {
+			return false
+		}
+	}
+	return true
+}
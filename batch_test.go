@@ -0,0 +1,225 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"golang.org/x/crypto/bn256"
+	"math/big"
+	"testing"
+)
+
+// newBatch encrypts count random messages for LINEAR_HIERARCHY and returns
+// the key for it along with the messages and ciphertexts.
+func newBatch(tb testing.TB, count int) (*PrivateKey, []*bn256.GT, []*Ciphertext) {
+	params, master, err := Setup(rand.Reader, 10)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	key, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	messages := make([]*bn256.GT, count)
+	ciphertexts := make([]*Ciphertext, count)
+	for i := range ciphertexts {
+		if messages[i], err = NewRandomMessage(rand.Reader); err != nil {
+			tb.Fatal(err)
+		}
+		if ciphertexts[i], err = Encrypt(rand.Reader, params, LINEAR_HIERARCHY, messages[i]); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return key, messages, ciphertexts
+}
+
+func TestDecryptBatch(t *testing.T) {
+	key, messages, ciphertexts := newBatch(t, 17)
+
+	decrypted, errs := DecryptBatch(key, ciphertexts)
+	for i := range messages {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if !bytes.Equal(messages[i].Marshal(), decrypted[i].Marshal()) {
+			t.Fatal("Original and decrypted messages differ")
+		}
+	}
+
+	if decrypted, _ = DecryptBatch(key, nil); len(decrypted) != 0 {
+		t.Fatal("Decrypted messages out of nothing")
+	}
+}
+
+func TestDecryptBatchChecks(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tagged, err := Encrypt(rand.Reader, params, LINEAR_HIERARCHY[:2], NewMessage(), WithIntegrityTag())
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupted, err := Encrypt(rand.Reader, params, LINEAR_HIERARCHY[:2], NewMessage(), WithIntegrityTag())
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupted.A.Add(corrupted.A, gtBase)
+	untagged, err := Encrypt(rand.Reader, params, LINEAR_HIERARCHY[:2], NewMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreign, err := Encrypt(rand.Reader, other, LINEAR_HIERARCHY[:2], NewMessage(), WithIntegrityTag())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, errs := DecryptBatch(key, []*Ciphertext{tagged, corrupted, untagged, foreign}, WithTagRequired())
+	for i, want := range []error{nil, ErrDecryptFailed, ErrMalformedCiphertext, ErrCurveMismatch} {
+		if want == nil && errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if want != nil && !errors.Is(errs[i], want) {
+			t.Fatalf("Ciphertext %d: got %v instead of %v", i, errs[i], want)
+		}
+	}
+}
+
+func BenchmarkDecryptSequential(b *testing.B) {
+	b.StopTimer()
+	key, _, ciphertexts := newBatch(b, 32)
+	b.StartTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, ciphertext := range ciphertexts {
+			Decrypt(key, ciphertext)
+		}
+	}
+}
+
+func BenchmarkDecryptBatch(b *testing.B) {
+	b.StopTimer()
+	key, _, ciphertexts := newBatch(b, 32)
+	b.StartTimer()
+
+	for i := 0; i < b.N; i++ {
+		DecryptBatch(key, ciphertexts)
+	}
+}
+
+func TestKeyGenBatch(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([][]*big.Int, 20)
+	for i := range ids {
+		ids[i] = []*big.Int{big.NewInt(int64(i + 1)), big.NewInt(7)}
+	}
+	ids[5] = append(LINEAR_HIERARCHY[:3:3], big.NewInt(4))
+
+	keys, errs := KeyGenBatch(rand.Reader, params, master, ids, 4)
+	message := NewMessage()
+	for i, id := range ids {
+		if i == 5 {
+			if errs[i] == nil {
+				t.Fatal("Generated key deeper than the hierarchy")
+			}
+			continue
+		}
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		ciphertext, err := Encrypt(rand.Reader, params, id, message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(message.Marshal(), mustDecrypt(t, keys[i], ciphertext).Marshal()) {
+			t.Fatal("Batch key is not for the identity at its position")
+		}
+	}
+}
+
+func TestKeyGenBatchNilRandom(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := [][]*big.Int{LINEAR_HIERARCHY[:1], LINEAR_HIERARCHY[:2]}
+	keys, errs := KeyGenBatch(nil, params, master, ids, 2)
+	for i := range ids {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if keys[i] == nil {
+			t.Fatal("No key generated with the default source of randomness")
+		}
+	}
+}
+
+func TestEncryptAll(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	devices := make([][]*big.Int, 3)
+	keys := make([]*PrivateKey, len(devices))
+	for d := range devices {
+		devices[d] = []*big.Int{big.NewInt(9), big.NewInt(int64(d + 1))}
+		if keys[d], err = KeyGenFromMaster(rand.Reader, params, master, devices[d]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	items := make([]EncryptItem, 2*encryptAllChunk+10)
+	for i := range items {
+		items[i] = EncryptItem{ID: devices[i/100%3], Message: []byte{byte(i), byte(i >> 8)}}
+	}
+	items[7].ID = []*big.Int{big.NewInt(9), nil}
+
+	ciphertexts, errs := EncryptAll(rand.Reader, params, items, 3, WithAAD([]byte("telemetry")))
+	for i, item := range items {
+		if i == 7 {
+			if errs[i] == nil {
+				t.Fatal("Encrypted for an identity with a missing component")
+			}
+			continue
+		}
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		plaintext, err := DecryptBytes(keys[i/100%3], ciphertexts[i], WithDecryptAAD([]byte("telemetry")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(plaintext, item.Message) {
+			t.Fatal("Ciphertext is not for the message at its position")
+		}
+	}
+}
+
+func BenchmarkKeyGenBatch(b *testing.B) {
+	params, master, err := Setup(rand.Reader, 10)
+	if err != nil {
+		b.Fatal(err)
+	}
+	params.Precompute()
+	ids := make([][]*big.Int, 64)
+	for i := range ids {
+		ids[i] = append(LINEAR_HIERARCHY[:2:2], big.NewInt(int64(i)))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, errs := KeyGenBatch(rand.Reader, params, master, ids, 0); errs[0] != nil {
+			b.Fatal(errs[0])
+		}
+	}
+}
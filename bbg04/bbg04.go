@@ -0,0 +1,119 @@
+// Package bbg04 registers the HIBE of Boneh, Boyen and Goh (2005) on bn256,
+// as implemented by package hibe_sm9, with the scheme registry. Identity
+// components are mapped onto Zp with hibe_sm9.HashID.
+package bbg04
+
+import (
+	"errors"
+	"golang.org/x/crypto/bn256"
+	"hibe_sm9"
+	"hibe_sm9/scheme"
+	"io"
+	"math/big"
+)
+
+// Name is the name under which the scheme is registered.
+const Name = "bbg04-bn256"
+
+var errMalformed = errors.New("bbg04: malformed encoding")
+
+func init() {
+	scheme.Register(bbg04{})
+}
+
+type bbg04 struct{}
+
+func (bbg04) Name() string {
+	return Name
+}
+
+// mapID maps the components of id onto Zp.
+func mapID(id scheme.ID) []*big.Int {
+	return hibe_sm9.HashID(id)
+}
+
+func parseParams(encoded []byte) (*hibe_sm9.Params, error) {
+	params, ok := new(hibe_sm9.Params).Unmarshal(encoded)
+	if !ok {
+		return nil, errMalformed
+	}
+	return params, nil
+}
+
+func parseKey(encoded []byte) (*hibe_sm9.PrivateKey, error) {
+	key, ok := new(hibe_sm9.PrivateKey).Unmarshal(encoded)
+	if !ok {
+		return nil, errMalformed
+	}
+	return key, nil
+}
+
+func (bbg04) Setup(random io.Reader, depth int) ([]byte, []byte, error) {
+	params, master, err := hibe_sm9.Setup(random, depth)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer hibe_sm9.ZeroizeMasterKey(master)
+	return params.Marshal(), (*bn256.G1)(master).Marshal(), nil
+}
+
+func (bbg04) KeyGen(random io.Reader, encodedParams []byte, encodedMaster []byte, id scheme.ID) ([]byte, error) {
+	params, err := parseParams(encodedParams)
+	if err != nil {
+		return nil, err
+	}
+	master, ok := new(bn256.G1).Unmarshal(encodedMaster)
+	if !ok {
+		return nil, errMalformed
+	}
+	defer hibe_sm9.ZeroizeMasterKey(master)
+	key, err := hibe_sm9.KeyGenFromMaster(random, params, master, mapID(id))
+	if err != nil {
+		return nil, err
+	}
+	defer key.Zeroize()
+	return key.Marshal(), nil
+}
+
+func (bbg04) Delegate(random io.Reader, encodedParams []byte, encodedParent []byte, id scheme.ID) ([]byte, error) {
+	params, err := parseParams(encodedParams)
+	if err != nil {
+		return nil, err
+	}
+	parent, err := parseKey(encodedParent)
+	if err != nil {
+		return nil, err
+	}
+	defer parent.Zeroize()
+	key, err := hibe_sm9.KeyGenFromParent(random, params, parent, mapID(id))
+	if err != nil {
+		return nil, err
+	}
+	defer key.Zeroize()
+	return key.Marshal(), nil
+}
+
+func (bbg04) Encapsulate(random io.Reader, encodedParams []byte, id scheme.ID) ([]byte, []byte, error) {
+	params, err := parseParams(encodedParams)
+	if err != nil {
+		return nil, nil, err
+	}
+	secret, encapsulation, err := hibe_sm9.Encapsulate(random, params, mapID(id))
+	if err != nil {
+		return nil, nil, err
+	}
+	return secret, encapsulation.Marshal(), nil
+}
+
+func (bbg04) Decapsulate(encodedKey []byte, encodedEncapsulation []byte) ([]byte, error) {
+	key, err := parseKey(encodedKey)
+	if err != nil {
+		return nil, err
+	}
+	defer key.Zeroize()
+	encapsulation, ok := new(hibe_sm9.Ciphertext).Unmarshal(encodedEncapsulation)
+	if !ok {
+		return nil, errMalformed
+	}
+	return hibe_sm9.Decapsulate(key, encapsulation)
+}
@@ -0,0 +1,53 @@
+package bbg04
+
+import (
+	"bytes"
+	"crypto/rand"
+	"hibe_sm9/scheme"
+	"testing"
+)
+
+func TestRegistered(t *testing.T) {
+	s, err := scheme.Lookup(Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params, master, err := s.Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parentKey, err := s.KeyGen(rand.Reader, params, master, scheme.ParseID("org/dept"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := s.Delegate(rand.Reader, params, parentKey, scheme.ParseID("org/dept/alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret, encapsulation, err := s.Encapsulate(rand.Reader, params, scheme.ParseID("org/dept/alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decapsulated, err := s.Decapsulate(key, encapsulation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(secret, decapsulated) {
+		t.Fatal("Decapsulated secret does not match")
+	}
+
+	_, encapsulation, err = s.Encapsulate(rand.Reader, params, scheme.ParseID("org/dept/bob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decapsulated, err = s.Decapsulate(key, encapsulation)
+	if err == nil && bytes.Equal(secret, decapsulated) {
+		t.Fatal("Decapsulated a secret for another identity")
+	}
+
+	if _, err = s.Decapsulate([]byte{1, 2, 3}, encapsulation); err == nil {
+		t.Fatal("Accepted a malformed key")
+	}
+}
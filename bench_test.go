@@ -0,0 +1,168 @@
+package hibe_sm9
+
+import (
+	"crypto/rand"
+	"fmt"
+	"golang.org/x/crypto/bn256"
+	"math/big"
+	"sync/atomic"
+	"testing"
+)
+
+// benchmarkDepths are the hierarchy depths the operations are measured at.
+var benchmarkDepths = []int{1, 5, 10, 20}
+
+// benchmarkOperation runs op b.N times with the timer running only around op,
+// and reports allocations and the number of pairings computed per operation
+// alongside the time. Run with
+//
+//	go test -run - -bench Depth -count 10 > new.txt
+//
+// and compare against a previous run with benchstat to catch regressions.
+func benchmarkOperation(b *testing.B, op func() error) {
+	b.ReportAllocs()
+	start := atomic.LoadUint64(&pairings)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := op(); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	count := atomic.LoadUint64(&pairings) - start
+	b.ReportMetric(float64(count)/float64(b.N), "pairings/op")
+}
+
+// benchmarkID returns a fixed identity with the given number of components.
+func benchmarkID(depth int) []*big.Int {
+	id := make([]*big.Int, depth)
+	for i := range id {
+		id[i] = HashToZp([]byte(fmt.Sprintf("level %d", i)))
+	}
+	return id
+}
+
+// benchmarkHierarchy sets up a hierarchy of the given depth and returns the
+// key for an identity at its last level, along with its parent's key.
+func benchmarkHierarchy(b *testing.B, depth int) (*Params, MasterKey, []*big.Int, *PrivateKey, *PrivateKey) {
+	params, master, err := Setup(rand.Reader, depth)
+	if err != nil {
+		b.Fatal(err)
+	}
+	params.Precache()
+	id := benchmarkID(depth)
+	key, err := KeyGenFromMaster(rand.Reader, params, master, id)
+	if err != nil {
+		b.Fatal(err)
+	}
+	var parent *PrivateKey
+	if depth > 1 {
+		if parent, err = KeyGenFromMaster(rand.Reader, params, master, id[:depth-1]); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return params, master, id, key, parent
+}
+
+func BenchmarkSetupDepth(b *testing.B) {
+	for _, depth := range benchmarkDepths {
+		b.Run(fmt.Sprintf("depth=%d", depth), func(b *testing.B) {
+			benchmarkOperation(b, func() error {
+				_, _, err := Setup(rand.Reader, depth)
+				return err
+			})
+		})
+	}
+}
+
+func BenchmarkKeyGenFromMasterDepth(b *testing.B) {
+	for _, depth := range benchmarkDepths {
+		b.Run(fmt.Sprintf("depth=%d", depth), func(b *testing.B) {
+			params, master, id, _, _ := benchmarkHierarchy(b, depth)
+			benchmarkOperation(b, func() error {
+				_, err := KeyGenFromMaster(rand.Reader, params, master, id)
+				return err
+			})
+		})
+	}
+}
+
+func BenchmarkKeyGenFromParentDepth(b *testing.B) {
+	for _, depth := range benchmarkDepths {
+		if depth == 1 {
+			// Keys at the first level have no parent
+			continue
+		}
+		b.Run(fmt.Sprintf("depth=%d", depth), func(b *testing.B) {
+			params, _, id, _, parent := benchmarkHierarchy(b, depth)
+			benchmarkOperation(b, func() error {
+				_, err := KeyGenFromParent(rand.Reader, params, parent, id)
+				return err
+			})
+		})
+	}
+}
+
+func BenchmarkDelegatorDepth(b *testing.B) {
+	for _, depth := range benchmarkDepths {
+		if depth == 1 {
+			continue
+		}
+		b.Run(fmt.Sprintf("depth=%d", depth), func(b *testing.B) {
+			// A Delegator issuing many keys is worth precomputing for
+			params, _, id, _, parent := benchmarkHierarchy(b, depth)
+			params.Precompute()
+			delegator, err := NewDelegator(params, parent, id[:depth-1])
+			if err != nil {
+				b.Fatal(err)
+			}
+			benchmarkOperation(b, func() error {
+				_, err := delegator.Delegate(rand.Reader, id[depth-1])
+				return err
+			})
+		})
+	}
+}
+
+func BenchmarkEncryptDepth(b *testing.B) {
+	for _, depth := range benchmarkDepths {
+		b.Run(fmt.Sprintf("depth=%d", depth), func(b *testing.B) {
+			params, _, id, _, _ := benchmarkHierarchy(b, depth)
+			message := NewMessage()
+			benchmarkOperation(b, func() error {
+				_, err := Encrypt(rand.Reader, params, id, message)
+				return err
+			})
+		})
+	}
+}
+
+func BenchmarkDecryptDepth(b *testing.B) {
+	for _, depth := range benchmarkDepths {
+		b.Run(fmt.Sprintf("depth=%d", depth), func(b *testing.B) {
+			params, _, id, key, _ := benchmarkHierarchy(b, depth)
+			ciphertext, err := Encrypt(rand.Reader, params, id, NewMessage())
+			if err != nil {
+				b.Fatal(err)
+			}
+			benchmarkOperation(b, func() error {
+				Decrypt(key, ciphertext)
+				return nil
+			})
+		})
+	}
+}
+
+// BenchmarkPair measures a single pairing, which bounds the throughput of
+// decryption, as a baseline for any faster pairing implementation.
+func BenchmarkPair(b *testing.B) {
+	g1 := HashToG1([]byte("benchmark g1"))
+	_, g2, err := bn256.RandomG2(rand.Reader)
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmarkOperation(b, func() error {
+		pair(g1, g2)
+		return nil
+	})
+}
@@ -0,0 +1,189 @@
+package hibe_sm9
+
+import (
+	"crypto/rand"
+	"errors"
+	"golang.org/x/crypto/bn256"
+	"io"
+	"math/big"
+)
+
+var (
+	errBlindAnonymous = errors.New("hibe: anonymous hierarchies do not support blinded extraction")
+	errBlindResponse  = errors.New("hibe: blinded key response is invalid")
+)
+
+// BlindKeyRequest is sent by a user to the PKG to obtain a key without
+// revealing their identity. Q = (g3 * h1^id1 * ... * hk^idk)^beta for a
+// secret, uniformly random beta, so Q is a uniformly random element of G1
+// whatever the identity. Only the depth k is revealed.
+type BlindKeyRequest struct {
+	Depth int
+	Q     *bn256.G1
+}
+
+// BlindKeyResponse is the PKG's answer to a BlindKeyRequest:
+// A0 = g2^alpha * Q^r, A1 = g^r and B[j] = h(k+1+j)^r.
+type BlindKeyResponse struct {
+	A0 *bn256.G1
+	A1 *bn256.G2
+	B  []*bn256.G1
+}
+
+// BlindingFactor is the secret a user keeps between making a BlindKeyRequest
+// and unblinding the response.
+type BlindingFactor struct {
+	id   []*big.Int
+	beta *big.Int
+}
+
+// BlindKeyRequestFor creates a blinded request for the key of id.
+//
+// Blinded extraction (after Green and Hohenberger, "Blind Identity-Based
+// Encryption and Simulatable Oblivious Transfer") hides from the PKG which
+// identity a key was issued for, and, since the user re-randomizes the key
+// while unblinding, the PKG never sees the key it issued. It does not remove
+// key escrow altogether: a PKG that knows the master key can still generate
+// the key for any identity itself. It also means the PKG cannot check that the
+// requester is entitled to the identity, so it is only appropriate where any
+// requester may obtain a key for any identity at the requested depth, or where
+// entitlement is established by other means.
+func BlindKeyRequestFor(random io.Reader, params *Params, id []*big.Int) (*BlindKeyRequest, *BlindingFactor, error) {
+	random = randomSource(random)
+	if params.Anonymous() {
+		return nil, nil, errBlindAnonymous
+	}
+	if err := checkID(params, id); err != nil {
+		return nil, nil, err
+	}
+
+	// Randomly choose beta in Zp*
+	beta, err := rand.Int(random, new(big.Int).Sub(bn256.Order, big.NewInt(1)))
+	if err != nil {
+		return nil, nil, err
+	}
+	beta.Add(beta, big.NewInt(1))
+
+	q, err := secretMultG1(idProduct(params, id), beta)
+	if err != nil {
+		return nil, nil, err
+	}
+	factor := &BlindingFactor{id: append([]*big.Int{}, id...), beta: beta}
+	return &BlindKeyRequest{Depth: len(id), Q: q}, factor, nil
+}
+
+// BlindKeyGen answers a blinded key request with the master key.
+func BlindKeyGen(random io.Reader, params *Params, master MasterKey, request *BlindKeyRequest) (*BlindKeyResponse, error) {
+	random = randomSource(random)
+	if params.Anonymous() {
+		return nil, errBlindAnonymous
+	}
+	k := request.Depth
+	l := len(params.H)
+	if k < 1 || k > l {
+		return nil, ErrDepthExceeded
+	}
+	if err := checkG1(request.Q); err != nil {
+		return nil, err
+	}
+
+	// Randomly choose r in Zp.
+	r, err := rand.Int(random, bn256.Order)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroizeScalar(r)
+
+	product, err := secretMultG1(request.Q, r)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroizeG1(product)
+
+	response := &BlindKeyResponse{}
+	response.A0 = new(bn256.G1).Add(master, product)
+	if response.A1, err = powerG(params, r); err != nil {
+		return nil, err
+	}
+	response.B = make([]*bn256.G1, l-k)
+	for j := range response.B {
+		if response.B[j], err = powerH(params, k+j, r); err != nil {
+			return nil, err
+		}
+	}
+	return response, nil
+}
+
+// UnblindKey turns the PKG's response into the private key for the identity
+// of the request. The response is verified with pairings, so a PKG that
+// answers with anything but a valid key is detected. The key is then
+// re-randomized, so the PKG does not know it. The blinding factor is destroyed.
+func UnblindKey(random io.Reader, params *Params, factor *BlindingFactor, response *BlindKeyResponse) (*PrivateKey, error) {
+	random = randomSource(random)
+	defer zeroizeScalar(factor.beta)
+	id := factor.id
+	k := len(id)
+	if len(response.B) != len(params.H)-k {
+		return nil, errBlindResponse
+	}
+	if checkG1(response.A0) != nil || checkG2(response.A1) != nil {
+		return nil, errBlindResponse
+	}
+	for _, bj := range response.B {
+		if checkG1(bj) != nil {
+			return nil, errBlindResponse
+		}
+	}
+
+	// A0 = g2^alpha * P^(r*beta) is already right for randomness r*beta; the
+	// other components are raised to beta to match
+	key := &PrivateKey{A0: deepClone(response.A0), B: make([]*bn256.G1, len(response.B))}
+	var err error
+	if key.A1, err = secretMultG2(response.A1, factor.beta); err != nil {
+		return nil, err
+	}
+	for j, bj := range response.B {
+		if key.B[j], err = secretMultG1(bj, factor.beta); err != nil {
+			return nil, err
+		}
+	}
+
+	// Check e(A0, g) = e(g2, g1) * e(P, A1) and e(Bj, g) = e(h(k+1+j), A1)
+	params.Precache()
+	product := idProduct(params, id)
+	rhs := pair(product, key.A1)
+	rhs.Add(rhs, params.Pairing)
+	if string(pair(key.A0, params.G).Marshal()) != string(rhs.Marshal()) {
+		return nil, errBlindResponse
+	}
+	for j, bj := range key.B {
+		if string(pair(bj, params.G).Marshal()) != string(pair(params.H[k+j], key.A1).Marshal()) {
+			return nil, errBlindResponse
+		}
+	}
+
+	// Re-randomize with t, so the PKG does not know the final key
+	t, err := rand.Int(random, bn256.Order)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroizeScalar(t)
+	power, err := secretMultG1(product, t)
+	if err != nil {
+		return nil, err
+	}
+	key.A0.Add(key.A0, power)
+	a1, err := powerG(params, t)
+	if err != nil {
+		return nil, err
+	}
+	key.A1.Add(key.A1, a1)
+	for j, bj := range key.B {
+		ht, err := powerH(params, k+j, t)
+		if err != nil {
+			return nil, err
+		}
+		bj.Add(bj, ht)
+	}
+	return key, nil
+}
@@ -0,0 +1,71 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"golang.org/x/crypto/bn256"
+	"testing"
+)
+
+func TestBlindExtraction(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := LINEAR_HIERARCHY[:2]
+
+	request, factor, err := BlindKeyRequestFor(rand.Reader, params, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(request.Q.Marshal(), idProduct(params, id).Marshal()) {
+		t.Fatal("Request reveals the identity")
+	}
+	response, err := BlindKeyGen(rand.Reader, params, master, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := UnblindKey(rand.Reader, params, factor, response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(key.A0.Marshal(), response.A0.Marshal()) {
+		t.Fatal("Unblinded key was not re-randomized")
+	}
+
+	message := NewMessage()
+	ciphertext, err := Encrypt(rand.Reader, params, id, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(message.Marshal(), mustDecrypt(t, key, ciphertext).Marshal()) {
+		t.Fatal("Unblinded key does not decrypt")
+	}
+
+	// The unblinded key delegates like any other
+	child, err := KeyGenFromParent(rand.Reader, params, key, LINEAR_HIERARCHY[:3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err = Encrypt(rand.Reader, params, LINEAR_HIERARCHY[:3], message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(message.Marshal(), mustDecrypt(t, child, ciphertext).Marshal()) {
+		t.Fatal("Key delegated from an unblinded key does not decrypt")
+	}
+
+	// A PKG that answers with a corrupted key is detected
+	request, factor, err = BlindKeyRequestFor(rand.Reader, params, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err = BlindKeyGen(rand.Reader, params, master, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.A0.Add(response.A0, new(bn256.G1).ScalarBaseMult(LINEAR_HIERARCHY[0]))
+	if _, err = UnblindKey(rand.Reader, params, factor, response); err != errBlindResponse {
+		t.Fatal("Accepted an invalid blinded response")
+	}
+}
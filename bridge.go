@@ -0,0 +1,188 @@
+package hibe_sm9
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// bridgeDomain separates the data signed by cross-certificates from other
+// uses of Sign.
+var bridgeDomain = []byte("HIBE-CROSS-CERTIFICATE")
+
+var (
+	errBridgeMalformed = errors.New("hibe: malformed cross-certificate")
+	errBridgeSignature = wrapError(ErrCurveMismatch, "hibe: cross-certificate is not signed by the local administrator")
+	errBridgeExpired   = wrapError(ErrCurveMismatch, "hibe: cross-certificate has expired")
+)
+
+// CrossCertificate is a record by which the administrator of one hierarchy,
+// the issuer, certifies the parameters of another, the subject, for the
+// identities under Prefix. Organizations that federate can then encrypt to
+// each other's users through a Bridge: an identity (P1 ... Pj, I1 ... Ik)
+// with Prefix (P1 ... Pj) is the identity (I1 ... Ik) in the subject
+// hierarchy.
+//
+// The certificate is signed with Sign by the administrator's identity in the
+// issuing hierarchy, so anyone who trusts the issuer's parameters can check
+// it without a separate signing key.
+type CrossCertificate struct {
+	Issuer    []byte
+	Prefix    []*big.Int
+	Subject   *Params
+	Expires   time.Time
+	Signature *Signature
+}
+
+// CertifyParams issues a cross-certificate for subject under prefix, valid
+// until expires, signed with adminKey, the key for admin in the hierarchy
+// with parameters issuer. As with Sign, adminKey must be able to delegate
+// one more level.
+func CertifyParams(random io.Reader, issuer *Params, adminKey *PrivateKey, admin []*big.Int, subject *Params, prefix []*big.Int, expires time.Time) (*CrossCertificate, error) {
+	if len(prefix) == 0 {
+		return nil, errEmptyID
+	}
+	if err := subject.Validate(); err != nil {
+		return nil, err
+	}
+	certificate := &CrossCertificate{
+		Issuer:  issuer.Fingerprint(),
+		Prefix:  append([]*big.Int{}, prefix...),
+		Subject: subject,
+		Expires: expires,
+	}
+	var err error
+	if certificate.Signature, err = Sign(randomSource(random), issuer, adminKey, admin, certificate.signed()); err != nil {
+		return nil, err
+	}
+	return certificate, nil
+}
+
+// signed returns the data that the administrator signs: the issuer's
+// fingerprint, the prefix as by encodeID, the expiry time in nanoseconds
+// since the Unix epoch (8 bytes, big endian), and the subject's parameters
+// with their length (4 bytes, big endian). It is prefixed with bridgeDomain,
+// which is not part of the encoding.
+func (certificate *CrossCertificate) signed() []byte {
+	signed := append([]byte{}, bridgeDomain...)
+	signed = append(signed, certificate.Issuer...)
+	signed = append(signed, encodeID(certificate.Prefix)...)
+	signed = binary.BigEndian.AppendUint64(signed, uint64(certificate.Expires.UnixNano()))
+	subject := certificate.Subject.Marshal()
+	signed = binary.BigEndian.AppendUint32(signed, uint32(len(subject)))
+	return append(signed, subject...)
+}
+
+// Verify checks that the certificate was issued by admin in the hierarchy
+// with parameters issuer, and is valid at now.
+func (certificate *CrossCertificate) Verify(issuer *Params, admin []*big.Int, now time.Time) error {
+	if string(certificate.Issuer) != string(issuer.Fingerprint()) || certificate.Signature == nil ||
+		!Verify(issuer, admin, certificate.signed(), certificate.Signature) {
+		return errBridgeSignature
+	}
+	if !now.Before(certificate.Expires) {
+		return errBridgeExpired
+	}
+	return nil
+}
+
+// Marshal encodes the certificate as the data it signs, without the domain
+// prefix, followed by the signature.
+func (certificate *CrossCertificate) Marshal() []byte {
+	encoded := certificate.signed()[len(bridgeDomain):]
+	return append(encoded, certificate.Signature.Marshal()...)
+}
+
+// Unmarshal recovers the certificate from its encoding. The subject's
+// parameters are validated, but the signature is only checked by Verify.
+func (certificate *CrossCertificate) Unmarshal(encoded []byte) (*CrossCertificate, bool) {
+	if len(encoded) < FingerprintSize {
+		return nil, false
+	}
+	issuer := append([]byte{}, encoded[:FingerprintSize]...)
+	prefix, encoded, ok := decodeID(encoded[FingerprintSize:])
+	if !ok || len(prefix) == 0 || len(encoded) < 12 {
+		return nil, false
+	}
+	expires := time.Unix(0, int64(binary.BigEndian.Uint64(encoded)))
+	size := binary.BigEndian.Uint32(encoded[8:])
+	encoded = encoded[12:]
+	if uint64(len(encoded)) < uint64(size) {
+		return nil, false
+	}
+	subject, ok := new(Params).Unmarshal(encoded[:size])
+	if !ok {
+		return nil, false
+	}
+	signature, ok := new(Signature).Unmarshal(encoded[size:])
+	if !ok {
+		return nil, false
+	}
+	*certificate = CrossCertificate{issuer, prefix, subject, expires, signature}
+	return certificate, true
+}
+
+// Bridge picks the parameters to encrypt to by identity prefix: identities
+// under the prefix of a cross-certificate are encrypted in the certified
+// hierarchy, and all others in the local one. A Bridge is safe for
+// concurrent use.
+type Bridge struct {
+	local *Params
+	admin []*big.Int
+
+	lock         sync.RWMutex
+	certificates []*CrossCertificate
+}
+
+// NewBridge returns a Bridge for the local hierarchy, which accepts
+// cross-certificates issued by admin in it.
+func NewBridge(local *Params, admin []*big.Int) *Bridge {
+	return &Bridge{local: local, admin: append([]*big.Int{}, admin...)}
+}
+
+// Add verifies the certificate at now and adds it to the bridge, replacing
+// any certificate with the same prefix.
+func (bridge *Bridge) Add(certificate *CrossCertificate, now time.Time) error {
+	if err := certificate.Verify(bridge.local, bridge.admin, now); err != nil {
+		return err
+	}
+	bridge.lock.Lock()
+	defer bridge.lock.Unlock()
+	for i, existing := range bridge.certificates {
+		if len(existing.Prefix) == len(certificate.Prefix) && idsAgree(existing.Prefix, certificate.Prefix) {
+			bridge.certificates[i] = certificate
+			return nil
+		}
+	}
+	bridge.certificates = append(bridge.certificates, certificate)
+	return nil
+}
+
+// Resolve returns the parameters for id and the identity in them: those of
+// the unexpired certificate with the longest prefix of id, with the prefix
+// removed, or the local parameters and id itself.
+func (bridge *Bridge) Resolve(id []*big.Int, now time.Time) (*Params, []*big.Int) {
+	bridge.lock.RLock()
+	defer bridge.lock.RUnlock()
+	var best *CrossCertificate
+	for _, certificate := range bridge.certificates {
+		if len(certificate.Prefix) < len(id) && idsAgree(certificate.Prefix, id) && now.Before(certificate.Expires) &&
+			(best == nil || len(certificate.Prefix) > len(best.Prefix)) {
+			best = certificate
+		}
+	}
+	if best == nil {
+		return bridge.local, id
+	}
+	return best.Subject, id[len(best.Prefix):]
+}
+
+// EncryptBytes is EncryptBytes with the parameters and identity that
+// Resolve picks for id.
+func (bridge *Bridge) EncryptBytes(random io.Reader, id []*big.Int, plaintext []byte, now time.Time, opts ...EncryptOption) ([]byte, error) {
+	params, resolved := bridge.Resolve(id, now)
+	return EncryptBytes(random, params, resolved, plaintext, opts...)
+}
@@ -0,0 +1,102 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestBridge(t *testing.T) {
+	local, localMaster, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote, remoteMaster, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin := []*big.Int{big.NewInt(1)}
+	adminKey, err := KeyGenFromMaster(rand.Reader, local, localMaster, admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	prefix := []*big.Int{big.NewInt(42)}
+	certificate, err := CertifyParams(rand.Reader, local, adminKey, admin, remote, prefix, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, ok := new(CrossCertificate).Unmarshal(certificate.Marshal())
+	if !ok {
+		t.Fatal("Failed to decode cross-certificate")
+	}
+	bridge := NewBridge(local, admin)
+	if err = bridge.Add(decoded, now); err != nil {
+		t.Fatal(err)
+	}
+
+	// Identities under the prefix are encrypted in the remote hierarchy
+	remoteID := []*big.Int{big.NewInt(7), big.NewInt(8)}
+	remoteKey, err := KeyGenFromMaster(rand.Reader, remote, remoteMaster, remoteID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := []byte("across the bridge")
+	ciphertext, err := bridge.EncryptBytes(rand.Reader, append(prefix, remoteID...), message, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := DecryptBytes(remoteKey, ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, message) {
+		t.Fatal("Remote recipient could not decrypt")
+	}
+
+	// Others, and everyone once the certificate expires, in the local one
+	if params, id := bridge.Resolve(LINEAR_HIERARCHY[:2], now); params != local || len(id) != 2 {
+		t.Fatal("Local identity resolved to another hierarchy")
+	}
+	if params, _ := bridge.Resolve(append(prefix, remoteID...), now.Add(2*time.Hour)); params != local {
+		t.Fatal("Expired certificate still used")
+	}
+	if err = decoded.Verify(local, admin, now.Add(2*time.Hour)); !errors.Is(err, ErrCurveMismatch) {
+		t.Fatal("Expired certificate verified")
+	}
+}
+
+func TestBridgeRejectsForeignCertificates(t *testing.T) {
+	local, localMaster, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote, _, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin := []*big.Int{big.NewInt(1)}
+	other := []*big.Int{big.NewInt(2)}
+	otherKey, err := KeyGenFromMaster(rand.Reader, local, localMaster, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	certificate, err := CertifyParams(rand.Reader, local, otherKey, other, remote, []*big.Int{big.NewInt(42)}, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bridge := NewBridge(local, admin)
+	if bridge.Add(certificate, now) == nil {
+		t.Fatal("Accepted a certificate from another identity")
+	}
+
+	certificate.Prefix = []*big.Int{big.NewInt(43)}
+	if NewBridge(local, other).Add(certificate, now) == nil {
+		t.Fatal("Accepted a modified certificate")
+	}
+}
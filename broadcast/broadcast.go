@@ -0,0 +1,329 @@
+// Package broadcast encrypts a message to a set of identities at once, with a
+// ciphertext whose size does not depend on the number of recipients, for
+// group messaging and pub/sub fan-out.
+//
+// It implements the identity-based broadcast encryption scheme of Delerablée
+// (ASIACRYPT 2007) on bn256. The ciphertext is one element each of G1, G2 and
+// GT, plus the list of recipients, which decryption needs; the public
+// parameters grow with the maximum number of recipients per message instead.
+//
+// Identities are the paths of a HIBE hierarchy ([]*big.Int, as in package
+// hibe_sm9), but the scheme has its own parameters and master key, and keys
+// are issued by the holder of the master key rather than delegated. To let a
+// message reach every descendant of the listed identities (see
+// WithDescendants), each private key also covers the subtrees of the
+// identity and of its ancestors.
+package broadcast
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"golang.org/x/crypto/bn256"
+	"hibe_sm9"
+	"io"
+	"math/big"
+)
+
+var (
+	// ErrNoRecipients is returned when encrypting to an empty set.
+	ErrNoRecipients = errors.New("broadcast: no recipients")
+
+	// ErrTooManyRecipients is returned when encrypting to more identities
+	// than the parameters support.
+	ErrTooManyRecipients = errors.New("broadcast: too many recipients for the parameters")
+
+	// ErrNotRecipient is returned when decrypting with the key of an
+	// identity the ciphertext was not encrypted for.
+	ErrNotRecipient = errors.New("broadcast: key is not for a recipient of the ciphertext")
+)
+
+// Params are the public parameters: w = g^gamma, v = e(g, h), and
+// H[i] = h^(gamma^i) for i = 0 ... the maximum number of recipients.
+type Params struct {
+	W *bn256.G1
+	V *bn256.GT
+	H []*bn256.G2
+}
+
+// MaxRecipients returns the maximum number of recipients of a ciphertext.
+// With WithDescendants, each listed identity counts as one recipient however
+// many descendants it has.
+func (params *Params) MaxRecipients() int {
+	return len(params.H) - 1
+}
+
+// MasterKey is the secret (g, gamma) from which private keys are issued.
+type MasterKey struct {
+	G     *bn256.G1
+	Gamma *big.Int
+}
+
+// PrivateKey is the key for an identity. Exact decrypts messages for the
+// identity itself, and Subtrees[i] messages for the descendants of ID[:i+1]
+// (see WithDescendants).
+type PrivateKey struct {
+	ID       []*big.Int
+	Exact    *bn256.G1
+	Subtrees []*bn256.G1
+}
+
+// Ciphertext is a message encrypted to a set of identities.
+type Ciphertext struct {
+	Recipients  [][]*big.Int
+	Descendants bool
+
+	A  *bn256.GT
+	C1 *bn256.G1
+	C2 *bn256.G2
+}
+
+// Setup generates parameters for ciphertexts with up to maxRecipients
+// recipients, and the master key.
+func Setup(random io.Reader, maxRecipients int) (*Params, *MasterKey, error) {
+	if maxRecipients < 1 {
+		return nil, nil, errors.New("broadcast: at least one recipient is needed")
+	}
+	if random == nil {
+		random = rand.Reader
+	}
+	gamma, err := randomScalar(random)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, g, err := bn256.RandomG1(random)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, h, err := bn256.RandomG2(random)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	params := &Params{
+		W: new(bn256.G1).ScalarMult(g, gamma),
+		V: bn256.Pair(g, h),
+		H: make([]*bn256.G2, maxRecipients+1),
+	}
+	power := big.NewInt(1)
+	for i := range params.H {
+		params.H[i] = new(bn256.G2).ScalarMult(h, power)
+		power.Mul(power, gamma)
+		power.Mod(power, bn256.Order)
+	}
+	return params, &MasterKey{G: g, Gamma: gamma}, nil
+}
+
+func randomScalar(random io.Reader) (*big.Int, error) {
+	for {
+		k, err := rand.Int(random, bn256.Order)
+		if err != nil || k.Sign() != 0 {
+			return k, err
+		}
+	}
+}
+
+// Tags that keep the exact identity apart from the subtree below it.
+const (
+	tagExact   = 0
+	tagSubtree = 1
+)
+
+// hashMember maps an identity, or the subtree below it, onto Zp*.
+func hashMember(tag byte, id []*big.Int) *big.Int {
+	encoded := append([]byte("HIBE-BROADCAST-V1"), tag)
+	encoded = binary.BigEndian.AppendUint16(encoded, uint16(len(id)))
+	for _, component := range id {
+		encoded = append(encoded, component.FillBytes(make([]byte, 32))...)
+	}
+	return hibe_sm9.HashToZp(encoded)
+}
+
+// extract computes g^(1/(gamma+x)).
+func extract(master *MasterKey, x *big.Int) (*bn256.G1, error) {
+	exponent := new(big.Int).Add(master.Gamma, x)
+	if exponent.ModInverse(exponent.Mod(exponent, bn256.Order), bn256.Order) == nil {
+		return nil, errors.New("broadcast: identity hashes to -gamma")
+	}
+	return new(bn256.G1).ScalarMult(master.G, exponent), nil
+}
+
+// KeyGen issues the private key for id.
+func KeyGen(master *MasterKey, id []*big.Int) (*PrivateKey, error) {
+	if len(id) == 0 {
+		return nil, hibe_sm9.ErrInvalidID
+	}
+	key := &PrivateKey{
+		ID:       append([]*big.Int{}, id...),
+		Subtrees: make([]*bn256.G1, len(id)),
+	}
+	var err error
+	if key.Exact, err = extract(master, hashMember(tagExact, id)); err != nil {
+		return nil, err
+	}
+	for i := range key.Subtrees {
+		if key.Subtrees[i], err = extract(master, hashMember(tagSubtree, id[:i+1])); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// EncryptOption configures EncryptToSet.
+type EncryptOption func(*encryptConfig)
+
+type encryptConfig struct {
+	descendants bool
+}
+
+// WithDescendants makes the ciphertext decryptable by the descendants of the
+// recipients as well as by the recipients themselves.
+func WithDescendants() EncryptOption {
+	return func(config *encryptConfig) {
+		config.descendants = true
+	}
+}
+
+// members returns the hashes of the recipients of a ciphertext.
+func members(ids [][]*big.Int, descendants bool) []*big.Int {
+	tag := byte(tagExact)
+	if descendants {
+		tag = tagSubtree
+	}
+	xs := make([]*big.Int, len(ids))
+	for i, id := range ids {
+		xs[i] = hashMember(tag, id)
+	}
+	return xs
+}
+
+// polynomial returns the coefficients, lowest first, of the product of
+// (gamma + x) over xs, modulo the group order.
+func polynomial(xs []*big.Int) []*big.Int {
+	coefficients := []*big.Int{big.NewInt(1)}
+	for _, x := range xs {
+		next := make([]*big.Int, len(coefficients)+1)
+		for i := range next {
+			next[i] = new(big.Int)
+			if i < len(coefficients) {
+				next[i].Mul(coefficients[i], x)
+			}
+			if i > 0 {
+				next[i].Add(next[i], coefficients[i-1])
+			}
+			next[i].Mod(next[i], bn256.Order)
+		}
+		coefficients = next
+	}
+	return coefficients
+}
+
+// EncryptToSet encrypts message so that the key of any identity in ids, and
+// with WithDescendants of any of their descendants, can decrypt it. The set
+// may hold up to params.MaxRecipients() identities; duplicates are dropped.
+func EncryptToSet(random io.Reader, params *Params, ids [][]*big.Int, message *bn256.GT, opts ...EncryptOption) (*Ciphertext, error) {
+	config := &encryptConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+	recipients := dedupe(ids)
+	if len(recipients) == 0 {
+		return nil, ErrNoRecipients
+	}
+	if len(recipients) > params.MaxRecipients() {
+		return nil, ErrTooManyRecipients
+	}
+	for _, id := range recipients {
+		if len(id) == 0 {
+			return nil, hibe_sm9.ErrInvalidID
+		}
+	}
+
+	if random == nil {
+		random = rand.Reader
+	}
+	k, err := randomScalar(random)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := &Ciphertext{Recipients: recipients, Descendants: config.descendants}
+
+	// C1 = w^-k, C2 = h^(k * prod(gamma + x)), A = v^k * message
+	ciphertext.C1 = new(bn256.G1).ScalarMult(params.W, k)
+	ciphertext.C1.Neg(ciphertext.C1)
+	ciphertext.C2 = combine(params.H, polynomial(members(recipients, config.descendants)))
+	ciphertext.C2.ScalarMult(ciphertext.C2, k)
+	ciphertext.A = new(bn256.GT).ScalarMult(params.V, k)
+	ciphertext.A.Add(ciphertext.A, message)
+	return ciphertext, nil
+}
+
+// combine returns the sum of bases[i]^scalars[i].
+func combine(bases []*bn256.G2, scalars []*big.Int) *bn256.G2 {
+	sum := new(bn256.G2).ScalarBaseMult(new(big.Int))
+	for i, scalar := range scalars {
+		sum.Add(sum, new(bn256.G2).ScalarMult(bases[i], scalar))
+	}
+	return sum
+}
+
+func dedupe(ids [][]*big.Int) [][]*big.Int {
+	seen := make(map[string]bool, len(ids))
+	unique := make([][]*big.Int, 0, len(ids))
+	for _, id := range ids {
+		encoded := string(hashMember(tagExact, id).Bytes())
+		if !seen[encoded] {
+			seen[encoded] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// isPrefix reports whether prefix is id or one of its ancestors.
+func isPrefix(prefix []*big.Int, id []*big.Int) bool {
+	if len(prefix) == 0 || len(prefix) > len(id) {
+		return false
+	}
+	for i, component := range prefix {
+		if component.Cmp(id[i]) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Decrypt recovers the message with the key of a recipient, or of a
+// descendant of one if the ciphertext was encrypted with WithDescendants. It
+// returns ErrNotRecipient for any other key.
+func Decrypt(params *Params, key *PrivateKey, ciphertext *Ciphertext) (*bn256.GT, error) {
+	index, secret := -1, key.Exact
+	for i, id := range ciphertext.Recipients {
+		if isPrefix(id, key.ID) && (ciphertext.Descendants || len(id) == len(key.ID)) {
+			index = i
+			if ciphertext.Descendants {
+				secret = key.Subtrees[len(id)-1]
+			}
+			break
+		}
+	}
+	if index < 0 {
+		return nil, ErrNotRecipient
+	}
+	if len(ciphertext.Recipients) > params.MaxRecipients() {
+		return nil, ErrTooManyRecipients
+	}
+
+	// With Q(gamma) = prod over the other recipients of (gamma + x) = b0 +
+	// gamma * p(gamma), e(C1, h^p(gamma)) * e(secret, C2) = v^(k * b0).
+	xs := members(ciphertext.Recipients, ciphertext.Descendants)
+	others := append(append([]*big.Int{}, xs[:index]...), xs[index+1:]...)
+	q := polynomial(others)
+	hp := combine(params.H, q[1:])
+
+	blind := bn256.Pair(ciphertext.C1, hp)
+	blind.Add(blind, bn256.Pair(secret, ciphertext.C2))
+	blind.ScalarMult(blind, new(big.Int).ModInverse(q[0], bn256.Order))
+	plaintext := new(bn256.GT).Neg(blind)
+	return plaintext.Add(ciphertext.A, plaintext), nil
+}
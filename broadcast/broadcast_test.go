@@ -0,0 +1,109 @@
+package broadcast
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"hibe_sm9"
+	"math/big"
+	"testing"
+)
+
+func path(components ...int64) []*big.Int {
+	id := make([]*big.Int, len(components))
+	for i, component := range components {
+		id[i] = big.NewInt(component)
+	}
+	return id
+}
+
+func TestEncryptToSet(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipients := [][]*big.Int{path(1, 2), path(1, 3), path(4), path(5, 6, 7)}
+	message := hibe_sm9.HashToGT([]byte("message"))
+	ciphertext, err := EncryptToSet(rand.Reader, params, recipients, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range recipients {
+		key, err := KeyGen(master, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decrypted, err := Decrypt(params, key, ciphertext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(message.Marshal(), decrypted.Marshal()) {
+			t.Fatal("Recipient did not decrypt the message")
+		}
+	}
+
+	// Neither other identities nor descendants of the recipients can decrypt
+	for _, id := range [][]*big.Int{path(1), path(1, 4), path(4, 1)} {
+		key, err := KeyGen(master, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = Decrypt(params, key, ciphertext); !errors.Is(err, ErrNotRecipient) {
+			t.Fatal("Key for another identity was accepted")
+		}
+	}
+
+	// A key cannot pretend to be a recipient
+	impostor, err := KeyGen(master, path(9, 9))
+	if err != nil {
+		t.Fatal(err)
+	}
+	impostor.ID = path(1, 2)
+	if decrypted, err := Decrypt(params, impostor, ciphertext); err == nil && bytes.Equal(message.Marshal(), decrypted.Marshal()) {
+		t.Fatal("Key for another identity decrypted the message")
+	}
+
+	if _, err = EncryptToSet(rand.Reader, params, nil, message); !errors.Is(err, ErrNoRecipients) {
+		t.Fatal("Empty set was accepted")
+	}
+	tooMany := make([][]*big.Int, 9)
+	for i := range tooMany {
+		tooMany[i] = path(int64(i + 1))
+	}
+	if _, err = EncryptToSet(rand.Reader, params, tooMany, message); !errors.Is(err, ErrTooManyRecipients) {
+		t.Fatal("Too many recipients were accepted")
+	}
+}
+
+func TestDescendants(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := hibe_sm9.HashToGT([]byte("message"))
+	ciphertext, err := EncryptToSet(rand.Reader, params, [][]*big.Int{path(1, 2), path(3)}, message, WithDescendants())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range [][]*big.Int{path(1, 2), path(1, 2, 5), path(3), path(3, 1, 4)} {
+		key, err := KeyGen(master, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decrypted, err := Decrypt(params, key, ciphertext)
+		if err != nil || !bytes.Equal(message.Marshal(), decrypted.Marshal()) {
+			t.Fatal("Descendant did not decrypt the message")
+		}
+	}
+	for _, id := range [][]*big.Int{path(1), path(1, 3), path(2, 3)} {
+		key, err := KeyGen(master, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = Decrypt(params, key, ciphertext); !errors.Is(err, ErrNotRecipient) {
+			t.Fatal("Key outside the subtrees was accepted")
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package hibe_sm9
+
+import (
+	"crypto/ed25519"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+)
+
+// bundleContext is prepended to the encoded parameters before signing, so
+// that a bundle signature cannot be mistaken for a signature by the same key
+// over anything else.
+const bundleContext = "HIBE-SIGNED-PARAMS-V1"
+
+var oidEd25519 = asn1.ObjectIdentifier{1, 3, 101, 112}
+
+// ErrParamsSignature is returned by VerifyParams when the bundle is not
+// signed by the expected key.
+var ErrParamsSignature = errors.New("hibe: parameters are not signed by the expected key")
+
+// asn1SignedParams is the ASN.1 structure of a signed parameter bundle:
+//
+//	HIBESignedParameters ::= SEQUENCE {
+//	  version INTEGER,
+//	  params OCTET STRING,
+//	  algorithm OBJECT IDENTIFIER,
+//	  signature OCTET STRING }
+//
+// params is the binary encoding of the parameters (see Params.Marshal).
+type asn1SignedParams struct {
+	Version   int
+	Params    []byte
+	Algorithm asn1.ObjectIdentifier
+	Signature []byte
+}
+
+// SignParams encodes the parameters as a "HIBE SIGNED PARAMETERS" PEM block
+// signed with the Ed25519 key of the PKG. Clients that know the public key
+// load the bundle with VerifyParams, so parameters fetched over HTTP or
+// shipped with an application cannot be substituted with those of a hierarchy
+// controlled by someone else.
+func SignParams(params *Params, key ed25519.PrivateKey) ([]byte, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, errors.New("hibe: invalid Ed25519 private key")
+	}
+	encoded := params.Marshal()
+	structure := asn1SignedParams{
+		Version:   pemVersion,
+		Params:    encoded,
+		Algorithm: oidEd25519,
+		Signature: ed25519.Sign(key, append([]byte(bundleContext), encoded...)),
+	}
+	der, err := asn1.Marshal(structure)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: PEMTypeSignedParams, Bytes: der}), nil
+}
+
+// VerifyParams recovers the parameters from a bundle produced by SignParams,
+// after checking its signature with the PKG's public key. It returns
+// ErrParamsSignature if the signature does not verify.
+func VerifyParams(data []byte, key ed25519.PublicKey) (*Params, error) {
+	der, err := decodePEM(data, PEMTypeSignedParams)
+	if err != nil {
+		return nil, err
+	}
+	var structure asn1SignedParams
+	if err = parseDER(der, &structure); err != nil {
+		return nil, err
+	}
+	if structure.Version != pemVersion || !structure.Algorithm.Equal(oidEd25519) {
+		return nil, errPEMMalformed
+	}
+	if len(key) != ed25519.PublicKeySize ||
+		!ed25519.Verify(key, append([]byte(bundleContext), structure.Params...), structure.Signature) {
+		return nil, ErrParamsSignature
+	}
+	params, ok := new(Params).Unmarshal(structure.Params)
+	if !ok {
+		return nil, errPEMMalformed
+	}
+	return params, nil
+}
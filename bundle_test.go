@@ -0,0 +1,67 @@
+package hibe_sm9
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"testing"
+)
+
+func TestSignedParams(t *testing.T) {
+	params, _, err := Setup(rand.Reader, 3, WithAnonymity())
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := SignParams(params, private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verified, err := VerifyParams(bundle, public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !verified.Equal(params) {
+		t.Fatal("Verified parameters differ")
+	}
+
+	// A bundle signed by anyone else is rejected
+	otherPublic, otherPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = VerifyParams(bundle, otherPublic); !errors.Is(err, ErrParamsSignature) {
+		t.Fatal("Bundle verified under the wrong key")
+	}
+
+	// So are substituted parameters
+	other, _, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged, err := SignParams(other, otherPrivate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = VerifyParams(forged, public); !errors.Is(err, ErrParamsSignature) {
+		t.Fatal("Substituted parameters were accepted")
+	}
+	block, _ := pem.Decode(bundle)
+	block.Bytes[len(block.Bytes)-1] ^= 1
+	if _, err = VerifyParams(pem.EncodeToMemory(block), public); !errors.Is(err, ErrParamsSignature) {
+		t.Fatal("Corrupted signature was accepted")
+	}
+
+	// Plain parameters are not a bundle
+	plain, err := params.MarshalPEM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = VerifyParams(plain, public); err == nil {
+		t.Fatal("Unsigned parameters were accepted")
+	}
+}
@@ -0,0 +1,139 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+)
+
+var (
+	oidSM3    = asn1.ObjectIdentifier{1, 2, 156, 10197, 1, 401}
+	oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+)
+
+var (
+	errNoParamsExtension = wrapError(ErrCurveMismatch, "hibe: certificate does not name any parameters")
+	errParamsExtension   = wrapError(ErrCurveMismatch, "hibe: certificate names different parameters")
+	errCertificate       = errors.New("hibe: malformed certificate")
+	errExtensionOID      = errors.New("hibe: invalid OID for the parameters extension")
+)
+
+// asn1ParamsExtension is the ASN.1 structure of the value of the extension:
+//
+//	HIBEParameters ::= SEQUENCE {
+//	  hashAlgorithm OBJECT IDENTIFIER,
+//	  fingerprint OCTET STRING }
+//
+// fingerprint is computed as in Params.Fingerprint, with the hash named by
+// hashAlgorithm: SM3 or SHA-256.
+type asn1ParamsExtension struct {
+	HashAlgorithm asn1.ObjectIdentifier
+	Fingerprint   []byte
+}
+
+// ParamsExtension returns a non-critical certificate extension that names the
+// parameters by their SM3 fingerprint. A CA adds it to the certificate of the
+// PKG, for instance through x509.Certificate.ExtraExtensions or the SM2
+// certificate library of a GM/T PKI, so that relying parties that already
+// trust the CA can anchor their trust in the parameters too, instead of
+// pinning them or the key that signs them (see SignParams).
+//
+// No OID has been registered for the extension, so the deployment chooses
+// oid from an arc it controls, and its relying parties verify with the same
+// one.
+func ParamsExtension(params *Params, oid asn1.ObjectIdentifier) (pkix.Extension, error) {
+	if !validOID(oid) {
+		return pkix.Extension{}, errExtensionOID
+	}
+	value, err := asn1.Marshal(asn1ParamsExtension{
+		HashAlgorithm: oidSM3,
+		Fingerprint:   params.fingerprintWith(newSM3()),
+	})
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+	return pkix.Extension{Id: oid, Value: value}, nil
+}
+
+// validOID reports whether oid can be encoded: it has at least two
+// components, the first of which is 0, 1 or 2, and none are negative.
+func validOID(oid asn1.ObjectIdentifier) bool {
+	if len(oid) < 2 || oid[0] > 2 || (oid[0] < 2 && oid[1] >= 40) {
+		return false
+	}
+	for _, component := range oid {
+		if component < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// VerifyParamsExtension checks that extensions, such as those of an
+// x509.Certificate, contain the extension of ParamsExtension for params under
+// oid. It accepts SHA-256 fingerprints as well as SM3 ones. It does not check
+// the certificate itself, which must already have been verified against the
+// PKI.
+func VerifyParamsExtension(params *Params, oid asn1.ObjectIdentifier, extensions []pkix.Extension) error {
+	if !validOID(oid) {
+		return errExtensionOID
+	}
+	for _, extension := range extensions {
+		if !extension.Id.Equal(oid) {
+			continue
+		}
+		var value asn1ParamsExtension
+		if rest, err := asn1.Unmarshal(extension.Value, &value); err != nil || len(rest) != 0 {
+			return errCertificate
+		}
+		var fingerprint []byte
+		switch {
+		case value.HashAlgorithm.Equal(oidSM3):
+			fingerprint = params.fingerprintWith(newSM3())
+		case value.HashAlgorithm.Equal(oidSHA256):
+			fingerprint = params.Fingerprint()
+		default:
+			return errCertificate
+		}
+		if !bytes.Equal(value.Fingerprint, fingerprint) {
+			return errParamsExtension
+		}
+		return nil
+	}
+	return errNoParamsExtension
+}
+
+// asn1Certificate is as much of the X.509 structure of a certificate as is
+// needed to find its extensions (RFC 5280, section 4.1).
+type asn1Certificate struct {
+	TBSCertificate     asn1TBSCertificate
+	SignatureAlgorithm asn1.RawValue
+	Signature          asn1.BitString
+}
+
+type asn1TBSCertificate struct {
+	Version         int `asn1:"optional,explicit,default:0,tag:0"`
+	SerialNumber    asn1.RawValue
+	Signature       asn1.RawValue
+	Issuer          asn1.RawValue
+	Validity        asn1.RawValue
+	Subject         asn1.RawValue
+	PublicKey       asn1.RawValue
+	IssuerUniqueID  asn1.BitString   `asn1:"optional,tag:1"`
+	SubjectUniqueID asn1.BitString   `asn1:"optional,tag:2"`
+	Extensions      []pkix.Extension `asn1:"optional,explicit,tag:3"`
+}
+
+// VerifyParamsCertificate is VerifyParamsExtension for a DER-encoded
+// certificate. crypto/x509 cannot parse certificates with SM2 keys, and this
+// package does not implement SM2, so the certificate is only decoded as far
+// as its extensions: its signature and validity must be checked with the SM2
+// library of the PKI beforehand.
+func VerifyParamsCertificate(params *Params, oid asn1.ObjectIdentifier, der []byte) error {
+	var certificate asn1Certificate
+	if rest, err := asn1.Unmarshal(der, &certificate); err != nil || len(rest) != 0 {
+		return errCertificate
+	}
+	return VerifyParamsExtension(params, oid, certificate.TBSCertificate.Extensions)
+}
@@ -0,0 +1,84 @@
+package hibe_sm9
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// testParamsOID is an unregistered OID under the private enterprise arc, for
+// tests only.
+var testParamsOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 7, 1}
+
+func newCertificate(t *testing.T, extensions []pkix.Extension) []byte {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(1),
+		Subject:         pkix.Name{CommonName: "pkg"},
+		NotBefore:       time.Now(),
+		NotAfter:        time.Now().Add(time.Hour),
+		ExtraExtensions: extensions,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, public, private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func TestParamsCertificate(t *testing.T) {
+	params, _, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	extension, err := ParamsExtension(params, testParamsOID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der := newCertificate(t, []pkix.Extension{extension})
+
+	if err = VerifyParamsCertificate(params, testParamsOID, der); err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = VerifyParamsExtension(params, testParamsOID, certificate.Extensions); err != nil {
+		t.Fatal(err)
+	}
+	if err = VerifyParamsCertificate(other, testParamsOID, der); !errors.Is(err, ErrCurveMismatch) {
+		t.Fatal("Certificate verified for other parameters")
+	}
+	if err = VerifyParamsCertificate(params, testParamsOID, newCertificate(t, nil)); !errors.Is(err, ErrCurveMismatch) {
+		t.Fatal("Certificate without the extension verified")
+	}
+	if err = VerifyParamsCertificate(params, testParamsOID, der[:len(der)-1]); err == nil {
+		t.Fatal("Truncated certificate verified")
+	}
+	otherOID := asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 7, 2}
+	if err = VerifyParamsCertificate(params, otherOID, der); !errors.Is(err, ErrCurveMismatch) {
+		t.Fatal("Certificate verified with the extension under another OID")
+	}
+	for _, oid := range []asn1.ObjectIdentifier{nil, {1}, {3, 1}, {1, 40}, {1, 3, -6}} {
+		if _, err = ParamsExtension(params, oid); err == nil {
+			t.Fatal("Created an extension with an invalid OID", oid)
+		}
+		if err = VerifyParamsCertificate(params, oid, der); err == nil {
+			t.Fatal("Verified a certificate with an invalid OID", oid)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package hibe_sm9
+
+import (
+	"golang.org/x/crypto/bn256"
+	"math/big"
+)
+
+func deepCloneGT(src *bn256.GT) *bn256.GT {
+	clone, _ := new(bn256.GT).Unmarshal(src.Marshal())
+	return clone
+}
+
+// cloneG1s clones a slice of elements of G1, keeping nil as nil.
+func cloneG1s(src []*bn256.G1) []*bn256.G1 {
+	if src == nil {
+		return nil
+	}
+	clone := make([]*bn256.G1, len(src))
+	for i, p := range src {
+		clone[i] = deepClone(p)
+	}
+	return clone
+}
+
+// cloneG2s is the mirror of cloneG1s in G2.
+func cloneG2s(src []*bn256.G2) []*bn256.G2 {
+	if src == nil {
+		return nil
+	}
+	clone := make([]*bn256.G2, len(src))
+	for i, p := range src {
+		clone[i] = deepCloneG2(p)
+	}
+	return clone
+}
+
+func cloneBytes(src []byte) []byte {
+	if src == nil {
+		return nil
+	}
+	return append([]byte{}, src...)
+}
+
+// Clone returns a copy of the parameters that shares no group elements with
+// the original. The precomputed tables (see Precompute) are shared, since they
+// are never modified once built.
+func (params *Params) Clone() *Params {
+	clone := &Params{
+		G:      deepCloneG2(params.G),
+		G1:     deepCloneG2(params.G1),
+		G2:     deepClone(params.G2),
+		G3:     deepClone(params.G3),
+		H:      cloneG1s(params.H),
+		HHat:   cloneG2s(params.HHat),
+		tables: params.tables,
+
+		IdentityHash: params.IdentityHash,
+	}
+	if params.HSeed != nil {
+		clone.HSeed = append([]byte{}, params.HSeed...)
+	}
+	if params.G3Hat != nil {
+		clone.G3Hat = deepCloneG2(params.G3Hat)
+	}
+	if params.Pairing != nil {
+		clone.Pairing = deepCloneGT(params.Pairing)
+	}
+	return clone
+}
+
+// clone returns a deep copy of the policy and the policies it is nested in.
+func (policy *DelegationPolicy) clone() *DelegationPolicy {
+	if policy == nil {
+		return nil
+	}
+	clone := &DelegationPolicy{MaxDepth: policy.MaxDepth, outer: policy.outer.clone()}
+	if policy.Subtrees != nil {
+		clone.Subtrees = make([][]*big.Int, len(policy.Subtrees))
+		for i, subtree := range policy.Subtrees {
+			clone.Subtrees[i] = make([]*big.Int, len(subtree))
+			for j, component := range subtree {
+				clone.Subtrees[i][j] = new(big.Int).Set(component)
+			}
+		}
+	}
+	return clone
+}
+
+// Clone returns a copy of the private key that shares nothing with the
+// original, so that zeroizing one leaves the other intact.
+func (privkey *PrivateKey) Clone() *PrivateKey {
+	clone := &PrivateKey{
+		A0:                deepClone(privkey.A0),
+		B:                 cloneG1s(privkey.B),
+		Policy:            privkey.Policy.clone(),
+		ParamsFingerprint: cloneBytes(privkey.ParamsFingerprint),
+		levels:            privkey.levels,
+	}
+	if privkey.A1 != nil {
+		clone.A1 = deepCloneG2(privkey.A1)
+	}
+	if privkey.A1Hat != nil {
+		clone.A1Hat = deepClone(privkey.A1Hat)
+	}
+	return clone
+}
+
+// Clone returns a copy of the ciphertext that shares nothing with the
+// original.
+func (ciphertext *Ciphertext) Clone() *Ciphertext {
+	clone := &Ciphertext{
+		A:                 deepCloneGT(ciphertext.A),
+		B:                 deepCloneG2(ciphertext.B),
+		Tag:               cloneBytes(ciphertext.Tag),
+		ParamsFingerprint: cloneBytes(ciphertext.ParamsFingerprint),
+	}
+	if ciphertext.C != nil {
+		clone.C = deepClone(ciphertext.C)
+	}
+	if ciphertext.CHat != nil {
+		clone.CHat = deepCloneG2(ciphertext.CHat)
+	}
+	return clone
+}
@@ -0,0 +1,50 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func TestClone(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params.Precache()
+	clone := params.Clone()
+	if !bytes.Equal(clone.Marshal(), params.Marshal()) || clone.Pairing == params.Pairing || clone.H[0] == params.H[0] {
+		t.Fatal("Parameters were not cloned")
+	}
+
+	key, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	key = key.Restrict(DelegationPolicy{MaxDepth: 2, Subtrees: [][]*big.Int{LINEAR_HIERARCHY[:2]}})
+	keyClone := key.Clone()
+	if !bytes.Equal(keyClone.Marshal(), key.Marshal()) || keyClone.Policy == key.Policy {
+		t.Fatal("Private key was not cloned")
+	}
+	keyClone.Policy.Subtrees[0][1].SetInt64(9)
+	keyClone.Zeroize()
+	if _, err = KeyGenFromParent(rand.Reader, params, key, LINEAR_HIERARCHY[:2]); err != nil {
+		t.Fatal("Changing the clone changed the original:", err)
+	}
+
+	message := NewMessage()
+	ciphertext, err := Encrypt(rand.Reader, params, LINEAR_HIERARCHY[:1], message, WithIntegrityTag())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertextClone := ciphertext.Clone()
+	if !bytes.Equal(ciphertextClone.Marshal(), ciphertext.Marshal()) {
+		t.Fatal("Ciphertext was not cloned")
+	}
+	ciphertextClone.Tag[0] ^= 1
+	ciphertextClone.C.Add(ciphertextClone.C, params.G3)
+	if !bytes.Equal(message.Marshal(), mustDecrypt(t, key, ciphertext).Marshal()) {
+		t.Fatal("Changing the clone changed the original")
+	}
+}
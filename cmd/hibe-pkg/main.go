@@ -0,0 +1,174 @@
+// Command hibe-pkg runs a Private Key Generator server for a HIBE hierarchy.
+// It loads the parameters and master key from PEM files and issues private
+// keys to authenticated requesters over HTTPS (see package pkgserver).
+//
+// Requesters authenticate either with a bearer token listed in the -tokens
+// file (one "token requester" pair per line), or, if -client-ca is given, with
+// a TLS client certificate signed by that CA. Each requester obtains only the
+// keys granted in the -authz file, one "requester identity" pair per line,
+// where the identity is a path as in command hibe and the grant covers the
+// identities below it too.
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"hibe_sm9"
+	"hibe_sm9/ids"
+	"hibe_sm9/pkgserver"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+func loadTokens(path string) (pkgserver.TokenAuthenticator, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	tokens := make(pkgserver.TokenAuthenticator)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) != 2 {
+			log.Fatalf("malformed line in %s: expected \"token requester\"", path)
+		}
+		tokens[fields[0]] = fields[1]
+	}
+	return tokens, scanner.Err()
+}
+
+func loadGrants(path string, params *hibe_sm9.Params) (pkgserver.PrefixAuthorizer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	grants := make(pkgserver.PrefixAuthorizer)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) != 2 {
+			log.Fatalf("malformed line in %s: expected \"requester identity\"", path)
+		}
+		id, err := ids.ID(params, fields[1])
+		if err != nil {
+			log.Fatalf("%s: %v", path, err)
+		}
+		grants[fields[0]] = append(grants[fields[0]], id)
+	}
+	return grants, scanner.Err()
+}
+
+func main() {
+	listen := flag.String("listen", ":8443", "address to listen on")
+	paramsPath := flag.String("params", "params.pem", "PEM file with the public parameters")
+	masterPath := flag.String("master", "master.pem", "PEM file with the master key")
+	tokensPath := flag.String("tokens", "", "file of \"token requester\" lines for bearer-token authentication")
+	grantsPath := flag.String("authz", "", "file of \"requester identity\" lines granting each requester the keys at and below an identity (required)")
+	certPath := flag.String("tls-cert", "", "TLS certificate")
+	keyPath := flag.String("tls-key", "", "TLS private key")
+	clientCA := flag.String("client-ca", "", "CA for verifying client certificates (enables mTLS authentication)")
+	rate := flag.Float64("rate", 1, "sustained keys per second per requester (0 disables rate limiting)")
+	burst := flag.Int("burst", 10, "keys a requester may obtain at once")
+	quota := flag.Int64("quota", 0, "keys each requester may obtain per quota window (0 disables the quota)")
+	quotaWindow := flag.Duration("quota-window", 24*time.Hour, "period after which quotas start again (0 makes them totals)")
+	auditPath := flag.String("audit-log", "", "file to append audit records to (default stderr)")
+	flag.Parse()
+
+	data, err := os.ReadFile(*paramsPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	params, err := new(hibe_sm9.Params).ParsePEM(data)
+	if err != nil {
+		log.Fatalf("%s: %v", *paramsPath, err)
+	}
+	data, err = os.ReadFile(*masterPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	master, err := hibe_sm9.ParseMasterKeyPEM(data)
+	if err != nil {
+		log.Fatalf("%s: %v", *masterPath, err)
+	}
+
+	if *grantsPath == "" {
+		log.Fatal("-authz is required; the PKG issues no keys that are not granted")
+	}
+	grants, err := loadGrants(*grantsPath, params)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	config := pkgserver.Config{
+		Params:    params,
+		Master:    master,
+		Authorize: grants.Authorize,
+		Rate:      *rate,
+		Burst:     *burst,
+		AuditLog:  os.Stderr,
+	}
+	if *quota > 0 {
+		config.Quota = &pkgserver.Quota{PerRequester: *quota, Window: *quotaWindow}
+	}
+	if *auditPath != "" {
+		audit, err := os.OpenFile(*auditPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer audit.Close()
+		config.AuditLog = audit
+	}
+
+	httpServer := &http.Server{Addr: *listen}
+	switch {
+	case *clientCA != "":
+		pool := x509.NewCertPool()
+		data, err = os.ReadFile(*clientCA)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			log.Fatalf("%s: no certificates found", *clientCA)
+		}
+		httpServer.TLSConfig = &tls.Config{
+			ClientAuth: tls.RequireAndVerifyClientCert,
+			ClientCAs:  pool,
+			MinVersion: tls.VersionTLS12,
+		}
+		config.Authenticator = pkgserver.MTLSAuthenticator{}
+	case *tokensPath != "":
+		tokens, err := loadTokens(*tokensPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		config.Authenticator = tokens
+	default:
+		log.Fatal("one of -tokens or -client-ca is required")
+	}
+	if *certPath == "" || *keyPath == "" {
+		log.Fatal("-tls-cert and -tls-key are required; the PKG never serves keys in the clear")
+	}
+
+	server, err := pkgserver.New(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	httpServer.Handler = server
+	log.Printf("serving hierarchy of depth %d on %s", params.MaximumDepth(), *listen)
+	log.Fatal(httpServer.ListenAndServeTLS(*certPath, *keyPath))
+}
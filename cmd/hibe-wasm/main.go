@@ -0,0 +1,207 @@
+//go:build js && wasm
+
+// Command hibe-wasm exposes a HIBE hierarchy to JavaScript, so that browser
+// clients can decrypt with delegated keys. Build it with
+//
+//	GOOS=js GOARCH=wasm go build -o hibe.wasm ./cmd/hibe-wasm
+//
+// and load it with the wasm_exec.js of the Go distribution. It defines a
+// global hibe object with
+//
+//	hibe.setup(depth)                  -> {params, master}
+//	hibe.keygen(params, master, id)    -> key
+//	hibe.delegate(params, parent, id)  -> key
+//	hibe.encrypt(params, id, data)     -> ciphertext
+//	hibe.decrypt(key, ciphertext)      -> data
+//
+// Parameters, master keys and private keys are PEM strings, data and
+// ciphertexts are Uint8Arrays, and identities are slash-separated paths as in
+// command hibe. Data is encrypted with hibe_sm9.EncryptBytes. On failure each
+// function returns an object {error: message} instead.
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"hibe_sm9"
+	"hibe_sm9/ids"
+	"math/big"
+	"syscall/js"
+)
+
+var errArguments = errors.New("hibe: wrong number or type of arguments")
+
+// function wraps fn as a JavaScript function taking n arguments, which turns
+// errors into {error: message}.
+func function(n int, fn func(args []js.Value) (interface{}, error)) js.Func {
+	return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if len(args) != n {
+			return map[string]interface{}{"error": errArguments.Error()}
+		}
+		result, err := fn(args)
+		if err != nil {
+			return map[string]interface{}{"error": err.Error()}
+		}
+		return result
+	})
+}
+
+func stringArg(value js.Value) (string, error) {
+	if value.Type() != js.TypeString {
+		return "", errArguments
+	}
+	return value.String(), nil
+}
+
+func bytesArg(value js.Value) ([]byte, error) {
+	if !value.InstanceOf(js.Global().Get("Uint8Array")) {
+		return nil, errArguments
+	}
+	data := make([]byte, value.Get("length").Int())
+	js.CopyBytesToGo(data, value)
+	return data, nil
+}
+
+func bytesValue(data []byte) js.Value {
+	array := js.Global().Get("Uint8Array").New(len(data))
+	js.CopyBytesToJS(array, data)
+	return array
+}
+
+func paramsArg(value js.Value) (*hibe_sm9.Params, error) {
+	encoded, err := stringArg(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(hibe_sm9.Params).ParsePEM([]byte(encoded))
+}
+
+func keyArg(value js.Value) (*hibe_sm9.PrivateKey, error) {
+	encoded, err := stringArg(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(hibe_sm9.PrivateKey).ParsePEM([]byte(encoded), nil)
+}
+
+func idArg(params *hibe_sm9.Params, value js.Value) ([]*big.Int, error) {
+	path, err := stringArg(value)
+	if err != nil || path == "" {
+		return nil, errArguments
+	}
+	return ids.ID(params, path)
+}
+
+func setup(args []js.Value) (interface{}, error) {
+	if args[0].Type() != js.TypeNumber {
+		return nil, errArguments
+	}
+	params, master, err := hibe_sm9.Setup(rand.Reader, args[0].Int())
+	if err != nil {
+		return nil, err
+	}
+	encodedParams, err := params.MarshalPEM()
+	if err != nil {
+		return nil, err
+	}
+	encodedMaster, err := hibe_sm9.MarshalMasterKeyPEM(master)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"params": string(encodedParams), "master": string(encodedMaster)}, nil
+}
+
+func keygen(args []js.Value) (interface{}, error) {
+	params, err := paramsArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+	encodedMaster, err := stringArg(args[1])
+	if err != nil {
+		return nil, err
+	}
+	master, err := hibe_sm9.ParseMasterKeyPEM([]byte(encodedMaster))
+	if err != nil {
+		return nil, err
+	}
+	id, err := idArg(params, args[2])
+	if err != nil {
+		return nil, err
+	}
+	key, err := hibe_sm9.KeyGenFromMaster(rand.Reader, params, master, id)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := key.MarshalPEM()
+	return string(encoded), err
+}
+
+func delegate(args []js.Value) (interface{}, error) {
+	params, err := paramsArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+	parent, err := keyArg(args[1])
+	if err != nil {
+		return nil, err
+	}
+	id, err := idArg(params, args[2])
+	if err != nil {
+		return nil, err
+	}
+	key, err := hibe_sm9.KeyGenFromParent(rand.Reader, params, parent, id)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := key.MarshalPEM()
+	return string(encoded), err
+}
+
+func encrypt(args []js.Value) (interface{}, error) {
+	params, err := paramsArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+	id, err := idArg(params, args[1])
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := bytesArg(args[2])
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := hibe_sm9.EncryptBytes(rand.Reader, params, id, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	return bytesValue(ciphertext), nil
+}
+
+func decrypt(args []js.Value) (interface{}, error) {
+	key, err := keyArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := bytesArg(args[1])
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := hibe_sm9.DecryptBytes(key, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	return bytesValue(plaintext), nil
+}
+
+func main() {
+	js.Global().Set("hibe", map[string]interface{}{
+		"setup":    function(1, setup),
+		"keygen":   function(3, keygen),
+		"delegate": function(3, delegate),
+		"encrypt":  function(3, encrypt),
+		"decrypt":  function(2, decrypt),
+	})
+
+	// Keep the functions alive
+	select {}
+}
@@ -0,0 +1,291 @@
+// Command hibe exercises a HIBE hierarchy from the command line.
+//
+//	hibe setup -l 5                              create params.pem and master.pem
+//	hibe keygen -id org/dept/alice               issue a key from master.pem
+//	hibe keygen -id org/dept/alice -parent k.pem delegate a key from its parent
+//	hibe keygen -id org/dept/alice -leaf         issue a decryption-only key
+//	hibe encrypt -id org/dept/alice file         encrypt file for an identity
+//	hibe decrypt -key alice.pem file             decrypt file with a private key
+//	hibe tree -dir keys -params params.pem       list the keys in a directory
+//
+// Identities are slash-separated paths whose components are brought to their
+// canonical form (see ids.Normalize), so that "Org/Alice" and "org/alice" are
+// the same identity, and hashed onto Zp with the identity hash of the
+// parameters (see ids.ID), like the identities of the directory package. Keys
+// and parameters are stored in PEM format, and files are encrypted in the
+// streaming format of hibe_sm9.EncryptingWriter. Output goes to standard
+// output unless -out is given.
+package main
+
+import (
+	"crypto/rand"
+	"flag"
+	"fmt"
+	"hibe_sm9"
+	"hibe_sm9/ids"
+	"io"
+	"os"
+)
+
+const usage = `usage: hibe <command> [flags]
+
+commands:
+  setup    generate public parameters and a master key
+  keygen   generate the private key for an identity
+  encrypt  encrypt a file for an identity
+  decrypt  decrypt a file with a private key
+  tree     print the hierarchy of the keys in a directory
+
+Run "hibe <command> -h" for the flags of a command.
+`
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
+
+func readParams(path string) (*hibe_sm9.Params, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return new(hibe_sm9.Params).ParsePEM(data)
+}
+
+func readKey(path string) (*hibe_sm9.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return new(hibe_sm9.PrivateKey).ParsePEM(data, nil)
+}
+
+// openOutput returns the file named by path, or standard output if path is
+// empty.
+func openOutput(path string, perm os.FileMode) (io.WriteCloser, error) {
+	if path == "" {
+		return os.Stdout, nil
+	}
+	return os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+}
+
+// openInput returns the file named by the only argument of flags, or standard
+// input if there is none.
+func openInput(flags *flag.FlagSet) (io.ReadCloser, error) {
+	switch flags.NArg() {
+	case 0:
+		return os.Stdin, nil
+	case 1:
+		return os.Open(flags.Arg(0))
+	default:
+		return nil, fmt.Errorf("expected at most one input file, got %d", flags.NArg())
+	}
+}
+
+func setup(args []string) error {
+	flags := flag.NewFlagSet("setup", flag.ExitOnError)
+	l := flags.Int("l", 5, "maximum depth of the hierarchy")
+	anonymous := flags.Bool("anonymous", false, "create an anonymous hierarchy")
+	derived := flags.Bool("derived-h", false, "derive h1 ... hl from a short seed")
+	paramsPath := flags.String("params", "params.pem", "file to write the public parameters to")
+	masterPath := flags.String("master", "master.pem", "file to write the master key to")
+	flags.Parse(args)
+
+	var opts []hibe_sm9.SetupOption
+	if *anonymous {
+		opts = append(opts, hibe_sm9.WithAnonymity())
+	}
+	if *derived {
+		opts = append(opts, hibe_sm9.WithDerivedH())
+	}
+	params, master, err := hibe_sm9.Setup(rand.Reader, *l, opts...)
+	if err != nil {
+		return err
+	}
+	encodedParams, err := params.MarshalPEM()
+	if err != nil {
+		return err
+	}
+	encodedMaster, err := hibe_sm9.MarshalMasterKeyPEM(master)
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(*paramsPath, encodedParams, 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(*masterPath, encodedMaster, 0600)
+}
+
+func keygen(args []string) error {
+	flags := flag.NewFlagSet("keygen", flag.ExitOnError)
+	path := flags.String("id", "", "identity to generate the key for, e.g. org/dept/alice")
+	paramsPath := flags.String("params", "params.pem", "public parameters")
+	masterPath := flags.String("master", "master.pem", "master key, used unless -parent is given")
+	parentPath := flags.String("parent", "", "private key of the parent identity to delegate from")
+	leaf := flags.Bool("leaf", false, "issue a decryption-only key that cannot delegate or sign")
+	out := flags.String("out", "", "file to write the private key to")
+	flags.Parse(args)
+	if *path == "" {
+		return fmt.Errorf("keygen: -id is required")
+	}
+
+	params, err := readParams(*paramsPath)
+	if err != nil {
+		return err
+	}
+	id, err := ids.ID(params, *path)
+	if err != nil {
+		return fmt.Errorf("keygen: %w", err)
+	}
+	if len(id) > params.MaximumDepth() {
+		return fmt.Errorf("keygen: identity is deeper than the hierarchy (%d levels)", params.MaximumDepth())
+	}
+
+	var opts []hibe_sm9.KeyGenOption
+	if *leaf {
+		opts = append(opts, hibe_sm9.WithLeafOnly())
+	}
+	var key *hibe_sm9.PrivateKey
+	if *parentPath != "" {
+		parent, err := readKey(*parentPath)
+		if err != nil {
+			return err
+		}
+		if params.Anonymous() {
+			return fmt.Errorf("keygen: keys in an anonymous hierarchy cannot be delegated")
+		}
+		if parent.DepthLeft() != params.MaximumDepth()-len(id)+1 {
+			return fmt.Errorf("keygen: %s is not the key of the parent of %s", *parentPath, *path)
+		}
+		key, err = hibe_sm9.KeyGenFromParent(rand.Reader, params, parent, id, opts...)
+		if err != nil {
+			return err
+		}
+	} else {
+		data, err := os.ReadFile(*masterPath)
+		if err != nil {
+			return err
+		}
+		master, err := hibe_sm9.ParseMasterKeyPEM(data)
+		if err != nil {
+			return err
+		}
+		key, err = hibe_sm9.KeyGenFromMaster(rand.Reader, params, master, id, opts...)
+		if err != nil {
+			return err
+		}
+	}
+
+	encoded, err := key.MarshalPEM()
+	if err != nil {
+		return err
+	}
+	w, err := openOutput(*out, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(encoded); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func encrypt(args []string) error {
+	flags := flag.NewFlagSet("encrypt", flag.ExitOnError)
+	path := flags.String("id", "", "identity to encrypt for, e.g. org/dept/alice")
+	paramsPath := flags.String("params", "params.pem", "public parameters")
+	out := flags.String("out", "", "file to write the ciphertext to")
+	flags.Parse(args)
+	if *path == "" {
+		return fmt.Errorf("encrypt: -id is required")
+	}
+
+	params, err := readParams(*paramsPath)
+	if err != nil {
+		return err
+	}
+	id, err := ids.ID(params, *path)
+	if err != nil {
+		return fmt.Errorf("encrypt: %w", err)
+	}
+	if len(id) > params.MaximumDepth() {
+		return fmt.Errorf("encrypt: identity is deeper than the hierarchy (%d levels)", params.MaximumDepth())
+	}
+	r, err := openInput(flags)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := openOutput(*out, 0644)
+	if err != nil {
+		return err
+	}
+
+	writer, err := hibe_sm9.NewEncryptingWriter(rand.Reader, params, id, w)
+	if err == nil {
+		if _, err = io.Copy(writer, r); err == nil {
+			err = writer.Close()
+		}
+	}
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func decrypt(args []string) error {
+	flags := flag.NewFlagSet("decrypt", flag.ExitOnError)
+	keyPath := flags.String("key", "", "private key to decrypt with")
+	out := flags.String("out", "", "file to write the plaintext to")
+	flags.Parse(args)
+	if *keyPath == "" {
+		return fmt.Errorf("decrypt: -key is required")
+	}
+
+	key, err := readKey(*keyPath)
+	if err != nil {
+		return err
+	}
+	r, err := openInput(flags)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := openOutput(*out, 0600)
+	if err != nil {
+		return err
+	}
+
+	reader, err := hibe_sm9.NewDecryptingReader(key, r)
+	if err == nil {
+		_, err = io.Copy(w, reader)
+	}
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	commands := map[string]func([]string) error{
+		"setup":   setup,
+		"keygen":  keygen,
+		"encrypt": encrypt,
+		"decrypt": decrypt,
+		"tree":    tree,
+	}
+	command, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err := command(os.Args[2:]); err != nil {
+		fatal(err)
+	}
+}
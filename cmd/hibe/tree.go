@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"hibe_sm9"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// treeNode is an identity in the tree printed by "hibe tree". Identities on
+// the path to a key, for which there is no key file, have no file.
+type treeNode struct {
+	name     string
+	file     string
+	key      *hibe_sm9.PrivateKey
+	err      error
+	children map[string]*treeNode
+}
+
+func (node *treeNode) child(name string) *treeNode {
+	if node.children == nil {
+		node.children = make(map[string]*treeNode)
+	}
+	child, ok := node.children[name]
+	if !ok {
+		child = &treeNode{name: name}
+		node.children[name] = child
+	}
+	return child
+}
+
+// keyIdentity returns the identity path of a key file, relative to the
+// directory being inspected: its path without the .pem extension, or the
+// path of its directory for files named key.pem, as in a keystore.FileStore.
+func keyIdentity(relative string) []string {
+	relative = filepath.ToSlash(relative)
+	if filepath.Base(relative) == "key.pem" {
+		relative = filepath.Dir(relative)
+	} else {
+		relative = strings.TrimSuffix(relative, ".pem")
+	}
+	return strings.Split(relative, "/")
+}
+
+// describe summarizes the key of a node at the given depth.
+func (node *treeNode) describe(params *hibe_sm9.Params, depth int) string {
+	if node.file == "" {
+		return "(no key)"
+	}
+	if node.err != nil {
+		return fmt.Sprintf("%s: %v", node.file, node.err)
+	}
+	fingerprint := hex.EncodeToString(node.key.Fingerprint()[:8])
+	description := fmt.Sprintf("%s  depth %d  delegates %d  fingerprint %s", node.file, depth, node.key.DepthLeft(), fingerprint)
+	if params == nil {
+		return description
+	}
+	switch {
+	case node.key.Validate(params) != nil:
+		description += "  INVALID"
+	case node.key.ParamsFingerprint != nil && hex.EncodeToString(node.key.ParamsFingerprint) != hex.EncodeToString(params.Fingerprint()):
+		description += "  OTHER HIERARCHY"
+	case node.key.DepthLeft() > params.MaximumDepth()-depth:
+		description += "  SHALLOWER THAN ITS PATH"
+	case node.key.Usage() == hibe_sm9.KeyUsageDecrypt:
+		description += "  decrypt only"
+	case node.key.DepthLeft() < params.MaximumDepth()-depth:
+		description += "  restricted, or deeper than its path"
+	}
+	return description
+}
+
+func (node *treeNode) print(w io.Writer, params *hibe_sm9.Params, depth int) {
+	names := make([]string, 0, len(node.children))
+	for name := range node.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		child := node.children[name]
+		fmt.Fprintf(w, "%s%s  %s\n", strings.Repeat("  ", depth), name, child.describe(params, depth+1))
+		child.print(w, params, depth+1)
+	}
+}
+
+func tree(args []string) error {
+	flags := flag.NewFlagSet("tree", flag.ExitOnError)
+	dir := flags.String("dir", ".", "directory of PEM private keys, named by identity path")
+	paramsPath := flags.String("params", "", "public parameters to check the keys against")
+	passphrasePath := flags.String("passphrase-file", "", "file holding the passphrase of encrypted keys")
+	flags.Parse(args)
+
+	var params *hibe_sm9.Params
+	if *paramsPath != "" {
+		var err error
+		if params, err = readParams(*paramsPath); err != nil {
+			return err
+		}
+	}
+	var passphrase []byte
+	if *passphrasePath != "" {
+		data, err := os.ReadFile(*passphrasePath)
+		if err != nil {
+			return err
+		}
+		passphrase = []byte(strings.TrimRight(string(data), "\r\n"))
+	}
+
+	root := &treeNode{}
+	err := filepath.WalkDir(*dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || filepath.Ext(path) != ".pem" {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if block, _ := pem.Decode(data); block == nil || (block.Type != hibe_sm9.PEMTypePrivateKey && block.Type != hibe_sm9.PEMTypeEncryptedPrivateKey) {
+			// Parameters, master keys and other files
+			return nil
+		}
+		relative, err := filepath.Rel(*dir, path)
+		if err != nil {
+			return err
+		}
+
+		node := root
+		for _, name := range keyIdentity(relative) {
+			node = node.child(name)
+		}
+		node.file = relative
+		node.key, node.err = new(hibe_sm9.PrivateKey).ParsePEM(data, passphrase)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	root.print(os.Stdout, params, 0)
+	return nil
+}
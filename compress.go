@@ -0,0 +1,433 @@
+package hibe_sm9
+
+import (
+	"golang.org/x/crypto/bn256"
+	"math/big"
+)
+
+// MarshalOption configures the encoding produced by Marshal.
+type MarshalOption func(*marshalConfig)
+
+type marshalConfig struct {
+	compressed bool
+}
+
+// WithCompression makes Marshal encode points of G1 and G2 by their
+// x-coordinate and the parity of their y-coordinate, which halves their size.
+// Elements of GT are not compressed. Unmarshal detects compressed encodings
+// automatically; decoding them costs a square root per point.
+func WithCompression() MarshalOption {
+	return func(config *marshalConfig) {
+		config.compressed = true
+	}
+}
+
+func compressed(opts []MarshalOption) bool {
+	config := &marshalConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+	return config.compressed
+}
+
+// Compressed encodings start with one of these tags, which cannot be the
+// first byte of an uncompressed encoding: that is either the first byte of a
+// coordinate (which is less than the field prime, 0x8f...) or the 0xff of
+// anonymousMarker.
+const (
+	compressedTag          = 0xfe
+	compressedAnonymousTag = 0xfd
+)
+
+// Sizes of compressed elements. Each point has a leading byte holding the
+// parity of y (or 0 for the identity).
+const (
+	compressedG1Size = 1 + 32
+	compressedG2Size = 1 + 64
+	gtSize           = 6 << geShift
+)
+
+func isCompressed(encoded []byte) bool {
+	return len(encoded) != 0 && (encoded[0] == compressedTag || encoded[0] == compressedAnonymousTag)
+}
+
+// pointWriter appends elements to a compressed encoding.
+type pointWriter struct {
+	buf []byte
+}
+
+func (w *pointWriter) g1(p *bn256.G1) {
+	m := p.Marshal()
+	if allZero(m) {
+		w.buf = append(w.buf, make([]byte, compressedG1Size)...)
+		return
+	}
+	w.buf = append(w.buf, 2|m[63]&1)
+	w.buf = append(w.buf, m[:32]...)
+}
+
+func (w *pointWriter) g2(p *bn256.G2) {
+	m := p.Marshal()
+	if allZero(m) {
+		w.buf = append(w.buf, make([]byte, compressedG2Size)...)
+		return
+	}
+	y := fp2FromBytes(m[64:])
+	w.buf = append(w.buf, 2|y.parity())
+	w.buf = append(w.buf, m[:64]...)
+}
+
+func (w *pointWriter) gt(e *bn256.GT) {
+	w.buf = append(w.buf, e.Marshal()...)
+}
+
+// pointReader decodes elements from a compressed encoding. After a failure,
+// every further read fails as well, so callers need only check ok at the end.
+type pointReader struct {
+	buf []byte
+	ok  bool
+}
+
+func (r *pointReader) next(n int) []byte {
+	if !r.ok || len(r.buf) < n {
+		r.ok = false
+		return nil
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *pointReader) g1() *bn256.G1 {
+	b := r.next(compressedG1Size)
+	if b == nil {
+		return nil
+	}
+	m := make([]byte, 64)
+	switch b[0] {
+	case 0:
+		if !allZero(b) {
+			r.ok = false
+			return nil
+		}
+	case 2, 3:
+		x := new(big.Int).SetBytes(b[1:])
+		if x.Cmp(fieldPrime) >= 0 {
+			r.ok = false
+			return nil
+		}
+		y := new(big.Int).Exp(x, big.NewInt(3), fieldPrime)
+		y.Add(y, curveB)
+		if y.ModSqrt(y, fieldPrime) == nil {
+			r.ok = false
+			return nil
+		}
+		if y.Bit(0) != uint(b[0]&1) {
+			y.Sub(fieldPrime, y)
+		}
+		copy(m[:32], b[1:])
+		y.FillBytes(m[32:])
+	default:
+		r.ok = false
+		return nil
+	}
+	p, ok := new(bn256.G1).Unmarshal(m)
+	r.ok = ok
+	return p
+}
+
+func (r *pointReader) g2() *bn256.G2 {
+	b := r.next(compressedG2Size)
+	if b == nil {
+		return nil
+	}
+	m := make([]byte, 128)
+	switch b[0] {
+	case 0:
+		if !allZero(b) {
+			r.ok = false
+			return nil
+		}
+	case 2, 3:
+		x := fp2FromBytes(b[1:])
+		if x.re.Cmp(fieldPrime) >= 0 || x.im.Cmp(fieldPrime) >= 0 {
+			r.ok = false
+			return nil
+		}
+		y := x.mul(x).mul(x).add(twistB).sqrt()
+		if y == nil {
+			r.ok = false
+			return nil
+		}
+		if y.parity() != b[0]&1 {
+			y = y.neg()
+		}
+		copy(m[:64], b[1:])
+		y.fillBytes(m[64:])
+	default:
+		r.ok = false
+		return nil
+	}
+	p, ok := new(bn256.G2).Unmarshal(m)
+	r.ok = ok
+	return p
+}
+
+func (r *pointReader) gt() *bn256.GT {
+	b := r.next(gtSize)
+	if b == nil {
+		return nil
+	}
+	e, err := unmarshalGT(b)
+	r.ok = err == nil
+	return e
+}
+
+// done reports whether every read succeeded and the whole input was consumed.
+func (r *pointReader) done() bool {
+	return r.ok && len(r.buf) == 0
+}
+
+// fp2 is an element re + im*i of the field Fp[i]/(i^2 + 1) over which G2 is
+// defined. bn256 encodes it as im followed by re.
+type fp2 struct {
+	re, im *big.Int
+}
+
+func fp2FromBytes(b []byte) *fp2 {
+	return &fp2{re: new(big.Int).SetBytes(b[32:64]), im: new(big.Int).SetBytes(b[:32])}
+}
+
+func (a *fp2) fillBytes(b []byte) {
+	a.im.FillBytes(b[:32])
+	a.re.FillBytes(b[32:64])
+}
+
+func (a *fp2) add(b *fp2) *fp2 {
+	re := new(big.Int).Add(a.re, b.re)
+	im := new(big.Int).Add(a.im, b.im)
+	return &fp2{re.Mod(re, fieldPrime), im.Mod(im, fieldPrime)}
+}
+
+func (a *fp2) sub(b *fp2) *fp2 {
+	return a.add(b.neg())
+}
+
+func (a *fp2) neg() *fp2 {
+	re := new(big.Int).Neg(a.re)
+	im := new(big.Int).Neg(a.im)
+	return &fp2{re.Mod(re, fieldPrime), im.Mod(im, fieldPrime)}
+}
+
+func (a *fp2) mul(b *fp2) *fp2 {
+	re := new(big.Int).Mul(a.re, b.re)
+	re.Sub(re, new(big.Int).Mul(a.im, b.im))
+	im := new(big.Int).Mul(a.re, b.im)
+	im.Add(im, new(big.Int).Mul(a.im, b.re))
+	return &fp2{re.Mod(re, fieldPrime), im.Mod(im, fieldPrime)}
+}
+
+func (a *fp2) exp(k *big.Int) *fp2 {
+	result := &fp2{big.NewInt(1), big.NewInt(0)}
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		result = result.mul(result)
+		if k.Bit(i) == 1 {
+			result = result.mul(a)
+		}
+	}
+	return result
+}
+
+func (a *fp2) equal(b *fp2) bool {
+	return a.re.Cmp(b.re) == 0 && a.im.Cmp(b.im) == 0
+}
+
+// parity is the parity of re, or of im if re is zero. It distinguishes a
+// non-zero element from its negation.
+func (a *fp2) parity() byte {
+	if a.re.Sign() != 0 {
+		return byte(a.re.Bit(0))
+	}
+	return byte(a.im.Bit(0))
+}
+
+// sqrt returns a square root of a, or nil if there is none. Since p = 3 mod 4,
+// this is Algorithm 9 of Adj and Rodríguez-Henríquez, "Square root computation
+// over even extension fields".
+func (a *fp2) sqrt() *fp2 {
+	minusOne := &fp2{new(big.Int).Sub(fieldPrime, big.NewInt(1)), big.NewInt(0)}
+	exponent := new(big.Int).Rsh(new(big.Int).Sub(fieldPrime, big.NewInt(3)), 2)
+	a1 := a.exp(exponent)
+	alpha := a1.mul(a1).mul(a)
+	conjugate := &fp2{alpha.re, new(big.Int).Mod(new(big.Int).Neg(alpha.im), fieldPrime)}
+	if conjugate.mul(alpha).equal(minusOne) {
+		return nil
+	}
+
+	x0 := a1.mul(a)
+	var x *fp2
+	if alpha.equal(minusOne) {
+		x = (&fp2{big.NewInt(0), big.NewInt(1)}).mul(x0)
+	} else {
+		half := new(big.Int).Rsh(new(big.Int).Sub(fieldPrime, big.NewInt(1)), 1)
+		x = alpha.add(&fp2{big.NewInt(1), big.NewInt(0)}).exp(half).mul(x0)
+	}
+	if !x.mul(x).equal(a) {
+		return nil
+	}
+	return x
+}
+
+// twistB is the constant term of the curve equation y^2 = x^3 + b' of G2,
+// recovered from the generator.
+var twistB = func() *fp2 {
+	m := new(bn256.G2).ScalarBaseMult(big.NewInt(1)).Marshal()
+	x, y := fp2FromBytes(m[:64]), fp2FromBytes(m[64:])
+	return y.mul(y).sub(x.mul(x).mul(x))
+}()
+
+func (params *Params) marshalCompressed() []byte {
+	w := &pointWriter{buf: []byte{compressedTag}}
+	if params.Anonymous() {
+		w.buf[0] = compressedAnonymousTag
+	}
+	w.g2(params.G)
+	w.g2(params.G1)
+	w.g1(params.G2)
+	w.g1(params.G3)
+	for _, hi := range params.H {
+		w.g1(hi)
+	}
+	if params.Anonymous() {
+		w.g2(params.G3Hat)
+		for _, hi := range params.HHat {
+			w.g2(hi)
+		}
+	}
+	return w.buf
+}
+
+func (params *Params) unmarshalCompressed(encoded []byte) (*Params, bool) {
+	anonymous := encoded[0] == compressedAnonymousTag
+	body := len(encoded) - 1 - 2*compressedG2Size - 2*compressedG1Size
+	perLevel := compressedG1Size
+	if anonymous {
+		body -= compressedG2Size
+		perLevel += compressedG2Size
+	}
+	if body < 0 || body%perLevel != 0 {
+		return nil, false
+	}
+	hlen := body / perLevel
+
+	r := &pointReader{buf: encoded[1:], ok: true}
+	params.G = r.g2()
+	params.G1 = r.g2()
+	params.G2 = r.g1()
+	params.G3 = r.g1()
+	params.H = make([]*bn256.G1, hlen)
+	for i := range params.H {
+		params.H[i] = r.g1()
+	}
+	params.G3Hat, params.HHat = nil, nil
+	if anonymous {
+		params.G3Hat = r.g2()
+		params.HHat = make([]*bn256.G2, hlen)
+		for i := range params.HHat {
+			params.HHat[i] = r.g2()
+		}
+	}
+	params.Pairing, params.tables = nil, nil
+
+	if !r.done() || params.Validate() != nil {
+		return nil, false
+	}
+	return params, true
+}
+
+func (key *PrivateKey) marshalCompressed() []byte {
+	w := &pointWriter{buf: []byte{compressedTag}}
+	w.g1(key.A0)
+	if key.A1Hat != nil {
+		w.g1(key.A1Hat)
+		return w.buf
+	}
+	w.g2(key.A1)
+	for _, bi := range key.B {
+		w.g1(bi)
+	}
+	return w.buf
+}
+
+func (key *PrivateKey) unmarshalCompressed(encoded []byte) (*PrivateKey, bool) {
+	r := &pointReader{buf: encoded[1:], ok: true}
+	key.A0 = r.g1()
+	key.A1, key.A1Hat, key.B = nil, nil, nil
+	if len(encoded) == 1+2*compressedG1Size {
+		key.A1Hat = r.g1()
+	} else {
+		body := len(encoded) - 1 - compressedG1Size - compressedG2Size
+		if body < 0 || body%compressedG1Size != 0 {
+			return nil, false
+		}
+		key.A1 = r.g2()
+		key.B = make([]*bn256.G1, body/compressedG1Size)
+		for i := range key.B {
+			key.B[i] = r.g1()
+		}
+	}
+
+	if !r.done() || key.validatePoints() != nil {
+		return nil, false
+	}
+	return key, true
+}
+
+func (ciphertext *Ciphertext) marshalCompressed() []byte {
+	w := &pointWriter{buf: []byte{compressedTag}}
+	w.gt(ciphertext.A)
+	w.g2(ciphertext.B)
+	if ciphertext.CHat != nil {
+		w.g2(ciphertext.CHat)
+	} else {
+		w.g1(ciphertext.C)
+	}
+	return w.buf
+}
+
+func (ciphertext *Ciphertext) unmarshalCompressed(encoded []byte) (*Ciphertext, bool) {
+	r := &pointReader{buf: encoded[1:], ok: true}
+	ciphertext.A = r.gt()
+	ciphertext.B = r.g2()
+	ciphertext.C, ciphertext.CHat = nil, nil
+	switch len(encoded) {
+	case 1 + gtSize + compressedG2Size + compressedG1Size:
+		ciphertext.C = r.g1()
+	case 1 + gtSize + 2*compressedG2Size:
+		ciphertext.CHat = r.g2()
+	default:
+		return nil, false
+	}
+
+	if !r.done() || ciphertext.Validate() != nil {
+		return nil, false
+	}
+	return ciphertext, true
+}
+
+func (signature *Signature) marshalCompressed() []byte {
+	w := &pointWriter{buf: []byte{compressedTag}}
+	w.g1(signature.A0)
+	w.g2(signature.A1)
+	return w.buf
+}
+
+func (signature *Signature) unmarshalCompressed(encoded []byte) (*Signature, bool) {
+	r := &pointReader{buf: encoded[1:], ok: true}
+	signature.A0 = r.g1()
+	signature.A1 = r.g2()
+	if !r.done() {
+		return nil, false
+	}
+	return signature, true
+}
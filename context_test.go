@@ -0,0 +1,41 @@
+package hibe_sm9
+
+import (
+	"context"
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func TestContextCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, _, err := SetupContext(ctx, rand.Reader, 50); err != context.Canceled {
+		t.Fatal("SetupContext ignored a cancelled context")
+	}
+	if _, _, err := SetupContext(ctx, rand.Reader, 50, WithAnonymity()); err != context.Canceled {
+		t.Fatal("SetupContext ignored a cancelled context in anonymous mode")
+	}
+
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := [][]*big.Int{LINEAR_HIERARCHY[:1], LINEAR_HIERARCHY[:2]}
+	if _, err = EncryptMultiContext(ctx, rand.Reader, params, ids, NewMessage()); err != context.Canceled {
+		t.Fatal("EncryptMultiContext ignored a cancelled context")
+	}
+	keys, errs := KeyGenBatchContext(ctx, rand.Reader, params, master, ids, 2)
+	for i := range ids {
+		if keys[i] != nil || errs[i] != context.Canceled {
+			t.Fatal("KeyGenBatchContext ignored a cancelled context")
+		}
+	}
+
+	// An open context does not change the results
+	keys, errs = KeyGenBatchContext(context.Background(), rand.Reader, params, master, ids, 2)
+	if errs[0] != nil || errs[1] != nil || keys[1].Validate(params) != nil {
+		t.Fatal("KeyGenBatchContext failed with an open context")
+	}
+}
@@ -1,10 +1,13 @@
 package hibe_sm9
 
 import (
+	"bytes"
+	"context"
 	"crypto/rand"
 	"golang.org/x/crypto/bn256"
 	"io"
 	"math/big"
+	"time"
 )
 
 // Params represents the system parameters for a hierarchy.
@@ -15,8 +18,22 @@ type Params struct {
 	G3 *bn256.G1
 	H  []*bn256.G1
 
+	// Mirrors of g3 and h1 ... hl in G2, used instead of g3 and h1 ... hl to
+	// encrypt in anonymous hierarchies. They are nil otherwise.
+	G3Hat *bn256.G2
+	HHat  []*bn256.G2
+
+	// IdentityHash is the hash function with which HashID maps byte
+	// identities onto Zp in this hierarchy.
+	IdentityHash IdentityHash
+
+	// HSeed is the seed from which h1 ... hl are derived (see WithDerivedH),
+	// or nil if they were chosen at random.
+	HSeed []byte
+
 	// Some cached state
 	Pairing *bn256.GT
+	tables  *precomputed
 }
 
 // MasterKey represents the key for a hierarchy that can create a key for any
@@ -36,6 +53,21 @@ type PrivateKey struct {
 	A0 *bn256.G1
 	A1 *bn256.G2
 	B  []*bn256.G1
+
+	// Keys in anonymous hierarchies have A1Hat in G1 instead of A1.
+	A1Hat *bn256.G1
+
+	// Restrictions on delegation, or nil if the key is unrestricted.
+	Policy *DelegationPolicy
+
+	// Fingerprint of the parameters of the hierarchy the key belongs to, or
+	// nil if it is unknown.
+	ParamsFingerprint []byte
+
+	// Number of levels below the key in its hierarchy if Restrict dropped
+	// some of its delegation components, and 0 otherwise. Like Policy, it is
+	// not part of the key encodings.
+	levels int
 }
 
 // Ciphertext represents an encrypted message.
@@ -43,6 +75,16 @@ type Ciphertext struct {
 	A *bn256.GT
 	B *bn256.G2
 	C *bn256.G1
+
+	// Ciphertexts in anonymous hierarchies have CHat in G2 instead of C.
+	CHat *bn256.G2
+
+	// Tag is set for ciphertexts encrypted with WithIntegrityTag.
+	Tag []byte
+
+	// Fingerprint of the parameters the ciphertext was encrypted under, or
+	// nil if it is unknown.
+	ParamsFingerprint []byte
 }
 
 // DepthLeft returns the maximum depth of descendants in the hierarchy whose
@@ -51,13 +93,57 @@ func (privkey *PrivateKey) DepthLeft() int {
 	return len(privkey.B)
 }
 
+// SetupOption configures optional features of a hierarchy at Setup time.
+type SetupOption func(*setupConfig)
+
+type setupConfig struct {
+	anonymous    bool
+	derivedH     bool
+	identityHash IdentityHash
+}
+
+// WithAnonymity makes Setup create an anonymous hierarchy, whose ciphertexts
+// do not reveal the identity they were encrypted for. See setupAnonymous for
+// the restrictions that come with it.
+func WithAnonymity() SetupOption {
+	return func(config *setupConfig) {
+		config.anonymous = true
+	}
+}
+
 // Setup generates the system parameters, (hich may be made visible to an
 // adversary. The parameter "l" is the maximum depth that the hierarchy will
 // support.
-func Setup(random io.Reader, l int) (*Params, MasterKey, error) {
+//
+// Like key generation and encryption, Setup uses crypto/rand if random is
+// nil, and fails rather than continue if random returns short reads or
+// constant output.
+func Setup(random io.Reader, l int, opts ...SetupOption) (*Params, MasterKey, error) {
+	return SetupContext(context.Background(), random, l, opts...)
+}
+
+// SetupContext is like Setup, but gives up with ctx.Err() once ctx is done.
+// The context is checked between the scalar multiplications, which matters
+// for deep hierarchies.
+func SetupContext(ctx context.Context, random io.Reader, l int, opts ...SetupOption) (_ *Params, _ MasterKey, err error) {
+	defer observe(OpSetup, time.Now(), &err)
+	random = randomSource(random)
+	config := &setupConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+	if !config.identityHash.valid() {
+		return nil, nil, errIdentityHash
+	}
+	if config.derivedH && config.anonymous {
+		return nil, nil, errHSeedAnonymous
+	}
+	if config.derivedH && l > maxDerivedDepth {
+		return nil, nil, ErrDepthExceeded
+	}
+
 	// 1.
-	params := &Params{}
-	var err error
+	params := &Params{IdentityHash: config.identityHash}
 
 	// The algorithm technically needs g to be a generator of G, but since G is
 	// isomorphic to Zp, any element in G is technically a generator. So, we
@@ -72,9 +158,13 @@ func Setup(random io.Reader, l int) (*Params, MasterKey, error) {
 	if err != nil {
 		return nil, nil, err
 	}
+	defer zeroizeScalar(alpha)
 
 	// Choose g1 = g ^ alpha.
-	params.G1 = new(bn256.G2).ScalarMult(params.G, alpha)
+	params.G1, err = secretMultG2(params.G, alpha)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	// Randomly choose g2 and g3.
 	_, params.G2, err = bn256.RandomG1(random)
@@ -86,9 +176,22 @@ func Setup(random io.Reader, l int) (*Params, MasterKey, error) {
 		return nil, nil, err
 	}
 
-	// Randomly choose h1 ... hl.
+	// Randomly choose h1 ... hl, or a seed to derive them from.
+	if config.derivedH {
+		params.HSeed = make([]byte, HSeedSize)
+		if _, err = io.ReadFull(random, params.HSeed); err != nil {
+			return nil, nil, err
+		}
+	}
 	params.H = make([]*bn256.G1, l, l)
 	for i := range params.H {
+		if err = ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		if params.HSeed != nil {
+			params.H[i] = deriveH(params.HSeed, i, i+1)[0]
+			continue
+		}
 		_, params.H[i], err = bn256.RandomG1(random)
 		if err != nil {
 			return nil, nil, err
@@ -96,43 +199,98 @@ func Setup(random io.Reader, l int) (*Params, MasterKey, error) {
 	}
 
 	// Compute the master key as g2 ^ alpha.
-	master := new(bn256.G1).ScalarMult(params.G2, alpha)
+	master, err := secretMultG1(params.G2, alpha)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if config.anonymous {
+		if err = setupAnonymous(ctx, random, params, master); err != nil {
+			return nil, nil, err
+		}
+	}
 
 	return params, master, nil
 }
 
 // KeyGenFromMaster generates a key for an ID using the master key.
-func KeyGenFromMaster(random io.Reader, params *Params, master MasterKey, id []*big.Int) (*PrivateKey, error) {
+func KeyGenFromMaster(random io.Reader, params *Params, master MasterKey, id []*big.Int, opts ...KeyGenOption) (_ *PrivateKey, err error) {
+	defer observe(OpKeyGen, time.Now(), &err)
 	// 1. 私钥的三个参数是什么意思
 	// 2. id []*big.Int 就是身份id ，用数组表达身份标识的原因
 	// 3. r的作用，加噪?
 	// 4. ScalarMult 功能是椭圆曲线的乘法，需要找到SM9的实现中对应的函数是什么 ，可能是WrapKey
 	// 5. 终极目标：给一个实际的案例，参数赋值后，然后怎么计算
-	key := &PrivateKey{}
+	if err := checkID(params, id); err != nil {
+		return nil, err
+	}
+	random = randomSource(random)
+	if params.Anonymous() {
+		if err := checkIssue(id, opts); err != nil {
+			return nil, err
+		}
+		return keyGenAnonymous(random, params, master, id)
+	}
+	return KeyGenFromMasterOp(random, params, SoftwareMasterKey{master}, id, opts...)
+}
+
+// KeyGenFromMasterOp is like KeyGenFromMaster, but the master key is only
+// used through op, so that it can be kept in an HSM. Anonymous hierarchies
+// need the master key itself, and are only supported with SoftwareMasterKey.
+func KeyGenFromMasterOp(random io.Reader, params *Params, op MasterKeyOp, id []*big.Int, opts ...KeyGenOption) (*PrivateKey, error) {
+	if err := checkID(params, id); err != nil {
+		return nil, err
+	}
+	if err := checkIssue(id, opts); err != nil {
+		return nil, err
+	}
+	random = randomSource(random)
+	if params.Anonymous() {
+		software, ok := op.(SoftwareMasterKey)
+		if !ok {
+			return nil, errMasterKeyOpAnonymous
+		}
+		return keyGenAnonymous(random, params, software.Key, id)
+	}
+
+	key := &PrivateKey{ParamsFingerprint: params.Fingerprint()}
 	k := len(id)
 	l := len(params.H)
-	if k > l {
-		panic("Cannot generate key at greater than maximum depth.")
-	}
 
 	// Randomly choose r in Zp.
 	r, err := rand.Int(random, bn256.Order)
 	if err != nil {
 		return nil, err
 	}
+	defer zeroizeScalar(r)
 
-	product := deepClone(params.G3)
-	for i := 0; i != k; i++ {
-		h := new(bn256.G1).ScalarMult(params.H[i], id[i])
-		product.Add(product, h)
+	product, err := idProductPower(params, id, r)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroizeG1(product)
+	if debug {
+		trace("keygen.product", product)
 	}
-	product.ScalarMult(product, r)
 
-	key.A0 = new(bn256.G1).Add(master, product)
-	key.A1 = new(bn256.G2).ScalarMult(params.G, r)
-	key.B = make([]*bn256.G1, l-k)
-	for j := 0; j != l-k; j++ {
-		key.B[j] = new(bn256.G1).ScalarMult(params.H[k+j], r)
+	key.A0, err = op.AddMaster(product)
+	if err != nil {
+		return nil, err
+	}
+	key.A1, err = powerG(params, r)
+	if err != nil {
+		return nil, err
+	}
+	delegable := l - k
+	if keyGenOptions(opts).leafOnly {
+		delegable = 0
+	}
+	key.B = make([]*bn256.G1, delegable)
+	for j := range key.B {
+		key.B[j], err = powerH(params, k+j, r)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return key, nil
@@ -140,95 +298,254 @@ func KeyGenFromMaster(random io.Reader, params *Params, master MasterKey, id []*
 
 // KeyGenFromParent generates a key for an ID using the private key of the
 // parent of ID in the hierarchy. Using a different parent will result in
-// undefined behavior.
-func KeyGenFromParent(random io.Reader, params *Params, parent *PrivateKey, id []*big.Int) (*PrivateKey, error) {
-	key := &PrivateKey{}
+// undefined behavior. If the parent is restricted by a DelegationPolicy, the
+// child inherits it, and ErrDelegationDenied is returned if the policy does not
+// allow the child. Keys in anonymous hierarchies cannot be delegated.
+func KeyGenFromParent(random io.Reader, params *Params, parent *PrivateKey, id []*big.Int, opts ...KeyGenOption) (_ *PrivateKey, err error) {
+	defer observe(OpKeyGen, time.Now(), &err)
+	if err := checkID(params, id); err != nil {
+		return nil, err
+	}
+	if params.Anonymous() {
+		return nil, errAnonymousDelegation
+	}
+	random = randomSource(random)
+	if err := checkBinding(parent.ParamsFingerprint, params.Fingerprint()); err != nil {
+		return nil, err
+	}
 	k := len(id)
-	l := len(params.H)
-	if k > l {
-		panic("Cannot generate key at greater than maximum depth")
+	if !parent.isParentAtDepth(params, k-1) {
+		return nil, errNotParent
+	}
+	return delegate(random, params, parent, id, opts, func(t *big.Int) (*bn256.G1, error) {
+		return secretMultG1(idProduct(params, id), t)
+	}, func() *bn256.G1 {
+		return new(bn256.G1).ScalarMult(parent.B[0], id[k-1])
+	})
+}
+
+// delegate generates the key for id from the key of its parent, which has
+// been checked to be at the right depth. It is given (g3 * h1^id1 * ... *
+// hk^idk)^t for the randomness t, and b1^idk for the first delegation
+// component b1 of the parent, so that Delegator can compute them faster.
+func delegate(random io.Reader, params *Params, parent *PrivateKey, id []*big.Int, opts []KeyGenOption, productPower func(*big.Int) (*bn256.G1, error), lastPower func() *bn256.G1) (*PrivateKey, error) {
+	if parent.DepthLeft() == 0 || !parent.Policy.allows(id) {
+		return nil, ErrDelegationDenied
 	}
-	if parent.DepthLeft() != l-k+1 {
-		panic("Trying to generate key at depth that is not the child of the provided parent")
+	if err := checkIssue(id, opts); err != nil {
+		return nil, err
 	}
+	key := &PrivateKey{ParamsFingerprint: params.Fingerprint(), Policy: parent.Policy}
+	k := len(id)
 
 	// Randomly choose t in Zp
 	t, err := rand.Int(random, bn256.Order)
 	if err != nil {
 		return nil, err
 	}
+	defer zeroizeScalar(t)
 
-	product := deepClone(params.G3)
-	for i := 0; i != k; i++ {
-		h := new(bn256.G1).ScalarMult(params.H[i], id[i])
-		product.Add(product, h)
+	product, err := productPower(t)
+	if err != nil {
+		return nil, err
 	}
-	product.ScalarMult(product, t)
+	defer zeroizeG1(product)
 
-	bpower := new(bn256.G1).ScalarMult(parent.B[0], id[k-1])
+	bpower := lastPower()
+	defer zeroizeG1(bpower)
 
 	key.A0 = new(bn256.G1).Add(parent.A0, bpower)
 	key.A0.Add(key.A0, product)
 
-	key.A1 = new(bn256.G2).ScalarMult(params.G, t)
+	key.A1, err = powerG(params, t)
+	if err != nil {
+		return nil, err
+	}
 	key.A1.Add(parent.A1, key.A1)
 
-	key.B = make([]*bn256.G1, l-k)
-	for j := 0; j != l-k; j++ {
-		key.B[j] = new(bn256.G1).ScalarMult(params.H[k+j], t)
+	// A restricted parent passes on fewer delegation components than l-k
+	delegable := parent.DepthLeft() - 1
+	if keyGenOptions(opts).leafOnly {
+		delegable = 0
+	}
+	key.B = make([]*bn256.G1, delegable)
+	for j := range key.B {
+		key.B[j], err = powerH(params, k+j, t)
+		if err != nil {
+			return nil, err
+		}
 		key.B[j].Add(parent.B[j+1], key.B[j])
 	}
+	if parent.levels != 0 {
+		key.levels = parent.levels - 1
+	}
+	recordDelegation(params, parent, key, id, opts)
 
 	return key, nil
 }
 
+// idProduct computes g3 * h1^id1 * ... * hk^idk, the element of G1 that the
+// identity id maps to.
+func idProduct(params *Params, id []*big.Int) *bn256.G1 {
+	if len(id) >= multiExpThreshold {
+		return multiExpG1(params.G3, params.H[:len(id)], id)
+	}
+	product := deepClone(params.G3)
+	h := new(bn256.G1)
+	for i := range id {
+		product.Add(product, h.ScalarMult(params.H[i], id[i]))
+	}
+	return product
+}
+
 // Precache forces "cached params" to be computed. Normally, they are computed
 // on the fly, but that is not thread-safe. If you plan to call functions
 // (especially Encrypt) multiple times concurrently, you should call this first,
 // to eliminate race conditions.
 func (params *Params) Precache() {
 	if params.Pairing == nil {
-		params.Pairing = bn256.Pair(params.G2, params.G1)
+		params.Pairing = pair(params.G2, params.G1)
 	}
+	// Marshal normalizes the points it encodes, so do it once here rather
+	// than concurrently from Fingerprint
+	params.Fingerprint()
 }
 
 // Encrypt converts the provided message to ciphertext, using the provided ID
 // as the public key.
-func Encrypt(random io.Reader, params *Params, id []*big.Int, message *bn256.GT) (*Ciphertext, error) {
-	ciphertext := &Ciphertext{}
-	k := len(id)
+func Encrypt(random io.Reader, params *Params, id []*big.Int, message *bn256.GT, opts ...EncryptOption) (_ *Ciphertext, err error) {
+	defer observe(OpEncrypt, time.Now(), &err)
+	if err := checkID(params, id); err != nil {
+		return nil, err
+	}
+	if encryptor := encryptOptions(opts).encryptor; encryptor != nil {
+		if encryptor.params != params || len(encryptor.id) != len(id) || !idsAgree(encryptor.id, id) {
+			return nil, errPrecomputedTarget
+		}
+		return encryptor.Encrypt(random, message, opts...)
+	}
+	return encrypt(random, params, message, opts, func(ciphertext *Ciphertext, s *big.Int) (err error) {
+		if params.Anonymous() {
+			ciphertext.CHat, err = idProductHatPower(params, id, s)
+		} else {
+			ciphertext.C, err = idProductPower(params, id, s)
+		}
+		return err
+	})
+}
+
+// encrypt is Encrypt for a checked identity, whose component of the
+// ciphertext for the random s is set by identityPower.
+func encrypt(random io.Reader, params *Params, message *bn256.GT, opts []EncryptOption, identityPower func(*Ciphertext, *big.Int) error) (*Ciphertext, error) {
+	random = randomSource(random)
+	config := encryptOptions(opts)
+	if config.anonymous && !params.Anonymous() {
+		return nil, errNotAnonymous
+	}
+	ciphertext := &Ciphertext{ParamsFingerprint: params.Fingerprint()}
 
 	// Randomly choose s in Zp
 	s, err := rand.Int(random, bn256.Order)
 	if err != nil {
 		return nil, err
 	}
+	defer zeroizeScalar(s)
 
 	if params.Pairing == nil {
-		params.Pairing = bn256.Pair(params.G2, params.G1)
+		params.Pairing = pair(params.G2, params.G1)
 	}
 
-	ciphertext.A = new(bn256.GT)
-	ciphertext.A.ScalarMult(params.Pairing, s)
-	ciphertext.A.Add(ciphertext.A, message)
+	mask, err := powerPairing(params, s)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroizeGT(mask)
+	if debug {
+		trace("encrypt.mask", mask)
+	}
+	ciphertext.A = new(bn256.GT).Add(mask, message)
 
-	ciphertext.B = new(bn256.G2).ScalarMult(params.G, s)
+	ciphertext.B, err = powerG(params, s)
+	if err != nil {
+		return nil, err
+	}
 
-	ciphertext.C = deepClone(params.G3)
-	for i := 0; i != k; i++ {
-		h := new(bn256.G1).ScalarMult(params.H[i], id[i])
-		ciphertext.C.Add(ciphertext.C, h)
+	if err = identityPower(ciphertext, s); err != nil {
+		return nil, err
 	}
-	ciphertext.C.ScalarMult(ciphertext.C, s)
 
+	if config.tag {
+		ciphertext.Tag = integrityTag(mask, ciphertext)
+	}
 	return ciphertext, nil
 }
 
 // Decrypt recovers the original message from the provided ciphertext, using
-// the provided private key.
-func Decrypt(key *PrivateKey, ciphertext *Ciphertext) *bn256.GT {
-	plaintext := bn256.Pair(ciphertext.C, key.A1)
-	invdenominator := new(bn256.GT).Neg(bn256.Pair(key.A0, ciphertext.B))
+// the provided private key. If the ciphertext has an integrity tag (see
+// WithIntegrityTag), it is checked, and a mismatch, whether from corruption or
+// the wrong key, is reported as ErrDecryptFailed. Without a tag, a corrupted
+// ciphertext decrypts to an unrelated element of GT; WithTagRequired rejects
+// such ciphertexts instead.
+func Decrypt(key *PrivateKey, ciphertext *Ciphertext, opts ...DecryptOption) (_ *bn256.GT, err error) {
+	defer observe(OpDecrypt, time.Now(), &err)
+	if err := checkDecrypt(key, ciphertext, decryptOptions(opts)); err != nil {
+		return nil, err
+	}
+	plaintext := decrypt(key, ciphertext)
+	if err := checkIntegrity(plaintext, ciphertext); err != nil {
+		zeroizeGT(plaintext)
+		return nil, err
+	}
+	return plaintext, nil
+}
+
+// DecryptWithParams is like Decrypt, but first checks that the ciphertext is
+// well formed for id in the hierarchy with the provided parameters, by
+// verifying e(C, g) = e(g3 * h1^I1 * ... * hk^Ik, B). A ciphertext that was
+// mauled, or encrypted to another identity, is rejected with
+// ErrMalformedCiphertext rather than decrypted to an unrelated element of GT.
+// The check costs two pairings.
+//
+// Ciphertexts in anonymous hierarchies cannot be checked, since that would
+// reveal their identity, and are rejected.
+func DecryptWithParams(params *Params, key *PrivateKey, id []*big.Int, ciphertext *Ciphertext) (*bn256.GT, error) {
+	if err := checkID(params, id); err != nil {
+		return nil, err
+	}
+	if params.Anonymous() || ciphertext.C == nil {
+		return nil, errCheckAnonymous
+	}
+	if err := checkBinding(params.Fingerprint(), ciphertext.ParamsFingerprint); err != nil {
+		return nil, err
+	}
+	if !key.isKeyAtDepth(params, len(id)) {
+		return nil, wrapError(ErrInvalidID, "hibe: private key does not match the identity")
+	}
+	if err := ciphertext.Validate(); err != nil {
+		return nil, err
+	}
+	left := pair(ciphertext.C, params.G)
+	right := pair(idProduct(params, id), ciphertext.B)
+	if !bytes.Equal(left.Marshal(), right.Marshal()) {
+		return nil, errCiphertextRelation
+	}
+	return Decrypt(key, ciphertext)
+}
+
+// decrypt is Decrypt without the integrity check.
+func decrypt(key *PrivateKey, ciphertext *Ciphertext) *bn256.GT {
+	var plaintext *bn256.GT
+	if ciphertext.CHat != nil {
+		plaintext = pair(key.A1Hat, ciphertext.CHat)
+	} else {
+		plaintext = pair(ciphertext.C, key.A1)
+	}
+	denominator := pair(key.A0, ciphertext.B)
+	if debug {
+		trace("decrypt.numerator", plaintext)
+		trace("decrypt.denominator", denominator)
+	}
+	invdenominator := new(bn256.GT).Neg(denominator)
 	plaintext.Add(plaintext, invdenominator)
 	plaintext.Add(ciphertext.A, plaintext)
 	return plaintext
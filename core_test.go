@@ -3,6 +3,7 @@ package hibe_sm9
 import (
 	"bytes"
 	"crypto/rand"
+	"errors"
 	"golang.org/x/crypto/bn256"
 	"io"
 	"math/big"
@@ -15,6 +16,16 @@ func NewMessage() *bn256.GT {
 	return bn256.Pair(new(bn256.G1).ScalarBaseMult(big.NewInt(3)), new(bn256.G2).ScalarBaseMult(big.NewInt(5)))
 }
 
+// mustDecrypt is Decrypt for ciphertexts that are expected to decrypt.
+func mustDecrypt(t testing.TB, key *PrivateKey, ciphertext *Ciphertext) *bn256.GT {
+	t.Helper()
+	plaintext, err := Decrypt(key, ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return plaintext
+}
+
 func NewRandomMessage(random io.Reader) (*bn256.GT, error) {
 	_, g1, err := bn256.RandomG1(random)
 	if err != nil {
@@ -54,7 +65,7 @@ func TestTopLevel(t *testing.T) {
 	}
 
 	// Decrypt ciphertext with key and check that it is correct
-	decrypted := Decrypt(toplevelkey, ciphertext)
+	decrypted := mustDecrypt(t, toplevelkey, ciphertext)
 	if !bytes.Equal(message.Marshal(), decrypted.Marshal()) {
 		t.Fatal("Original and decrypted messages differ")
 	}
@@ -86,7 +97,7 @@ func TestSecondLevelFromMaster(t *testing.T) {
 		t.Fatal("Depth remaining on key is incorrect")
 	}
 
-	decrypted := Decrypt(secondlevelkey, ciphertext)
+	decrypted := mustDecrypt(t, secondlevelkey, ciphertext)
 	if !bytes.Equal(message.Marshal(), decrypted.Marshal()) {
 		t.Fatal("Original and decrypted messages differ")
 	}
@@ -124,12 +135,64 @@ func TestSecondLevelFromParent(t *testing.T) {
 		t.Fatal("Depth remaining on key is incorrect")
 	}
 
-	decrypted := Decrypt(secondlevelkey, ciphertext)
+	decrypted := mustDecrypt(t, secondlevelkey, ciphertext)
 	if !bytes.Equal(message.Marshal(), decrypted.Marshal()) {
 		t.Fatal("Original and decrypted messages differ")
 	}
 }
 
+func TestDecryptWithParams(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := NewMessage()
+	ciphertext, err := Encrypt(rand.Reader, params, LINEAR_HIERARCHY, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decrypted, err := DecryptWithParams(params, key, LINEAR_HIERARCHY, ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(message.Marshal(), decrypted.Marshal()) {
+		t.Fatal("Original and decrypted messages differ")
+	}
+
+	// Encrypted to a sibling
+	sibling := []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(4)}
+	other, err := Encrypt(rand.Reader, params, sibling, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = DecryptWithParams(params, key, LINEAR_HIERARCHY, other); !errors.Is(err, ErrMalformedCiphertext) {
+		t.Fatal("Ciphertext for another identity was decrypted")
+	}
+
+	// Mauled
+	_, mauled, err := bn256.RandomG1(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext.C = mauled
+	if _, err = DecryptWithParams(params, key, LINEAR_HIERARCHY, ciphertext); !errors.Is(err, ErrMalformedCiphertext) {
+		t.Fatal("Mauled ciphertext was decrypted")
+	}
+
+	anonymous, _, err := Setup(rand.Reader, 3, WithAnonymity())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = DecryptWithParams(anonymous, key, LINEAR_HIERARCHY, other); !errors.Is(err, ErrMalformedCiphertext) {
+		t.Fatal("Anonymous parameters were accepted")
+	}
+}
+
 func BenchmarkSetup(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		_, _, err := Setup(rand.Reader, 10)
@@ -271,8 +334,11 @@ func BenchmarkDecryptWithKeyGeneratedFromMaster(b *testing.B) {
 			b.Fatal(err)
 		}
 		b.StartTimer()
-		decrypted := Decrypt(thirdlevelkey, ciphertext)
+		decrypted, err := Decrypt(thirdlevelkey, ciphertext)
 		b.StopTimer()
+		if err != nil {
+			b.Fatal(err)
+		}
 		if !bytes.Equal(message.Marshal(), decrypted.Marshal()) {
 			b.Fatal("Original and decrypted messages differ")
 		}
@@ -316,8 +382,11 @@ func BenchmarkDecryptWithKeyGeneratedFromParent(b *testing.B) {
 			b.Fatal(err)
 		}
 		b.StartTimer()
-		decrypted := Decrypt(thirdlevelkey, ciphertext)
+		decrypted, err := Decrypt(thirdlevelkey, ciphertext)
 		b.StopTimer()
+		if err != nil {
+			b.Fatal(err)
+		}
 		if !bytes.Equal(message.Marshal(), decrypted.Marshal()) {
 			b.Fatal("Original and decrypted messages differ")
 		}
@@ -0,0 +1,39 @@
+package hibe_sm9
+
+import (
+	"errors"
+	"fmt"
+)
+
+// CurveID identifies the pairing-friendly curve a hierarchy is built on.
+type CurveID uint8
+
+const (
+	// CurveBN256 is the 256-bit Barreto-Naehrig curve of
+	// golang.org/x/crypto/bn256. Its security level is now estimated to be
+	// well below 128 bits.
+	CurveBN256 CurveID = 1
+)
+
+// ErrUnsupportedCurve is returned when an encoding names a curve that has no
+// backend in this build.
+var ErrUnsupportedCurve = errors.New("hibe: unsupported curve")
+
+func (id CurveID) String() string {
+	switch id {
+	case CurveBN256:
+		return "bn256"
+	default:
+		return fmt.Sprintf("CurveID(%d)", uint8(id))
+	}
+}
+
+// Supported returns true if this build has a backend for the curve.
+func (id CurveID) Supported() bool {
+	return id == CurveBN256
+}
+
+// Curve returns the curve the hierarchy is built on.
+func (params *Params) Curve() CurveID {
+	return CurveBN256
+}
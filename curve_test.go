@@ -0,0 +1,19 @@
+package hibe_sm9
+
+import (
+	"crypto/rand"
+	"testing"
+)
+
+func TestCurve(t *testing.T) {
+	params, _, err := Setup(rand.Reader, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Curve() != CurveBN256 || !params.Curve().Supported() {
+		t.Fatal("Parameters report the wrong curve")
+	}
+	if CurveID(2).Supported() {
+		t.Fatal("Curve without a backend is reported as supported")
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !hibe_debug
+
+package hibe_sm9
+
+// debug enables tracing of the intermediate values of the algorithms. It is
+// set by building with the hibe_debug tag.
+const debug = false
+
+// trace does nothing without the hibe_debug tag.
+func trace(step string, value interface{ Marshal() []byte }) {}
@@ -0,0 +1,43 @@
+//go:build hibe_debug
+
+package hibe_sm9
+
+import "sync"
+
+// debug enables tracing of the intermediate values of the algorithms. It is
+// set by building with the hibe_debug tag, which must never be used in
+// production: the values traced are as secret as the keys and messages they
+// are computed from.
+const debug = true
+
+var (
+	traceLock sync.Mutex
+	traceHook func(step string, value []byte)
+)
+
+// SetTrace installs a function that receives the encoding of each
+// intermediate value that the algorithms compute, named by step, so that an
+// implementation can be checked against the equations of Boneh, Boyen and
+// Goh one step at a time. The steps are
+//
+//	keygen.product       (g3 * h1^I1 * ... * hk^Ik)^r, in KeyGenFromMaster
+//	encrypt.mask         e(g2, g1)^s, which blinds the message, in Encrypt
+//	decrypt.numerator    e(C, A1), in Decrypt
+//	decrypt.denominator  e(A0, B), in Decrypt
+//
+// A nil hook disables tracing. SetTrace only exists in builds with the
+// hibe_debug tag.
+func SetTrace(hook func(step string, value []byte)) {
+	traceLock.Lock()
+	defer traceLock.Unlock()
+	traceHook = hook
+}
+
+// trace passes value to the hook installed with SetTrace, if any.
+func trace(step string, value interface{ Marshal() []byte }) {
+	traceLock.Lock()
+	defer traceLock.Unlock()
+	if traceHook != nil {
+		traceHook(step, value.Marshal())
+	}
+}
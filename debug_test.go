@@ -0,0 +1,29 @@
+//go:build hibe_debug
+
+package hibe_sm9
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	steps := make(map[string][]byte)
+	SetTrace(func(step string, value []byte) {
+		steps[step] = value
+	})
+	defer SetTrace(nil)
+	_, _, key, ciphertext := toyRun(t)
+	mustDecrypt(t, key, ciphertext)
+
+	for step, want := range map[string]interface{ Marshal() []byte }{
+		"keygen.product":      toyG1(836),
+		"encrypt.mask":        toyGT(870),
+		"decrypt.numerator":   toyGT(48488),
+		"decrypt.denominator": toyGT(49358),
+	} {
+		if !bytes.Equal(steps[step], want.Marshal()) {
+			t.Fatalf("%s does not match the paper", step)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package hibe_sm9
+
+import (
+	"crypto"
+	"errors"
+	"io"
+	"math/big"
+	"time"
+)
+
+// PublicKey is the public key of an identity in a hierarchy: the parameters
+// of the hierarchy and the identity itself.
+type PublicKey struct {
+	Params *Params
+	ID     []*big.Int
+}
+
+// Equal reports whether x is a PublicKey for the same identity in a hierarchy
+// with the same parameters.
+func (pub *PublicKey) Equal(x crypto.PublicKey) bool {
+	other, ok := x.(*PublicKey)
+	if !ok || len(other.ID) != len(pub.ID) {
+		return false
+	}
+	for i := range pub.ID {
+		if pub.ID[i].Cmp(other.ID[i]) != 0 {
+			return false
+		}
+	}
+	return string(pub.Params.Marshal()) == string(other.Params.Marshal())
+}
+
+// Encrypt encrypts plaintext for the identity with EncryptBytes.
+func (pub *PublicKey) Encrypt(random io.Reader, plaintext []byte) ([]byte, error) {
+	return EncryptBytes(random, pub.Params, pub.ID, plaintext)
+}
+
+// Decrypter wraps a private key as a crypto.Decrypter, for use with code that
+// accepts the standard interfaces. It decrypts ciphertexts produced by
+// EncryptBytes (or PublicKey.Encrypt).
+type Decrypter struct {
+	public PublicKey
+	key    *PrivateKey
+
+	auditor   Auditor
+	requester string
+}
+
+// NewDecrypter returns a crypto.Decrypter for the private key of id.
+func NewDecrypter(params *Params, key *PrivateKey, id []*big.Int) (*Decrypter, error) {
+	if !key.isKeyAtDepth(params, len(id)) {
+		return nil, wrapError(ErrInvalidID, "hibe: private key does not match the identity")
+	}
+	return &Decrypter{public: PublicKey{Params: params, ID: append([]*big.Int{}, id...)}, key: key}, nil
+}
+
+// SetAuditor makes the decrypter report every decryption to auditor, on
+// behalf of requester.
+func (decrypter *Decrypter) SetAuditor(auditor Auditor, requester string) {
+	decrypter.auditor = auditor
+	decrypter.requester = requester
+}
+
+// Public returns the *PublicKey of the identity.
+func (decrypter *Decrypter) Public() crypto.PublicKey {
+	return &decrypter.public
+}
+
+// Decrypt decrypts ciphertext with DecryptBytes. The random source is not
+// used, and opts must be nil, as there are no options.
+func (decrypter *Decrypter) Decrypt(random io.Reader, ciphertext []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	if opts != nil {
+		return nil, errors.New("hibe: Decrypter does not accept options")
+	}
+	plaintext, err := DecryptBytes(decrypter.key, ciphertext)
+	if decrypter.auditor != nil {
+		decrypter.auditor.OnDecrypt(decrypter.public.ID, decrypter.requester, time.Now(), err)
+	}
+	return plaintext, err
+}
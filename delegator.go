@@ -0,0 +1,114 @@
+package hibe_sm9
+
+import (
+	"golang.org/x/crypto/bn256"
+	"io"
+	"math/big"
+	"time"
+)
+
+// Delegator issues the keys for the children of one parent key, such as the
+// per-device keys below a user. It computes the product g3 * h1^I1 * ... *
+// hk^Ik of the parent's identity once, and builds fixed-base tables for it
+// and for the first delegation component of the parent, so that each child
+// costs a few table lookups instead of k+1 scalar multiplications. With
+// Params.Precompute, the remaining multiplications by g and h1 ... hl use
+// tables as well. Hardened builds do not use the table for the product,
+// whose scalar is secret, but still save computing it.
+//
+// A Delegator is safe for concurrent use, and its keys are distributed like
+// those of KeyGenFromParent. The parent key must not be modified or zeroized
+// while the Delegator is in use; Zeroize wipes both.
+type Delegator struct {
+	params *Params
+	parent *PrivateKey
+	id     []*big.Int
+
+	product *bn256.G1
+	table   g1Table
+	last    g1Table
+}
+
+// NewDelegator returns a Delegator for the children of parent, the key for
+// id. It precaches params (see Params.Precache), so it must not be called
+// concurrently with other uses of params.
+func NewDelegator(params *Params, parent *PrivateKey, id []*big.Int) (*Delegator, error) {
+	if err := checkID(params, id); err != nil {
+		return nil, err
+	}
+	if params.Anonymous() {
+		return nil, errAnonymousDelegation
+	}
+	params.Precache()
+	if err := checkBinding(parent.ParamsFingerprint, params.Fingerprint()); err != nil {
+		return nil, err
+	}
+	if !parent.isParentAtDepth(params, len(id)) {
+		return nil, errNotParent
+	}
+	if parent.DepthLeft() == 0 {
+		return nil, ErrDelegationDenied
+	}
+
+	delegator := &Delegator{
+		params:  params,
+		parent:  parent,
+		id:      append([]*big.Int{}, id...),
+		product: idProduct(params, id),
+		// The scalars for the first delegation component are the public
+		// identities of the children, so its table is used in hardened
+		// builds too
+		last: newG1Table(parent.B[0]),
+	}
+	if !hardened {
+		delegator.table = newG1Table(delegator.product)
+	}
+	return delegator, nil
+}
+
+// ID returns the identity of the parent key.
+func (delegator *Delegator) ID() []*big.Int {
+	return append([]*big.Int{}, delegator.id...)
+}
+
+// Delegate is KeyGenFromParent for the child of the parent key with the last
+// identity component child.
+func (delegator *Delegator) Delegate(random io.Reader, child *big.Int, opts ...KeyGenOption) (_ *PrivateKey, err error) {
+	defer observe(OpKeyGen, time.Now(), &err)
+	params := delegator.params
+	id := append(delegator.ID(), child)
+	if err := checkID(params, id); err != nil {
+		return nil, err
+	}
+	k := len(id)
+	return delegate(randomSource(random), params, delegator.parent, id, opts, func(t *big.Int) (*bn256.G1, error) {
+		if delegator.table == nil {
+			product := new(bn256.G1).ScalarMult(params.H[k-1], child)
+			return secretMultG1(product.Add(delegator.product, product), t)
+		}
+		exponent := new(big.Int).Mul(child, t)
+		defer zeroizeScalar(exponent)
+		product, err := powerH(params, k-1, exponent)
+		if err != nil {
+			return nil, err
+		}
+		return delegator.table.multAdd(product, t), nil
+	}, func() *bn256.G1 {
+		return delegator.last.mult(child)
+	})
+}
+
+// Zeroize overwrites the tables of the Delegator and the parent key, neither
+// of which must be used afterwards.
+func (delegator *Delegator) Zeroize() {
+	for _, table := range []g1Table{delegator.table, delegator.last} {
+		for _, row := range table {
+			for _, entry := range row {
+				zeroizeG1(entry)
+			}
+		}
+	}
+	zeroizeG1(delegator.product)
+	delegator.parent.Zeroize()
+	delegator.table, delegator.last, delegator.product = nil, nil, nil
+}
@@ -0,0 +1,64 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func TestDelegator(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params.Precompute()
+	parent, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	delegator, err := NewDelegator(params, parent, LINEAR_HIERARCHY[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := int64(1); i <= 3; i++ {
+		id := []*big.Int{LINEAR_HIERARCHY[0], big.NewInt(i)}
+		child, err := delegator.Delegate(rand.Reader, id[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = VerifyKey(params, id, child); err != nil {
+			t.Fatal(err)
+		}
+		message := NewMessage()
+		ciphertext, err := Encrypt(rand.Reader, params, id, message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(message.Marshal(), mustDecrypt(t, child, ciphertext).Marshal()) {
+			t.Fatal("Delegated key did not decrypt")
+		}
+
+		// The children can delegate in turn
+		grandchild, err := KeyGenFromParent(rand.Reader, params, child, append(id, LINEAR_HIERARCHY[2]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = VerifyKey(params, append(id, LINEAR_HIERARCHY[2]), grandchild); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The parent must be the key for the identity, and able to delegate
+	if _, err = NewDelegator(params, parent, LINEAR_HIERARCHY[:2]); err == nil {
+		t.Fatal("Created a Delegator for a key at the wrong depth")
+	}
+	leaf, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewDelegator(params, leaf, LINEAR_HIERARCHY); err == nil {
+		t.Fatal("Created a Delegator for a key that cannot delegate")
+	}
+}
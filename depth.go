@@ -0,0 +1,79 @@
+package hibe_sm9
+
+import (
+	"crypto/rand"
+	"golang.org/x/crypto/bn256"
+	"io"
+)
+
+// ExtendDepth returns parameters for the same hierarchy with extraLevels more
+// levels, obtained by appending fresh random elements to h1 ... hl (and to
+// their mirrors in anonymous hierarchies, which is why the master key is
+// needed). For parameters with derived h1 ... hl (see WithDerivedH), the new
+// elements are derived from the seed instead. The original parameters are
+// left unchanged.
+//
+// This is as secure as having run Setup with the larger depth in the first
+// place: the new elements are independent and uniformly random, exactly as
+// Setup would have chosen them, and every key issued so far is such a key of
+// the deeper hierarchy with its delegation components for the new levels
+// dropped, which anyone could do. Existing keys and ciphertexts therefore
+// remain valid, but existing keys cannot delegate into the new levels; keys
+// that need to must be reissued from the master key. Existing keys delegate
+// with the original parameters, since KeyGenFromParent cannot tell them from
+// keys deeper in the extended hierarchy.
+func ExtendDepth(random io.Reader, params *Params, master MasterKey, extraLevels int) (*Params, error) {
+	random = randomSource(random)
+	if extraLevels < 0 {
+		return nil, ErrDepthExceeded
+	}
+	if err := checkMaster(params, master); err != nil {
+		return nil, err
+	}
+
+	extended := &Params{
+		G:       params.G,
+		G1:      params.G1,
+		G2:      params.G2,
+		G3:      params.G3,
+		G3Hat:   params.G3Hat,
+		H:       append([]*bn256.G1{}, params.H...),
+		Pairing: params.Pairing,
+
+		IdentityHash: params.IdentityHash,
+	}
+	if params.Anonymous() {
+		extended.HHat = append([]*bn256.G2{}, params.HHat...)
+	}
+	if params.HSeed != nil {
+		if len(params.H)+extraLevels > maxDerivedDepth {
+			return nil, ErrDepthExceeded
+		}
+		extended.HSeed = append([]byte{}, params.HSeed...)
+		extended.H = append(extended.H, deriveH(params.HSeed, len(params.H), len(params.H)+extraLevels)...)
+		return extended, nil
+	}
+
+	var generator *bn256.G1
+	if params.Anonymous() {
+		generator = privateGenerator(master)
+		defer zeroizeG1(generator)
+	}
+	for i := 0; i != extraLevels; i++ {
+		if generator == nil {
+			_, hi, err := bn256.RandomG1(random)
+			if err != nil {
+				return nil, err
+			}
+			extended.H = append(extended.H, hi)
+			continue
+		}
+		exponent, err := rand.Int(random, bn256.Order)
+		if err != nil {
+			return nil, err
+		}
+		extended.H = append(extended.H, new(bn256.G1).ScalarMult(generator, exponent))
+		extended.HHat = append(extended.HHat, new(bn256.G2).ScalarMult(params.G, exponent))
+	}
+	return extended, nil
+}
@@ -0,0 +1,96 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func testExtendDepth(t *testing.T, opts ...SetupOption) {
+	params, master, err := Setup(rand.Reader, 2, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldKey, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	extended, err := ExtendDepth(rand.Reader, params, master, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if extended.MaximumDepth() != 4 || params.MaximumDepth() != 2 {
+		t.Fatal("Wrong depths after extension")
+	}
+	if err = extended.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	message := NewMessage()
+	ciphertext, err := Encrypt(rand.Reader, extended, LINEAR_HIERARCHY[:2], message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(message.Marshal(), mustDecrypt(t, oldKey, ciphertext).Marshal()) {
+		t.Fatal("Existing key does not decrypt under the extended parameters")
+	}
+
+	deep := append(LINEAR_HIERARCHY[:3:3], big.NewInt(9))
+	key, err := KeyGenFromMaster(rand.Reader, extended, master, deep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ciphertext, err = Encrypt(rand.Reader, extended, deep, message); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(message.Marshal(), mustDecrypt(t, key, ciphertext).Marshal()) {
+		t.Fatal("Key for a new level does not decrypt")
+	}
+	if _, err = Encrypt(rand.Reader, params, deep, message); !errors.Is(err, ErrDepthExceeded) {
+		t.Fatal("Original parameters were extended in place")
+	}
+
+	_, other, err := Setup(rand.Reader, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ExtendDepth(rand.Reader, params, other, 1); !errors.Is(err, ErrCurveMismatch) {
+		t.Fatal("Extended with the wrong master key")
+	}
+}
+
+func TestExtendDepth(t *testing.T) {
+	testExtendDepth(t)
+
+	// Existing keys can still delegate down to the original depth, with the
+	// parameters they were issued under.
+	params, master, err := Setup(rand.Reader, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	extended, err := ExtendDepth(rand.Reader, params, master, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = KeyGenFromParent(rand.Reader, extended, parent, LINEAR_HIERARCHY[:2]); !errors.Is(err, ErrInvalidID) {
+		t.Fatal("Existing key delegated with the extended parameters")
+	}
+	child, err := KeyGenFromParent(rand.Reader, params, parent, LINEAR_HIERARCHY[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = KeyGenFromParent(rand.Reader, extended, child, append(LINEAR_HIERARCHY[:2:2], big.NewInt(9))); err == nil {
+		t.Fatal("Existing key delegated into a new level")
+	}
+}
+
+func TestExtendDepthAnonymous(t *testing.T) {
+	testExtendDepth(t, WithAnonymity())
+}
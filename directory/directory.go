@@ -0,0 +1,318 @@
+// Package directory resolves human identities, such as email addresses and
+// device serials, to the hierarchical identities that messages for them are
+// encrypted to. A Directory maps canonical names to identities, and serves
+// them over HTTP together with the fingerprint of the current parameters, in
+// responses signed with an Ed25519 key:
+//
+//	GET /v1/resolve?name=alice@corp returns {"entry": <entry>, "signature": ..}
+//
+// Senders pin the public key of the directory, and resolve names with
+// Lookup, which checks the signature, the name, the validity period and,
+// through Entry.Identity, the parameters:
+//
+//	entry, err := directory.Lookup(ctx, nil, "https://dir.example.com", "alice@corp", key)
+//	id, err := entry.Identity(params)
+//	ciphertext, err := hibe_sm9.EncryptBytes(rand.Reader, params, id, message)
+//
+// Identity components are decimal strings, as in package pkgserver.
+package directory
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hibe_sm9"
+	"hibe_sm9/ids"
+	"hibe_sm9/pkgserver"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// signatureContext is prepended to the encoded entry before signing, so that
+// a directory signature cannot be mistaken for a signature by the same key
+// over anything else, such as a parameter bundle.
+const signatureContext = "HIBE-DIRECTORY-ENTRY-V1"
+
+// DefaultTTL is the validity period of entries if Config.TTL is zero.
+const DefaultTTL = time.Hour
+
+// clockSkew is how far in the future Verify accepts the issue time of an
+// entry to be, to allow for the clocks of directory and sender to differ.
+const clockSkew = 5 * time.Minute
+
+// maxResponseSize bounds the size of a response body.
+const maxResponseSize = 1 << 16
+
+var (
+	// ErrNotFound is returned for names that are not in the directory.
+	ErrNotFound = errors.New("directory: name not found")
+
+	// ErrSignature is returned when a response is not signed by the pinned
+	// key.
+	ErrSignature = errors.New("directory: response is not signed by the directory key")
+
+	// ErrNameMismatch is returned when a response is for a different name
+	// from the one that was looked up.
+	ErrNameMismatch = errors.New("directory: response is for a different name")
+
+	// ErrExpired is returned for entries outside their validity period.
+	ErrExpired = errors.New("directory: entry is outside its validity period")
+
+	// ErrParamsMismatch is returned by Entry.Identity when the entry is for
+	// different parameters, for instance because they have been rotated.
+	ErrParamsMismatch = errors.New("directory: entry is for different parameters")
+)
+
+// Canonical returns the canonical form of a name, under which it is
+// registered and resolved: surrounding white space is removed and letters are
+// lowercased, so that "Alice@Corp " and "alice@corp" are the same name.
+func Canonical(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// Entry is the resolution of a name: the identity in the hierarchy with the
+// given parameters fingerprint, valid from Issued until Expires. Path holds
+// the components the identity was hashed from, if it was registered with
+// Register.
+type Entry struct {
+	Name        string    `json:"name"`
+	Path        []string  `json:"path,omitempty"`
+	ID          []string  `json:"id"`
+	Fingerprint []byte    `json:"params"`
+	Issued      time.Time `json:"issued"`
+	Expires     time.Time `json:"expires"`
+}
+
+// Identity returns the identity of the entry, after checking that the entry
+// is for params.
+func (entry *Entry) Identity(params *hibe_sm9.Params) ([]*big.Int, error) {
+	if string(entry.Fingerprint) != string(params.Fingerprint()) {
+		return nil, ErrParamsMismatch
+	}
+	return pkgserver.ParseID(entry.ID)
+}
+
+// Response is the body of a resolve response. Entry is the JSON encoding of
+// the entry, which the signature covers as is.
+type Response struct {
+	Entry     json.RawMessage `json:"entry"`
+	Signature []byte          `json:"signature"`
+}
+
+// Verify checks that the response is signed by key, and is for name and
+// valid at now, allowing for a few minutes of clock skew, and returns its
+// entry.
+func (response *Response) Verify(key ed25519.PublicKey, name string, now time.Time) (*Entry, error) {
+	if len(key) != ed25519.PublicKeySize ||
+		!ed25519.Verify(key, append([]byte(signatureContext), response.Entry...), response.Signature) {
+		return nil, ErrSignature
+	}
+	entry := new(Entry)
+	if err := json.Unmarshal(response.Entry, entry); err != nil {
+		return nil, err
+	}
+	if entry.Name != Canonical(name) {
+		return nil, ErrNameMismatch
+	}
+	if now.Add(clockSkew).Before(entry.Issued) || !now.Before(entry.Expires) {
+		return nil, ErrExpired
+	}
+	return entry, nil
+}
+
+// Config configures a Directory.
+type Config struct {
+	// Params are the current parameters of the hierarchy. They can be
+	// replaced later with SetParams.
+	Params *hibe_sm9.Params
+
+	// Signer signs the responses of the directory.
+	Signer ed25519.PrivateKey
+
+	// TTL is the validity period of the entries served. If zero, it is
+	// DefaultTTL.
+	TTL time.Duration
+
+	// Now returns the current time. If nil, time.Now is used.
+	Now func() time.Time
+}
+
+// Directory maps names to identities. It is an http.Handler serving the
+// resolve API, and is safe for concurrent use.
+type Directory struct {
+	config Config
+
+	lock    sync.RWMutex
+	params  *hibe_sm9.Params
+	entries map[string]*Entry
+}
+
+// New creates an empty Directory from config.
+func New(config Config) (*Directory, error) {
+	if config.Params == nil || len(config.Signer) != ed25519.PrivateKeySize {
+		return nil, errors.New("directory: params and an Ed25519 signer are required")
+	}
+	if config.TTL == 0 {
+		config.TTL = DefaultTTL
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return &Directory{
+		config:  config,
+		params:  config.Params,
+		entries: make(map[string]*Entry),
+	}, nil
+}
+
+// Register maps name to the identity hashed from path with Params.HashID,
+// replacing any previous mapping. The components of path are brought to
+// their canonical form with ids.Normalize first.
+func (directory *Directory) Register(name string, path ...string) error {
+	path, err := ids.Default.NormalizeComponents(path)
+	if err != nil {
+		return err
+	}
+	components := make([][]byte, len(path))
+	for i, component := range path {
+		components[i] = []byte(component)
+	}
+	directory.lock.Lock()
+	defer directory.lock.Unlock()
+	return directory.register(name, path, directory.params.HashID(components))
+}
+
+// RegisterID maps name to id, replacing any previous mapping.
+func (directory *Directory) RegisterID(name string, id []*big.Int) error {
+	directory.lock.Lock()
+	defer directory.lock.Unlock()
+	return directory.register(name, nil, id)
+}
+
+func (directory *Directory) register(name string, path []string, id []*big.Int) error {
+	name = Canonical(name)
+	if name == "" {
+		return errors.New("directory: empty name")
+	}
+	if len(id) == 0 || len(id) > directory.params.MaximumDepth() {
+		return fmt.Errorf("directory: identity for %q is empty or deeper than the hierarchy", name)
+	}
+	directory.entries[name] = &Entry{Name: name, Path: path, ID: pkgserver.FormatID(id)}
+	return nil
+}
+
+// Remove removes the mapping of name, if there is one. Senders may still hold
+// signed entries for it until they expire.
+func (directory *Directory) Remove(name string) {
+	directory.lock.Lock()
+	defer directory.lock.Unlock()
+	delete(directory.entries, Canonical(name))
+}
+
+// SetParams replaces the parameters of the hierarchy, for instance after a
+// rotation. Identities registered with Register are hashed anew, since the
+// identity hash may have changed, and every entry is served with the new
+// fingerprint.
+func (directory *Directory) SetParams(params *hibe_sm9.Params) {
+	directory.lock.Lock()
+	defer directory.lock.Unlock()
+	directory.params = params
+	for _, entry := range directory.entries {
+		if entry.Path == nil {
+			continue
+		}
+		components := make([][]byte, len(entry.Path))
+		for i, component := range entry.Path {
+			components[i] = []byte(component)
+		}
+		entry.ID = pkgserver.FormatID(params.HashID(components))
+	}
+}
+
+// Resolve returns the signed response for name, or ErrNotFound.
+func (directory *Directory) Resolve(name string) (*Response, error) {
+	directory.lock.RLock()
+	registered, ok := directory.entries[Canonical(name)]
+	var entry Entry
+	if ok {
+		entry = *registered
+		entry.Fingerprint = directory.params.Fingerprint()
+	}
+	directory.lock.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	entry.Issued = directory.config.Now().UTC()
+	entry.Expires = entry.Issued.Add(directory.config.TTL)
+	encoded, err := json.Marshal(&entry)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{
+		Entry:     encoded,
+		Signature: ed25519.Sign(directory.config.Signer, append([]byte(signatureContext), encoded...)),
+	}, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (directory *Directory) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/resolve" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	response, err := directory.Resolve(r.URL.Query().Get("name"))
+	if err == ErrNotFound {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "resolution failed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Lookup resolves name with the directory at base, the URL of the server
+// without the /v1 path, and verifies the response with the pinned key of the
+// directory. If client is nil, http.DefaultClient is used.
+func Lookup(ctx context.Context, client *http.Client, base string, name string, key ed25519.PublicKey) (*Entry, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		strings.TrimRight(base, "/")+"/v1/resolve?name="+url.QueryEscape(name), nil)
+	if err != nil {
+		return nil, err
+	}
+	httpResponse, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResponse.Body.Close()
+	switch httpResponse.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, fmt.Errorf("directory: server returned %s", httpResponse.Status)
+	}
+
+	var response Response
+	if err = json.NewDecoder(io.LimitReader(httpResponse.Body, maxResponseSize)).Decode(&response); err != nil {
+		return nil, err
+	}
+	return response.Verify(key, name, time.Now())
+}
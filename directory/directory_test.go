@@ -0,0 +1,127 @@
+package directory
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"hibe_sm9"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestDirectory(t *testing.T) (*Directory, *hibe_sm9.Params, hibe_sm9.MasterKey, ed25519.PublicKey) {
+	params, master, err := hibe_sm9.Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	directory, err := New(Config{Params: params, Signer: private})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return directory, params, master, public
+}
+
+func TestLookup(t *testing.T) {
+	directory, params, master, public := newTestDirectory(t)
+	if err := directory.Register("Alice@Corp", "corp", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(directory)
+	defer server.Close()
+
+	entry, err := Lookup(context.Background(), nil, server.URL, " alice@corp", public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := entry.Identity(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := hibe_sm9.KeyGenFromMaster(rand.Reader, params, master, params.HashID([][]byte{[]byte("corp"), []byte("alice")}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := []byte("hello")
+	ciphertext, err := hibe_sm9.EncryptBytes(rand.Reader, params, id, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := hibe_sm9.DecryptBytes(key, ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, message) {
+		t.Fatal("Resolved identity is not the registered one")
+	}
+
+	if _, err = Lookup(context.Background(), nil, server.URL, "bob@corp", public); err != ErrNotFound {
+		t.Fatal("Resolved an unregistered name")
+	}
+	other, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Lookup(context.Background(), nil, server.URL, "alice@corp", other); err != ErrSignature {
+		t.Fatal("Accepted a response signed by another key")
+	}
+}
+
+func TestVerifyRejectsSubstitution(t *testing.T) {
+	directory, params, _, public := newTestDirectory(t)
+	if err := directory.RegisterID("device-1", []*big.Int{big.NewInt(1), big.NewInt(2)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := directory.RegisterID("device-2", []*big.Int{big.NewInt(1), big.NewInt(3)}); err != nil {
+		t.Fatal(err)
+	}
+	response, err := directory.Resolve("device-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if _, err = response.Verify(public, "device-1", now); err != ErrNameMismatch {
+		t.Fatal("Accepted the entry of another name")
+	}
+	if _, err = response.Verify(public, "device-2", now.Add(2*DefaultTTL)); err != ErrExpired {
+		t.Fatal("Accepted an expired entry")
+	}
+	response.Entry = bytes.Replace(response.Entry, []byte(`"3"`), []byte(`"2"`), 1)
+	if _, err = response.Verify(public, "device-2", now); err != ErrSignature {
+		t.Fatal("Accepted a modified entry")
+	}
+
+	rotated, _, err := hibe_sm9.Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response, err = directory.Resolve("device-1"); err != nil {
+		t.Fatal(err)
+	}
+	entry, err := response.Verify(public, "device-1", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = entry.Identity(rotated); err != ErrParamsMismatch {
+		t.Fatal("Accepted an entry for other parameters")
+	}
+	directory.SetParams(rotated)
+	if response, err = directory.Resolve("device-1"); err != nil {
+		t.Fatal(err)
+	}
+	if entry, err = response.Verify(public, "device-1", now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = entry.Identity(rotated); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = entry.Identity(params); err != ErrParamsMismatch {
+		t.Fatal("Entry still matches the old parameters")
+	}
+}
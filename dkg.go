@@ -0,0 +1,286 @@
+package hibe_sm9
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bn256"
+	"io"
+	"math/big"
+)
+
+// dkgDomain separates the elements derived from a setup transcript from other
+// uses of HashToG1.
+var dkgDomain = []byte("HIBE-DKG-V1")
+
+var (
+	errSetupBroadcast = errors.New("hibe: malformed or missing setup broadcast")
+	errSetupShare     = errors.New("hibe: setup share does not match its commitments")
+)
+
+// SetupBroadcast is the message that each participant in a distributed setup
+// sends to all others in the first round: commitments g^a0 ... g^a(t-1) to the
+// coefficients of its secret polynomial f(x) = a0 + a1 x + ... + a(t-1)
+// x^(t-1).
+type SetupBroadcast struct {
+	Index       int
+	Commitments []*bn256.G2
+}
+
+// SetupShare is the message that participant From sends to participant To in
+// the second round: the value of its polynomial at To. It must be sent over a
+// confidential and authenticated channel.
+type SetupShare struct {
+	From  int
+	To    int
+	Value *big.Int
+}
+
+// SetupTranscript is the public record of a distributed setup: its
+// configuration and every broadcast, in index order. Anyone can recompute the
+// parameters from it with VerifySetupTranscript.
+type SetupTranscript struct {
+	Parties    int
+	Threshold  int
+	Depth      int
+	Broadcasts []*SetupBroadcast
+}
+
+// SetupParticipant runs one party's side of a distributed setup, in which n
+// parties jointly generate the parameters of a hierarchy and Shamir shares of
+// alpha, so that no party ever learns alpha or the master key g2^alpha, even
+// during setup. Each party ends up with a MasterKeyShare for PartialKeyGen;
+// any t of them can issue keys with KeyGenFromMasterShares. With t = n, every
+// party is needed, as with additive shares.
+//
+// The protocol is the joint-Feldman DKG. Each party i chooses a random
+// polynomial f_i of degree t-1, broadcasts commitments to its coefficients
+// (NewSetupParticipant), and once it has every broadcast, sends f_i(j) to
+// each party j (ProcessRound). Party j checks the values it receives against
+// the commitments and sums them into its share s_j of alpha = f_1(0) + ... +
+// f_n(0) (Finalize). g is the generator of G2 and g1 = g^alpha is the sum of
+// the first commitments. g2, g3 and h1 ... hl are hashed from the transcript
+// of broadcasts, so nobody knows their discrete logarithms; h1 ... hl are
+// derived as with WithDerivedH.
+//
+// Broadcasts must reach every party unchanged, for instance through a
+// bulletin board. A party that sends an invalid share makes Finalize fail,
+// naming it; the protocol has no complaint round, so the setup must then be
+// restarted without that party. As with any joint-Feldman DKG, the last party
+// to broadcast can bias g1, but learns nothing about alpha.
+type SetupParticipant struct {
+	index        int
+	transcript   SetupTranscript
+	coefficients []*big.Int
+	own          *SetupBroadcast
+	broadcasts   []*SetupBroadcast
+}
+
+// NewSetupParticipant starts a distributed setup of a hierarchy of the given
+// depth for the participant with the given index among parties, numbered from
+// 1, with threshold t. It returns the participant and its broadcast for the
+// first round.
+func NewSetupParticipant(random io.Reader, index int, parties int, t int, depth int) (*SetupParticipant, *SetupBroadcast, error) {
+	if err := checkThreshold(parties, t); err != nil {
+		return nil, nil, err
+	}
+	if index < 1 || index > parties {
+		return nil, nil, errors.New("hibe: participant index must be between 1 and the number of parties")
+	}
+	if depth < 0 || depth > maxDerivedDepth {
+		return nil, nil, ErrDepthExceeded
+	}
+	random = randomSource(random)
+
+	participant := &SetupParticipant{
+		index:        index,
+		transcript:   SetupTranscript{Parties: parties, Threshold: t, Depth: depth},
+		coefficients: make([]*big.Int, t),
+	}
+	broadcast := &SetupBroadcast{Index: index, Commitments: make([]*bn256.G2, t)}
+	for k := range participant.coefficients {
+		coefficient, err := rand.Int(random, bn256.Order)
+		if err != nil {
+			return nil, nil, err
+		}
+		participant.coefficients[k] = coefficient
+		if broadcast.Commitments[k], err = secretMultG2(dkgGenerator(), coefficient); err != nil {
+			return nil, nil, err
+		}
+	}
+	participant.own = broadcast
+	return participant, broadcast, nil
+}
+
+// dkgGenerator returns g, the generator of G2.
+func dkgGenerator() *bn256.G2 {
+	return new(bn256.G2).ScalarBaseMult(big.NewInt(1))
+}
+
+// ProcessRound takes the broadcasts of all parties, including the
+// participant's own, and returns the shares to send to the others, one per
+// party other than the participant.
+func (participant *SetupParticipant) ProcessRound(broadcasts []*SetupBroadcast) ([]*SetupShare, error) {
+	ordered, err := participant.transcript.order(broadcasts)
+	if err != nil {
+		return nil, err
+	}
+	for k, commitment := range ordered[participant.index-1].Commitments {
+		if string(commitment.Marshal()) != string(participant.own.Commitments[k].Marshal()) {
+			return nil, errSetupBroadcast
+		}
+	}
+	participant.broadcasts = ordered
+	participant.transcript.Broadcasts = ordered
+
+	shares := make([]*SetupShare, 0, participant.transcript.Parties-1)
+	for j := 1; j <= participant.transcript.Parties; j++ {
+		if j != participant.index {
+			shares = append(shares, &SetupShare{From: participant.index, To: j, Value: participant.evaluate(j)})
+		}
+	}
+	return shares, nil
+}
+
+// order checks that broadcasts has one well-formed broadcast per party, and
+// returns them in index order.
+func (transcript *SetupTranscript) order(broadcasts []*SetupBroadcast) ([]*SetupBroadcast, error) {
+	if len(broadcasts) != transcript.Parties {
+		return nil, errSetupBroadcast
+	}
+	ordered := make([]*SetupBroadcast, transcript.Parties)
+	for _, broadcast := range broadcasts {
+		if broadcast == nil || broadcast.Index < 1 || broadcast.Index > transcript.Parties ||
+			ordered[broadcast.Index-1] != nil || len(broadcast.Commitments) != transcript.Threshold {
+			return nil, errSetupBroadcast
+		}
+		for _, commitment := range broadcast.Commitments {
+			if checkG2(commitment) != nil {
+				return nil, errSetupBroadcast
+			}
+		}
+		ordered[broadcast.Index-1] = broadcast
+	}
+	return ordered, nil
+}
+
+// evaluate returns the value of the participant's polynomial at x.
+func (participant *SetupParticipant) evaluate(x int) *big.Int {
+	value := new(big.Int)
+	for k := len(participant.coefficients) - 1; k >= 0; k-- {
+		value.Mul(value, big.NewInt(int64(x)))
+		value.Add(value, participant.coefficients[k])
+		value.Mod(value, bn256.Order)
+	}
+	return value
+}
+
+// commitmentAt returns g^f(x) for the polynomial committed to by broadcast.
+func (broadcast *SetupBroadcast) commitmentAt(x int) *bn256.G2 {
+	result := deepCloneG2(broadcast.Commitments[len(broadcast.Commitments)-1])
+	for k := len(broadcast.Commitments) - 2; k >= 0; k-- {
+		result.ScalarMult(result, big.NewInt(int64(x)))
+		result.Add(result, broadcast.Commitments[k])
+	}
+	return result
+}
+
+// Finalize checks the shares sent to the participant by every other party
+// against their commitments, and returns the parameters, the participant's
+// master key share and the transcript of the setup. The participant's
+// polynomial is zeroized.
+func (participant *SetupParticipant) Finalize(shares []*SetupShare) (*Params, *MasterKeyShare, *SetupTranscript, error) {
+	if participant.broadcasts == nil {
+		return nil, nil, nil, errSetupBroadcast
+	}
+	parties := participant.transcript.Parties
+	if len(shares) != parties-1 {
+		return nil, nil, nil, errors.New("hibe: wrong number of setup shares")
+	}
+	seen := make([]bool, parties+1)
+	sum := participant.evaluate(participant.index)
+	defer zeroizeScalar(sum)
+	for _, share := range shares {
+		if share == nil || share.To != participant.index || share.From < 1 || share.From > parties ||
+			share.From == participant.index || seen[share.From] || share.Value == nil {
+			return nil, nil, nil, errors.New("hibe: misaddressed or duplicate setup share")
+		}
+		seen[share.From] = true
+		expected := participant.broadcasts[share.From-1].commitmentAt(participant.index)
+		if string(new(bn256.G2).ScalarBaseMult(share.Value).Marshal()) != string(expected.Marshal()) {
+			return nil, nil, nil, fmt.Errorf("%w: from party %d", errSetupShare, share.From)
+		}
+		sum.Add(sum, share.Value)
+		sum.Mod(sum, bn256.Order)
+	}
+
+	params, err := participant.transcript.params()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	point, err := secretMultG1(params.G2, sum)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for _, coefficient := range participant.coefficients {
+		zeroizeScalar(coefficient)
+	}
+	participant.coefficients = nil
+
+	transcript := participant.transcript
+	transcript.Broadcasts = append([]*SetupBroadcast{}, participant.broadcasts...)
+	share := &MasterKeyShare{Index: participant.index, Threshold: transcript.Threshold, Share: point}
+	return params, share, &transcript, nil
+}
+
+// params computes the parameters from the transcript.
+func (transcript *SetupTranscript) params() (*Params, error) {
+	hash := sha256.New()
+	hash.Write(dkgDomain)
+	var header [12]byte
+	binary.BigEndian.PutUint32(header[0:], uint32(transcript.Parties))
+	binary.BigEndian.PutUint32(header[4:], uint32(transcript.Threshold))
+	binary.BigEndian.PutUint32(header[8:], uint32(transcript.Depth))
+	hash.Write(header[:])
+	params := &Params{G: dkgGenerator(), G1: new(bn256.G2).ScalarBaseMult(big.NewInt(0))}
+	for _, broadcast := range transcript.Broadcasts {
+		for _, commitment := range broadcast.Commitments {
+			hash.Write(commitment.Marshal())
+		}
+		params.G1.Add(params.G1, broadcast.Commitments[0])
+	}
+	seed := hash.Sum(nil)
+
+	params.G2 = HashToG1(append(append(append([]byte{}, dkgDomain...), seed...), "g2"...))
+	params.G3 = HashToG1(append(append(append([]byte{}, dkgDomain...), seed...), "g3"...))
+	if err := params.setHSeed(seed, transcript.Depth); err != nil {
+		return nil, err
+	}
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+// VerifySetupTranscript recomputes the parameters from the transcript of a
+// distributed setup, and checks that they are params. This lets anyone who
+// trusts that at least one party was honest trust the parameters.
+func VerifySetupTranscript(params *Params, transcript *SetupTranscript) error {
+	if err := checkThreshold(transcript.Parties, transcript.Threshold); err != nil {
+		return err
+	}
+	ordered, err := transcript.order(transcript.Broadcasts)
+	if err != nil {
+		return err
+	}
+	recomputed, err := (&SetupTranscript{transcript.Parties, transcript.Threshold, transcript.Depth, ordered}).params()
+	if err != nil {
+		return err
+	}
+	if !recomputed.Equal(params) {
+		return errors.New("hibe: parameters do not match the setup transcript")
+	}
+	return nil
+}
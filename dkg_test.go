@@ -0,0 +1,103 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+// runSetup runs a distributed setup among n parties with threshold t, with
+// the shares sent by the given party passed through tamper.
+func runSetup(t *testing.T, n int, threshold int, depth int, tamper func(*SetupShare)) ([]*Params, []*MasterKeyShare, []*SetupTranscript, error) {
+	participants := make([]*SetupParticipant, n)
+	broadcasts := make([]*SetupBroadcast, n)
+	for i := range participants {
+		var err error
+		if participants[i], broadcasts[i], err = NewSetupParticipant(rand.Reader, i+1, n, threshold, depth); err != nil {
+			t.Fatal(err)
+		}
+	}
+	inbox := make([][]*SetupShare, n)
+	for _, participant := range participants {
+		shares, err := participant.ProcessRound(broadcasts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, share := range shares {
+			if tamper != nil {
+				tamper(share)
+			}
+			inbox[share.To-1] = append(inbox[share.To-1], share)
+		}
+	}
+	params := make([]*Params, n)
+	masterShares := make([]*MasterKeyShare, n)
+	transcripts := make([]*SetupTranscript, n)
+	for i, participant := range participants {
+		var err error
+		if params[i], masterShares[i], transcripts[i], err = participant.Finalize(inbox[i]); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	return params, masterShares, transcripts, nil
+}
+
+func TestDistributedSetup(t *testing.T) {
+	params, shares, transcripts, err := runSetup(t, 4, 3, 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range params {
+		if !params[i].Equal(params[0]) {
+			t.Fatal("Participants disagree on the parameters")
+		}
+		if err = VerifySetupTranscript(params[0], transcripts[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Any three of the four shares issue working keys
+	partials := make([]*PrivateKeyShare, 0, 3)
+	for _, share := range shares[1:] {
+		partial, err := PartialKeyGen(rand.Reader, params[0], share, LINEAR_HIERARCHY)
+		if err != nil {
+			t.Fatal(err)
+		}
+		partials = append(partials, partial)
+	}
+	key, err := KeyGenFromMasterShares(partials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = VerifyKey(params[0], LINEAR_HIERARCHY, key); err != nil {
+		t.Fatal(err)
+	}
+	message := NewMessage()
+	ciphertext, err := Encrypt(rand.Reader, params[0], LINEAR_HIERARCHY, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(message.Marshal(), mustDecrypt(t, key, ciphertext).Marshal()) {
+		t.Fatal("Key from distributed setup does not decrypt")
+	}
+
+	other, _, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if VerifySetupTranscript(other, transcripts[0]) == nil {
+		t.Fatal("Transcript verified other parameters")
+	}
+}
+
+func TestDistributedSetupRejectsBadShares(t *testing.T) {
+	_, _, _, err := runSetup(t, 3, 2, 2, func(share *SetupShare) {
+		if share.From == 2 {
+			share.Value.Add(share.Value, share.Value)
+		}
+	})
+	if !errors.Is(err, errSetupShare) {
+		t.Fatal("Accepted a share that does not match its commitments")
+	}
+}
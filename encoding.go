@@ -0,0 +1,145 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// EncodingVersion is the version of the encodings produced by Marshal.
+// Version 1 headers, which have no fingerprint, are still decoded.
+const EncodingVersion = 2
+
+// encodingMagic starts every encoding produced by Marshal. Its first byte is
+// larger than the leading byte of any headerless encoding (at most 0x8f for a
+// coordinate, and 0xfd to 0xff for the compressed tags and the anonymous
+// marker), so headerless encodings from earlier versions are still decoded.
+var encodingMagic = []byte{0xe9, 'H', 'B', 'E'}
+
+// headerSizeV1 is the size of a version 1 header: the magic, the version, the
+// curve, the kind of object, and the depth. headerSize adds the fingerprint of
+// the parameters the object belongs to.
+const (
+	headerSizeV1 = 9
+	headerSize   = headerSizeV1 + FingerprintSize
+)
+
+// EncodingKind identifies the kind of object in an encoding.
+type EncodingKind uint8
+
+const (
+	KindParams     EncodingKind = 1
+	KindPrivateKey EncodingKind = 2
+	KindCiphertext EncodingKind = 3
+)
+
+func (kind EncodingKind) String() string {
+	switch kind {
+	case KindParams:
+		return "parameters"
+	case KindPrivateKey:
+		return "private key"
+	case KindCiphertext:
+		return "ciphertext"
+	default:
+		return "unknown"
+	}
+}
+
+// Header describes an encoded object. Depth is the maximum depth of the
+// hierarchy for parameters, the number of levels a private key can still
+// delegate, and zero for ciphertexts, whose encodings do not reveal the
+// depth of the recipient. Fingerprint is the fingerprint of the parameters
+// the object belongs to (see Params.Fingerprint), or nil if it is unknown.
+type Header struct {
+	Version     uint8
+	Curve       CurveID
+	Kind        EncodingKind
+	Depth       int
+	Fingerprint []byte
+}
+
+var (
+	errNoHeader        = errors.New("hibe: encoding has no header")
+	errEncodingVersion = wrapError(ErrCurveMismatch, "hibe: unsupported encoding version")
+	errEncodingKind    = wrapError(ErrCurveMismatch, "hibe: encoding is for a different kind of object")
+)
+
+// appendHeader prefixes body with a header for the current version. A nil
+// fingerprint is encoded as zeros.
+func appendHeader(kind EncodingKind, depth int, fingerprint []byte, body []byte) []byte {
+	encoded := make([]byte, headerSize, headerSize+len(body))
+	copy(encoded, encodingMagic)
+	encoded[4] = EncodingVersion
+	encoded[5] = byte(CurveBN256)
+	encoded[6] = byte(kind)
+	binary.BigEndian.PutUint16(encoded[7:], uint16(depth))
+	copy(encoded[headerSizeV1:], fingerprint)
+	return append(encoded, body...)
+}
+
+// ParseHeader reads the header of an encoding produced by Marshal, so that
+// callers can report why an encoding is rejected. Encodings produced before
+// headers were introduced have none, and are reported with an error even
+// though Unmarshal still accepts them.
+func ParseHeader(encoded []byte) (Header, error) {
+	header, _, err := parseHeader(encoded)
+	return header, err
+}
+
+// parseHeader is ParseHeader, also returning the size of the header.
+func parseHeader(encoded []byte) (Header, int, error) {
+	if len(encoded) < headerSizeV1 || string(encoded[:len(encodingMagic)]) != string(encodingMagic) {
+		return Header{}, 0, errNoHeader
+	}
+	header := Header{
+		Version: encoded[4],
+		Curve:   CurveID(encoded[5]),
+		Kind:    EncodingKind(encoded[6]),
+		Depth:   int(binary.BigEndian.Uint16(encoded[7:])),
+	}
+	size := headerSizeV1
+	switch header.Version {
+	case 1:
+	case EncodingVersion:
+		if len(encoded) < headerSize {
+			return header, 0, errNoHeader
+		}
+		size = headerSize
+		if fingerprint := encoded[headerSizeV1:headerSize]; !bytes.Equal(fingerprint, make([]byte, FingerprintSize)) {
+			header.Fingerprint = append([]byte{}, fingerprint...)
+		}
+	default:
+		return header, 0, errEncodingVersion
+	}
+	if !header.Curve.Supported() {
+		return header, 0, ErrUnsupportedCurve
+	}
+	return header, size, nil
+}
+
+// CheckEncoding reports whether encoded is an encoding of the given kind of
+// object that this version can decode, with an error explaining why not.
+func CheckEncoding(encoded []byte, kind EncodingKind) error {
+	header, err := ParseHeader(encoded)
+	if err != nil {
+		return err
+	}
+	if header.Kind != kind {
+		return errEncodingKind
+	}
+	return nil
+}
+
+// stripHeader removes the header from an encoding of the given kind, and
+// returns it. Headerless encodings are reported with a depth of -1.
+func stripHeader(encoded []byte, kind EncodingKind) ([]byte, Header, bool) {
+	if len(encoded) == 0 || encoded[0] != encodingMagic[0] {
+		return encoded, Header{Depth: -1}, true
+	}
+	header, size, err := parseHeader(encoded)
+	if err != nil || header.Kind != kind {
+		return nil, header, false
+	}
+	return encoded[size:], header, true
+}
@@ -0,0 +1,102 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+func TestEncodingHeader(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := Encrypt(rand.Reader, params, LINEAR_HIERARCHY[:1], NewMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		encoded []byte
+		kind    EncodingKind
+		depth   int
+	}{
+		{params.Marshal(), KindParams, 4},
+		{params.Marshal(WithCompression()), KindParams, 4},
+		{key.Marshal(), KindPrivateKey, 3},
+		{ciphertext.Marshal(), KindCiphertext, 0},
+	} {
+		header, err := ParseHeader(test.encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.Version != EncodingVersion || header.Curve != CurveBN256 || header.Kind != test.kind || header.Depth != test.depth {
+			t.Fatal("Wrong header for", test.kind)
+		}
+	}
+
+	if _, ok := new(PrivateKey).Unmarshal(params.Marshal()); ok {
+		t.Fatal("Decoded parameters as a private key")
+	}
+	if err = CheckEncoding(params.Marshal(), KindPrivateKey); !errors.Is(err, ErrCurveMismatch) {
+		t.Fatal("Kind mismatch not reported")
+	}
+
+	encoded := ciphertext.Marshal()
+	encoded[5] = 2
+	if _, ok := new(Ciphertext).Unmarshal(encoded); ok {
+		t.Fatal("Decoded a ciphertext for another curve")
+	}
+	if err = CheckEncoding(encoded, KindCiphertext); err != ErrUnsupportedCurve {
+		t.Fatal("Curve mismatch not reported")
+	}
+	encoded[5] = byte(CurveBN256)
+	encoded[4] = EncodingVersion + 1
+	if _, ok := new(Ciphertext).Unmarshal(encoded); ok {
+		t.Fatal("Decoded a ciphertext from a future version")
+	}
+
+	encoded = key.Marshal()
+	encoded[8]++
+	if _, ok := new(PrivateKey).Unmarshal(encoded); ok {
+		t.Fatal("Decoded a private key whose depth disagrees with its header")
+	}
+}
+
+func TestEncodingHeaderless(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3, WithAnonymity())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := Encrypt(rand.Reader, params, LINEAR_HIERARCHY[:2], NewMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, opts := range [][]MarshalOption{nil, {WithCompression()}} {
+		if _, err = ParseHeader(params.marshalBody(opts)); err != errNoHeader {
+			t.Fatal("Found a header in a headerless encoding")
+		}
+		decodedParams, ok := new(Params).Unmarshal(params.marshalBody(opts))
+		if !ok || !bytes.Equal(decodedParams.Marshal(), params.Marshal()) {
+			t.Fatal("Could not decode headerless parameters")
+		}
+		decodedKey, ok := new(PrivateKey).Unmarshal(key.marshalBody(opts))
+		if !ok || !bytes.Equal(decodedKey.marshalBody(nil), key.marshalBody(nil)) {
+			t.Fatal("Could not decode headerless private key")
+		}
+		decodedCiphertext, ok := new(Ciphertext).Unmarshal(ciphertext.marshalBody(opts))
+		if !ok || !bytes.Equal(decodedCiphertext.marshalBody(nil), ciphertext.marshalBody(nil)) {
+			t.Fatal("Could not decode headerless ciphertext")
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package hibe_sm9
+
+import (
+	"golang.org/x/crypto/bn256"
+	"io"
+	"math/big"
+)
+
+// Encryptor encrypts messages to one identity. It computes the product
+// g3 * h1^I1 * ... * hk^Ik of the identity once, and builds a fixed-base
+// table for it, so that each encryption costs a few table lookups instead of
+// k scalar multiplications. This pays off for services that send many
+// messages to the same recipient. Hardened builds do not use the table, as
+// for Params.Precompute, but still save computing the product.
+//
+// An Encryptor is safe for concurrent use, and its ciphertexts are the same
+// as those of Encrypt.
+type Encryptor struct {
+	params *Params
+	id     []*big.Int
+
+	product    *bn256.G1
+	productHat *bn256.G2
+	table      g1Table
+	tableHat   g2Table
+}
+
+// EncryptorFor returns an Encryptor for id. It precaches params (see
+// Params.Precache), so it must not be called concurrently with other uses of
+// params.
+func EncryptorFor(params *Params, id []*big.Int) (*Encryptor, error) {
+	if err := checkID(params, id); err != nil {
+		return nil, err
+	}
+	params.Precache()
+	encryptor := &Encryptor{params: params, id: append([]*big.Int{}, id...)}
+	if params.Anonymous() {
+		encryptor.productHat = idProductHat(params, id)
+		if !hardened {
+			encryptor.tableHat = newG2Table(encryptor.productHat)
+		}
+	} else {
+		encryptor.product = idProduct(params, id)
+		if !hardened {
+			encryptor.table = newG1Table(encryptor.product)
+		}
+	}
+	return encryptor, nil
+}
+
+// ID returns the identity that the Encryptor encrypts to.
+func (encryptor *Encryptor) ID() []*big.Int {
+	return append([]*big.Int{}, encryptor.id...)
+}
+
+// Encrypt is Encrypt for the identity of the Encryptor.
+func (encryptor *Encryptor) Encrypt(random io.Reader, message *bn256.GT, opts ...EncryptOption) (*Ciphertext, error) {
+	return encrypt(random, encryptor.params, message, opts, func(ciphertext *Ciphertext, s *big.Int) (err error) {
+		switch {
+		case encryptor.table != nil:
+			ciphertext.C = encryptor.table.mult(s)
+		case encryptor.tableHat != nil:
+			ciphertext.CHat = encryptor.tableHat.mult(s)
+		case encryptor.product != nil:
+			ciphertext.C, err = secretMultG1(encryptor.product, s)
+		default:
+			ciphertext.CHat, err = secretMultG2(encryptor.productHat, s)
+		}
+		return err
+	})
+}
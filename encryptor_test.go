@@ -0,0 +1,64 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestEncryptor(t *testing.T) {
+	for _, opts := range [][]SetupOption{nil, {WithAnonymity()}} {
+		params, master, err := Setup(rand.Reader, 5, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encryptor, err := EncryptorFor(params, LINEAR_HIERARCHY)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i != 3; i++ {
+			message, err := NewRandomMessage(rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ciphertext, err := encryptor.Encrypt(rand.Reader, message, WithIntegrityTag())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(message.Marshal(), mustDecrypt(t, key, ciphertext).Marshal()) {
+				t.Fatal("Original and decrypted messages differ")
+			}
+		}
+	}
+
+	params, _, err := Setup(rand.Reader, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = EncryptorFor(params, LINEAR_HIERARCHY); err == nil {
+		t.Fatal("Identity deeper than the hierarchy was accepted")
+	}
+}
+
+func BenchmarkEncryptor(b *testing.B) {
+	params, _, err := Setup(rand.Reader, 10)
+	if err != nil {
+		b.Fatal(err)
+	}
+	params.Precompute()
+	encryptor, err := EncryptorFor(params, LINEAR_HIERARCHY)
+	if err != nil {
+		b.Fatal(err)
+	}
+	message := NewMessage()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err = encryptor.Encrypt(rand.Reader, message); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package hibe_sm9
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+	"time"
+)
+
+// EnvelopeVersion is the version of the envelopes produced by Seal.
+const EnvelopeVersion = 1
+
+// envelopeClockSkew is how far in the future Open accepts the creation time
+// of an envelope to be, to allow for the clocks of sender and recipient to
+// differ.
+const envelopeClockSkew = 5 * time.Minute
+
+// envelopeDomain separates the data signed and authenticated by envelopes
+// from other uses of Sign and EncryptBytes.
+var envelopeDomain = []byte("HIBE-ENVELOPE")
+
+// ErrEnvelopeExpired is returned by Open for envelopes outside their
+// validity period.
+var ErrEnvelopeExpired = errors.New("hibe: envelope is outside its validity period")
+
+var (
+	errEnvelopeMalformed = wrapError(ErrMalformedCiphertext, "hibe: malformed envelope")
+	errEnvelopeSignature = wrapError(ErrDecryptFailed, "hibe: envelope signature does not verify")
+)
+
+// Envelope is a complete secure message: a ciphertext for Recipient, signed
+// by Sender. Unlike Signcrypt, it encrypts and then signs, so the sender, the
+// recipient and the validity period are public, and anyone can check the
+// signature without being able to decrypt.
+//
+// The signature covers everything but itself, and the header (every field
+// but the ciphertext and the signature) is also authenticated by the
+// encryption, so that an attacker who replaces the signature with their own
+// cannot make the recipient accept the message as theirs.
+type Envelope struct {
+	Version    byte
+	Sender     []*big.Int
+	Recipient  []*big.Int
+	Created    time.Time
+	Expires    time.Time
+	Ciphertext []byte
+	Signature  *Signature
+}
+
+// Seal encrypts plaintext for recipient with EncryptBytes, and signs the
+// result with the private key of sender, which must be able to delegate one
+// more level (see Sign). The envelope is created at now, and expires after
+// lifetime, or never if lifetime is zero. The options are those of
+// EncryptBytes; data given with WithAAD is authenticated after the header,
+// and must be given to Open.
+func Seal(random io.Reader, params *Params, senderKey *PrivateKey, sender []*big.Int, recipient []*big.Int, plaintext []byte, now time.Time, lifetime time.Duration, opts ...EncryptOption) (*Envelope, error) {
+	if err := checkID(params, recipient); err != nil {
+		return nil, err
+	}
+	envelope := &Envelope{
+		Version:   EnvelopeVersion,
+		Sender:    append([]*big.Int{}, sender...),
+		Recipient: append([]*big.Int{}, recipient...),
+		Created:   now,
+	}
+	if lifetime != 0 {
+		envelope.Expires = now.Add(lifetime)
+	}
+
+	var err error
+	random = randomSource(random)
+	envelope.Ciphertext, err = encryptBytes(random, params, recipient, plaintext, envelope.header(), opts...)
+	if err != nil {
+		return nil, err
+	}
+	if envelope.Signature, err = Sign(random, params, senderKey, sender, envelope.signed()); err != nil {
+		return nil, err
+	}
+	return envelope, nil
+}
+
+// Open verifies the signature of the envelope and its validity period at
+// now, and decrypts it with the private key of the recipient. It returns
+// ErrEnvelopeExpired if the envelope has expired, or was created more than a
+// few minutes after now.
+func Open(params *Params, recipientKey *PrivateKey, envelope *Envelope, now time.Time, opts ...DecryptOption) ([]byte, error) {
+	if envelope.Version != EnvelopeVersion || envelope.Signature == nil {
+		return nil, errEnvelopeMalformed
+	}
+	if !Verify(params, envelope.Sender, envelope.signed(), envelope.Signature) {
+		return nil, errEnvelopeSignature
+	}
+	if now.Add(envelopeClockSkew).Before(envelope.Created) ||
+		(!envelope.Expires.IsZero() && now.After(envelope.Expires)) {
+		return nil, ErrEnvelopeExpired
+	}
+	return decryptBytes(recipientKey, envelope.Ciphertext, append(envelope.header(), decryptOptions(opts).aad...))
+}
+
+// header encodes the fields of the envelope up to the ciphertext: the
+// version, the creation and expiry times in nanoseconds since the Unix epoch
+// (8 bytes each, big endian, with 0 for no expiry), and the sender and
+// recipient as by encodeID. It is prefixed with envelopeDomain, which is not
+// part of the encoding.
+func (envelope *Envelope) header() []byte {
+	header := append([]byte{}, envelopeDomain...)
+	header = append(header, envelope.Version)
+	header = binary.BigEndian.AppendUint64(header, uint64(envelope.Created.UnixNano()))
+	var expires int64
+	if !envelope.Expires.IsZero() {
+		expires = envelope.Expires.UnixNano()
+	}
+	header = binary.BigEndian.AppendUint64(header, uint64(expires))
+	header = append(header, encodeID(envelope.Sender)...)
+	return append(header, encodeID(envelope.Recipient)...)
+}
+
+// signed returns the data that the sender signs: the header, and the
+// ciphertext with its length (4 bytes, big endian).
+func (envelope *Envelope) signed() []byte {
+	signed := binary.BigEndian.AppendUint32(envelope.header(), uint32(len(envelope.Ciphertext)))
+	return append(signed, envelope.Ciphertext...)
+}
+
+// Marshal encodes the envelope as the data it signs, without the domain
+// prefix, followed by the signature.
+func (envelope *Envelope) Marshal() []byte {
+	encoded := envelope.signed()[len(envelopeDomain):]
+	return append(encoded, envelope.Signature.Marshal()...)
+}
+
+// Unmarshal recovers the envelope from its encoding. The signature is only
+// checked by Open.
+func (envelope *Envelope) Unmarshal(encoded []byte) (*Envelope, bool) {
+	if len(encoded) < 17 || encoded[0] != EnvelopeVersion {
+		return nil, false
+	}
+	envelope.Version = encoded[0]
+	envelope.Created = time.Unix(0, int64(binary.BigEndian.Uint64(encoded[1:])))
+	envelope.Expires = time.Time{}
+	if expires := int64(binary.BigEndian.Uint64(encoded[9:])); expires != 0 {
+		envelope.Expires = time.Unix(0, expires)
+	}
+	var ok bool
+	if envelope.Sender, encoded, ok = decodeID(encoded[17:]); !ok {
+		return nil, false
+	}
+	if envelope.Recipient, encoded, ok = decodeID(encoded); !ok || len(encoded) < 4 {
+		return nil, false
+	}
+	size := binary.BigEndian.Uint32(encoded)
+	encoded = encoded[4:]
+	if uint64(len(encoded)) != uint64(size)+3<<geShift {
+		return nil, false
+	}
+	envelope.Ciphertext = append([]byte{}, encoded[:size]...)
+	if envelope.Signature, ok = new(Signature).Unmarshal(encoded[size:]); !ok {
+		return nil, false
+	}
+	return envelope, true
+}
@@ -0,0 +1,115 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestEnvelope(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := []*big.Int{big.NewInt(1), big.NewInt(2)}
+	bob := []*big.Int{big.NewInt(1), big.NewInt(3)}
+	carol := []*big.Int{big.NewInt(1), big.NewInt(4)}
+	alicekey, err := KeyGenFromMaster(rand.Reader, params, master, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobkey, err := KeyGenFromMaster(rand.Reader, params, master, bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	carolkey, err := KeyGenFromMaster(rand.Reader, params, master, carol)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	envelope, err := Seal(rand.Reader, params, alicekey, alice, bob, []byte("hello bob"), now, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, ok := new(Envelope).Unmarshal(envelope.Marshal())
+	if !ok {
+		t.Fatal("Failed to unmarshal envelope")
+	}
+	message, err := Open(params, bobkey, decoded, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(message, []byte("hello bob")) {
+		t.Fatal("Opened message does not match")
+	}
+
+	// Only the recipient can open it, and only while it is valid
+	if _, err = Open(params, carolkey, envelope, now); err == nil {
+		t.Fatal("Envelope opened by another recipient")
+	}
+	if _, err = Open(params, bobkey, envelope, now.Add(2*time.Hour)); !errors.Is(err, ErrEnvelopeExpired) {
+		t.Fatal("Expired envelope opened")
+	}
+	if _, err = Open(params, bobkey, envelope, now.Add(-time.Hour)); !errors.Is(err, ErrEnvelopeExpired) {
+		t.Fatal("Envelope from the future opened")
+	}
+
+	// Changing any signed field breaks the signature
+	tampered := *envelope
+	tampered.Expires = now.Add(24 * time.Hour)
+	if _, err = Open(params, bobkey, &tampered, now); err == nil {
+		t.Fatal("Envelope with a changed expiry opened")
+	}
+
+	// Carol cannot claim the ciphertext as her own by signing it again
+	resigned := *envelope
+	resigned.Sender = carol
+	if resigned.Signature, err = Sign(rand.Reader, params, carolkey, carol, resigned.signed()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Open(params, bobkey, &resigned, now); err == nil {
+		t.Fatal("Re-signed envelope opened")
+	}
+
+	if _, ok = new(Envelope).Unmarshal(envelope.Marshal()[:40]); ok {
+		t.Fatal("Unmarshalled a truncated envelope")
+	}
+}
+
+func TestEnvelopeAAD(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := []*big.Int{big.NewInt(1), big.NewInt(2)}
+	bob := []*big.Int{big.NewInt(1), big.NewInt(3)}
+	alicekey, err := KeyGenFromMaster(rand.Reader, params, master, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobkey, err := KeyGenFromMaster(rand.Reader, params, master, bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	route := []byte("route: queue-7")
+	envelope, err := Seal(rand.Reader, params, alicekey, alice, bob, []byte("hello bob"), now, time.Hour, WithAAD(route))
+	if err != nil {
+		t.Fatal(err)
+	}
+	message, err := Open(params, bobkey, envelope, now, WithDecryptAAD(route))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(message, []byte("hello bob")) {
+		t.Fatal("Opened message does not match")
+	}
+	if _, err = Open(params, bobkey, envelope, now, WithDecryptAAD([]byte("route: queue-8"))); !errors.Is(err, ErrDecryptFailed) {
+		t.Fatal("Envelope opened with a changed routing header")
+	}
+}
@@ -0,0 +1,35 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/subtle"
+)
+
+// Equal reports whether params and other are the same parameters. Cached
+// values such as the precomputed pairing are not compared.
+func (params *Params) Equal(other *Params) bool {
+	if params == nil || other == nil {
+		return params == other
+	}
+	return bytes.Equal(params.marshalBody(nil), other.marshalBody(nil))
+}
+
+// Equal reports whether key and other hold the same key material, in
+// constant time for keys of the same shape. The delegation policy and the
+// parameters binding are not compared.
+func (key *PrivateKey) Equal(other *PrivateKey) bool {
+	if key == nil || other == nil {
+		return key == other
+	}
+	return subtle.ConstantTimeCompare(key.marshalBody(nil), other.marshalBody(nil)) == 1
+}
+
+// Equal reports whether ciphertext and other are the same ciphertext,
+// including the integrity tag and the parameters binding.
+func (ciphertext *Ciphertext) Equal(other *Ciphertext) bool {
+	if ciphertext == nil || other == nil {
+		return ciphertext == other
+	}
+	return bytes.Equal(ciphertext.marshalBody(nil), other.marshalBody(nil)) &&
+		bytes.Equal(ciphertext.ParamsFingerprint, other.ParamsFingerprint)
+}
@@ -0,0 +1,50 @@
+package hibe_sm9
+
+import (
+	"crypto/rand"
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !params.Equal(params.Clone()) || params.Equal(other) || params.Equal(nil) {
+		t.Fatal("Parameters compared incorrectly")
+	}
+
+	key, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	rerandomized, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !key.Equal(key.Clone()) || key.Equal(rerandomized) || key.Equal(nil) {
+		t.Fatal("Private keys compared incorrectly")
+	}
+
+	ciphertext, err := Encrypt(rand.Reader, params, LINEAR_HIERARCHY[:1], NewMessage(), WithIntegrityTag())
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone := ciphertext.Clone()
+	if !ciphertext.Equal(clone) {
+		t.Fatal("Ciphertext differs from its clone")
+	}
+	clone.Tag[0] ^= 1
+	if ciphertext.Equal(clone) {
+		t.Fatal("Ciphertexts with different tags are equal")
+	}
+	clone = ciphertext.Clone()
+	clone.ParamsFingerprint = nil
+	if ciphertext.Equal(clone) {
+		t.Fatal("Ciphertexts with different bindings are equal")
+	}
+}
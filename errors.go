@@ -0,0 +1,109 @@
+package hibe_sm9
+
+import (
+	"errors"
+	"golang.org/x/crypto/bn256"
+	"math/big"
+)
+
+// The errors below classify the failures of this package. The errors that are
+// actually returned usually wrap one of them with more detail, so they should
+// be tested for with errors.Is rather than compared directly.
+var (
+	// ErrInvalidID is returned for identities that cannot be used, such as
+	// those with missing components, and for keys that do not belong to the
+	// identity they are used with.
+	ErrInvalidID = errors.New("hibe: invalid identity")
+
+	// ErrDepthExceeded is returned for identities deeper than the hierarchy,
+	// and for keys that cannot delegate any further.
+	ErrDepthExceeded = errors.New("hibe: identity is deeper than the hierarchy")
+
+	// ErrMalformedCiphertext is returned when a ciphertext or other encrypted
+	// message cannot be parsed.
+	ErrMalformedCiphertext = errors.New("hibe: malformed ciphertext")
+
+	// ErrCurveMismatch is returned when an object does not belong to the
+	// parameters it is used with: it is for a different curve, depth or kind
+	// of hierarchy.
+	ErrCurveMismatch = errors.New("hibe: object does not match the parameters")
+
+	// ErrDecryptFailed is returned when a ciphertext fails authentication, or
+	// the key cannot decrypt it.
+	ErrDecryptFailed = errors.New("hibe: decryption failed")
+)
+
+// wrapError returns an error that reads as detail but matches sentinel with
+// errors.Is.
+func wrapError(sentinel error, detail string) error {
+	return &detailedError{sentinel: sentinel, detail: detail}
+}
+
+type detailedError struct {
+	sentinel error
+	detail   string
+}
+
+func (err *detailedError) Error() string {
+	return err.detail
+}
+
+func (err *detailedError) Unwrap() error {
+	return err.sentinel
+}
+
+var (
+	errMissingComponent     = wrapError(ErrInvalidID, "hibe: identity has a missing component")
+	errEmptyID              = wrapError(ErrInvalidID, "hibe: identity is empty")
+	errComponentRange       = wrapError(ErrInvalidID, "hibe: identity component is not in [1, Order)")
+	errNotParent            = wrapError(ErrInvalidID, "hibe: key is not for the parent of the identity")
+	errSigningKey           = wrapError(ErrInvalidID, "hibe: signing key is not the key for the identity")
+	errAnonymousDelegation  = wrapError(ErrDelegationDenied, "hibe: keys in an anonymous hierarchy cannot be delegated")
+	errCheckAnonymous       = wrapError(ErrMalformedCiphertext, "hibe: anonymous ciphertexts cannot be checked against an identity")
+	errCiphertextRelation   = wrapError(ErrMalformedCiphertext, "hibe: ciphertext is not well formed for the identity")
+	errIncompleteKey        = wrapError(ErrInvalidID, "hibe: private key is missing components")
+	errIncompleteCiphertext = wrapError(ErrMalformedCiphertext, "hibe: ciphertext is missing components")
+)
+
+// checkID verifies that id can be used in the hierarchy with the provided
+// parameters. Components must be in [1, Order): 0 cancels the level's
+// element, and values congruent modulo the order would make distinct
+// identities share keys.
+func checkID(params *Params, id []*big.Int) error {
+	if len(id) == 0 {
+		return errEmptyID
+	}
+	if len(id) > len(params.H) {
+		return ErrDepthExceeded
+	}
+	return checkIDComponents(id)
+}
+
+// checkIDComponents verifies that every component of id is in [1, Order).
+func checkIDComponents(id []*big.Int) error {
+	for _, component := range id {
+		if component == nil {
+			return errMissingComponent
+		}
+		if component.Sign() <= 0 || component.Cmp(bn256.Order) >= 0 {
+			return errComponentRange
+		}
+	}
+	return nil
+}
+
+// checkComponents verifies that key and ciphertext have the components that
+// decryption pairs, so that a zero or partly filled value is reported as an
+// error instead of dereferencing nil.
+func checkComponents(key *PrivateKey, ciphertext *Ciphertext) error {
+	if key == nil || key.A0 == nil || (key.A1 == nil && key.A1Hat == nil) {
+		return errIncompleteKey
+	}
+	if ciphertext == nil || ciphertext.A == nil || ciphertext.B == nil || (ciphertext.C == nil && ciphertext.CHat == nil) {
+		return errIncompleteCiphertext
+	}
+	if ciphertext.CHat != nil && key.A1Hat == nil || ciphertext.CHat == nil && key.A1 == nil {
+		return errModeMismatch
+	}
+	return nil
+}
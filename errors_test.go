@@ -0,0 +1,98 @@
+package hibe_sm9
+
+import (
+	"crypto/rand"
+	"errors"
+	"golang.org/x/crypto/bn256"
+	"math/big"
+	"testing"
+)
+
+func TestErrors(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tooDeep := append(LINEAR_HIERARCHY[:3:3], big.NewInt(4))
+	if _, err = KeyGenFromMaster(rand.Reader, params, master, tooDeep); !errors.Is(err, ErrDepthExceeded) {
+		t.Fatal("Generated a key deeper than the hierarchy")
+	}
+	if _, err = Encrypt(rand.Reader, params, tooDeep, NewMessage()); !errors.Is(err, ErrDepthExceeded) {
+		t.Fatal("Encrypted for an identity deeper than the hierarchy")
+	}
+	if _, err = Encrypt(rand.Reader, params, []*big.Int{nil}, NewMessage()); !errors.Is(err, ErrInvalidID) {
+		t.Fatal("Encrypted for an identity with a missing component")
+	}
+	for _, id := range [][]*big.Int{
+		{},
+		{big.NewInt(0)},
+		{big.NewInt(-1)},
+		{big.NewInt(1), bn256.Order},
+		{big.NewInt(1), new(big.Int).Add(bn256.Order, big.NewInt(2))},
+	} {
+		if _, err = KeyGenFromMaster(rand.Reader, params, master, id); !errors.Is(err, ErrInvalidID) {
+			t.Fatal("Generated a key for an out-of-range identity", id)
+		}
+		if _, err = Encrypt(rand.Reader, params, id, NewMessage()); !errors.Is(err, ErrInvalidID) {
+			t.Fatal("Encrypted for an out-of-range identity", id)
+		}
+	}
+
+	key, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = KeyGenFromParent(rand.Reader, params, key, LINEAR_HIERARCHY); !errors.Is(err, ErrInvalidID) {
+		t.Fatal("Delegated to an identity that is not a child")
+	}
+	child, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Sign(rand.Reader, params, child, LINEAR_HIERARCHY, []byte("message")); !errors.Is(err, ErrDepthExceeded) {
+		t.Fatal("Signed with a key that cannot delegate")
+	}
+
+	ciphertext, err := EncryptBytes(rand.Reader, params, LINEAR_HIERARCHY[:1], []byte("message"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = DecryptBytes(key, ciphertext[:10]); !errors.Is(err, ErrMalformedCiphertext) {
+		t.Fatal("Truncated ciphertext is not reported as malformed")
+	}
+	ciphertext[len(ciphertext)-1] ^= 1
+	if _, err = DecryptBytes(key, ciphertext); !errors.Is(err, ErrDecryptFailed) {
+		t.Fatal("Tampered ciphertext is not reported as a failed decryption")
+	}
+	if err.Error() != errHybridAuth.Error() {
+		t.Fatal("Wrapped error lost its message")
+	}
+}
+
+func TestDecryptIncomplete(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := Encrypt(rand.Reader, params, LINEAR_HIERARCHY[:1], NewMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, incomplete := range []*PrivateKey{nil, {}, {A0: key.A0}, {A1: key.A1, B: key.B}} {
+		if _, err = Decrypt(incomplete, ciphertext); !errors.Is(err, ErrInvalidID) {
+			t.Fatal("Decrypted with an incomplete key")
+		}
+	}
+	for _, incomplete := range []*Ciphertext{nil, {}, {A: ciphertext.A, B: ciphertext.B}, {B: ciphertext.B, C: ciphertext.C}} {
+		if _, err = Decrypt(key, incomplete); !errors.Is(err, ErrMalformedCiphertext) {
+			t.Fatal("Decrypted an incomplete ciphertext")
+		}
+	}
+	if _, errs := DecryptBatch(&PrivateKey{}, []*Ciphertext{ciphertext}); !errors.Is(errs[0], ErrInvalidID) {
+		t.Fatal("Batch decrypted with an incomplete key")
+	}
+}
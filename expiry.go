@@ -0,0 +1,129 @@
+package hibe_sm9
+
+import (
+	"encoding/binary"
+	"errors"
+	"golang.org/x/crypto/bn256"
+	"io"
+	"math/big"
+	"time"
+)
+
+// ErrKeyExpired is returned by DecryptWithTime outside the validity period of
+// a key.
+var ErrKeyExpired = errors.New("hibe: key is outside its validity period")
+
+var errValidityPeriod = errors.New("hibe: validity period must be at least one second")
+
+// validityDomain separates validity period components from ordinary identity
+// components and epoch components.
+var validityDomain = []byte("HIBE-VALIDITY")
+
+// ValidityWindow returns the validity period of the given length that
+// contains t. Periods are consecutive whole seconds counted from the Unix
+// epoch, so encryptors and the issuer agree on them without coordination.
+func ValidityWindow(period time.Duration, t time.Time) (notBefore time.Time, notAfter time.Time) {
+	seconds := int64(period / time.Second)
+	index := t.Unix() / seconds
+	if t.Unix() < 0 && t.Unix()%seconds != 0 {
+		index--
+	}
+	notBefore = time.Unix(index*seconds, 0).UTC()
+	return notBefore, notBefore.Add(time.Duration(seconds) * time.Second)
+}
+
+// PeriodID returns the identity that id takes on during the validity period
+// of the given length that contains t. As with EpochID, the period is
+// appended as one more level of the hierarchy.
+func PeriodID(id []*big.Int, period time.Duration, t time.Time) []*big.Int {
+	notBefore, _ := ValidityWindow(period, t)
+	var encoded [16]byte
+	binary.BigEndian.PutUint64(encoded[:8], uint64(period/time.Second))
+	binary.BigEndian.PutUint64(encoded[8:], uint64(notBefore.Unix()))
+	component := HashToZp(append(append([]byte{}, validityDomain...), encoded[:]...))
+
+	periodid := make([]*big.Int, len(id), len(id)+1)
+	copy(periodid, id)
+	return append(periodid, component)
+}
+
+// ExpiringKey is the key of an identity for one validity period, issued by
+// KeyGenExpiring or KeyGenExpiringFromParent. It only decrypts ciphertexts
+// encrypted with EncryptForPeriod for the same period.
+//
+// DecryptWithTime refuses to use the key outside its period, but that is a
+// courtesy to honest holders: the lifetime is really enforced by encryptors
+// moving on to the next period, after which the key has nothing to decrypt.
+type ExpiringKey struct {
+	*PrivateKey
+
+	// ID is the identity the key was issued for, without the period.
+	ID        []*big.Int
+	NotBefore time.Time
+	NotAfter  time.Time
+}
+
+// newExpiringKey checks the period and returns an ExpiringKey for id without
+// the private key.
+func newExpiringKey(params *Params, id []*big.Int, period time.Duration, t time.Time) (*ExpiringKey, []*big.Int, error) {
+	if period < time.Second {
+		return nil, nil, errValidityPeriod
+	}
+	if len(id) >= params.MaximumDepth() {
+		return nil, nil, wrapError(ErrDepthExceeded, "hibe: no room for the validity period below the identity")
+	}
+	key := &ExpiringKey{ID: append([]*big.Int{}, id...)}
+	key.NotBefore, key.NotAfter = ValidityWindow(period, t)
+	return key, PeriodID(id, period, t), nil
+}
+
+// KeyGenExpiring generates the key of id for the validity period of the
+// given length that contains t, using the master key.
+func KeyGenExpiring(random io.Reader, params *Params, master MasterKey, id []*big.Int, period time.Duration, t time.Time, opts ...KeyGenOption) (*ExpiringKey, error) {
+	key, periodid, err := newExpiringKey(params, id, period, t)
+	if err != nil {
+		return nil, err
+	}
+	if key.PrivateKey, err = KeyGenFromMaster(random, params, master, periodid, opts...); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// KeyGenExpiringFromParent is like KeyGenExpiring, but uses the key of id
+// itself, which is the parent of every period of id. This lets the holder of
+// a long-term key hand out short-lived keys for its own identity.
+func KeyGenExpiringFromParent(random io.Reader, params *Params, parent *PrivateKey, id []*big.Int, period time.Duration, t time.Time, opts ...KeyGenOption) (*ExpiringKey, error) {
+	key, periodid, err := newExpiringKey(params, id, period, t)
+	if err != nil {
+		return nil, err
+	}
+	if key.PrivateKey, err = KeyGenFromParent(random, params, parent, periodid, opts...); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Valid reports whether now is within the validity period of the key.
+func (key *ExpiringKey) Valid(now time.Time) bool {
+	return !now.Before(key.NotBefore) && now.Before(key.NotAfter)
+}
+
+// EncryptForPeriod encrypts message to id during the validity period of the
+// given length that contains t, usually the current time, so that only an
+// ExpiringKey for that period decrypts it.
+func EncryptForPeriod(random io.Reader, params *Params, id []*big.Int, period time.Duration, t time.Time, message *bn256.GT, opts ...EncryptOption) (*Ciphertext, error) {
+	if period < time.Second {
+		return nil, errValidityPeriod
+	}
+	return Encrypt(random, params, PeriodID(id, period, t), message, opts...)
+}
+
+// DecryptWithTime decrypts ciphertext with key, or returns ErrKeyExpired if
+// now is outside the validity period of the key.
+func DecryptWithTime(key *ExpiringKey, ciphertext *Ciphertext, now time.Time) (*bn256.GT, error) {
+	if !key.Valid(now) {
+		return nil, ErrKeyExpired
+	}
+	return Decrypt(key.PrivateKey, ciphertext)
+}
@@ -0,0 +1,88 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestValidityWindow(t *testing.T) {
+	now := time.Date(2025, 6, 11, 15, 30, 0, 0, time.UTC)
+	notBefore, notAfter := ValidityWindow(24*time.Hour, now)
+	if !notBefore.Equal(time.Date(2025, 6, 11, 0, 0, 0, 0, time.UTC)) || !notAfter.Equal(notBefore.Add(24*time.Hour)) {
+		t.Fatal("Wrong window for a day period")
+	}
+	notBefore, _ = ValidityWindow(time.Hour, time.Unix(-1, 0))
+	if notBefore.Unix() != -3600 {
+		t.Fatal("Wrong window before the epoch")
+	}
+	if PeriodID(LINEAR_HIERARCHY, time.Hour, now)[3].Cmp(PeriodID(LINEAR_HIERARCHY, time.Hour, now.Add(29*time.Minute))[3]) != 0 {
+		t.Fatal("Times in the same period have different identities")
+	}
+	if PeriodID(LINEAR_HIERARCHY, time.Hour, now)[3].Cmp(PeriodID(LINEAR_HIERARCHY, time.Hour, now.Add(time.Hour))[3]) == 0 {
+		t.Fatal("Consecutive periods have the same identity")
+	}
+}
+
+func TestExpiringKey(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2025, 6, 11, 15, 30, 0, 0, time.UTC)
+	message := NewMessage()
+
+	key, err := KeyGenExpiring(rand.Reader, params, master, LINEAR_HIERARCHY, 24*time.Hour, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := EncryptForPeriod(rand.Reader, params, LINEAR_HIERARCHY, 24*time.Hour, now.Add(time.Hour), message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := DecryptWithTime(key, ciphertext, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(message.Marshal(), decrypted.Marshal()) {
+		t.Fatal("Original and decrypted messages differ")
+	}
+	if _, err = DecryptWithTime(key, ciphertext, key.NotAfter); !errors.Is(err, ErrKeyExpired) {
+		t.Fatal("Expired key was used")
+	}
+	if _, err = DecryptWithTime(key, ciphertext, key.NotBefore.Add(-time.Second)); !errors.Is(err, ErrKeyExpired) {
+		t.Fatal("Key was used before its period")
+	}
+
+	// A long-term key hands out keys for its own periods
+	parent, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := KeyGenExpiringFromParent(rand.Reader, params, parent, LINEAR_HIERARCHY, 24*time.Hour, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err = DecryptWithTime(child, ciphertext, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(message.Marshal(), decrypted.Marshal()) {
+		t.Fatal("Key from parent does not decrypt")
+	}
+
+	// The next period's ciphertexts are out of reach
+	next, err := EncryptForPeriod(rand.Reader, params, LINEAR_HIERARCHY, 24*time.Hour, key.NotAfter, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(message.Marshal(), mustDecrypt(t, key.PrivateKey, next).Marshal()) {
+		t.Fatal("Key decrypts the next period")
+	}
+
+	if _, err = KeyGenExpiring(rand.Reader, params, master, LINEAR_HIERARCHY, time.Millisecond, now); err == nil {
+		t.Fatal("Sub-second period was accepted")
+	}
+}
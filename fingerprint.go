@@ -0,0 +1,65 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"hash"
+)
+
+// FingerprintSize is the size in bytes of a fingerprint.
+const FingerprintSize = sha256.Size
+
+var errParamsMismatch = wrapError(ErrCurveMismatch, "hibe: key or ciphertext belongs to a different hierarchy")
+
+// Fingerprint returns the SHA-256 hash of the canonical (uncompressed,
+// headerless) encoding of g, g1, g2 and g3, and of g3Hat in anonymous
+// hierarchies. These determine the master key, so two parameters have the same
+// fingerprint exactly when they belong to the same hierarchy. h1 ... hl are
+// left out so that parameters extended with ExtendDepth keep their
+// fingerprint, since the keys and ciphertexts of the hierarchy remain valid
+// under them. A non-default identity hash is included too, so that it cannot
+// be changed without changing the fingerprint.
+//
+// Keys and ciphertexts record the fingerprint of the parameters they were
+// created under, and Marshal embeds it in their header, so that mixing them up
+// across hierarchies is reported as ErrCurveMismatch instead of yielding
+// garbage.
+func (params *Params) Fingerprint() []byte {
+	return params.fingerprintWith(sha256.New())
+}
+
+// fingerprintWith is Fingerprint with another hash function.
+func (params *Params) fingerprintWith(hash hash.Hash) []byte {
+	hash.Write(params.G.Marshal())
+	hash.Write(params.G1.Marshal())
+	hash.Write(params.G2.Marshal())
+	hash.Write(params.G3.Marshal())
+	if params.G3Hat != nil {
+		hash.Write(params.G3Hat.Marshal())
+	}
+	if params.IdentityHash != IdentityHashSHA256 {
+		hash.Write(identityHashMarker(params.IdentityHash))
+	}
+	return hash.Sum(nil)
+}
+
+// Fingerprint returns the SHA-256 hash of the canonical (uncompressed,
+// headerless) encoding of the key. Since it identifies secret material, it
+// should only be shown to the holder of the key, for instance to compare
+// backups.
+func (key *PrivateKey) Fingerprint() []byte {
+	encoded := key.marshalBody(nil)
+	defer zeroizeBytes(encoded)
+	fingerprint := sha256.Sum256(encoded)
+	return fingerprint[:]
+}
+
+// checkBinding reports whether two objects may belong to the same hierarchy,
+// given the fingerprints of their parameters. Unknown fingerprints match
+// anything.
+func checkBinding(a []byte, b []byte) error {
+	if a != nil && b != nil && !bytes.Equal(a, b) {
+		return errParamsMismatch
+	}
+	return nil
+}
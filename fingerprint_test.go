@@ -0,0 +1,84 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherParams, otherMaster, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(params.Fingerprint()) != FingerprintSize || bytes.Equal(params.Fingerprint(), otherParams.Fingerprint()) {
+		t.Fatal("Different hierarchies have the same fingerprint")
+	}
+	decoded, ok := new(Params).Unmarshal(params.Marshal(WithCompression()))
+	if !ok || !bytes.Equal(decoded.Fingerprint(), params.Fingerprint()) {
+		t.Fatal("Fingerprint changed after encoding")
+	}
+	extended, err := ExtendDepth(rand.Reader, params, master, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(extended.Fingerprint(), params.Fingerprint()) {
+		t.Fatal("Fingerprint changed after extending the hierarchy")
+	}
+
+	key, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	decodedKey, ok := new(PrivateKey).Unmarshal(key.Marshal())
+	if !ok || !bytes.Equal(decodedKey.Fingerprint(), key.Fingerprint()) || !bytes.Equal(decodedKey.ParamsFingerprint, params.Fingerprint()) {
+		t.Fatal("Key fingerprints changed after encoding")
+	}
+	header, err := ParseHeader(key.Marshal())
+	if err != nil || !bytes.Equal(header.Fingerprint, params.Fingerprint()) {
+		t.Fatal("Header does not carry the fingerprint of the parameters")
+	}
+
+	// Keys and ciphertexts of different hierarchies are not mixed up
+	otherKey, err := KeyGenFromMaster(rand.Reader, otherParams, otherMaster, LINEAR_HIERARCHY[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := Encrypt(rand.Reader, params, LINEAR_HIERARCHY[:1], NewMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	decodedCiphertext, ok := new(Ciphertext).Unmarshal(ciphertext.Marshal())
+	if !ok {
+		t.Fatal("Could not decode ciphertext")
+	}
+	if _, err = Decrypt(otherKey, decodedCiphertext); !errors.Is(err, ErrCurveMismatch) {
+		t.Fatal("Decrypted with a key from another hierarchy")
+	}
+	if _, err = KeyGenFromParent(rand.Reader, otherParams, decodedKey, LINEAR_HIERARCHY[:2]); !errors.Is(err, ErrCurveMismatch) {
+		t.Fatal("Delegated a key under the parameters of another hierarchy")
+	}
+
+	// Encodings with a version 1 header, which has no fingerprint, still
+	// decode, and match any hierarchy
+	legacy := append(append([]byte{}, ciphertext.Marshal()[:headerSizeV1]...), ciphertext.marshalBody(nil)...)
+	legacy[4] = 1
+	decodedCiphertext, ok = new(Ciphertext).Unmarshal(legacy)
+	if !ok || decodedCiphertext.ParamsFingerprint != nil {
+		t.Fatal("Could not decode a version 1 ciphertext")
+	}
+	if !bytes.Equal(NewMessage().Marshal(), mustDecrypt(t, key, decodedCiphertext).Marshal()) {
+		t.Fatal("Version 1 ciphertext does not decrypt")
+	}
+
+	encoded := params.Marshal()
+	encoded[headerSizeV1] ^= 1
+	if _, ok = new(Params).Unmarshal(encoded); ok {
+		t.Fatal("Decoded parameters whose fingerprint does not match")
+	}
+}
@@ -0,0 +1,269 @@
+// Package fshibe adds forward security to a HIBE hierarchy, by using the last
+// three levels of the hierarchy for the time period (year, month and day) in
+// which a message is encrypted.
+//
+// A user's Key does not hold the key for their identity. Instead, it holds
+// keys for the nodes of the time tree that cover the current period and every
+// later one, up to the end of a configured last year: the key for the current
+// day, keys for the remaining days of the month, keys for the remaining months
+// of the year, and keys for the remaining years. Update moves the key forward
+// by deriving the keys it needs from these and destroying the keys for
+// earlier periods, so a key compromised later cannot decrypt messages from
+// periods that have already passed.
+package fshibe
+
+import (
+	"errors"
+	"golang.org/x/crypto/bn256"
+	"hibe_sm9"
+	"io"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// Levels is the number of hierarchy levels used for the time period.
+const Levels = 3
+
+var (
+	// ErrPeriodPassed is returned when moving a key to a period before its
+	// current one.
+	ErrPeriodPassed = errors.New("fshibe: period has already passed")
+
+	// ErrPeriodOutOfRange is returned for periods after the last year that a
+	// key covers.
+	ErrPeriodOutOfRange = errors.New("fshibe: period is beyond the last year of the key")
+
+	// ErrHierarchyTooShallow is returned when the hierarchy has no room for
+	// the time levels below the identity.
+	ErrHierarchyTooShallow = errors.New("fshibe: hierarchy is too shallow for the identity and time levels")
+)
+
+// Period is a day, the smallest time period of a key.
+type Period struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// PeriodOf returns the period containing t, in the location of t.
+func PeriodOf(t time.Time) Period {
+	year, month, day := t.Date()
+	return Period{year, month, day}
+}
+
+// Before reports whether the period p is before q.
+func (p Period) Before(q Period) bool {
+	if p.Year != q.Year {
+		return p.Year < q.Year
+	}
+	if p.Month != q.Month {
+		return p.Month < q.Month
+	}
+	return p.Day < q.Day
+}
+
+func (p Period) path() []int {
+	return []int{p.Year, int(p.Month), p.Day}
+}
+
+// valid reports whether p is a day of the calendar.
+func (p Period) valid() bool {
+	return PeriodOf(time.Date(p.Year, p.Month, p.Day, 0, 0, 0, 0, time.UTC)) == p
+}
+
+// daysIn returns the number of days in a month.
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// Identity returns the identity in the hierarchy that messages for id in the
+// given period are encrypted to.
+func Identity(id []*big.Int, period Period) []*big.Int {
+	return appendPath(id, period.path())
+}
+
+func appendPath(id []*big.Int, path []int) []*big.Int {
+	full := make([]*big.Int, len(id), len(id)+len(path))
+	copy(full, id)
+	for _, component := range path {
+		full = append(full, big.NewInt(int64(component)))
+	}
+	return full
+}
+
+// Encrypt encrypts message for id in the given period.
+func Encrypt(random io.Reader, params *hibe_sm9.Params, id []*big.Int, period Period, message *bn256.GT) (*hibe_sm9.Ciphertext, error) {
+	if len(id)+Levels > params.MaximumDepth() {
+		return nil, ErrHierarchyTooShallow
+	}
+	if !period.valid() {
+		return nil, errors.New("fshibe: invalid period")
+	}
+	return hibe_sm9.Encrypt(random, params, Identity(id, period), message)
+}
+
+// node is the key for a node of the time tree: a year, a month or a day.
+type node struct {
+	path []int
+	key  *hibe_sm9.PrivateKey
+}
+
+// Key is a forward-secure private key for an identity.
+type Key struct {
+	params   *hibe_sm9.Params
+	id       []*big.Int
+	period   Period
+	lastYear int
+
+	// Nodes covering the current period and every later one, in order.
+	nodes []node
+}
+
+// NewKey creates a forward-secure key for id, starting in period start and
+// valid until the end of lastYear, from the ordinary private key for id. The
+// caller should destroy userkey afterwards (see PrivateKey.Zeroize), since it
+// can derive the keys for every period.
+func NewKey(random io.Reader, params *hibe_sm9.Params, userkey *hibe_sm9.PrivateKey, id []*big.Int, start Period, lastYear int) (*Key, error) {
+	if len(id)+Levels > params.MaximumDepth() {
+		return nil, ErrHierarchyTooShallow
+	}
+	if !start.valid() {
+		return nil, errors.New("fshibe: invalid period")
+	}
+	if start.Year > lastYear {
+		return nil, ErrPeriodOutOfRange
+	}
+	key := &Key{params: params, id: append([]*big.Int{}, id...), period: start, lastYear: lastYear}
+	nodes, err := key.expand(random, node{key: userkey}, start)
+	if err != nil {
+		return nil, err
+	}
+	key.nodes = nodes
+	return key, nil
+}
+
+// Period returns the current period of the key.
+func (key *Key) Period() Period {
+	return key.period
+}
+
+// derive generates the key for the child of parent with the next time
+// component.
+func (key *Key) derive(random io.Reader, parent node, component int) (node, error) {
+	path := append(append([]int{}, parent.path...), component)
+	child, err := hibe_sm9.KeyGenFromParent(random, key.params, parent.key, appendPath(key.id, path))
+	if err != nil {
+		return node{}, err
+	}
+	return node{path: path, key: child}, nil
+}
+
+// expand returns the keys for nodes below parent that cover period and every
+// later period within parent. The key of parent itself is not included.
+func (key *Key) expand(random io.Reader, parent node, period Period) ([]node, error) {
+	var first, last int
+	switch len(parent.path) {
+	case 0:
+		first, last = period.Year, key.lastYear
+	case 1:
+		first, last = int(period.Month), 12
+	case 2:
+		first, last = period.Day, daysIn(period.Year, period.Month)
+	default:
+		return []node{parent}, nil
+	}
+
+	// The child containing period is expanded further, and discarded
+	child, err := key.derive(random, parent, first)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := key.expand(random, child, period)
+	if len(child.path) < Levels {
+		child.key.Zeroize()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for component := first + 1; component <= last; component++ {
+		sibling, err := key.derive(random, parent, component)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, sibling)
+	}
+	return nodes, nil
+}
+
+// covers reports whether the node is an ancestor of (or is) the period.
+func (n node) covers(period Period) bool {
+	path := period.path()
+	for i, component := range n.path {
+		if path[i] != component {
+			return false
+		}
+	}
+	return true
+}
+
+// Update moves the key forward to period, destroying the keys for every
+// earlier period.
+func (key *Key) Update(random io.Reader, period Period) error {
+	if !period.valid() {
+		return errors.New("fshibe: invalid period")
+	}
+	if period.Before(key.period) {
+		return ErrPeriodPassed
+	}
+	if period.Year > key.lastYear {
+		return ErrPeriodOutOfRange
+	}
+
+	// Nodes are ordered by time, so the ones that have passed come first,
+	// followed by the one covering period
+	i := sort.Search(len(key.nodes), func(i int) bool {
+		return key.nodes[i].covers(period) || !key.nodes[i].before(period)
+	})
+	for _, passed := range key.nodes[:i] {
+		passed.key.Zeroize()
+	}
+	remaining := key.nodes[i:]
+
+	expanded, err := key.expand(random, remaining[0], period)
+	if err != nil {
+		return err
+	}
+	if len(remaining[0].path) < Levels {
+		remaining[0].key.Zeroize()
+	}
+	key.nodes = append(expanded, remaining[1:]...)
+	key.period = period
+	return nil
+}
+
+// before reports whether every period below the node is before period.
+func (n node) before(period Period) bool {
+	path := period.path()
+	for i, component := range n.path {
+		if component != path[i] {
+			return component < path[i]
+		}
+	}
+	return false
+}
+
+// Decrypt decrypts a ciphertext encrypted for the identity of the key in its
+// current period.
+func (key *Key) Decrypt(ciphertext *hibe_sm9.Ciphertext) (*bn256.GT, error) {
+	return hibe_sm9.Decrypt(key.nodes[0].key, ciphertext)
+}
+
+// Zeroize destroys every key held by the forward-secure key.
+func (key *Key) Zeroize() {
+	for _, n := range key.nodes {
+		n.key.Zeroize()
+	}
+	key.nodes = nil
+}
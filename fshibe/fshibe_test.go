@@ -0,0 +1,80 @@
+package fshibe
+
+import (
+	"bytes"
+	"crypto/rand"
+	"hibe_sm9"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestForwardSecurity(t *testing.T) {
+	params, master, err := hibe_sm9.Setup(rand.Reader, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := []*big.Int{big.NewInt(1), big.NewInt(2)}
+	userkey, err := hibe_sm9.KeyGenFromMaster(rand.Reader, params, master, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := Period{2026, time.December, 30}
+	key, err := NewKey(rand.Reader, params, userkey, id, start, 2028)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userkey.Zeroize()
+
+	// 2 days, no months, 2 years
+	if len(key.nodes) != 4 {
+		t.Fatalf("Key holds %d nodes", len(key.nodes))
+	}
+
+	message := hibe_sm9.HashToGT([]byte("message"))
+	past, err := Encrypt(rand.Reader, params, id, start, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := key.Decrypt(past)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(message.Marshal(), decrypted.Marshal()) {
+		t.Fatal("Could not decrypt in the current period")
+	}
+
+	next := Period{2027, time.March, 1}
+	if err = key.Update(rand.Reader, next); err != nil {
+		t.Fatal(err)
+	}
+	current, err := Encrypt(rand.Reader, params, id, next, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decrypted, err = key.Decrypt(current); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(message.Marshal(), decrypted.Marshal()) {
+		t.Fatal("Could not decrypt after update")
+	}
+
+	// No node left in the key covers any earlier period
+	for _, n := range key.nodes {
+		if n.before(next) {
+			t.Fatal("Key still holds a node for a passed period")
+		}
+	}
+	// 31 days of March, 9 months and 1 year
+	if len(key.nodes) != 41 {
+		t.Fatalf("Key holds %d nodes after update", len(key.nodes))
+	}
+
+	if key.Update(rand.Reader, start) != ErrPeriodPassed {
+		t.Fatal("Moved key backwards")
+	}
+	if key.Update(rand.Reader, Period{2029, time.January, 1}) != ErrPeriodOutOfRange {
+		t.Fatal("Moved key beyond its last year")
+	}
+}
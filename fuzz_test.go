@@ -0,0 +1,97 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+// fuzzFixture is a hierarchy with a key and encodings to seed the fuzzers.
+type fuzzFixture struct {
+	params     *Params
+	key        *PrivateKey
+	plaintext  []byte
+	ciphertext []byte
+}
+
+func newFuzzFixture(f *testing.F) *fuzzFixture {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		f.Fatal(err)
+	}
+	key, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[:2])
+	if err != nil {
+		f.Fatal(err)
+	}
+	plaintext := []byte("fuzz")
+	ciphertext, err := EncryptBytes(rand.Reader, params, LINEAR_HIERARCHY[:2], plaintext)
+	if err != nil {
+		f.Fatal(err)
+	}
+	return &fuzzFixture{params: params, key: key, plaintext: plaintext, ciphertext: ciphertext}
+}
+
+func FuzzUnmarshalCiphertext(f *testing.F) {
+	fixture := newFuzzFixture(f)
+	ciphertext, err := Encrypt(rand.Reader, fixture.params, LINEAR_HIERARCHY[:2], NewMessage())
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(ciphertext.Marshal())
+	f.Add(ciphertext.Marshal(WithCompression()))
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, encoded []byte) {
+		decoded, ok := new(Ciphertext).Unmarshal(encoded)
+		if !ok {
+			return
+		}
+		if decoded.Validate() != nil {
+			t.Fatal("Unmarshal accepted an invalid ciphertext")
+		}
+		// Re-encoding must be stable
+		if _, ok = new(Ciphertext).Unmarshal(decoded.Marshal()); !ok {
+			t.Fatal("Could not decode a re-encoded ciphertext")
+		}
+	})
+}
+
+func FuzzUnmarshalPrivateKey(f *testing.F) {
+	fixture := newFuzzFixture(f)
+	f.Add(fixture.key.Marshal())
+	f.Add(fixture.key.Marshal(WithCompression()))
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, encoded []byte) {
+		decoded, ok := new(PrivateKey).Unmarshal(encoded)
+		if !ok {
+			return
+		}
+		if decoded.validatePoints() != nil {
+			t.Fatal("Unmarshal accepted an invalid private key")
+		}
+		if _, ok = new(PrivateKey).Unmarshal(decoded.Marshal()); !ok {
+			t.Fatal("Could not decode a re-encoded private key")
+		}
+	})
+}
+
+func FuzzDecrypt(f *testing.F) {
+	fixture := newFuzzFixture(f)
+	f.Add(fixture.ciphertext)
+	f.Add(fixture.ciphertext[:len(fixture.ciphertext)-1])
+	f.Add([]byte{0, 0, 0, 0})
+
+	f.Fuzz(func(t *testing.T, ciphertext []byte) {
+		plaintext, err := DecryptBytes(fixture.key, ciphertext)
+		if err != nil {
+			return
+		}
+		// Anything that decrypts must be the genuine ciphertext (up to an
+		// alternative encoding of its encapsulation), so the plaintext must
+		// be the original one
+		if !bytes.Equal(plaintext, fixture.plaintext) {
+			t.Fatal("Decrypted a forged ciphertext")
+		}
+	})
+}
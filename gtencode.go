@@ -0,0 +1,115 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"errors"
+	"golang.org/x/crypto/bn256"
+	"math/big"
+	"sync"
+)
+
+// MaxGTPayloadSize is the largest payload, in bytes, that EncodeToGT accepts.
+const MaxGTPayloadSize = 3
+
+// gtStepBits is the number of bits of the exponent covered by the baby steps
+// of DecodeFromGT. Encoded exponents have 8*MaxGTPayloadSize+1 bits.
+const gtStepBits = 13
+
+// gtDecodeKeySize is the prefix of the encoding of a baby step used as its
+// key. A collision only costs a failed check, which is followed by the search
+// continuing.
+const gtDecodeKeySize = 16
+
+var (
+	errGTPayloadSize = errors.New("hibe: payload is too long to encode in GT; use EncryptBytes")
+	errNotEncodedGT  = wrapError(ErrDecryptFailed, "hibe: element of GT does not encode a payload")
+)
+
+// gtDecodeTable maps the baby steps e(g1, g2)^j, for j in
+// [0, 2^gtStepBits), to j.
+var (
+	gtDecodeOnce  sync.Once
+	gtDecodeTable map[string]int64
+	gtGiantStep   *bn256.GT
+)
+
+func buildGTDecodeTable() {
+	gtDecodeTable = make(map[string]int64, 1<<gtStepBits)
+	step := new(bn256.GT).ScalarMult(gtBase, big.NewInt(0))
+	for j := int64(0); j != 1<<gtStepBits; j++ {
+		gtDecodeTable[string(step.Marshal()[:gtDecodeKeySize])] = j
+		step.Add(step, gtBase)
+	}
+	// step is now e(g1, g2)^(2^gtStepBits)
+	gtGiantStep = new(bn256.GT).Neg(step)
+}
+
+// MarshalGT returns the canonical encoding of an element of GT: its twelve
+// coordinates in Fp, reduced modulo p, as 32-byte big-endian integers. It is
+// the encoding produced by the Marshal method of bn256.GT, and the only one
+// that UnmarshalGT accepts.
+func MarshalGT(e *bn256.GT) []byte {
+	return e.Marshal()
+}
+
+// UnmarshalGT decodes an element of GT encoded by MarshalGT. Unlike the
+// Unmarshal method of bn256.GT, which accepts unreduced coordinates and any
+// element of Fp12, it rejects encodings that MarshalGT would not produce and
+// elements outside GT, so that a decoded element re-encodes byte for byte.
+// Ciphertexts are decoded with the same checks.
+func UnmarshalGT(encoded []byte) (*bn256.GT, error) {
+	e, err := unmarshalGT(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if !inGT(e) {
+		return nil, errNotInSubgroup
+	}
+	return e, nil
+}
+
+// EncodeToGT encodes a payload of at most MaxGTPayloadSize bytes as the
+// element e(g1, g2)^m of GT, where m is the big-endian integer 0x01 || data,
+// so that it can be encrypted with Encrypt. Longer payloads are rejected;
+// EncryptBytes handles data of any length.
+//
+// The encoding is reversible because the exponent is small: DecodeFromGT
+// recovers it with a baby-step giant-step search of about 2^12 steps, after a
+// one-time table of 2^13 elements is built.
+func EncodeToGT(data []byte) (*bn256.GT, error) {
+	if len(data) > MaxGTPayloadSize {
+		return nil, errGTPayloadSize
+	}
+	m := new(big.Int).SetBytes(append([]byte{1}, data...))
+	return new(bn256.GT).ScalarMult(gtBase, m), nil
+}
+
+// DecodeFromGT recovers the payload encoded in gt by EncodeToGT. It returns
+// an error wrapping ErrDecryptFailed if gt does not encode a payload, as
+// happens when it was decrypted with the wrong key.
+func DecodeFromGT(gt *bn256.GT) ([]byte, error) {
+	gtDecodeOnce.Do(buildGTDecodeTable)
+
+	target := gt.Marshal()
+	gamma, ok := new(bn256.GT).Unmarshal(target)
+	if !ok {
+		return nil, errNotEncodedGT
+	}
+	giantSteps := int64(1) << (8*MaxGTPayloadSize + 1 - gtStepBits)
+	for i := int64(0); i != giantSteps; i++ {
+		j, found := gtDecodeTable[string(gamma.Marshal()[:gtDecodeKeySize])]
+		if found {
+			m := big.NewInt(i<<gtStepBits + j)
+			check := new(bn256.GT).ScalarMult(gtBase, m)
+			if bytes.Equal(check.Marshal(), target) {
+				encoded := m.Bytes()
+				if len(encoded) == 0 || encoded[0] != 1 {
+					return nil, errNotEncodedGT
+				}
+				return encoded[1:], nil
+			}
+		}
+		gamma.Add(gamma, gtGiantStep)
+	}
+	return nil, errNotEncodedGT
+}
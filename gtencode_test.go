@@ -0,0 +1,116 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"golang.org/x/crypto/bn256"
+	"math/big"
+	"testing"
+)
+
+func TestEncodeToGT(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, data := range [][]byte{{}, {0}, {0, 0, 0}, []byte("hi!"), {0xff, 0xff, 0xff}} {
+		message, err := EncodeToGT(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ciphertext, err := Encrypt(rand.Reader, params, LINEAR_HIERARCHY, message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := DecodeFromGT(mustDecrypt(t, key, ciphertext))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decoded, data) {
+			t.Fatal("Payload does not round-trip")
+		}
+	}
+
+	if _, err = EncodeToGT([]byte("four")); err == nil {
+		t.Fatal("Oversized payload was accepted")
+	}
+	if _, err = DecodeFromGT(NewMessage()); !errors.Is(err, ErrDecryptFailed) {
+		t.Fatal("Arbitrary element was decoded")
+	}
+}
+
+// unreduce returns a non-canonical encoding of the same elements as encoded,
+// by adding p to the first 32-byte coordinate for which the sum still fits.
+func unreduce(t *testing.T, encoded []byte) []byte {
+	limit := new(big.Int).Lsh(big.NewInt(1), 256)
+	for i := 0; i+32 <= len(encoded); i += 32 {
+		coordinate := new(big.Int).SetBytes(encoded[i : i+32])
+		if coordinate.Add(coordinate, fieldPrime).Cmp(limit) < 0 {
+			unreduced := append([]byte{}, encoded...)
+			coordinate.FillBytes(unreduced[i : i+32])
+			return unreduced
+		}
+	}
+	t.Fatal("No coordinate can be unreduced")
+	return nil
+}
+
+func TestUnmarshalGT(t *testing.T) {
+	e := NewMessage()
+	encoded := MarshalGT(e)
+	decoded, err := UnmarshalGT(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(MarshalGT(decoded), encoded) {
+		t.Fatal("Element does not re-encode byte for byte")
+	}
+
+	unreduced := unreduce(t, encoded)
+	if _, ok := new(bn256.GT).Unmarshal(unreduced); !ok {
+		t.Fatal("bn256 rejects unreduced coordinates")
+	}
+	if _, err = UnmarshalGT(unreduced); !errors.Is(err, errMalformedElement) {
+		t.Fatal("Accepted a non-canonical encoding")
+	}
+
+	// The constant 2 of Fp12 is not in GT
+	outside := make([]byte, len(encoded))
+	outside[len(outside)-1] = 2
+	if _, err = UnmarshalGT(outside); !errors.Is(err, errNotInSubgroup) {
+		t.Fatal("Accepted an element outside GT")
+	}
+}
+
+func TestCiphertextCanonical(t *testing.T) {
+	params, _, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := Encrypt(rand.Reader, params, LINEAR_HIERARCHY, NewMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range [][]MarshalOption{nil, {WithCompression()}} {
+		encoded := ciphertext.Marshal(opts...)
+		decoded, ok := new(Ciphertext).Unmarshal(encoded)
+		if !ok {
+			t.Fatal("Failed to unmarshal ciphertext")
+		}
+		if !bytes.Equal(decoded.Marshal(opts...), encoded) {
+			t.Fatal("Ciphertext does not re-encode byte for byte")
+		}
+		// A is the first element after the header, and the compressed tag
+		offset := headerSize + len(opts)
+		unreduced := append(encoded[:offset:offset], unreduce(t, encoded[offset:offset+12*32])...)
+		if _, ok = new(Ciphertext).Unmarshal(append(unreduced, encoded[offset+12*32:]...)); ok {
+			t.Fatal("Accepted a ciphertext with an unreduced coordinate")
+		}
+	}
+}
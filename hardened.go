@@ -0,0 +1,69 @@
+package hibe_sm9
+
+import (
+	"crypto/rand"
+	"golang.org/x/crypto/bn256"
+	"io"
+	"math/big"
+)
+
+// blindingBits is the size of the random multiple of the group order added to
+// secret scalars in hardened builds.
+const blindingBits = 64
+
+// blindScalar returns k + m * Order for a random, non-zero m of blindingBits
+// bits. Multiplying a point of order Order by the result gives the same point
+// as multiplying by k, but the sequence of doublings and additions performed
+// by bn256's variable-time double-and-add no longer depends only on k, and it
+// changes on every call.
+func blindScalar(random io.Reader, k *big.Int) (*big.Int, error) {
+	m, err := rand.Int(random, new(big.Int).Lsh(big.NewInt(1), blindingBits))
+	if err != nil {
+		return nil, err
+	}
+	m.SetBit(m, blindingBits, 1)
+	m.Mul(m, bn256.Order)
+	return m.Add(m, k), nil
+}
+
+// secretMultG1 computes a^k for a secret scalar k, blinding k in hardened
+// builds. Blinding factors always come from crypto/rand rather than the
+// caller's random source, so that hardened and ordinary builds consume the
+// caller's randomness identically and produce the same results from the same
+// seed.
+func secretMultG1(a *bn256.G1, k *big.Int) (*bn256.G1, error) {
+	if hardened {
+		var err error
+		if k, err = blindScalar(rand.Reader, k); err != nil {
+			return nil, err
+		}
+		defer zeroizeScalar(k)
+	}
+	return new(bn256.G1).ScalarMult(a, k), nil
+}
+
+// secretMultG2 computes a^k for a secret scalar k, blinding k in hardened
+// builds.
+func secretMultG2(a *bn256.G2, k *big.Int) (*bn256.G2, error) {
+	if hardened {
+		var err error
+		if k, err = blindScalar(rand.Reader, k); err != nil {
+			return nil, err
+		}
+		defer zeroizeScalar(k)
+	}
+	return new(bn256.G2).ScalarMult(a, k), nil
+}
+
+// secretMultGT computes a^k for a secret scalar k, blinding k in hardened
+// builds.
+func secretMultGT(a *bn256.GT, k *big.Int) (*bn256.GT, error) {
+	if hardened {
+		var err error
+		if k, err = blindScalar(rand.Reader, k); err != nil {
+			return nil, err
+		}
+		defer zeroizeScalar(k)
+	}
+	return new(bn256.GT).ScalarMult(a, k), nil
+}
@@ -0,0 +1,7 @@
+//go:build !hibe_hardened
+
+package hibe_sm9
+
+// hardened enables scalar blinding for all multiplications by secret scalars.
+// It is set by building with the hibe_hardened tag.
+const hardened = false
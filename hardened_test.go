@@ -0,0 +1,62 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"flag"
+	"golang.org/x/crypto/bn256"
+	"math"
+	"math/big"
+	"testing"
+)
+
+var checkTiming = flag.Bool("timing", false, "run the timing-leakage tests, which need a quiet machine")
+
+func TestBlindScalar(t *testing.T) {
+	k, g, err := bn256.RandomG1(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blinded, err := blindScalar(rand.Reader, k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blinded.Cmp(k) == 0 || new(big.Int).Mod(blinded, bn256.Order).Cmp(k) != 0 {
+		t.Fatal("Blinded scalar is not congruent to the original")
+	}
+
+	base := new(bn256.G1).ScalarBaseMult(big.NewInt(1))
+	if !bytes.Equal(new(bn256.G1).ScalarMult(base, blinded).Marshal(), g.Marshal()) {
+		t.Fatal("Blinded scalar multiplication gives a different point")
+	}
+}
+
+func TestTimingLeakage(t *testing.T) {
+	if !*checkTiming {
+		t.Skip("timing measurements are noisy; run with -timing on a quiet machine")
+	}
+	sum := func(iterations int) {
+		n := new(big.Int)
+		for i := 0; i != iterations; i++ {
+			n.Add(n, big.NewInt(int64(i)))
+		}
+	}
+
+	// An operation that obviously leaks must be detected
+	statistic, err := TimingLeakage(10000, func(class int) { sum(1000 * (class + 1)) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(statistic) <= TimingLeakageThreshold {
+		t.Fatalf("Obvious leak was not detected (t = %f)", statistic)
+	}
+
+	// One that does the same work for both classes must not be
+	statistic, err = TimingLeakage(10000, func(class int) { sum(1000) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(statistic) > TimingLeakageThreshold {
+		t.Fatalf("Leak detected in an operation independent of its class (t = %f)", statistic)
+	}
+}
@@ -0,0 +1,103 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"golang.org/x/crypto/bn256"
+)
+
+// HSeedSize is the size in bytes of the seed from which WithDerivedH derives
+// h1 ... hl.
+const HSeedSize = 32
+
+// maxDerivedDepth bounds the depth that decoders accept for parameters with
+// derived h1 ... hl, since a short encoding could otherwise claim a depth
+// that takes hours to derive.
+const maxDerivedDepth = 1 << 12
+
+// hSeedDomain separates the derivation of h1 ... hl from other uses of
+// HashToG1.
+var hSeedDomain = []byte("HIBE-BN256-H-V1")
+
+var (
+	errHSeed          = errors.New("hibe: h1 ... hl do not match the seed of the parameters")
+	errHSeedAnonymous = errors.New("hibe: anonymous hierarchies cannot derive h1 ... hl from a seed")
+)
+
+// WithDerivedH makes Setup derive h1 ... hl from a random public seed with
+// HashToG1, instead of choosing them at random, and record the seed in
+// Params.HSeed. Encodings of the parameters then carry the seed instead of
+// h1 ... hl, so they stay a few hundred bytes long however deep the
+// hierarchy. Since nobody knows the discrete logarithms of hashed points,
+// this is as secure as random h1 ... hl. It is not supported for anonymous
+// hierarchies, whose mirrors of h1 ... hl need their discrete logarithms.
+func WithDerivedH() SetupOption {
+	return func(config *setupConfig) {
+		config.derivedH = true
+	}
+}
+
+// deriveH derives h(from+1) ... h(to) from seed.
+func deriveH(seed []byte, from int, to int) []*bn256.G1 {
+	h := make([]*bn256.G1, 0, to-from)
+	input := append(append([]byte{}, hSeedDomain...), seed...)
+	for i := from; i != to; i++ {
+		h = append(h, HashToG1(binary.BigEndian.AppendUint32(input, uint32(i))))
+	}
+	return h
+}
+
+// hSeedMarker returns the leading slot of the encoding of parameters with
+// derived h1 ... hl: 0xfb...fb followed by the depth (4 bytes, big endian)
+// and the seed. Like anonymousMarker, it is larger than the field prime, and
+// 0xfb is neither a compressed tag nor the first byte of the header.
+func hSeedMarker(depth int, seed []byte) []byte {
+	marker := bytesOf(0xfb, 1<<geShift)
+	binary.BigEndian.PutUint32(marker[len(marker)-HSeedSize-4:], uint32(depth))
+	copy(marker[len(marker)-HSeedSize:], seed)
+	return marker
+}
+
+// parseHSeedMarker recovers the depth and seed from a marker slot.
+func parseHSeedMarker(marker []byte) (int, []byte, bool) {
+	prefix := len(marker) - HSeedSize - 4
+	if !bytes.Equal(marker[:prefix], bytesOf(0xfb, prefix)) {
+		return 0, nil, false
+	}
+	depth := binary.BigEndian.Uint32(marker[prefix:])
+	if depth > maxDerivedDepth {
+		return 0, nil, false
+	}
+	return int(depth), append([]byte{}, marker[prefix+4:]...), true
+}
+
+// checkHSeed checks that h1 ... hl are derived from the seed of the
+// parameters, if they have one.
+func (params *Params) checkHSeed() error {
+	if params.HSeed == nil {
+		return nil
+	}
+	if params.G3Hat != nil {
+		return errHSeedAnonymous
+	}
+	if len(params.HSeed) != HSeedSize {
+		return errHSeed
+	}
+	for i, hi := range deriveH(params.HSeed, 0, len(params.H)) {
+		if !bytes.Equal(hi.Marshal(), params.H[i].Marshal()) {
+			return errHSeed
+		}
+	}
+	return nil
+}
+
+// setHSeed sets the seed of the parameters and derives h1 ... hl from it.
+func (params *Params) setHSeed(seed []byte, depth int) error {
+	if len(seed) != HSeedSize || depth < 0 || depth > maxDerivedDepth {
+		return errHSeed
+	}
+	params.HSeed = append([]byte{}, seed...)
+	params.H = deriveH(seed, 0, depth)
+	return nil
+}
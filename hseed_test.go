@@ -0,0 +1,102 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestDerivedH(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 20, WithDerivedH())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(params.HSeed) != HSeedSize {
+		t.Fatal("Setup did not record the seed")
+	}
+	if err = params.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	legacy, _, err := Setup(rand.Reader, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(params.Marshal()) > 500 || len(params.Marshal()) >= len(legacy.Marshal()) {
+		t.Fatal("Parameters with derived h1 ... hl are not compressed")
+	}
+
+	for _, encoded := range [][]byte{params.Marshal(), params.Marshal(WithCompression())} {
+		decoded, ok := new(Params).Unmarshal(encoded)
+		if !ok {
+			t.Fatal("Failed to decode parameters with derived h1 ... hl")
+		}
+		if !decoded.Equal(params) || !bytes.Equal(decoded.HSeed, params.HSeed) {
+			t.Fatal("Decoded parameters differ")
+		}
+	}
+	pemEncoded, err := params.MarshalPEM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromPEM, err := new(Params).ParsePEM(pemEncoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsonEncoded, err := params.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromJSON := new(Params)
+	if err = fromJSON.UnmarshalJSON(jsonEncoded); err != nil {
+		t.Fatal(err)
+	}
+	if !fromPEM.Equal(params) || !fromJSON.Equal(params) {
+		t.Fatal("PEM or JSON round trip changed the parameters")
+	}
+
+	id := LINEAR_HIERARCHY
+	key, err := KeyGenFromMaster(rand.Reader, fromJSON, master, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := NewMessage()
+	ciphertext, err := Encrypt(rand.Reader, params, id, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(message.Marshal(), mustDecrypt(t, key, ciphertext).Marshal()) {
+		t.Fatal("Key under decoded parameters does not decrypt")
+	}
+
+	extended, err := ExtendDepth(rand.Reader, params, master, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if extended.MaximumDepth() != 22 || !bytes.Equal(extended.HSeed, params.HSeed) || extended.Validate() != nil {
+		t.Fatal("Extended parameters do not derive the new levels from the seed")
+	}
+	if clone := params.Clone(); !clone.Equal(params) {
+		t.Fatal("Clone lost the seed")
+	}
+}
+
+func TestDerivedHRejectsTampering(t *testing.T) {
+	params, _, err := Setup(rand.Reader, 3, WithDerivedH())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := params.Clone()
+	tampered.H[1] = tampered.H[0]
+	if tampered.Validate() == nil {
+		t.Fatal("Validate accepted h1 ... hl that do not match the seed")
+	}
+	if _, _, err = Setup(rand.Reader, 3, WithDerivedH(), WithAnonymity()); err == nil {
+		t.Fatal("Setup derived h1 ... hl for an anonymous hierarchy")
+	}
+
+	encoded := params.marshalBody(nil)
+	encoded[len(encoded)-6<<geShift-HSeedSize-4] = 0x01
+	if _, ok := new(Params).unmarshalBody(encoded); ok {
+		t.Fatal("Decoded an unreasonable depth")
+	}
+}
@@ -0,0 +1,176 @@
+package hibe_sm9
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"golang.org/x/crypto/bn256"
+	"io"
+	"math/big"
+	"strconv"
+)
+
+// DEM is the data-encapsulation mechanism with which the hybrid mode seals
+// data under the secret of the KEM, selected with WithDEM.
+type DEM byte
+
+const (
+	// DEMAESGCM is AES-256-GCM, keyed with HKDF-SHA256. It is the default.
+	DEMAESGCM DEM = 0
+
+	// DEMSM4GCM is SM4-GCM, keyed with HKDF-SM3, for deployments that must
+	// use the GM/T algorithms.
+	DEMSM4GCM DEM = 1
+)
+
+// String returns the name of the DEM.
+func (dem DEM) String() string {
+	switch dem {
+	case DEMAESGCM:
+		return "aes-gcm"
+	case DEMSM4GCM:
+		return "sm4-gcm"
+	}
+	return "DEM(" + strconv.Itoa(int(dem)) + ")"
+}
+
+// kemInfoSM4 separates SM4-GCM keys from AES-GCM keys.
+var kemInfoSM4 = []byte("HIBE-KEM-SM4")
+
+// kdf returns the function that derives the key of the DEM from the
+// encapsulated element.
+func (dem DEM) kdf() func(*bn256.GT, *Ciphertext) []byte {
+	if dem == DEMSM4GCM {
+		return func(element *bn256.GT, encapsulation *Ciphertext) []byte {
+			return deriveSecretWith(newSM3, kemInfoSM4, sm4KeySize, element, encapsulation)
+		}
+	}
+	return deriveSecret
+}
+
+// newAEAD returns the AEAD of the DEM keyed with secret.
+func (dem DEM) newAEAD(secret []byte) (cipher.AEAD, error) {
+	if dem == DEMSM4GCM {
+		block, err := newSM4(secret)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	}
+	return newStreamAEAD(secret)
+}
+
+// WithDEM makes EncryptBytes seal the data with dem instead of AES-GCM. The
+// DEM is recorded in the ciphertext, so DecryptBytes needs no option. Encrypt
+// and Encapsulate ignore it.
+func WithDEM(dem DEM) EncryptOption {
+	return func(config *encryptConfig) {
+		config.dem = dem
+	}
+}
+
+// hybridNonce is the AES-GCM nonce of hybrid ciphertexts. Every message has a
+// fresh key from Encapsulate, so a fixed nonce is never reused with a key.
+var hybridNonce = make([]byte, 12)
+
+var (
+	errHybridMalformed = wrapError(ErrMalformedCiphertext, "hibe: malformed hybrid ciphertext")
+	errHybridAuth      = wrapError(ErrDecryptFailed, "hibe: hybrid ciphertext failed authentication")
+	errHybridDEM       = errors.New("hibe: unknown DEM")
+)
+
+// EncryptBytes encrypts an arbitrary byte string for id, by encapsulating a
+// fresh key with Encapsulate and sealing the plaintext under it with AES-GCM,
+// or another DEM given with WithDEM. The result is the DEM (1 byte), the
+// length of the encapsulation (3 bytes, big endian), the encapsulation, and
+// the sealed plaintext. Data that travels in the clear alongside the
+// ciphertext can be bound to it with WithAAD. For large inputs, use
+// NewEncryptingWriter instead.
+func EncryptBytes(random io.Reader, params *Params, id []*big.Int, plaintext []byte, opts ...EncryptOption) ([]byte, error) {
+	return encryptBytes(random, params, id, plaintext, nil, opts...)
+}
+
+// encryptBytes is EncryptBytes with additional data authenticated by the DEM,
+// which must be given again to decryptBytes.
+func encryptBytes(random io.Reader, params *Params, id []*big.Int, plaintext []byte, additionalData []byte, opts ...EncryptOption) ([]byte, error) {
+	config := encryptOptions(opts)
+	if config.aad != nil {
+		additionalData = append(append([]byte{}, additionalData...), config.aad...)
+	}
+	if config.dem != DEMAESGCM && config.dem != DEMSM4GCM {
+		return nil, errHybridDEM
+	}
+	secret, encapsulation, err := encapsulate(random, params, id, config.dem.kdf(), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroizeBytes(secret)
+	aead, err := config.dem.newAEAD(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	header := encapsulation.Marshal()
+	ciphertext := make([]byte, 4, 4+len(header)+len(plaintext)+aead.Overhead())
+	binary.BigEndian.PutUint32(ciphertext, uint32(len(header)))
+	ciphertext[0] = byte(config.dem)
+	ciphertext = append(ciphertext, header...)
+	return aead.Seal(ciphertext, hybridNonce, plaintext, additionalData), nil
+}
+
+// DecryptBytes decrypts a ciphertext produced by EncryptBytes. Decrypting with
+// the key for a different identity, or without the additional data given with
+// WithAAD, fails authentication.
+func DecryptBytes(key *PrivateKey, ciphertext []byte, opts ...DecryptOption) ([]byte, error) {
+	return decryptBytes(key, ciphertext, decryptOptions(opts).aad)
+}
+
+// decryptBytes is DecryptBytes for a ciphertext from encryptBytes.
+func decryptBytes(key *PrivateKey, ciphertext []byte, additionalData []byte) ([]byte, error) {
+	dem, encapsulation, sealed, err := parseHybrid(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	return openHybrid(key, dem, encapsulation, sealed, additionalData)
+}
+
+// parseHybrid splits a ciphertext from encryptBytes into its DEM, its
+// encapsulation and the sealed plaintext.
+func parseHybrid(ciphertext []byte) (DEM, *Ciphertext, []byte, error) {
+	if len(ciphertext) < 4 {
+		return 0, nil, nil, errHybridMalformed
+	}
+	dem := DEM(ciphertext[0])
+	if dem != DEMAESGCM && dem != DEMSM4GCM {
+		return 0, nil, nil, errHybridMalformed
+	}
+	size := binary.BigEndian.Uint32(ciphertext) & 0xffffff
+	ciphertext = ciphertext[4:]
+	if size > maxStreamHeaderSize || int(size) > len(ciphertext) {
+		return 0, nil, nil, errHybridMalformed
+	}
+	encapsulation, ok := new(Ciphertext).Unmarshal(ciphertext[:size])
+	if !ok {
+		return 0, nil, nil, errHybridMalformed
+	}
+	return dem, encapsulation, ciphertext[size:], nil
+}
+
+// openHybrid decapsulates the key of a parsed hybrid ciphertext and opens
+// the sealed plaintext with it.
+func openHybrid(key *PrivateKey, dem DEM, encapsulation *Ciphertext, sealed []byte, additionalData []byte) ([]byte, error) {
+	secret, err := decapsulate(key, encapsulation, dem.kdf())
+	if err != nil {
+		return nil, err
+	}
+	defer zeroizeBytes(secret)
+	aead, err := dem.newAEAD(secret)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, hybridNonce, sealed, additionalData)
+	if err != nil {
+		return nil, errHybridAuth
+	}
+	return plaintext, nil
+}
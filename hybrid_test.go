@@ -0,0 +1,123 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"testing"
+)
+
+func TestEncryptBytes(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext := []byte("hybrid encryption")
+	ciphertext, err := EncryptBytes(rand.Reader, params, LINEAR_HIERARCHY[:2], plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := DecryptBytes(key, ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(plaintext, decrypted) {
+		t.Fatal("Decrypted bytes do not match")
+	}
+
+	if _, err = DecryptBytes(other, ciphertext); err == nil {
+		t.Fatal("Wrong key decrypted")
+	}
+	ciphertext[len(ciphertext)-1] ^= 1
+	if _, err = DecryptBytes(key, ciphertext); err == nil {
+		t.Fatal("Decrypted tampered ciphertext")
+	}
+	if _, err = DecryptBytes(key, ciphertext[:3]); err == nil {
+		t.Fatal("Decrypted truncated ciphertext")
+	}
+}
+
+func TestEncryptBytesSM4(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext := []byte("hybrid encryption with SM4")
+	ciphertext, err := EncryptBytes(rand.Reader, params, LINEAR_HIERARCHY[:2], plaintext, WithDEM(DEMSM4GCM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if DEM(ciphertext[0]) != DEMSM4GCM {
+		t.Fatal("Ciphertext does not record the DEM")
+	}
+	decrypted, err := DecryptBytes(key, ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(plaintext, decrypted) {
+		t.Fatal("Decrypted bytes do not match")
+	}
+
+	// Opening with the other DEM fails, as does an unknown one
+	ciphertext[0] = byte(DEMAESGCM)
+	if _, err = DecryptBytes(key, ciphertext); err == nil {
+		t.Fatal("SM4-GCM ciphertext opened as AES-GCM")
+	}
+	ciphertext[0] = 7
+	if _, err = DecryptBytes(key, ciphertext); err == nil {
+		t.Fatal("Ciphertext with an unknown DEM decrypted")
+	}
+	if _, err = EncryptBytes(rand.Reader, params, LINEAR_HIERARCHY[:2], plaintext, WithDEM(7)); err == nil {
+		t.Fatal("Encrypted with an unknown DEM")
+	}
+}
+
+func TestDecrypter(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypter, err := NewDecrypter(params, key, LINEAR_HIERARCHY[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var _ crypto.Decrypter = decrypter
+
+	public := decrypter.Public().(*PublicKey)
+	if !public.Equal(&PublicKey{Params: params, ID: LINEAR_HIERARCHY[:2]}) {
+		t.Fatal("Public key does not match the identity")
+	}
+	ciphertext, err := public.Encrypt(rand.Reader, []byte("message"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := decrypter.Decrypt(rand.Reader, ciphertext, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(decrypted) != "message" {
+		t.Fatal("Decrypter returned the wrong plaintext")
+	}
+
+	if _, err = NewDecrypter(params, key, LINEAR_HIERARCHY); err == nil {
+		t.Fatal("Accepted key for the wrong depth")
+	}
+}
@@ -0,0 +1,146 @@
+package hibe_sm9
+
+import (
+	"golang.org/x/crypto/bn256"
+	"io"
+)
+
+// Sizes of the encodings of IBEKey and IBECiphertext.
+const (
+	IBEKeySize        = 3 << geShift
+	IBECiphertextSize = 9 << geShift
+)
+
+var (
+	errIBEParams     = wrapError(ErrCurveMismatch, "hibe: parameters are not plain IBE parameters")
+	errIBEKey        = wrapError(ErrInvalidID, "hibe: malformed IBE key")
+	errIBECiphertext = wrapError(ErrMalformedCiphertext, "hibe: malformed IBE ciphertext")
+)
+
+// IBEParams are the parameters of a plain, non-hierarchical IBE scheme: a
+// hierarchy of depth 1, set up with SetupIBE. They are ordinary parameters,
+// so they can be marshalled like any other, and the keys and ciphertexts of
+// the scheme are those of the hierarchy with their empty delegation
+// components left out.
+type IBEParams struct {
+	*Params
+}
+
+// IBEKey is the private key for an identity, obtained with ExtractIBE.
+type IBEKey struct {
+	A0 *bn256.G1
+	A1 *bn256.G2
+}
+
+// IBECiphertext is a message encrypted with EncryptIBE.
+type IBECiphertext struct {
+	A *bn256.GT
+	B *bn256.G2
+	C *bn256.G1
+}
+
+// SetupIBE generates the parameters and master key of a plain IBE scheme. The
+// parameters are precomputed (see Params.Precompute), which only takes tables
+// for a single level.
+func SetupIBE(random io.Reader, opts ...SetupOption) (*IBEParams, MasterKey, error) {
+	params, master, err := Setup(random, 1, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if params.Anonymous() {
+		return nil, nil, errIBEParams
+	}
+	params.Precompute()
+	return &IBEParams{params}, master, nil
+}
+
+// NewIBEParams wraps parameters of depth 1 for use with the IBE functions.
+// Anonymous parameters are rejected.
+func NewIBEParams(params *Params) (*IBEParams, error) {
+	if params.MaximumDepth() != 1 || params.Anonymous() {
+		return nil, errIBEParams
+	}
+	return &IBEParams{params}, nil
+}
+
+// ExtractIBE generates the private key for id. The identity is hashed with
+// Params.HashID, so the key is also the hierarchy key that
+// KeyGenFromMasterID issues for [][]byte{id}.
+func ExtractIBE(random io.Reader, params *IBEParams, master MasterKey, id []byte) (*IBEKey, error) {
+	key, err := KeyGenFromMasterID(random, params.Params, master, [][]byte{id})
+	if err != nil {
+		return nil, err
+	}
+	return &IBEKey{A0: key.A0, A1: key.A1}, nil
+}
+
+// EncryptIBE encrypts message for id.
+func EncryptIBE(random io.Reader, params *IBEParams, id []byte, message *bn256.GT) (*IBECiphertext, error) {
+	ciphertext, err := EncryptID(random, params.Params, [][]byte{id}, message)
+	if err != nil {
+		return nil, err
+	}
+	return &IBECiphertext{A: ciphertext.A, B: ciphertext.B, C: ciphertext.C}, nil
+}
+
+// DecryptIBE recovers the message from ciphertext. Like Decrypt without an
+// integrity tag, the wrong key yields an unrelated element of GT.
+func DecryptIBE(key *IBEKey, ciphertext *IBECiphertext) (*bn256.GT, error) {
+	if key.A0 == nil || key.A1 == nil {
+		return nil, errIBEKey
+	}
+	if ciphertext.A == nil || ciphertext.B == nil || ciphertext.C == nil {
+		return nil, errIBECiphertext
+	}
+	return decrypt(&PrivateKey{A0: key.A0, A1: key.A1}, &Ciphertext{A: ciphertext.A, B: ciphertext.B, C: ciphertext.C}), nil
+}
+
+// Marshal encodes the key as A0 || A1, in IBEKeySize bytes.
+func (key *IBEKey) Marshal() []byte {
+	encoded := make([]byte, IBEKeySize)
+	copy(geIndex(encoded, 0, 1), key.A0.Marshal())
+	copy(geIndex(encoded, 1, 2), key.A1.Marshal())
+	return encoded
+}
+
+// Unmarshal recovers the key from its encoding.
+func (key *IBEKey) Unmarshal(encoded []byte) (*IBEKey, bool) {
+	if len(encoded) != IBEKeySize {
+		return nil, false
+	}
+	var err error
+	if key.A0, err = unmarshalG1(geIndex(encoded, 0, 1)); err != nil {
+		return nil, false
+	}
+	if key.A1, err = unmarshalG2(geIndex(encoded, 1, 2)); err != nil {
+		return nil, false
+	}
+	return key, true
+}
+
+// Marshal encodes the ciphertext as A || B || C, in IBECiphertextSize bytes.
+func (ciphertext *IBECiphertext) Marshal() []byte {
+	encoded := make([]byte, IBECiphertextSize)
+	copy(geIndex(encoded, 0, 6), ciphertext.A.Marshal())
+	copy(geIndex(encoded, 6, 2), ciphertext.B.Marshal())
+	copy(geIndex(encoded, 8, 1), ciphertext.C.Marshal())
+	return encoded
+}
+
+// Unmarshal recovers the ciphertext from its encoding.
+func (ciphertext *IBECiphertext) Unmarshal(encoded []byte) (*IBECiphertext, bool) {
+	if len(encoded) != IBECiphertextSize {
+		return nil, false
+	}
+	var err error
+	if ciphertext.A, err = unmarshalGT(geIndex(encoded, 0, 6)); err != nil {
+		return nil, false
+	}
+	if ciphertext.B, err = unmarshalG2(geIndex(encoded, 6, 2)); err != nil {
+		return nil, false
+	}
+	if ciphertext.C, err = unmarshalG1(geIndex(encoded, 8, 1)); err != nil {
+		return nil, false
+	}
+	return ciphertext, true
+}
@@ -0,0 +1,86 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestIBE(t *testing.T) {
+	params, master, err := SetupIBE(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ExtractIBE(rand.Reader, params, master, []byte("alice@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := NewMessage()
+	ciphertext, err := EncryptIBE(rand.Reader, params, []byte("alice@example.com"), message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Keys and ciphertexts survive their compact encodings
+	encodedKey := key.Marshal()
+	encodedCiphertext := ciphertext.Marshal()
+	if len(encodedKey) != IBEKeySize || len(encodedCiphertext) != IBECiphertextSize {
+		t.Fatal("Encodings have the wrong size")
+	}
+	decodedKey, ok := new(IBEKey).Unmarshal(encodedKey)
+	if !ok {
+		t.Fatal("Could not unmarshal key")
+	}
+	decodedCiphertext, ok := new(IBECiphertext).Unmarshal(encodedCiphertext)
+	if !ok {
+		t.Fatal("Could not unmarshal ciphertext")
+	}
+	decrypted, err := DecryptIBE(decodedKey, decodedCiphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(message.Marshal(), decrypted.Marshal()) {
+		t.Fatal("Original and decrypted messages differ")
+	}
+
+	// The key of another identity does not decrypt
+	bob, err := ExtractIBE(rand.Reader, params, master, []byte("bob@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decrypted, err = DecryptIBE(bob, ciphertext); err != nil || bytes.Equal(message.Marshal(), decrypted.Marshal()) {
+		t.Fatal("Wrong key decrypted the message")
+	}
+
+	// IBE keys are the keys of a hierarchy of depth 1
+	hierarchyKey, err := KeyGenFromMasterID(rand.Reader, params.Params, master, [][]byte{[]byte("alice@example.com")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hierarchyCiphertext, err := EncryptID(rand.Reader, params.Params, [][]byte{[]byte("alice@example.com")}, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(message.Marshal(), mustDecrypt(t, hierarchyKey, &Ciphertext{A: ciphertext.A, B: ciphertext.B, C: ciphertext.C}).Marshal()) {
+		t.Fatal("Hierarchy key did not decrypt the IBE ciphertext")
+	}
+	decrypted, err = DecryptIBE(key, &IBECiphertext{A: hierarchyCiphertext.A, B: hierarchyCiphertext.B, C: hierarchyCiphertext.C})
+	if err != nil || !bytes.Equal(message.Marshal(), decrypted.Marshal()) {
+		t.Fatal("IBE key did not decrypt the hierarchy ciphertext")
+	}
+
+	// Deeper or anonymous parameters are not IBE parameters
+	deeper, _, err := Setup(rand.Reader, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewIBEParams(deeper); err == nil {
+		t.Fatal("Parameters of depth 2 were accepted")
+	}
+	if _, _, err = SetupIBE(rand.Reader, WithAnonymity()); err == nil {
+		t.Fatal("Anonymous parameters were accepted")
+	}
+	if _, ok = new(IBECiphertext).Unmarshal(encodedCiphertext[1:]); ok {
+		t.Fatal("Truncated ciphertext was accepted")
+	}
+}
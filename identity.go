@@ -0,0 +1,211 @@
+package hibe_sm9
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bn256"
+	"golang.org/x/crypto/sha3"
+	"hash"
+	"io"
+	"math/big"
+	"strconv"
+)
+
+// identityDST is the domain separation tag of HashID, to which the level is
+// appended.
+const identityDST = "HIBE-BN256-ID-V01-L"
+
+var errIdentityHash = errors.New("hibe: unknown identity hash")
+
+// IdentityHash identifies the hash function that maps byte identities onto
+// Zp (see Params.HashID).
+type IdentityHash uint8
+
+const (
+	// IdentityHashSHA256 is expand_message_xmd with SHA-256, as in HashID.
+	// It is the default.
+	IdentityHashSHA256 IdentityHash = 0
+	// IdentityHashSM3 is expand_message_xmd with SM3, the hash of the SM9
+	// standard, for deployments that must only use the Chinese algorithms.
+	IdentityHashSM3 IdentityHash = 1
+	// IdentityHashSHAKE256 is expand_message_xof with SHAKE256.
+	IdentityHashSHAKE256 IdentityHash = 2
+)
+
+func (h IdentityHash) String() string {
+	switch h {
+	case IdentityHashSHA256:
+		return "sha256"
+	case IdentityHashSM3:
+		return "sm3"
+	case IdentityHashSHAKE256:
+		return "shake256"
+	default:
+		return fmt.Sprintf("IdentityHash(%d)", uint8(h))
+	}
+}
+
+// parseIdentityHash is the inverse of IdentityHash.String.
+func parseIdentityHash(name string) (IdentityHash, bool) {
+	for h := IdentityHashSHA256; h.valid(); h++ {
+		if h.String() == name {
+			return h, true
+		}
+	}
+	return 0, false
+}
+
+func (h IdentityHash) valid() bool {
+	return h <= IdentityHashSHAKE256
+}
+
+// dst returns the domain separation tag for the given level. SHA-256 keeps the
+// tag of HashID, so that the default is unchanged.
+func (h IdentityHash) dst(level int) []byte {
+	switch h {
+	case IdentityHashSM3:
+		return []byte("HIBE-BN256-ID-V01-SM3-L" + strconv.Itoa(level))
+	case IdentityHashSHAKE256:
+		return []byte("HIBE-BN256-ID-V01-SHAKE256-L" + strconv.Itoa(level))
+	default:
+		return []byte(identityDST + strconv.Itoa(level))
+	}
+}
+
+// expand returns size uniform bytes derived from message and dst.
+func (h IdentityHash) expand(message []byte, dst []byte, size int) []byte {
+	switch h {
+	case IdentityHashSM3:
+		return expandMessageXMDWith(newSM3, message, dst, size)
+	case IdentityHashSHAKE256:
+		return expandMessageXOF(message, dst, size)
+	default:
+		return expandMessageXMD(message, dst, size)
+	}
+}
+
+// WithIdentityHash selects the hash function that Params.HashID, and with it
+// KeyGenFromMasterID, EncryptID and the other byte-identity functions, use to
+// map identities onto Zp. The choice is recorded in every encoding of the
+// parameters, so that both sides of a hierarchy agree on it.
+func WithIdentityHash(h IdentityHash) SetupOption {
+	return func(config *setupConfig) {
+		config.identityHash = h
+	}
+}
+
+// hashToFieldSize is the number of uniform bytes hashed to each identity
+// component: ceil((ceil(log2(r)) + 128) / 8) for the 254-bit group order r,
+// which makes the bias of the reduction negligible.
+const hashToFieldSize = 48
+
+// expandMessageXMD is expand_message_xmd of RFC 9380 (section 5.3.1) with
+// SHA-256.
+func expandMessageXMD(message []byte, dst []byte, size int) []byte {
+	return expandMessageXMDWith(sha256.New, message, dst, size)
+}
+
+// expandMessageXMDWith is expand_message_xmd with the given hash function.
+func expandMessageXMDWith(newHash func() hash.Hash, message []byte, dst []byte, size int) []byte {
+	dstPrime := append(append([]byte{}, dst...), byte(len(dst)))
+	hash := newHash()
+	ell := (size + hash.Size() - 1) / hash.Size()
+
+	hash.Write(make([]byte, hash.BlockSize()))
+	hash.Write(message)
+	hash.Write(binary.BigEndian.AppendUint16(nil, uint16(size)))
+	hash.Write([]byte{0})
+	hash.Write(dstPrime)
+	b0 := hash.Sum(nil)
+
+	uniform := make([]byte, 0, ell*hash.Size())
+	bi := make([]byte, hash.Size())
+	for i := 1; i <= ell; i++ {
+		for j := range bi {
+			bi[j] ^= b0[j]
+		}
+		hash.Reset()
+		hash.Write(bi)
+		hash.Write([]byte{byte(i)})
+		hash.Write(dstPrime)
+		bi = hash.Sum(nil)
+		uniform = append(uniform, bi...)
+	}
+	return uniform[:size]
+}
+
+// expandMessageXOF is expand_message_xof of RFC 9380 (section 5.3.2) with
+// SHAKE256.
+func expandMessageXOF(message []byte, dst []byte, size int) []byte {
+	xof := sha3.NewShake256()
+	xof.Write(message)
+	xof.Write(binary.BigEndian.AppendUint16(nil, uint16(size)))
+	xof.Write(dst)
+	xof.Write([]byte{byte(len(dst))})
+	uniform := make([]byte, size)
+	xof.Read(uniform)
+	return uniform
+}
+
+// HashID maps an identity given as one byte string per level onto the
+// identity in the hierarchy, by hashing each component to Zp* with the
+// hash_to_field construction of RFC 9380 (expand_message_xmd with SHA-256).
+// The level is part of the domain separation tag, so the same string at
+// different levels maps to unrelated components. Unlike passing integers
+// directly, this never yields 0 or components that collide modulo the group
+// order.
+func HashID(id [][]byte) []*big.Int {
+	return IdentityHashSHA256.hashID(id)
+}
+
+// HashID is like the package-level HashID, but uses the hash function chosen
+// with WithIdentityHash when the hierarchy was set up.
+func (params *Params) HashID(id [][]byte) []*big.Int {
+	return params.IdentityHash.hashID(id)
+}
+
+// HashComponent maps a single component at the given level, counting from 1,
+// as params.HashID does. It is for identities in which only some levels are
+// hashed, or whose levels are filled in separately.
+func (params *Params) HashComponent(level int, component []byte) *big.Int {
+	return params.IdentityHash.hashComponent(level, component)
+}
+
+func (h IdentityHash) hashID(id [][]byte) []*big.Int {
+	hashed := make([]*big.Int, len(id))
+	for i, component := range id {
+		hashed[i] = h.hashComponent(i+1, component)
+	}
+	return hashed
+}
+
+func (h IdentityHash) hashComponent(level int, component []byte) *big.Int {
+	orderMinusOne := new(big.Int).Sub(bn256.Order, big.NewInt(1))
+	hashed := new(big.Int).SetBytes(h.expand(component, h.dst(level), hashToFieldSize))
+	hashed.Mod(hashed, orderMinusOne)
+	return hashed.Add(hashed, big.NewInt(1))
+}
+
+// KeyGenFromMasterID is KeyGenFromMaster for an identity mapped with
+// params.HashID.
+func KeyGenFromMasterID(random io.Reader, params *Params, master MasterKey, id [][]byte, opts ...KeyGenOption) (*PrivateKey, error) {
+	return KeyGenFromMaster(random, params, master, params.HashID(id), opts...)
+}
+
+// KeyGenFromParentID is KeyGenFromParent for an identity mapped with
+// params.HashID.
+func KeyGenFromParentID(random io.Reader, params *Params, parent *PrivateKey, id [][]byte, opts ...KeyGenOption) (*PrivateKey, error) {
+	return KeyGenFromParent(random, params, parent, params.HashID(id), opts...)
+}
+
+// EncryptID is Encrypt for an identity mapped with params.HashID.
+func EncryptID(random io.Reader, params *Params, id [][]byte, message *bn256.GT, opts ...EncryptOption) (*Ciphertext, error) {
+	return Encrypt(random, params, params.HashID(id), message, opts...)
+}
+
+// EncapsulateID is Encapsulate for an identity mapped with params.HashID.
+func EncapsulateID(random io.Reader, params *Params, id [][]byte, opts ...EncryptOption) ([]byte, *Ciphertext, error) {
+	return Encapsulate(random, params, params.HashID(id), opts...)
+}
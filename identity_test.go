@@ -0,0 +1,150 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+)
+
+// TestExpandMessageXMD checks the SHA-256 test vectors of RFC 9380, appendix
+// K.1.
+func TestExpandMessageXMD(t *testing.T) {
+	dst := []byte("QUUX-V01-CS02-with-expander-SHA256-128")
+	for _, test := range []struct {
+		message, expected string
+	}{
+		{"", "68a985b87eb6b46952128911f2a4412bbc302a9d759667f87f7a21d803f07235"},
+		{"abc", "d8ccab23b5985ccea865c6c97b6e5b8350e794e603b4b97902f53a8a0d605615"},
+	} {
+		if hex.EncodeToString(expandMessageXMD([]byte(test.message), dst, 32)) != test.expected {
+			t.Fatalf("Wrong output for %q", test.message)
+		}
+	}
+}
+
+func TestHashID(t *testing.T) {
+	id := HashID([][]byte{[]byte("org"), []byte("org")})
+	if id[0].Sign() <= 0 || id[0].Cmp(id[1]) == 0 {
+		t.Fatal("Components at different levels are not separated")
+	}
+
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent, err := KeyGenFromMasterID(rand.Reader, params, master, [][]byte{[]byte("org")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := [][]byte{[]byte("org"), []byte("alice")}
+	key, err := KeyGenFromParentID(rand.Reader, params, parent, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := NewMessage()
+	ciphertext, err := EncryptID(rand.Reader, params, alice, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(message.Marshal(), mustDecrypt(t, key, ciphertext).Marshal()) {
+		t.Fatal("Original and decrypted messages differ")
+	}
+	secret, encapsulation, err := EncapsulateID(rand.Reader, params, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decapsulated, err := Decapsulate(key, encapsulation)
+	if err != nil || !bytes.Equal(secret, decapsulated) {
+		t.Fatal("Could not decapsulate")
+	}
+}
+
+// TestExpandMessageXOF checks the SHAKE256 test vectors of RFC 9380, appendix
+// K.6.
+func TestExpandMessageXOF(t *testing.T) {
+	dst := []byte("QUUX-V01-CS02-with-expander-SHAKE256")
+	for _, test := range []struct {
+		message, expected string
+	}{
+		{"", "2ffc05c48ed32b95d72e807f6eab9f7530dd1c2f013914c8fed38c5ccc15ad76"},
+		{"abc", "b39e493867e2767216792abce1f2676c197c0692aed061560ead251821808e07"},
+	} {
+		if hex.EncodeToString(expandMessageXOF([]byte(test.message), dst, 32)) != test.expected {
+			t.Fatalf("Wrong output for %q", test.message)
+		}
+	}
+}
+
+func TestIdentityHash(t *testing.T) {
+	alice := [][]byte{[]byte("org"), []byte("alice")}
+	for _, h := range []IdentityHash{IdentityHashSHA256, IdentityHashSM3, IdentityHashSHAKE256} {
+		params, master, err := Setup(rand.Reader, 3, WithIdentityHash(h))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h == IdentityHashSHA256 && params.HashID(alice)[1].Cmp(HashID(alice)[1]) != 0 {
+			t.Fatal("SHA-256 is not the default identity hash")
+		}
+		if h != IdentityHashSHA256 && params.HashID(alice)[1].Cmp(HashID(alice)[1]) == 0 {
+			t.Fatalf("%v maps identities like SHA-256", h)
+		}
+
+		// Both sides agree on the hash after any encoding of the parameters
+		pemParams, err := params.MarshalPEM()
+		if err != nil {
+			t.Fatal(err)
+		}
+		jsonParams, err := params.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded := make([]*Params, 4)
+		var ok bool
+		if decoded[0], ok = new(Params).Unmarshal(params.Marshal()); !ok {
+			t.Fatal("Could not unmarshal parameters")
+		}
+		if decoded[1], ok = new(Params).Unmarshal(params.Marshal(WithCompression())); !ok {
+			t.Fatal("Could not unmarshal compressed parameters")
+		}
+		if decoded[2], err = new(Params).ParsePEM(pemParams); err != nil {
+			t.Fatal(err)
+		}
+		decoded[3] = new(Params)
+		if err = decoded[3].UnmarshalJSON(jsonParams); err != nil {
+			t.Fatal(err)
+		}
+
+		key, err := KeyGenFromMasterID(rand.Reader, params, master, alice)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, other := range decoded {
+			if other.IdentityHash != h || !other.Equal(params) || !bytes.Equal(other.Fingerprint(), params.Fingerprint()) {
+				t.Fatalf("%v was not recorded in the encoding", h)
+			}
+			message := NewMessage()
+			ciphertext, err := EncryptID(rand.Reader, other, alice, message)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(message.Marshal(), mustDecrypt(t, key, ciphertext).Marshal()) {
+				t.Fatal("Original and decrypted messages differ")
+			}
+		}
+	}
+
+	// The hash is bound to the fingerprint
+	params, _, err := Setup(rand.Reader, 3, WithIdentityHash(IdentityHashSM3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded := params.Marshal()
+	encoded[headerSize+(1<<geShift)-1] = byte(IdentityHashSHAKE256)
+	if _, ok := new(Params).Unmarshal(encoded); ok {
+		t.Fatal("Changed identity hash was accepted")
+	}
+	if _, _, err = Setup(rand.Reader, 3, WithIdentityHash(IdentityHash(9))); err == nil {
+		t.Fatal("Unknown identity hash was accepted")
+	}
+}
@@ -0,0 +1,166 @@
+// Package ids defines the canonical form of identities written as strings,
+// such as "Corp/Engineering/Alice", before they are hashed onto the
+// hierarchy. Two spellings of the same name that hash to different
+// identities are dangerous: a message encrypted to one cannot be decrypted
+// with the key for the other, and a user may hold keys for both. Normalize
+// maps a path to its canonical components, or rejects it:
+//
+//	components, err := ids.Normalize("Corp/Engineering/Alice")
+//	// components is ["corp", "engineering", "alice"]
+//	id, err := ids.ID(params, "Corp/Engineering/Alice")
+//
+// Components are lowercased with Unicode case mapping, and must be valid
+// UTF-8 without control characters, surrounding white space or empty levels,
+// and at most a given number of bytes long.
+//
+// Unicode NFC needs the composition tables of golang.org/x/text, which this
+// module does not depend on. Without them, text in which the same character
+// can be written precomposed or with combining marks ("é" as U+00E9 or as
+// "e" followed by U+0301) cannot be brought to one form, so Normalize rejects
+// combining marks and conjoining Hangul jamo unless Rules.NFC is set, for
+// instance to norm.NFC.String.
+package ids
+
+import (
+	"errors"
+	"fmt"
+	"hibe_sm9"
+	"math/big"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultMaxLength is the maximum size in bytes of a component if
+// Rules.MaxLength has no limit for its level.
+const DefaultMaxLength = 255
+
+// Separator separates the levels of a path.
+const Separator = "/"
+
+var (
+	// ErrEmpty is returned for paths with an empty level.
+	ErrEmpty = errors.New("ids: empty identity component")
+
+	// ErrInvalid is returned for components that are not valid UTF-8, or
+	// that contain control characters or surrounding white space.
+	ErrInvalid = errors.New("ids: invalid character in identity component")
+
+	// ErrTooLong is returned for components longer than the limit of their
+	// level.
+	ErrTooLong = errors.New("ids: identity component is too long")
+
+	// ErrNotNormalized is returned for components with combining marks when
+	// no NFC function is configured.
+	ErrNotNormalized = errors.New("ids: identity component needs Unicode normalization")
+)
+
+// Rules are the normalization rules of a hierarchy. The zero value is the
+// default.
+type Rules struct {
+	// MaxLength is the maximum size in bytes of the component at each level,
+	// starting with the first. Levels without a positive limit use
+	// DefaultMaxLength.
+	MaxLength []int
+
+	// PreserveCase keeps the case of components, for hierarchies whose names
+	// are case-sensitive, such as serial numbers.
+	PreserveCase bool
+
+	// NFC, if set, converts components to Unicode Normalization Form C
+	// before they are checked, for instance norm.NFC.String from
+	// golang.org/x/text/unicode/norm. Combining marks are then accepted.
+	NFC func(string) string
+}
+
+// Default are the default rules, used by Normalize and ID.
+var Default = &Rules{}
+
+// Normalize returns the canonical components of a path under the default
+// rules.
+func Normalize(path string) ([]string, error) {
+	return Default.Normalize(path)
+}
+
+// ID returns the identity for path in the hierarchy with parameters params:
+// its canonical components under the default rules, hashed with
+// params.HashID.
+func ID(params *hibe_sm9.Params, path string) ([]*big.Int, error) {
+	return Default.ID(params, path)
+}
+
+// Normalize splits path at Separator and returns the canonical form of each
+// component.
+func (rules *Rules) Normalize(path string) ([]string, error) {
+	return rules.NormalizeComponents(strings.Split(path, Separator))
+}
+
+// NormalizeComponents returns the canonical form of each component of an
+// identity given level by level.
+func (rules *Rules) NormalizeComponents(components []string) ([]string, error) {
+	normalized := make([]string, len(components))
+	for i, component := range components {
+		var err error
+		if normalized[i], err = rules.normalize(i+1, component); err != nil {
+			return nil, fmt.Errorf("%w at level %d", err, i+1)
+		}
+	}
+	return normalized, nil
+}
+
+// ID returns the identity for path: its canonical components, hashed with
+// params.HashID.
+func (rules *Rules) ID(params *hibe_sm9.Params, path string) ([]*big.Int, error) {
+	components, err := rules.Normalize(path)
+	if err != nil {
+		return nil, err
+	}
+	encoded := make([][]byte, len(components))
+	for i, component := range components {
+		encoded[i] = []byte(component)
+	}
+	return params.HashID(encoded), nil
+}
+
+// normalize returns the canonical form of the component at the given level,
+// counting from 1.
+func (rules *Rules) normalize(level int, component string) (string, error) {
+	if component == "" {
+		return "", ErrEmpty
+	}
+	if !utf8.ValidString(component) || strings.TrimSpace(component) != component {
+		return "", ErrInvalid
+	}
+	if rules.NFC != nil {
+		component = rules.NFC(component)
+	}
+	if !rules.PreserveCase {
+		component = strings.ToLower(component)
+	}
+	for _, r := range component {
+		switch {
+		case unicode.IsControl(r):
+			return "", ErrInvalid
+		case rules.NFC == nil && (unicode.Is(unicode.M, r) || isConjoiningJamo(r)):
+			return "", ErrNotNormalized
+		}
+	}
+	if len(component) > rules.maxLength(level) {
+		return "", ErrTooLong
+	}
+	return component, nil
+}
+
+// maxLength returns the limit of the given level.
+func (rules *Rules) maxLength(level int) int {
+	if level <= len(rules.MaxLength) && rules.MaxLength[level-1] > 0 {
+		return rules.MaxLength[level-1]
+	}
+	return DefaultMaxLength
+}
+
+// isConjoiningJamo reports whether r is a Hangul jamo that NFC composes into
+// a syllable.
+func isConjoiningJamo(r rune) bool {
+	return r >= 0x1100 && r <= 0x11ff
+}
@@ -0,0 +1,87 @@
+package ids
+
+import (
+	"crypto/rand"
+	"errors"
+	"hibe_sm9"
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	for _, path := range []string{"corp/alice", "Corp/Alice", "CORP/ALICE"} {
+		components, err := Normalize(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(components, Separator) != "corp/alice" {
+			t.Fatalf("%q normalized to %q", path, components)
+		}
+	}
+	// The Kelvin sign lowercases to the letter k
+	components, err := Normalize("\u212aey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if components[0] != "key" {
+		t.Fatal("Kelvin sign was not folded")
+	}
+
+	for path, want := range map[string]error{
+		"corp//alice":       ErrEmpty,
+		"corp/ alice":       ErrInvalid,
+		"corp/ali\x00ce":    ErrInvalid,
+		"corp/\xff":         ErrInvalid,
+		"corp/e\u0301":      ErrNotNormalized,
+		"corp/\u1100\u1161": ErrNotNormalized,
+		"corp/" + strings.Repeat("a", DefaultMaxLength+1): ErrTooLong,
+	} {
+		if _, err := Normalize(path); !errors.Is(err, want) {
+			t.Fatalf("%q: got %v instead of %v", path, err, want)
+		}
+	}
+}
+
+func TestRules(t *testing.T) {
+	rules := &Rules{
+		MaxLength:    []int{4},
+		PreserveCase: true,
+		NFC: func(s string) string {
+			return strings.ReplaceAll(s, "e\u0301", "\u00e9")
+		},
+	}
+	components, err := rules.Normalize("Corp/Cafe\u0301")
+	if err != nil {
+		t.Fatal(err)
+	}
+	composed, err := rules.Normalize("Corp/Caf\u00e9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if components[1] != "Caf\u00e9" || composed[1] != components[1] {
+		t.Fatal("Rules were not applied")
+	}
+	if _, err = rules.Normalize("Corps/x"); !errors.Is(err, ErrTooLong) {
+		t.Fatal("Limit of the first level was not applied")
+	}
+}
+
+func TestID(t *testing.T) {
+	params, _, err := hibe_sm9.Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := ID(params, "Corp/Alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := params.HashID([][]byte{[]byte("corp"), []byte("alice")})
+	for i := range expected {
+		if id[i].Cmp(expected[i]) != 0 {
+			t.Fatal("Identity is not the hash of the canonical components")
+		}
+	}
+	if _, err = ID(params, "corp//alice"); err == nil {
+		t.Fatal("Invalid path was hashed")
+	}
+}
@@ -0,0 +1,99 @@
+package hibe_sm9
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"golang.org/x/crypto/bn256"
+)
+
+// IntegrityTagSize is the size in bytes of the tag added by WithIntegrityTag.
+const IntegrityTagSize = 16
+
+// integrityDomain separates the tag key from other uses of the encapsulated
+// element.
+var integrityDomain = []byte("HIBE-TAG")
+
+var (
+	errIntegrity = wrapError(ErrDecryptFailed, "hibe: ciphertext integrity check failed (wrong key or corrupted ciphertext)")
+	errNoTag     = wrapError(ErrMalformedCiphertext, "hibe: ciphertext has no integrity tag")
+)
+
+// WithIntegrityTag makes Encrypt add a tag to the ciphertext, with which
+// Decrypt detects corruption instead of returning an unrelated element of GT.
+// The tag is an HMAC over the other components, keyed by a hash of the
+// element e(g2, g1)^s that blinds the message, so only the sender and holders
+// of a suitable key can compute it. A tag adds IntegrityTagSize bytes to the
+// encoding.
+//
+// The tag detects accidental or malicious changes to a ciphertext, but it is
+// not a signature: anyone can encrypt a message of their choosing with a
+// valid tag. Since a tag can also be stripped, recipients that expect tagged
+// ciphertexts must decrypt with WithTagRequired.
+func WithIntegrityTag() EncryptOption {
+	return func(config *encryptConfig) {
+		config.tag = true
+	}
+}
+
+// integrityTag computes the tag of ciphertext, given the element that blinds
+// its message.
+func integrityTag(mask *bn256.GT, ciphertext *Ciphertext) []byte {
+	ikm := append(append([]byte{}, integrityDomain...), mask.Marshal()...)
+	defer zeroizeBytes(ikm)
+	key := sha256.Sum256(ikm)
+	defer zeroizeBytes(key[:])
+
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write(ciphertext.marshalComponents(nil))
+	return mac.Sum(nil)[:IntegrityTagSize]
+}
+
+// checkDecrypt runs the checks of Decrypt that come before decryption: the
+// key and ciphertext must have the components that are paired, the ciphertext
+// must belong to the hierarchy of the key, and have a tag if the options
+// require one.
+func checkDecrypt(key *PrivateKey, ciphertext *Ciphertext, config decryptConfig) error {
+	if err := checkComponents(key, ciphertext); err != nil {
+		return err
+	}
+	if err := checkBinding(key.ParamsFingerprint, ciphertext.ParamsFingerprint); err != nil {
+		return err
+	}
+	if config.requireTag && ciphertext.Tag == nil {
+		return errNoTag
+	}
+	return nil
+}
+
+// checkIntegrity verifies the tag of ciphertext, if it has one, given the
+// plaintext recovered from it.
+func checkIntegrity(plaintext *bn256.GT, ciphertext *Ciphertext) error {
+	if ciphertext.Tag == nil {
+		return nil
+	}
+	mask := new(bn256.GT).Neg(plaintext)
+	mask.Add(ciphertext.A, mask)
+	defer zeroizeGT(mask)
+	if !hmac.Equal(integrityTag(mask, ciphertext), ciphertext.Tag) {
+		return errIntegrity
+	}
+	return nil
+}
+
+// CheckKey reports whether key decrypts ciphertext, which must have an
+// integrity tag, so that an application holding several keys can route the
+// ciphertext to the right one. It returns nil if key decrypts ciphertext, an
+// error wrapping ErrDecryptFailed if it does not (or if the ciphertext was
+// corrupted), and one wrapping ErrMalformedCiphertext if there is no tag to
+// check. It costs as much as Decrypt.
+func CheckKey(key *PrivateKey, ciphertext *Ciphertext) error {
+	if ciphertext.Tag == nil {
+		return errNoTag
+	}
+	plaintext, err := Decrypt(key, ciphertext)
+	if err != nil {
+		return err
+	}
+	zeroizeGT(plaintext)
+	return nil
+}
@@ -0,0 +1,159 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func testIntegrityTag(t *testing.T, opts ...SetupOption) {
+	params, master, err := Setup(rand.Reader, 3, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := NewMessage()
+	ciphertext, err := Encrypt(rand.Reader, params, LINEAR_HIERARCHY[:2], message, WithIntegrityTag())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ciphertext.Tag) != IntegrityTagSize {
+		t.Fatal("Ciphertext has no integrity tag")
+	}
+	if !bytes.Equal(message.Marshal(), mustDecrypt(t, key, ciphertext).Marshal()) {
+		t.Fatal("Original and decrypted messages differ")
+	}
+
+	for _, encoded := range [][]byte{ciphertext.Marshal(), ciphertext.Marshal(WithCompression())} {
+		decoded, ok := new(Ciphertext).Unmarshal(encoded)
+		if !ok || !bytes.Equal(decoded.Tag, ciphertext.Tag) {
+			t.Fatal("Integrity tag was not preserved by encoding")
+		}
+		if !bytes.Equal(message.Marshal(), mustDecrypt(t, key, decoded).Marshal()) {
+			t.Fatal("Decoded ciphertext does not decrypt")
+		}
+	}
+	encoded, err := json.Marshal(ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := new(Ciphertext)
+	if err = json.Unmarshal(encoded, decoded); err != nil || !bytes.Equal(decoded.Tag, ciphertext.Tag) {
+		t.Fatal("Integrity tag was not preserved by JSON encoding")
+	}
+
+	// Flipping a bit of any component, or of the tag, is detected
+	encoded = ciphertext.Marshal()
+	for _, offset := range []int{headerSize, headerSize + 6<<geShift, headerSize + 8<<geShift, len(encoded) - 1} {
+		corrupted := append([]byte{}, encoded...)
+		corrupted[offset] ^= 1
+		decoded, ok := new(Ciphertext).Unmarshal(corrupted)
+		if !ok {
+			// Not a valid point any more, which is also detected
+			continue
+		}
+		if _, err = Decrypt(key, decoded); !errors.Is(err, ErrDecryptFailed) {
+			t.Fatalf("Corruption at offset %d was not detected", offset)
+		}
+	}
+
+	other, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[1:3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Decrypt(other, ciphertext); !errors.Is(err, ErrDecryptFailed) {
+		t.Fatal("Decryption with the wrong key was not detected")
+	}
+}
+
+func TestIntegrityTag(t *testing.T) {
+	testIntegrityTag(t)
+}
+
+func TestIntegrityTagAnonymous(t *testing.T) {
+	testIntegrityTag(t, WithAnonymity())
+}
+
+func TestNoIntegrityTag(t *testing.T) {
+	params, _, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := Encrypt(rand.Reader, params, LINEAR_HIERARCHY, NewMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ciphertext.Tag != nil || len(ciphertext.Marshal()) != headerSize+9<<geShift {
+		t.Fatal("Untagged ciphertext encoding changed")
+	}
+}
+
+func TestTagRequired(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := Encrypt(rand.Reader, params, LINEAR_HIERARCHY[:2], NewMessage(), WithIntegrityTag())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Decrypt(key, ciphertext, WithTagRequired()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Stripping the tag and changing A goes unnoticed unless a tag is required
+	ciphertext.Tag = nil
+	ciphertext.A.Add(ciphertext.A, gtBase)
+	if _, err = Decrypt(key, ciphertext); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Decrypt(key, ciphertext, WithTagRequired()); !errors.Is(err, ErrMalformedCiphertext) {
+		t.Fatal("Ciphertext with a stripped tag was decrypted")
+	}
+}
+
+func TestCheckKey(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := make([]*PrivateKey, 3)
+	for i := range keys {
+		id := append(LINEAR_HIERARCHY[:1:1], LINEAR_HIERARCHY[i])
+		if keys[i], err = KeyGenFromMaster(rand.Reader, params, master, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ciphertext, err := Encrypt(rand.Reader, params, LINEAR_HIERARCHY[:2], NewMessage(), WithIntegrityTag())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Route the ciphertext to the one key that decrypts it
+	matched := -1
+	for i, key := range keys {
+		err := CheckKey(key, ciphertext)
+		if err == nil {
+			matched = i
+		} else if !errors.Is(err, ErrDecryptFailed) {
+			t.Fatal(err)
+		}
+	}
+	if matched != 1 {
+		t.Fatal("Ciphertext was routed to the wrong key")
+	}
+
+	ciphertext.Tag = nil
+	if err = CheckKey(keys[1], ciphertext); !errors.Is(err, ErrMalformedCiphertext) {
+		t.Fatal("Untagged ciphertext was checked")
+	}
+}
@@ -0,0 +1,304 @@
+// Package interop checks this implementation against others, such as a
+// Python implementation of the BBG scheme, with test vectors in a JSON format
+// that spells out every group element:
+//
+//	[{"source": "...", "curve": "bn256", "id": ["1", "2"],
+//	  "params": {"g": "..", "g1": "..", "g2": "..", "g3": "..", "h": [".."]},
+//	  "private_key": {"a0": "..", "a1": "..", "b": [".."]},
+//	  "message": "..", "ciphertext": {"a": "..", "b": "..", "c": ".."}}]
+//
+// Check verifies vectors produced elsewhere, and Export produces vectors for
+// the other side to verify. The file testdata/vectors.json holds vectors
+// from this implementation; vectors from other implementations can be added
+// to testdata, and are checked by the tests of this package.
+//
+// In the notation of Boneh, Boyen and Goh (2005), with the pairing e(G1, G2)
+// of golang.org/x/crypto/bn256: g and g1 = g^alpha are in G2, and g2, g3 and
+// h1 ... hl in G1. The key for ID = (I1 ... Ik) is a0 = g2^alpha * (g3 * h1^I1
+// * ... * hk^Ik)^r and b = (h(k+1)^r ... hl^r) in G1, and a1 = g^r in G2. The
+// ciphertext of M is a = M * e(g2, g1)^s in GT, b = g^s in G2 and c = (g3 *
+// h1^I1 * ... * hk^Ik)^s in G1, and M = a * e(c, a1) / e(a0, b). Identity
+// components are decimal integers modulo the group order, and GT is written
+// additively by bn256, so M * X is bn256's Add.
+//
+// Elements are hex encoded as by the Marshal methods of bn256: the affine
+// coordinates, 32 bytes each, big endian, with the coefficient of i first for
+// elements of GF(p^2). The curve is the 256-bit BN curve of bn256, which is
+// not the BN254 curve of Charm-crypto or the alt_bn128 curve of Ethereum, so
+// other implementations must use its parameters to interoperate; vectors for
+// any other curve are rejected with ErrCurve.
+//
+// The SM9 master and user key formats of GmSSL are not supported. Those keys
+// are points on the SM9 curve, which this module does not implement, so
+// importing and exporting them remains open until there is an SM9 scheme.
+package interop
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bn256"
+	"hibe_sm9"
+	"io"
+	"math/big"
+)
+
+// Curve is the name of the curve of the vectors this package understands.
+const Curve = "bn256"
+
+// Source is the source of the vectors that Export produces.
+const Source = "hibe_sm9"
+
+var (
+	// ErrCurve is returned for vectors on another curve.
+	ErrCurve = errors.New("interop: vector is not on curve " + Curve)
+
+	// ErrMismatch is returned when a vector does not verify.
+	ErrMismatch = errors.New("interop: vector does not verify")
+)
+
+// Vector is a test vector: the parameters of a hierarchy, the key for an
+// identity, and a message encrypted for it.
+type Vector struct {
+	Source     string     `json:"source,omitempty"`
+	Curve      string     `json:"curve"`
+	ID         []string   `json:"id"`
+	Params     Params     `json:"params"`
+	PrivateKey PrivateKey `json:"private_key"`
+	Message    string     `json:"message"`
+	Ciphertext Ciphertext `json:"ciphertext"`
+}
+
+// Params are the public parameters of a vector.
+type Params struct {
+	G  string   `json:"g"`
+	G1 string   `json:"g1"`
+	G2 string   `json:"g2"`
+	G3 string   `json:"g3"`
+	H  []string `json:"h"`
+}
+
+// PrivateKey is the private key of a vector.
+type PrivateKey struct {
+	A0 string   `json:"a0"`
+	A1 string   `json:"a1"`
+	B  []string `json:"b"`
+}
+
+// Ciphertext is the ciphertext of a vector.
+type Ciphertext struct {
+	A string `json:"a"`
+	B string `json:"b"`
+	C string `json:"c"`
+}
+
+// Load reads a JSON array of vectors.
+func Load(r io.Reader) ([]*Vector, error) {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	var vectors []*Vector
+	if err := decoder.Decode(&vectors); err != nil {
+		return nil, err
+	}
+	return vectors, nil
+}
+
+// Write writes vectors as an indented JSON array.
+func Write(w io.Writer, vectors []*Vector) error {
+	encoded, err := json.MarshalIndent(vectors, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(encoded, '\n'))
+	return err
+}
+
+// Export sets up a hierarchy of the given depth, and produces the vector for
+// id in it with a random message.
+func Export(random io.Reader, depth int, id []*big.Int) (*Vector, error) {
+	params, master, err := hibe_sm9.Setup(random, depth)
+	if err != nil {
+		return nil, err
+	}
+	key, err := hibe_sm9.KeyGenFromMaster(random, params, master, id)
+	if err != nil {
+		return nil, err
+	}
+	if random == nil {
+		random = rand.Reader
+	}
+	m, err := rand.Int(random, bn256.Order)
+	if err != nil {
+		return nil, err
+	}
+	message := new(bn256.GT).ScalarMult(generator(), m)
+	ciphertext, err := hibe_sm9.Encrypt(random, params, id, message)
+	if err != nil {
+		return nil, err
+	}
+
+	vector := &Vector{
+		Source: Source,
+		Curve:  Curve,
+		ID:     make([]string, len(id)),
+		Params: Params{
+			G:  hex.EncodeToString(params.G.Marshal()),
+			G1: hex.EncodeToString(params.G1.Marshal()),
+			G2: hex.EncodeToString(params.G2.Marshal()),
+			G3: hex.EncodeToString(params.G3.Marshal()),
+			H:  make([]string, len(params.H)),
+		},
+		PrivateKey: PrivateKey{
+			A0: hex.EncodeToString(key.A0.Marshal()),
+			A1: hex.EncodeToString(key.A1.Marshal()),
+			B:  make([]string, len(key.B)),
+		},
+		Message: hex.EncodeToString(message.Marshal()),
+		Ciphertext: Ciphertext{
+			A: hex.EncodeToString(ciphertext.A.Marshal()),
+			B: hex.EncodeToString(ciphertext.B.Marshal()),
+			C: hex.EncodeToString(ciphertext.C.Marshal()),
+		},
+	}
+	for i, component := range id {
+		vector.ID[i] = component.String()
+	}
+	for i, hi := range params.H {
+		vector.Params.H[i] = hex.EncodeToString(hi.Marshal())
+	}
+	for i, bi := range key.B {
+		vector.PrivateKey.B[i] = hex.EncodeToString(bi.Marshal())
+	}
+	return vector, nil
+}
+
+// generator returns the pairing of the generators of G1 and G2.
+func generator() *bn256.GT {
+	one := big.NewInt(1)
+	return bn256.Pair(new(bn256.G1).ScalarBaseMult(one), new(bn256.G2).ScalarBaseMult(one))
+}
+
+// Check verifies a vector: the parameters must be valid, the private key must
+// be a valid key for the identity (see hibe_sm9.VerifyKey), and it must
+// decrypt both the ciphertext of the vector and a fresh ciphertext made by
+// this implementation.
+func (vector *Vector) Check() error {
+	if vector.Curve != Curve {
+		return ErrCurve
+	}
+	decoder := &elementDecoder{}
+	id := make([]*big.Int, len(vector.ID))
+	for i, component := range vector.ID {
+		var ok bool
+		id[i], ok = new(big.Int).SetString(component, 10)
+		if !ok || id[i].Sign() < 0 || id[i].Cmp(bn256.Order) >= 0 {
+			return fmt.Errorf("interop: malformed identity component %q", component)
+		}
+	}
+	params := &hibe_sm9.Params{
+		G:  decoder.g2("params.g", vector.Params.G),
+		G1: decoder.g2("params.g1", vector.Params.G1),
+		G2: decoder.g1("params.g2", vector.Params.G2),
+		G3: decoder.g1("params.g3", vector.Params.G3),
+		H:  make([]*bn256.G1, len(vector.Params.H)),
+	}
+	for i, hi := range vector.Params.H {
+		params.H[i] = decoder.g1(fmt.Sprintf("params.h[%d]", i), hi)
+	}
+	key := &hibe_sm9.PrivateKey{
+		A0: decoder.g1("private_key.a0", vector.PrivateKey.A0),
+		A1: decoder.g2("private_key.a1", vector.PrivateKey.A1),
+		B:  make([]*bn256.G1, len(vector.PrivateKey.B)),
+	}
+	for i, bi := range vector.PrivateKey.B {
+		key.B[i] = decoder.g1(fmt.Sprintf("private_key.b[%d]", i), bi)
+	}
+	ciphertext := &hibe_sm9.Ciphertext{
+		A: decoder.gt("ciphertext.a", vector.Ciphertext.A),
+		B: decoder.g2("ciphertext.b", vector.Ciphertext.B),
+		C: decoder.g1("ciphertext.c", vector.Ciphertext.C),
+	}
+	message := decoder.gt("message", vector.Message)
+	if decoder.err != nil {
+		return decoder.err
+	}
+
+	if err := params.Validate(); err != nil {
+		return err
+	}
+	if err := hibe_sm9.VerifyKey(params, id, key); err != nil {
+		return fmt.Errorf("%w: private key: %v", ErrMismatch, err)
+	}
+	decrypted, err := hibe_sm9.Decrypt(key, ciphertext)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(decrypted.Marshal(), message.Marshal()) {
+		return fmt.Errorf("%w: ciphertext does not decrypt to the message", ErrMismatch)
+	}
+
+	fresh, err := rand.Int(rand.Reader, bn256.Order)
+	if err != nil {
+		return err
+	}
+	message = new(bn256.GT).ScalarMult(generator(), fresh)
+	if ciphertext, err = hibe_sm9.Encrypt(rand.Reader, params, id, message); err != nil {
+		return err
+	}
+	if decrypted, err = hibe_sm9.Decrypt(key, ciphertext); err != nil {
+		return err
+	}
+	if !bytes.Equal(decrypted.Marshal(), message.Marshal()) {
+		return fmt.Errorf("%w: private key does not decrypt a fresh ciphertext", ErrMismatch)
+	}
+	return nil
+}
+
+// elementDecoder decodes hex-encoded elements, keeping the first error.
+type elementDecoder struct {
+	err error
+}
+
+func (decoder *elementDecoder) bytes(name string, encoded string) []byte {
+	if decoder.err != nil {
+		return nil
+	}
+	decoded, err := hex.DecodeString(encoded)
+	if err != nil {
+		decoder.err = fmt.Errorf("interop: malformed %s: %v", name, err)
+	}
+	return decoded
+}
+
+func (decoder *elementDecoder) fail(name string) {
+	if decoder.err == nil {
+		decoder.err = fmt.Errorf("interop: %s is not a valid group element", name)
+	}
+}
+
+func (decoder *elementDecoder) g1(name string, encoded string) *bn256.G1 {
+	element, ok := new(bn256.G1).Unmarshal(decoder.bytes(name, encoded))
+	if !ok {
+		decoder.fail(name)
+	}
+	return element
+}
+
+func (decoder *elementDecoder) g2(name string, encoded string) *bn256.G2 {
+	element, ok := new(bn256.G2).Unmarshal(decoder.bytes(name, encoded))
+	if !ok {
+		decoder.fail(name)
+	}
+	return element
+}
+
+func (decoder *elementDecoder) gt(name string, encoded string) *bn256.GT {
+	element, ok := new(bn256.GT).Unmarshal(decoder.bytes(name, encoded))
+	if !ok {
+		decoder.fail(name)
+	}
+	return element
+}
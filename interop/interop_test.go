@@ -0,0 +1,111 @@
+package interop
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"flag"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var updateVectors = flag.Bool("update-vectors", false, "regenerate testdata/vectors.json")
+
+const vectorsPath = "testdata/vectors.json"
+
+// vectorInputs are the depths and identities of the vectors in
+// testdata/vectors.json.
+var vectorInputs = []struct {
+	depth int
+	id    []*big.Int
+}{
+	{1, []*big.Int{big.NewInt(1)}},
+	{3, []*big.Int{big.NewInt(1), big.NewInt(2)}},
+	{5, []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(3), big.NewInt(4), big.NewInt(5)}},
+}
+
+func TestVectors(t *testing.T) {
+	if *updateVectors {
+		vectors := make([]*Vector, len(vectorInputs))
+		for i, input := range vectorInputs {
+			var err error
+			if vectors[i], err = Export(rand.Reader, input.depth, input.id); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var encoded bytes.Buffer
+		if err := Write(&encoded, vectors); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(vectorsPath, encoded.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Every file in testdata, including vectors from other implementations
+	paths, err := filepath.Glob("testdata/*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("No test vectors")
+	}
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vectors, err := Load(file)
+		file.Close()
+		if err != nil {
+			t.Fatal(path, err)
+		}
+		for i, vector := range vectors {
+			if err = vector.Check(); err != nil {
+				t.Fatal(path, i, vector.Source, err)
+			}
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	vector, err := Export(rand.Reader, 2, []*big.Int{big.NewInt(7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var encoded bytes.Buffer
+	if err = Write(&encoded, []*Vector{vector}); err != nil {
+		t.Fatal(err)
+	}
+	vectors, err := Load(&encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = vectors[0].Check(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A vector for another identity, message or curve fails
+	other := *vector
+	other.ID = []string{"8"}
+	if err = other.Check(); !errors.Is(err, ErrMismatch) {
+		t.Fatal("Checked a vector for the wrong identity")
+	}
+	other = *vector
+	other.Message = vector.Ciphertext.A
+	if err = other.Check(); !errors.Is(err, ErrMismatch) {
+		t.Fatal("Checked a vector with the wrong message")
+	}
+	other = *vector
+	other.Curve = "BN254"
+	if err = other.Check(); !errors.Is(err, ErrCurve) {
+		t.Fatal("Checked a vector on another curve")
+	}
+	other = *vector
+	other.PrivateKey.A0 = "00"
+	if err = other.Check(); err == nil {
+		t.Fatal("Checked a vector with a malformed element")
+	}
+}
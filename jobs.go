@@ -0,0 +1,252 @@
+package hibe_sm9
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"runtime"
+	"sync"
+)
+
+// DefaultCheckpointInterval is the number of keys between checkpoints if
+// JobRunner.CheckpointInterval is zero.
+const DefaultCheckpointInterval = 64
+
+var errJobState = errors.New("hibe: job state does not match the job")
+
+// KeyGenJob is a batch of identities to generate keys for with a JobRunner.
+type KeyGenJob struct {
+	// IDs are the identities of the job.
+	IDs [][]*big.Int
+
+	// Deliver receives the key for IDs[index], for instance to store it or
+	// send it to the device it is for. It is called by one worker at a time.
+	// If it fails, the identity is not marked done, and is retried when the
+	// job is resumed.
+	Deliver func(index int, key *PrivateKey) error
+
+	// State is the state of the job from a checkpoint, to resume it after a
+	// restart, or nil to start it afresh.
+	State *JobState
+}
+
+// JobState records which identities of a job have been delivered. It holds no
+// keys, so it can be persisted anywhere, for instance as JSON.
+type JobState struct {
+	Total int    `json:"total"`
+	Done  []byte `json:"done"`
+}
+
+// newJobState returns the state of a job of total identities, none of them
+// done.
+func newJobState(total int) *JobState {
+	return &JobState{Total: total, Done: make([]byte, (total+7)/8)}
+}
+
+// IsDone reports whether the identity with the given index has been
+// delivered.
+func (state *JobState) IsDone(index int) bool {
+	return state.Done[index/8]&(1<<(index%8)) != 0
+}
+
+// Completed returns the number of identities that have been delivered.
+func (state *JobState) Completed() int {
+	completed := 0
+	for index := 0; index != state.Total; index++ {
+		if state.IsDone(index) {
+			completed++
+		}
+	}
+	return completed
+}
+
+func (state *JobState) clone() *JobState {
+	return &JobState{Total: state.Total, Done: append([]byte{}, state.Done...)}
+}
+
+// JobProgress is reported after each identity of a job: Index is the identity
+// and Err its error, if key generation or delivery failed.
+type JobProgress struct {
+	Index  int
+	Err    error
+	Done   int
+	Failed int
+	Total  int
+}
+
+// JobRunner generates keys for large batches of identities, as provisioning
+// pipelines do, in a way that survives restarts. Keys are generated as by
+// KeyGenBatch and handed to the Deliver function of the job as they are
+// ready; every CheckpointInterval keys, the JobState of the job is passed to
+// Checkpoint to be persisted, so that a job that is interrupted can be resumed
+// from its last checkpoint without generating the keys already delivered
+// again. A key delivered after the last checkpoint is generated and delivered
+// again on resumption, so Deliver must tolerate duplicates.
+//
+// Run can be paused and resumed while it runs with Pause and Resume, and
+// stopped by cancelling its context.
+type JobRunner struct {
+	params  *Params
+	master  MasterKey
+	random  io.Reader
+	workers int
+
+	// Progress, if set, is called after each identity, by one worker at a
+	// time.
+	Progress func(JobProgress)
+
+	// Checkpoint, if set, persists the state of the job. It is called every
+	// CheckpointInterval keys (DefaultCheckpointInterval if zero), and when
+	// Run returns.
+	Checkpoint         func(*JobState) error
+	CheckpointInterval int
+
+	lock   sync.Mutex
+	cond   *sync.Cond
+	paused bool
+}
+
+// NewJobRunner returns a JobRunner that generates keys with the master key,
+// spread over the given number of workers (GOMAXPROCS if workers <= 0).
+func NewJobRunner(random io.Reader, params *Params, master MasterKey, workers int) *JobRunner {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	runner := &JobRunner{
+		params:  params,
+		master:  master,
+		random:  &lockedReader{r: randomSource(random)},
+		workers: workers,
+	}
+	runner.cond = sync.NewCond(&runner.lock)
+	return runner
+}
+
+// Pause makes the workers stop after the identity they are working on, until
+// Resume is called. Run does not return while paused, unless its context is
+// done.
+func (runner *JobRunner) Pause() {
+	runner.lock.Lock()
+	defer runner.lock.Unlock()
+	runner.paused = true
+}
+
+// Resume lets the workers continue after Pause.
+func (runner *JobRunner) Resume() {
+	runner.lock.Lock()
+	defer runner.lock.Unlock()
+	runner.paused = false
+	runner.cond.Broadcast()
+}
+
+// Run generates and delivers the keys of job that are not done yet, and
+// returns its final state. It returns ctx.Err() if ctx is done first, and an
+// error if any identity failed; the state then records the identities that
+// remain, and the job can be run again with it.
+func (runner *JobRunner) Run(ctx context.Context, job *KeyGenJob) (*JobState, error) {
+	state := job.State
+	if state == nil {
+		state = newJobState(len(job.IDs))
+	} else if state.Total != len(job.IDs) || len(state.Done) != (state.Total+7)/8 {
+		return nil, errJobState
+	} else {
+		state = state.clone()
+	}
+	runner.params.Precache()
+
+	interval := runner.CheckpointInterval
+	if interval <= 0 {
+		interval = DefaultCheckpointInterval
+	}
+	var pending []int
+	for index := 0; index != state.Total; index++ {
+		if !state.IsDone(index) {
+			pending = append(pending, index)
+		}
+	}
+	progress := JobProgress{Done: state.Total - len(pending), Total: state.Total}
+	sinceCheckpoint := 0
+	var checkpointErr error
+
+	// Wake paused workers when ctx is done.
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-ctx.Done():
+			runner.lock.Lock()
+			runner.cond.Broadcast()
+			runner.lock.Unlock()
+		case <-finished:
+		}
+	}()
+
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(runner.workers)
+	for w := 0; w != runner.workers; w++ {
+		go func() {
+			defer wg.Done()
+			for index := range indices {
+				runner.lock.Lock()
+				for runner.paused && ctx.Err() == nil {
+					runner.cond.Wait()
+				}
+				runner.lock.Unlock()
+				if ctx.Err() != nil {
+					continue
+				}
+
+				key, err := KeyGenFromMaster(runner.random, runner.params, runner.master, job.IDs[index])
+				runner.lock.Lock()
+				if err == nil && job.Deliver != nil {
+					err = job.Deliver(index, key)
+				}
+				if err == nil {
+					state.Done[index/8] |= 1 << (index % 8)
+					progress.Done++
+					if sinceCheckpoint++; sinceCheckpoint == interval && runner.Checkpoint != nil {
+						sinceCheckpoint = 0
+						if err := runner.Checkpoint(state.clone()); err != nil && checkpointErr == nil {
+							checkpointErr = err
+						}
+					}
+				} else {
+					progress.Failed++
+				}
+				if runner.Progress != nil {
+					progress.Index, progress.Err = index, err
+					runner.Progress(progress)
+				}
+				runner.lock.Unlock()
+			}
+		}()
+	}
+feed:
+	for _, index := range pending {
+		select {
+		case indices <- index:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indices)
+	wg.Wait()
+
+	if runner.Checkpoint != nil {
+		if err := runner.Checkpoint(state.clone()); err != nil && checkpointErr == nil {
+			checkpointErr = err
+		}
+	}
+	switch {
+	case ctx.Err() != nil:
+		return state, ctx.Err()
+	case checkpointErr != nil:
+		return state, checkpointErr
+	case progress.Failed != 0:
+		return state, fmt.Errorf("hibe: %d identities of the job failed", progress.Failed)
+	}
+	return state, nil
+}
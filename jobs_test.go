@@ -0,0 +1,130 @@
+package hibe_sm9
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+)
+
+func jobIDs(n int) [][]*big.Int {
+	ids := make([][]*big.Int, n)
+	for i := range ids {
+		ids[i] = []*big.Int{big.NewInt(1), big.NewInt(int64(i + 2))}
+	}
+	return ids
+}
+
+func TestJobRunnerResume(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := jobIDs(10)
+	keys := make([]*PrivateKey, len(ids))
+	deliveries := 0
+	deliver := func(index int, key *PrivateKey) error {
+		if index == 3 && keys[3] == nil && deliveries == 0 {
+			deliveries++
+			return errors.New("storage unavailable")
+		}
+		keys[index] = key
+		return nil
+	}
+
+	runner := NewJobRunner(rand.Reader, params, master, 2)
+	runner.CheckpointInterval = 4
+	var persisted []byte
+	runner.Checkpoint = func(state *JobState) error {
+		persisted, err = json.Marshal(state)
+		return err
+	}
+	var reports []JobProgress
+	runner.Progress = func(progress JobProgress) {
+		reports = append(reports, progress)
+	}
+	state, err := runner.Run(context.Background(), &KeyGenJob{IDs: ids, Deliver: deliver})
+	if err == nil {
+		t.Fatal("Failed delivery was not reported")
+	}
+	if state.Completed() != 9 || state.IsDone(3) || len(reports) != 10 || reports[9].Failed != 1 {
+		t.Fatal("Wrong state after a failed delivery")
+	}
+
+	// Resume from the persisted checkpoint, as after a restart
+	restored := new(JobState)
+	if err = json.Unmarshal(persisted, restored); err != nil {
+		t.Fatal(err)
+	}
+	if state, err = runner.Run(context.Background(), &KeyGenJob{IDs: ids, Deliver: deliver, State: restored}); err != nil {
+		t.Fatal(err)
+	}
+	if state.Completed() != len(ids) {
+		t.Fatal("Job did not complete")
+	}
+	for i, key := range keys {
+		if VerifyKey(params, ids[i], key) != nil {
+			t.Fatal("Delivered key is not for its identity")
+		}
+	}
+
+	if _, err = runner.Run(context.Background(), &KeyGenJob{IDs: ids[1:], State: restored}); err != errJobState {
+		t.Fatal("State of another job was accepted")
+	}
+}
+
+func TestJobRunnerPause(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner := NewJobRunner(rand.Reader, params, master, 1)
+	var lock sync.Mutex
+	delivered := 0
+	job := &KeyGenJob{IDs: jobIDs(4), Deliver: func(int, *PrivateKey) error {
+		lock.Lock()
+		defer lock.Unlock()
+		delivered++
+		return nil
+	}}
+
+	runner.Pause()
+	done := make(chan struct{})
+	var state *JobState
+	go func() {
+		defer close(done)
+		state, err = runner.Run(context.Background(), job)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	lock.Lock()
+	if delivered != 0 {
+		t.Fatal("Paused runner delivered a key")
+	}
+	lock.Unlock()
+	runner.Resume()
+	<-done
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Completed() != 4 || delivered != 4 {
+		t.Fatal("Resumed job did not complete")
+	}
+
+	// Cancelling a paused job returns its state
+	ctx, cancel := context.WithCancel(context.Background())
+	runner.Pause()
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	if state, err = runner.Run(ctx, &KeyGenJob{IDs: jobIDs(4)}); err != context.Canceled {
+		t.Fatal("Cancelled job did not return the context error")
+	}
+	if state.Completed() != 0 {
+		t.Fatal("Paused job made progress")
+	}
+}
@@ -0,0 +1,290 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"golang.org/x/crypto/bn256"
+	"io"
+)
+
+// jsonVersion is the version of the JSON encodings below. It is included in
+// every encoded object as the "v" field, and decoding fails for any other
+// version.
+const jsonVersion = 1
+
+var errJSONVersion = errors.New("hibe: unsupported JSON encoding version")
+var errJSONTag = wrapError(ErrMalformedCiphertext, "hibe: integrity tag has the wrong length")
+var errJSONFingerprint = errors.New("hibe: fingerprint has the wrong length")
+
+// jsonParams is the JSON encoding of Params. Group elements are encoded with
+// their Marshal method and then base64, as encoding/json does for []byte.
+type jsonParams struct {
+	Version int      `json:"v"`
+	G       []byte   `json:"g"`
+	G1      []byte   `json:"g1"`
+	G2      []byte   `json:"g2"`
+	G3      []byte   `json:"g3"`
+	H       [][]byte `json:"h,omitempty"`
+	G3Hat   []byte   `json:"g3_hat,omitempty"`
+	HHat    [][]byte `json:"h_hat,omitempty"`
+	IDHash  string   `json:"id_hash,omitempty"`
+	HSeed   []byte   `json:"h_seed,omitempty"`
+	Depth   int      `json:"depth,omitempty"`
+}
+
+// jsonPrivateKey is the JSON encoding of PrivateKey.
+type jsonPrivateKey struct {
+	Version int      `json:"v"`
+	A0      []byte   `json:"a0"`
+	A1      []byte   `json:"a1,omitempty"`
+	B       [][]byte `json:"b"`
+	A1Hat   []byte   `json:"a1_hat,omitempty"`
+	Params  []byte   `json:"params,omitempty"`
+}
+
+// jsonCiphertext is the JSON encoding of Ciphertext.
+type jsonCiphertext struct {
+	Version int    `json:"v"`
+	A       []byte `json:"a"`
+	B       []byte `json:"b"`
+	C       []byte `json:"c,omitempty"`
+	CHat    []byte `json:"c_hat,omitempty"`
+	Tag     []byte `json:"tag,omitempty"`
+	Params  []byte `json:"params,omitempty"`
+}
+
+// decodeJSONStrict decodes exactly one JSON object into out, rejecting
+// unknown fields and trailing data.
+func decodeJSONStrict(data []byte, out interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(out); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return errors.New("hibe: trailing data after JSON object")
+	}
+	return nil
+}
+
+// MarshalJSON encodes the parameters as a JSON object.
+func (params *Params) MarshalJSON() ([]byte, error) {
+	encoded := jsonParams{
+		Version: jsonVersion,
+		G:       params.G.Marshal(),
+		G1:      params.G1.Marshal(),
+		G2:      params.G2.Marshal(),
+		G3:      params.G3.Marshal(),
+		H:       make([][]byte, len(params.H)),
+	}
+	for i, hi := range params.H {
+		encoded.H[i] = hi.Marshal()
+	}
+	if params.HSeed != nil {
+		encoded.H, encoded.HSeed, encoded.Depth = nil, params.HSeed, len(params.H)
+	}
+	if params.Anonymous() {
+		encoded.G3Hat = params.G3Hat.Marshal()
+		encoded.HHat = make([][]byte, len(params.HHat))
+		for i, hi := range params.HHat {
+			encoded.HHat[i] = hi.Marshal()
+		}
+	}
+	if params.IdentityHash != IdentityHashSHA256 {
+		encoded.IDHash = params.IdentityHash.String()
+	}
+	return json.Marshal(encoded)
+}
+
+// UnmarshalJSON decodes the parameters from a JSON object produced by
+// MarshalJSON. Unknown fields are rejected and the parameters are validated.
+func (params *Params) UnmarshalJSON(data []byte) error {
+	var encoded jsonParams
+	err := decodeJSONStrict(data, &encoded)
+	if err != nil {
+		return err
+	}
+	if encoded.Version != jsonVersion {
+		return errJSONVersion
+	}
+
+	decoded := &Params{}
+	if encoded.IDHash != "" {
+		var ok bool
+		if decoded.IdentityHash, ok = parseIdentityHash(encoded.IDHash); !ok {
+			return errIdentityHash
+		}
+	}
+	if decoded.G, err = unmarshalG2(encoded.G); err != nil {
+		return err
+	}
+	if decoded.G1, err = unmarshalG2(encoded.G1); err != nil {
+		return err
+	}
+	if decoded.G2, err = unmarshalG1(encoded.G2); err != nil {
+		return err
+	}
+	if decoded.G3, err = unmarshalG1(encoded.G3); err != nil {
+		return err
+	}
+	decoded.H = make([]*bn256.G1, len(encoded.H))
+	for i, hi := range encoded.H {
+		if decoded.H[i], err = unmarshalG1(hi); err != nil {
+			return err
+		}
+	}
+	if encoded.HSeed != nil {
+		if len(encoded.H) != 0 {
+			return errHSeed
+		}
+		if err = decoded.setHSeed(encoded.HSeed, encoded.Depth); err != nil {
+			return err
+		}
+	}
+	if encoded.G3Hat != nil {
+		if decoded.G3Hat, err = unmarshalG2(encoded.G3Hat); err != nil {
+			return err
+		}
+		decoded.HHat = make([]*bn256.G2, len(encoded.HHat))
+		for i, hi := range encoded.HHat {
+			if decoded.HHat[i], err = unmarshalG2(hi); err != nil {
+				return err
+			}
+		}
+	}
+	if err = decoded.Validate(); err != nil {
+		return err
+	}
+
+	*params = *decoded
+	return nil
+}
+
+// MarshalJSON encodes the private key as a JSON object.
+func (key *PrivateKey) MarshalJSON() ([]byte, error) {
+	encoded := jsonPrivateKey{
+		Version: jsonVersion,
+		A0:      key.A0.Marshal(),
+		B:       make([][]byte, len(key.B)),
+		Params:  key.ParamsFingerprint,
+	}
+	if key.A1Hat != nil {
+		encoded.A1Hat = key.A1Hat.Marshal()
+	} else {
+		encoded.A1 = key.A1.Marshal()
+	}
+	for i, bi := range key.B {
+		encoded.B[i] = bi.Marshal()
+	}
+	return json.Marshal(encoded)
+}
+
+// UnmarshalJSON decodes the private key from a JSON object produced by
+// MarshalJSON. Unknown fields are rejected and the group elements of the key
+// are validated; use Validate to also check it against the parameters.
+func (key *PrivateKey) UnmarshalJSON(data []byte) error {
+	var encoded jsonPrivateKey
+	err := decodeJSONStrict(data, &encoded)
+	if err != nil {
+		return err
+	}
+	if encoded.Version != jsonVersion {
+		return errJSONVersion
+	}
+
+	decoded := &PrivateKey{}
+	if decoded.A0, err = unmarshalG1(encoded.A0); err != nil {
+		return err
+	}
+	if encoded.A1Hat != nil {
+		if decoded.A1Hat, err = unmarshalG1(encoded.A1Hat); err != nil {
+			return err
+		}
+	} else if decoded.A1, err = unmarshalG2(encoded.A1); err != nil {
+		return err
+	}
+	if len(encoded.B) != 0 {
+		decoded.B = make([]*bn256.G1, len(encoded.B))
+	}
+	for i, bi := range encoded.B {
+		if decoded.B[i], err = unmarshalG1(bi); err != nil {
+			return err
+		}
+	}
+	if encoded.Params != nil {
+		if len(encoded.Params) != FingerprintSize {
+			return errJSONFingerprint
+		}
+		decoded.ParamsFingerprint = encoded.Params
+	}
+	if err = decoded.validatePoints(); err != nil {
+		return err
+	}
+
+	*key = *decoded
+	return nil
+}
+
+// MarshalJSON encodes the ciphertext as a JSON object.
+func (ciphertext *Ciphertext) MarshalJSON() ([]byte, error) {
+	encoded := jsonCiphertext{
+		Version: jsonVersion,
+		A:       ciphertext.A.Marshal(),
+		B:       ciphertext.B.Marshal(),
+		Tag:     ciphertext.Tag,
+		Params:  ciphertext.ParamsFingerprint,
+	}
+	if ciphertext.CHat != nil {
+		encoded.CHat = ciphertext.CHat.Marshal()
+	} else {
+		encoded.C = ciphertext.C.Marshal()
+	}
+	return json.Marshal(encoded)
+}
+
+// UnmarshalJSON decodes the ciphertext from a JSON object produced by
+// MarshalJSON. Unknown fields are rejected and the ciphertext is validated.
+func (ciphertext *Ciphertext) UnmarshalJSON(data []byte) error {
+	var encoded jsonCiphertext
+	err := decodeJSONStrict(data, &encoded)
+	if err != nil {
+		return err
+	}
+	if encoded.Version != jsonVersion {
+		return errJSONVersion
+	}
+
+	decoded := &Ciphertext{}
+	if decoded.A, err = unmarshalGT(encoded.A); err != nil {
+		return err
+	}
+	if decoded.B, err = unmarshalG2(encoded.B); err != nil {
+		return err
+	}
+	if encoded.CHat != nil {
+		if decoded.CHat, err = unmarshalG2(encoded.CHat); err != nil {
+			return err
+		}
+	} else if decoded.C, err = unmarshalG1(encoded.C); err != nil {
+		return err
+	}
+	if encoded.Tag != nil {
+		if len(encoded.Tag) != IntegrityTagSize {
+			return errJSONTag
+		}
+		decoded.Tag = encoded.Tag
+	}
+	if encoded.Params != nil {
+		if len(encoded.Params) != FingerprintSize {
+			return errJSONFingerprint
+		}
+		decoded.ParamsFingerprint = encoded.Params
+	}
+	if err = decoded.Validate(); err != nil {
+		return err
+	}
+
+	*ciphertext = *decoded
+	return nil
+}
@@ -0,0 +1,88 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+)
+
+func TestJSON(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := Encrypt(rand.Reader, params, LINEAR_HIERARCHY[:2], NewMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Embed everything in a larger document, as a web service would
+	type document struct {
+		Params     *Params     `json:"params"`
+		Key        *PrivateKey `json:"key"`
+		Ciphertext *Ciphertext `json:"ciphertext"`
+	}
+	encoded, err := json.Marshal(document{params, key, ciphertext})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded document
+	if err = json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(params.Marshal(), decoded.Params.Marshal()) {
+		t.Fatal("Parameters differ after JSON round trip")
+	}
+	if !bytes.Equal(key.Marshal(), decoded.Key.Marshal()) {
+		t.Fatal("Private keys differ after JSON round trip")
+	}
+	if !bytes.Equal(ciphertext.Marshal(), decoded.Ciphertext.Marshal()) {
+		t.Fatal("Ciphertexts differ after JSON round trip")
+	}
+}
+
+func TestJSONStrict(t *testing.T) {
+	params, _, err := Setup(rand.Reader, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := Encrypt(rand.Reader, params, LINEAR_HIERARCHY[:1], NewMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := json.Marshal(ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fields map[string]interface{}
+	if err = json.Unmarshal(encoded, &fields); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mutate := range []func(map[string]interface{}){
+		func(f map[string]interface{}) { f["v"] = 2 },
+		func(f map[string]interface{}) { f["extra"] = true },
+		func(f map[string]interface{}) { delete(f, "b") },
+		func(f map[string]interface{}) { f["c"] = "AAAA" },
+	} {
+		mutated := make(map[string]interface{})
+		for k, v := range fields {
+			mutated[k] = v
+		}
+		mutate(mutated)
+		data, err := json.Marshal(mutated)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = new(Ciphertext).UnmarshalJSON(data); err == nil {
+			t.Fatalf("Accepted malformed ciphertext %s", data)
+		}
+	}
+}
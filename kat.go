@@ -0,0 +1,164 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bn256"
+	"io"
+	"math/big"
+)
+
+// katSalt separates the randomness of known-answer tests from SetupFromSeed.
+var katSalt = []byte("HIBE-KAT")
+
+// KnownAnswerTest is a test vector: the parameters, keys and ciphertext that
+// a seed deterministically produces, hex encoded in the formats of Marshal.
+//
+// All randomness is read, in order, from the DRBG of SetupFromSeed
+// (HMAC-SHA256 blocks keyed by HKDF-Extract(salt "HIBE-KAT", seed)) by
+// Setup, KeyGenFromMaster, the choice of message and Encrypt. Each random
+// scalar is drawn as crypto/rand.Int(random, bn256.Order) does: read
+// ceil(bits/8) bytes, clear the excess top bits, and retry if the result is
+// not less than the order. Each random point is the generator multiplied by
+// such a scalar. The message is gtBase (the pairing of the two generators)
+// raised to a random scalar.
+type KnownAnswerTest struct {
+	Seed       string   `json:"seed"`
+	Depth      int      `json:"depth"`
+	Anonymous  bool     `json:"anonymous,omitempty"`
+	ID         []string `json:"id"`
+	Params     string   `json:"params"`
+	MasterKey  string   `json:"master_key"`
+	PrivateKey string   `json:"private_key"`
+	Message    string   `json:"message"`
+	Ciphertext string   `json:"ciphertext"`
+}
+
+// katResults computes the parameters, keys and ciphertext for the inputs of
+// a test vector.
+func katResults(seed []byte, depth int, anonymous bool, id []*big.Int) (*KnownAnswerTest, error) {
+	random := newSeededReader(seed, katSalt)
+	var opts []SetupOption
+	if anonymous {
+		opts = append(opts, WithAnonymity())
+	}
+	params, master, err := Setup(random, depth, opts...)
+	if err != nil {
+		return nil, err
+	}
+	key, err := KeyGenFromMaster(random, params, master, id)
+	if err != nil {
+		return nil, err
+	}
+	m, err := rand.Int(random, bn256.Order)
+	if err != nil {
+		return nil, err
+	}
+	message := new(bn256.GT).ScalarMult(gtBase, m)
+	ciphertext, err := Encrypt(random, params, id, message)
+	if err != nil {
+		return nil, err
+	}
+
+	kat := &KnownAnswerTest{
+		Seed:       hex.EncodeToString(seed),
+		Depth:      depth,
+		Anonymous:  anonymous,
+		ID:         make([]string, len(id)),
+		Params:     hex.EncodeToString(params.Marshal()),
+		MasterKey:  hex.EncodeToString((*bn256.G1)(master).Marshal()),
+		PrivateKey: hex.EncodeToString(key.Marshal()),
+		Message:    hex.EncodeToString(message.Marshal()),
+		Ciphertext: hex.EncodeToString(ciphertext.Marshal()),
+	}
+	for i, component := range id {
+		kat.ID[i] = component.String()
+	}
+	return kat, nil
+}
+
+// GenerateKnownAnswerTest produces the test vector for a seed, depth and
+// identity.
+func GenerateKnownAnswerTest(seed []byte, depth int, anonymous bool, id []*big.Int) (*KnownAnswerTest, error) {
+	if len(seed) < MinimumSeedSize {
+		return nil, errors.New("hibe: seed is too short")
+	}
+	if len(id) > depth {
+		return nil, ErrDepthExceeded
+	}
+	return katResults(seed, depth, anonymous, id)
+}
+
+// Check replays the test vector and reports the first output that differs,
+// and also checks that the private key decrypts the ciphertext to the
+// message.
+func (kat *KnownAnswerTest) Check() error {
+	seed, err := hex.DecodeString(kat.Seed)
+	if err != nil {
+		return err
+	}
+	id := make([]*big.Int, len(kat.ID))
+	for i, component := range kat.ID {
+		var ok bool
+		if id[i], ok = new(big.Int).SetString(component, 10); !ok {
+			return fmt.Errorf("hibe: malformed identity component %q", component)
+		}
+	}
+	if len(id) > kat.Depth {
+		return ErrDepthExceeded
+	}
+
+	replayed, err := katResults(seed, kat.Depth, kat.Anonymous, id)
+	if err != nil {
+		return err
+	}
+	for _, field := range []struct {
+		name           string
+		want, replayed string
+	}{
+		{"params", kat.Params, replayed.Params},
+		{"master_key", kat.MasterKey, replayed.MasterKey},
+		{"private_key", kat.PrivateKey, replayed.PrivateKey},
+		{"message", kat.Message, replayed.Message},
+		{"ciphertext", kat.Ciphertext, replayed.Ciphertext},
+	} {
+		if field.want != field.replayed {
+			return fmt.Errorf("hibe: known-answer test %s mismatch", field.name)
+		}
+	}
+
+	// The vector must also be internally consistent, for implementations that
+	// only replay parts of it
+	encodedKey, _ := hex.DecodeString(kat.PrivateKey)
+	encodedCiphertext, _ := hex.DecodeString(kat.Ciphertext)
+	key, ok := new(PrivateKey).Unmarshal(encodedKey)
+	if !ok {
+		return errors.New("hibe: known-answer test has a malformed private key")
+	}
+	ciphertext, ok := new(Ciphertext).Unmarshal(encodedCiphertext)
+	if !ok {
+		return errors.New("hibe: known-answer test has a malformed ciphertext")
+	}
+	message, err := Decrypt(key, ciphertext)
+	if err != nil || hex.EncodeToString(message.Marshal()) != kat.Message {
+		return errors.New("hibe: known-answer test private key does not decrypt the ciphertext")
+	}
+	return nil
+}
+
+// LoadKnownAnswerTests reads a JSON array of test vectors, such as
+// testdata/kat.json.
+func LoadKnownAnswerTests(r io.Reader) ([]*KnownAnswerTest, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var kats []*KnownAnswerTest
+	if err = decodeJSONStrict(bytes.TrimSpace(data), &kats); err != nil {
+		return nil, err
+	}
+	return kats, nil
+}
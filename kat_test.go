@@ -0,0 +1,70 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"math/big"
+	"os"
+	"testing"
+)
+
+var updateKAT = flag.Bool("update-kat", false, "regenerate testdata/kat.json")
+
+const katPath = "testdata/kat.json"
+
+// katInputs are the inputs of the vectors in testdata/kat.json.
+var katInputs = []struct {
+	seedByte  byte
+	depth     int
+	anonymous bool
+	id        []*big.Int
+}{
+	{0x01, 1, false, []*big.Int{big.NewInt(1)}},
+	{0x02, 3, false, []*big.Int{big.NewInt(1), big.NewInt(2)}},
+	{0x03, 5, false, LINEAR_HIERARCHY},
+	{0x04, 3, true, []*big.Int{big.NewInt(7), big.NewInt(8), big.NewInt(9)}},
+}
+
+func TestKnownAnswerTests(t *testing.T) {
+	if *updateKAT {
+		kats := make([]*KnownAnswerTest, len(katInputs))
+		for i, input := range katInputs {
+			var err error
+			seed := bytes.Repeat([]byte{input.seedByte}, MinimumSeedSize)
+			if kats[i], err = GenerateKnownAnswerTest(seed, input.depth, input.anonymous, input.id); err != nil {
+				t.Fatal(err)
+			}
+		}
+		encoded, err := json.MarshalIndent(kats, "", "\t")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(katPath, append(encoded, '\n'), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file, err := os.Open(katPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	kats, err := LoadKnownAnswerTests(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kats) != len(katInputs) {
+		t.Fatal("Wrong number of known-answer tests")
+	}
+	for _, kat := range kats {
+		if err = kat.Check(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	kats[0].Ciphertext = kats[1].Ciphertext
+	if kats[0].Check() == nil {
+		t.Fatal("Check accepted a wrong answer")
+	}
+}
@@ -0,0 +1,89 @@
+package hibe_sm9
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"golang.org/x/crypto/bn256"
+	"golang.org/x/crypto/hkdf"
+	"hash"
+	"io"
+	"math/big"
+)
+
+// SharedSecretSize is the size in bytes of secrets produced by Encapsulate.
+const SharedSecretSize = 32
+
+// kemInfo separates secrets derived from an encapsulation from other uses of
+// the encapsulated group element.
+var kemInfo = []byte("HIBE-KEM")
+
+// deriveSecret maps the encapsulated element of GT onto a uniformly random
+// byte string with HKDF-SHA256. The encoded encapsulation (without its header,
+// so that secrets do not depend on the encoding version) is part of the info
+// string, so that every secret is bound to the ciphertext that carried it.
+func deriveSecret(element *bn256.GT, encapsulation *Ciphertext) []byte {
+	return deriveSecretWith(sha256.New, kemInfo, SharedSecretSize, element, encapsulation)
+}
+
+// deriveSecretWith is deriveSecret with another hash, info prefix and size.
+func deriveSecretWith(newHash func() hash.Hash, prefix []byte, size int, element *bn256.GT, encapsulation *Ciphertext) []byte {
+	info := append(append([]byte{}, prefix...), encapsulation.marshalBody(nil)...)
+	secret := make([]byte, size)
+	ikm := element.Marshal()
+	defer zeroizeBytes(ikm)
+	kdf := hkdf.New(newHash, ikm, nil, info)
+	if _, err := io.ReadFull(kdf, secret); err != nil {
+		panic(err)
+	}
+	return secret
+}
+
+// Encapsulate generates a random shared secret for id, along with the
+// encapsulation that the holder of a key for id (or an ancestor of id) can
+// use to recover it with Decapsulate. This is the key-encapsulation mechanism
+// on which the byte-oriented modes of this package are built. With
+// WithIntegrityTag, decapsulating with the wrong key fails instead of
+// yielding an unrelated secret.
+func Encapsulate(random io.Reader, params *Params, id []*big.Int, opts ...EncryptOption) (sharedSecret []byte, encapsulation *Ciphertext, err error) {
+	return encapsulate(random, params, id, deriveSecret, opts)
+}
+
+// encapsulate is Encapsulate with the key derivation function kdf.
+func encapsulate(random io.Reader, params *Params, id []*big.Int, kdf func(*bn256.GT, *Ciphertext) []byte, opts []EncryptOption) (sharedSecret []byte, encapsulation *Ciphertext, err error) {
+	random = randomSource(random)
+	z, err := rand.Int(random, bn256.Order)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer zeroizeScalar(z)
+	element := new(bn256.GT).ScalarMult(gtBase, z)
+	defer zeroizeGT(element)
+
+	encapsulation, err = Encrypt(random, params, id, element, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return kdf(element, encapsulation), encapsulation, nil
+}
+
+// Decapsulate recovers the shared secret from an encapsulation produced by
+// Encapsulate. The encapsulation is validated first. Unless the
+// encapsulation has an integrity tag, decapsulating with the key for a
+// different identity does not fail, but yields an unrelated secret; the layer
+// above must then authenticate its data with the secret to detect this.
+func Decapsulate(key *PrivateKey, encapsulation *Ciphertext) ([]byte, error) {
+	return decapsulate(key, encapsulation, deriveSecret)
+}
+
+// decapsulate is Decapsulate with the key derivation function kdf.
+func decapsulate(key *PrivateKey, encapsulation *Ciphertext, kdf func(*bn256.GT, *Ciphertext) []byte) ([]byte, error) {
+	if err := encapsulation.Validate(); err != nil {
+		return nil, err
+	}
+	element, err := Decrypt(key, encapsulation)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroizeGT(element)
+	return kdf(element, encapsulation), nil
+}
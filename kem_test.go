@@ -0,0 +1,62 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+func TestKEM(t *testing.T) {
+	// Set up parameters
+	params, key, err := Setup(rand.Reader, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret, encapsulation, err := Encapsulate(rand.Reader, params, LINEAR_HIERARCHY[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secret) != SharedSecretSize {
+		t.Fatal("Shared secret has the wrong size")
+	}
+
+	// An ancestor's key can derive the descendant's key and decapsulate
+	toplevelkey, err := KeyGenFromMaster(rand.Reader, params, key, LINEAR_HIERARCHY[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondlevelkey, err := KeyGenFromParent(rand.Reader, params, toplevelkey, LINEAR_HIERARCHY[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	recovered, err := Decapsulate(secondlevelkey, encapsulation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(secret, recovered) {
+		t.Fatal("Decapsulated secret differs")
+	}
+
+	// The wrong key yields an unrelated secret
+	recovered, err = Decapsulate(toplevelkey, encapsulation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(secret, recovered) {
+		t.Fatal("Wrong key recovered the secret")
+	}
+
+	// unless the encapsulation is tagged
+	secret, encapsulation, err = Encapsulate(rand.Reader, params, LINEAR_HIERARCHY[:2], WithIntegrityTag())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recovered, err = Decapsulate(secondlevelkey, encapsulation); err != nil || !bytes.Equal(secret, recovered) {
+		t.Fatal("Tagged encapsulation did not decapsulate")
+	}
+	if _, err = Decapsulate(toplevelkey, encapsulation); !errors.Is(err, ErrDecryptFailed) {
+		t.Fatal("Wrong key was not detected")
+	}
+}
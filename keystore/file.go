@@ -0,0 +1,154 @@
+package keystore
+
+import (
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"hibe_sm9"
+	"io"
+	"io/fs"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// keyFile is the name of the file holding the key of an identity, in the
+// directory of its path.
+const keyFile = "key.pem"
+
+var errNotEncrypted = errors.New("keystore: key file is not encrypted")
+
+// FileStore is a Store that keeps each key in an encrypted PEM file (see
+// PrivateKey.MarshalEncryptedPEM), at <dir>/<path>/key.pem for the identity
+// path of the key, so that the keys of a subtree share a directory. Files are
+// replaced atomically, so a crash never leaves a partially written key.
+//
+// Keys are encrypted with scrypt and AES-256-GCM, which makes Put and Get
+// deliberately slow; cache keys that are used often.
+type FileStore struct {
+	dir        string
+	passphrase []byte
+	random     io.Reader
+
+	// Serializes writers, so that concurrent Puts for one identity do not
+	// race on the rename.
+	lock sync.Mutex
+}
+
+// NewFileStore returns a FileStore in dir, which is created if it does not
+// exist, with keys encrypted under passphrase.
+func NewFileStore(dir string, passphrase []byte) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileStore{
+		dir:        dir,
+		passphrase: append([]byte{}, passphrase...),
+		random:     rand.Reader,
+	}, nil
+}
+
+func (store *FileStore) file(id []*big.Int) string {
+	return filepath.Join(store.dir, filepath.FromSlash(Path(id)), keyFile)
+}
+
+// Put implements Store.
+func (store *FileStore) Put(id []*big.Int, key *hibe_sm9.PrivateKey) error {
+	if err := checkID(id); err != nil {
+		return err
+	}
+	encoded, err := key.MarshalEncryptedPEM(store.random, store.passphrase)
+	if err != nil {
+		return err
+	}
+
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	name := store.file(id)
+	if err = os.MkdirAll(filepath.Dir(name), 0700); err != nil {
+		return err
+	}
+	return writeFileAtomic(name, encoded)
+}
+
+// writeFileAtomic replaces the file name with data, by writing a temporary
+// file in the same directory and renaming it over name.
+func writeFileAtomic(name string, data []byte) error {
+	temp, err := os.CreateTemp(filepath.Dir(name), "."+keyFile+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if _, err = temp.Write(data); err != nil {
+		temp.Close()
+		return err
+	}
+	if err = temp.Sync(); err != nil {
+		temp.Close()
+		return err
+	}
+	if err = temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), name)
+}
+
+// Get implements Store. It fails if the file is not encrypted, so that a key
+// dropped into the store in the clear is noticed.
+func (store *FileStore) Get(id []*big.Int) (*hibe_sm9.PrivateKey, error) {
+	if err := checkID(id); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(store.file(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if block, _ := pem.Decode(data); block == nil || block.Type != hibe_sm9.PEMTypeEncryptedPrivateKey {
+		return nil, errNotEncrypted
+	}
+	return new(hibe_sm9.PrivateKey).ParsePEM(data, store.passphrase)
+}
+
+// Delete implements Store. The directories of the identity are left in
+// place, as they may hold the keys of descendants.
+func (store *FileStore) Delete(id []*big.Int) error {
+	if err := checkID(id); err != nil {
+		return err
+	}
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	err := os.Remove(store.file(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// List implements Store.
+func (store *FileStore) List() ([][]*big.Int, error) {
+	var ids [][]*big.Int
+	err := filepath.WalkDir(store.dir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || entry.Name() != keyFile {
+			return err
+		}
+		relative, err := filepath.Rel(store.dir, filepath.Dir(name))
+		if err != nil {
+			return err
+		}
+		id, err := ParsePath(filepath.ToSlash(relative))
+		if err != nil {
+			// Not a key file of this store
+			return nil
+		}
+		ids = append(ids, id)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortIDs(ids)
+	return ids, nil
+}
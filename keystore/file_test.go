@@ -0,0 +1,75 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/rand"
+	"hibe_sm9"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir, []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, store)
+
+	// Only temporary files that were renamed away are left
+	matches, err := filepath.Glob(filepath.Join(dir, "*", ".key.pem.*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 0 {
+		t.Fatal("Temporary files were left behind")
+	}
+}
+
+func TestFileStoreEncryption(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir, []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	params, master, err := hibe_sm9.Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := hibe_sm9.KeyGenFromMaster(rand.Reader, params, master, id(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Put(id(1), key); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "1", "key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, key.A0.Marshal()) || !bytes.Contains(data, []byte(hibe_sm9.PEMTypeEncryptedPrivateKey)) {
+		t.Fatal("Key was not encrypted")
+	}
+
+	wrong, err := NewFileStore(dir, []byte("wrong"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = wrong.Get(id(1)); err == nil {
+		t.Fatal("Key was decrypted with the wrong passphrase")
+	}
+
+	// Keys in the clear are refused
+	plain, err := key.MarshalPEM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, "1", "key.pem"), plain, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.Get(id(1)); err != errNotEncrypted {
+		t.Fatal("Unencrypted key was accepted")
+	}
+}
@@ -0,0 +1,155 @@
+// Package keystore keeps the private keys of an application, looked up by
+// identity. MemoryStore holds them in memory; FileStore keeps each key in its
+// own file, encrypted under a passphrase, so that keys are never written to
+// disk in the clear.
+package keystore
+
+import (
+	"errors"
+	"hibe_sm9"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrNotFound is returned by Get and Delete when there is no key for the
+	// identity.
+	ErrNotFound = errors.New("keystore: no key for the identity")
+
+	// ErrInvalidID is returned for empty identities and identities with
+	// negative components.
+	ErrInvalidID = errors.New("keystore: invalid identity")
+)
+
+// Store stores private keys by identity. Implementations are safe for
+// concurrent use.
+type Store interface {
+	// Put stores key as the key of id, replacing any previous key.
+	Put(id []*big.Int, key *hibe_sm9.PrivateKey) error
+
+	// Get returns the key of id, or ErrNotFound.
+	Get(id []*big.Int) (*hibe_sm9.PrivateKey, error)
+
+	// Delete removes the key of id, or returns ErrNotFound.
+	Delete(id []*big.Int) error
+
+	// List returns the identities with stored keys, parents before their
+	// children.
+	List() ([][]*big.Int, error)
+}
+
+// checkID checks that id can be stored.
+func checkID(id []*big.Int) error {
+	if len(id) == 0 {
+		return ErrInvalidID
+	}
+	for _, component := range id {
+		if component == nil || component.Sign() < 0 {
+			return ErrInvalidID
+		}
+	}
+	return nil
+}
+
+// Path returns the identity path of id, its decimal components separated by
+// slashes, as in "1/2/3".
+func Path(id []*big.Int) string {
+	components := make([]string, len(id))
+	for i, component := range id {
+		components[i] = component.String()
+	}
+	return strings.Join(components, "/")
+}
+
+// ParsePath returns the identity with the given path.
+func ParsePath(path string) ([]*big.Int, error) {
+	components := strings.Split(path, "/")
+	id := make([]*big.Int, len(components))
+	for i, component := range components {
+		var ok bool
+		if id[i], ok = new(big.Int).SetString(component, 10); !ok {
+			return nil, ErrInvalidID
+		}
+	}
+	if err := checkID(id); err != nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+// sortIDs sorts identities so that parents come before their children.
+func sortIDs(ids [][]*big.Int) {
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := ids[i], ids[j]
+		for k := 0; k != len(a) && k != len(b); k++ {
+			if c := a[k].Cmp(b[k]); c != 0 {
+				return c < 0
+			}
+		}
+		return len(a) < len(b)
+	})
+}
+
+// MemoryStore is a Store that holds keys in memory. It stores and returns
+// copies, so callers may zeroize their keys.
+type MemoryStore struct {
+	lock sync.RWMutex
+	keys map[string]*hibe_sm9.PrivateKey
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{keys: make(map[string]*hibe_sm9.PrivateKey)}
+}
+
+// Put implements Store.
+func (store *MemoryStore) Put(id []*big.Int, key *hibe_sm9.PrivateKey) error {
+	if err := checkID(id); err != nil {
+		return err
+	}
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	store.keys[Path(id)] = key.Clone()
+	return nil
+}
+
+// Get implements Store.
+func (store *MemoryStore) Get(id []*big.Int) (*hibe_sm9.PrivateKey, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+	key, ok := store.keys[Path(id)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return key.Clone(), nil
+}
+
+// Delete implements Store.
+func (store *MemoryStore) Delete(id []*big.Int) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	path := Path(id)
+	if _, ok := store.keys[path]; !ok {
+		return ErrNotFound
+	}
+	delete(store.keys, path)
+	return nil
+}
+
+// List implements Store.
+func (store *MemoryStore) List() ([][]*big.Int, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+	ids := make([][]*big.Int, 0, len(store.keys))
+	for path := range store.keys {
+		id, err := ParsePath(path)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	sortIDs(ids)
+	return ids, nil
+}
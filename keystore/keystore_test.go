@@ -0,0 +1,99 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/rand"
+	"hibe_sm9"
+	"math/big"
+	"testing"
+)
+
+func id(components ...int64) []*big.Int {
+	result := make([]*big.Int, len(components))
+	for i, component := range components {
+		result[i] = big.NewInt(component)
+	}
+	return result
+}
+
+// testStore checks the behaviour common to every Store.
+func testStore(t *testing.T, store Store) {
+	params, master, err := hibe_sm9.Setup(rand.Reader, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := [][]*big.Int{id(2), id(1, 2), id(1)}
+	keys := make([]*hibe_sm9.PrivateKey, len(ids))
+	for i := range ids {
+		if keys[i], err = hibe_sm9.KeyGenFromMaster(rand.Reader, params, master, ids[i]); err != nil {
+			t.Fatal(err)
+		}
+		if err = store.Put(ids[i], keys[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := range ids {
+		key, err := store.Get(ids[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(key.Marshal(), keys[i].Marshal()) {
+			t.Fatal("Stored key differs")
+		}
+	}
+	if _, err = store.Get(id(3)); err != ErrNotFound {
+		t.Fatal("Missing key was found")
+	}
+
+	listed, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 3 || Path(listed[0]) != "1" || Path(listed[1]) != "1/2" || Path(listed[2]) != "2" {
+		t.Fatal("Wrong identities listed")
+	}
+
+	// Replacing and deleting
+	if err = store.Put(ids[0], keys[1]); err != nil {
+		t.Fatal(err)
+	}
+	key, err := store.Get(ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key.Marshal(), keys[1].Marshal()) {
+		t.Fatal("Key was not replaced")
+	}
+	if err = store.Delete(id(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Delete(id(1)); err != ErrNotFound {
+		t.Fatal("Deleted key was deleted again")
+	}
+	if _, err = store.Get(id(1, 2)); err != nil {
+		t.Fatal("Deleting a parent removed its child")
+	}
+	if err = store.Put(nil, keys[0]); err != ErrInvalidID {
+		t.Fatal("Empty identity was accepted")
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestPath(t *testing.T) {
+	parsed, err := ParsePath("1/22/333")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Path(parsed) != "1/22/333" {
+		t.Fatal("Path does not round-trip")
+	}
+	for _, path := range []string{"", "1//2", "1/-2", "1/x", "../1"} {
+		if _, err = ParsePath(path); err == nil {
+			t.Fatal("Invalid path was accepted: " + path)
+		}
+	}
+}
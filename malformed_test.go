@@ -0,0 +1,303 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"golang.org/x/crypto/bn256"
+	"math/big"
+	"os"
+	"testing"
+)
+
+var updateMalformed = flag.Bool("update-malformed", false, "regenerate testdata/malformed.json")
+
+const malformedPath = "testdata/malformed.json"
+
+// malformedCase is an entry of testdata/malformed.json: an encoding of Kind
+// ("params", "private_key" or "ciphertext") in Encoding ("binary", which is
+// hex encoded, "json" or "pem") that must be rejected with the error named
+// Error in malformedErrors.
+type malformedCase struct {
+	Name     string `json:"name"`
+	Kind     string `json:"kind"`
+	Encoding string `json:"encoding"`
+	Data     string `json:"data"`
+	Error    string `json:"error"`
+}
+
+// errRejected stands for the rejection of a binary encoding by Unmarshal,
+// which does not say why.
+var errRejected = errors.New("rejected by Unmarshal")
+
+var malformedErrors = map[string]error{
+	"ErrCiphertextRelation": errCiphertextRelation,
+	"ErrEncodingKind":       errEncodingKind,
+	"ErrEncodingVersion":    errEncodingVersion,
+	"ErrIdentityElement":    errIdentityElement,
+	"ErrInvalidID":          ErrInvalidID,
+	"ErrJSONFingerprint":    errJSONFingerprint,
+	"ErrJSONTag":            errJSONTag,
+	"ErrJSONVersion":        errJSONVersion,
+	"ErrMalformedElement":   errMalformedElement,
+	"ErrModeMismatch":       errModeMismatch,
+	"ErrNotInSubgroup":      errNotInSubgroup,
+	"ErrPEMMalformed":       errPEMMalformed,
+	"ErrPEMType":            errPEMType,
+	"ErrRejected":           errRejected,
+}
+
+// malformedSeed seeds the hierarchy that the corpus is made for, and
+// malformedID is the identity of its keys and ciphertexts.
+var (
+	malformedSeed = bytes.Repeat([]byte{0x05}, MinimumSeedSize)
+	malformedID   = LINEAR_HIERARCHY[:2]
+)
+
+func TestMalformedCorpus(t *testing.T) {
+	params, master, err := SetupFromSeed(malformedSeed, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := KeyGenFromMaster(rand.Reader, params, master, malformedID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *updateMalformed {
+		encoded, err := json.MarshalIndent(generateMalformed(t, params, master), "", "\t")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(malformedPath, append(encoded, '\n'), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := os.ReadFile(malformedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cases []malformedCase
+	if err = json.Unmarshal(data, &cases); err != nil {
+		t.Fatal(err)
+	}
+	if len(cases) == 0 {
+		t.Fatal("Empty corpus")
+	}
+	for _, c := range cases {
+		want, ok := malformedErrors[c.Error]
+		if !ok {
+			t.Fatalf("%s: unknown error %s", c.Name, c.Error)
+		}
+		err := decodeMalformed(params, key, c)
+		if err == nil {
+			t.Fatalf("%s: accepted", c.Name)
+		}
+		if !errors.Is(err, want) {
+			t.Fatalf("%s: rejected with %v instead of %v", c.Name, err, want)
+		}
+	}
+}
+
+// decodeMalformed decodes c and checks it against the hierarchy of the
+// corpus, returning the first error.
+func decodeMalformed(params *Params, key *PrivateKey, c malformedCase) error {
+	data := []byte(c.Data)
+	if c.Encoding == "binary" {
+		var err error
+		if data, err = hex.DecodeString(c.Data); err != nil {
+			return err
+		}
+	}
+
+	switch c.Kind + "/" + c.Encoding {
+	case "params/binary":
+		if err := CheckEncoding(data, KindParams); err != nil {
+			return err
+		}
+		if _, ok := new(Params).Unmarshal(data); !ok {
+			return errRejected
+		}
+	case "params/json":
+		return new(Params).UnmarshalJSON(data)
+	case "params/pem":
+		_, err := new(Params).ParsePEM(data)
+		return err
+	case "private_key/binary":
+		if err := CheckEncoding(data, KindPrivateKey); err != nil {
+			return err
+		}
+		if _, ok := new(PrivateKey).Unmarshal(data); !ok {
+			return errRejected
+		}
+	case "private_key/json":
+		decoded := new(PrivateKey)
+		if err := decoded.UnmarshalJSON(data); err != nil {
+			return err
+		}
+		return VerifyKey(params, malformedID, decoded)
+	case "private_key/pem":
+		decoded, err := new(PrivateKey).ParsePEM(data, nil)
+		if err != nil {
+			return err
+		}
+		return VerifyKey(params, malformedID, decoded)
+	case "ciphertext/binary":
+		if err := CheckEncoding(data, KindCiphertext); err != nil {
+			return err
+		}
+		if _, ok := new(Ciphertext).Unmarshal(data); !ok {
+			return errRejected
+		}
+	case "ciphertext/json":
+		decoded := new(Ciphertext)
+		if err := decoded.UnmarshalJSON(data); err != nil {
+			return err
+		}
+		_, err := DecryptWithParams(params, key, malformedID, decoded)
+		return err
+	default:
+		return errors.New("unknown kind or encoding")
+	}
+	return nil
+}
+
+// pointOutsideSubgroup returns the encoding of a point on the curve of G2
+// that is not in the subgroup of prime order.
+func pointOutsideSubgroup(t *testing.T) []byte {
+	for i := int64(1); ; i++ {
+		x := &fp2{big.NewInt(i), big.NewInt(1)}
+		y := x.mul(x).mul(x).add(twistB).sqrt()
+		if y == nil {
+			continue
+		}
+		encoded := make([]byte, 128)
+		x.fillBytes(encoded[:64])
+		y.fillBytes(encoded[64:])
+		point, ok := new(bn256.G2).Unmarshal(encoded)
+		if !ok {
+			t.Fatal("Point is not on the curve")
+		}
+		if checkG2(point) == errNotInSubgroup {
+			return encoded
+		}
+	}
+}
+
+// generateMalformed produces the corpus from valid objects of the hierarchy.
+func generateMalformed(t *testing.T, params *Params, master MasterKey) []malformedCase {
+	key, err := KeyGenFromMaster(rand.Reader, params, master, malformedID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := Encrypt(rand.Reader, params, malformedID, NewMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := Encrypt(rand.Reader, params, []*big.Int{LINEAR_HIERARCHY[0], LINEAR_HIERARCHY[2]}, NewMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	outside := pointOutsideSubgroup(t)
+	identityG1 := make([]byte, 64)
+
+	var cases []malformedCase
+	add := func(name string, kind string, encoding string, data []byte, err string) {
+		encoded := string(data)
+		if encoding == "binary" {
+			encoded = hex.EncodeToString(data)
+		}
+		cases = append(cases, malformedCase{name, kind, encoding, encoded, err})
+	}
+	keyJSON := func(mutate func(*jsonPrivateKey)) []byte {
+		encoded := jsonPrivateKey{
+			Version: jsonVersion,
+			A0:      key.A0.Marshal(),
+			A1:      key.A1.Marshal(),
+			B:       [][]byte{key.B[0].Marshal()},
+			Params:  key.ParamsFingerprint,
+		}
+		mutate(&encoded)
+		data, err := json.Marshal(encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	ciphertextJSON := func(mutate func(*jsonCiphertext)) []byte {
+		encoded := jsonCiphertext{
+			Version: jsonVersion,
+			A:       ciphertext.A.Marshal(),
+			B:       ciphertext.B.Marshal(),
+			C:       ciphertext.C.Marshal(),
+			Tag:     ciphertext.Tag,
+			Params:  ciphertext.ParamsFingerprint,
+		}
+		mutate(&encoded)
+		data, err := json.Marshal(encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	flip := func(data []byte, i int) []byte {
+		data = append([]byte{}, data...)
+		data[i] ^= 1
+		return data
+	}
+
+	add("key with truncated a0", "private_key", "json", keyJSON(func(k *jsonPrivateKey) { k.A0 = k.A0[:63] }), "ErrMalformedElement")
+	add("key with a0 off the curve", "private_key", "json", keyJSON(func(k *jsonPrivateKey) { k.A0 = flip(k.A0, 63) }), "ErrMalformedElement")
+	add("key with a0 the identity", "private_key", "json", keyJSON(func(k *jsonPrivateKey) { k.A0 = identityG1 }), "ErrIdentityElement")
+	add("key with b1 the identity", "private_key", "json", keyJSON(func(k *jsonPrivateKey) { k.B[0] = identityG1 }), "ErrIdentityElement")
+	add("key with a1 outside the subgroup", "private_key", "json", keyJSON(func(k *jsonPrivateKey) { k.A1 = outside }), "ErrNotInSubgroup")
+	add("key with a0 and a1 swapped", "private_key", "json", keyJSON(func(k *jsonPrivateKey) { k.A0, k.A1 = k.A1, k.A0 }), "ErrMalformedElement")
+	add("key with a0 and b1 swapped", "private_key", "json", keyJSON(func(k *jsonPrivateKey) { k.A0, k.B[0] = k.B[0], k.A0 }), "ErrInvalidID")
+	add("key with both a1 and a1_hat", "private_key", "json", keyJSON(func(k *jsonPrivateKey) { k.A1Hat = k.A0 }), "ErrModeMismatch")
+	add("key with an unknown version", "private_key", "json", keyJSON(func(k *jsonPrivateKey) { k.Version = jsonVersion + 1 }), "ErrJSONVersion")
+	add("key with a short fingerprint", "private_key", "json", keyJSON(func(k *jsonPrivateKey) { k.Params = k.Params[1:] }), "ErrJSONFingerprint")
+
+	add("ciphertext with truncated a", "ciphertext", "json", ciphertextJSON(func(c *jsonCiphertext) { c.A = c.A[:len(c.A)-1] }), "ErrMalformedElement")
+	add("ciphertext with c the identity", "ciphertext", "json", ciphertextJSON(func(c *jsonCiphertext) { c.C = identityG1 }), "ErrIdentityElement")
+	add("ciphertext with b outside the subgroup", "ciphertext", "json", ciphertextJSON(func(c *jsonCiphertext) { c.B = outside }), "ErrNotInSubgroup")
+	add("ciphertext with b and c swapped", "ciphertext", "json", ciphertextJSON(func(c *jsonCiphertext) { c.B, c.C = c.C, c.B }), "ErrMalformedElement")
+	add("ciphertext with c for another identity", "ciphertext", "json", ciphertextJSON(func(c *jsonCiphertext) { c.C = other.C.Marshal() }), "ErrCiphertextRelation")
+	add("ciphertext with an unreduced coordinate in a", "ciphertext", "json", ciphertextJSON(func(c *jsonCiphertext) { c.A = unreduce(t, c.A) }), "ErrMalformedElement")
+	add("ciphertext with a short tag", "ciphertext", "json", ciphertextJSON(func(c *jsonCiphertext) { c.Tag = make([]byte, IntegrityTagSize-1) }), "ErrJSONTag")
+
+	add("truncated key", "private_key", "binary", key.Marshal()[:headerSize+1<<geShift], "ErrRejected")
+	add("key with trailing data", "private_key", "binary", append(key.Marshal(), 0), "ErrRejected")
+	add("key with a1 off the curve", "private_key", "binary", flip(key.Marshal(), headerSize+(2<<geShift)-1), "ErrRejected")
+	add("ciphertext encoded as parameters", "ciphertext", "binary", params.Marshal(), "ErrEncodingKind")
+	add("ciphertext with an unknown version", "ciphertext", "binary", flip(ciphertext.Marshal(), 4), "ErrEncodingVersion")
+	add("ciphertext with an unreduced coordinate in a", "ciphertext", "binary", append(ciphertext.Marshal()[:headerSize], unreduce(t, ciphertext.Marshal()[headerSize:])...), "ErrRejected")
+	add("parameters with h1 off the curve", "params", "binary", flip(params.Marshal(), headerSize+(7<<geShift)-1), "ErrRejected")
+	add("parameters with a truncated slot", "params", "binary", params.Marshal()[:headerSize+(6<<geShift)+1], "ErrRejected")
+
+	paramsPEM, err := params.MarshalPEM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM, err := key.MarshalPEM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	add("key as parameters", "params", "pem", keyPEM, "ErrPEMType")
+	add("parameters without a PEM block", "params", "pem", paramsPEM[:20], "ErrPEMMalformed")
+	add("parameters with trailing DER", "params", "pem", appendDER(t, paramsPEM), "ErrPEMMalformed")
+	return cases
+}
+
+// appendDER appends a zero byte to the contents of a PEM block.
+func appendDER(t *testing.T, data []byte) []byte {
+	der, err := decodePEM(data, PEMTypeParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: PEMTypeParams, Bytes: append(der, 0)})
+}
@@ -0,0 +1,112 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"golang.org/x/crypto/bn256"
+	"io"
+	"math/big"
+	"sync"
+)
+
+var errMasterKeyOpAnonymous = wrapError(ErrCurveMismatch, "hibe: anonymous hierarchies need the master key itself")
+
+// MasterKeyOp performs the group operations that involve the master key, so
+// that the master key can live in an HSM and KeyGenFromMasterOp never sees
+// it. The master key is an element of G1.
+type MasterKeyOp interface {
+	// AddMaster returns master + p.
+	AddMaster(p *bn256.G1) (*bn256.G1, error)
+	// MultMaster returns k * master.
+	MultMaster(k *big.Int) (*bn256.G1, error)
+}
+
+// SoftwareMasterKey is a MasterKeyOp for a master key held in memory.
+type SoftwareMasterKey struct {
+	Key MasterKey
+}
+
+func (master SoftwareMasterKey) AddMaster(p *bn256.G1) (*bn256.G1, error) {
+	return new(bn256.G1).Add(master.Key, p), nil
+}
+
+func (master SoftwareMasterKey) MultMaster(k *big.Int) (*bn256.G1, error) {
+	return secretMultG1(master.Key, k)
+}
+
+// VerifyMasterKeyOp checks that op holds the master key for params, without
+// learning anything about it: for a random k, k * master is paired with g and
+// compared to e(g2, g1)^k.
+func VerifyMasterKeyOp(random io.Reader, params *Params, op MasterKeyOp) error {
+	random = randomSource(random)
+	k, err := rand.Int(random, bn256.Order)
+	if err != nil {
+		return err
+	}
+	multiple, err := op.MultMaster(k)
+	if err != nil {
+		return err
+	}
+	expected := new(bn256.GT).ScalarMult(pair(params.G2, params.G1), k)
+	if !bytes.Equal(pair(multiple, params.G).Marshal(), expected.Marshal()) {
+		return errMasterMismatch
+	}
+	return nil
+}
+
+// PKCS11Session is the part of a PKCS#11 session that PKCS11MasterKey uses:
+// C_EncryptInit and C_Encrypt. It is an interface so that this package does
+// not depend on a cgo PKCS#11 binding; with github.com/miekg/pkcs11, it is a
+// few lines wrapping (*pkcs11.Ctx).EncryptInit and Encrypt for a session
+// handle.
+type PKCS11Session interface {
+	EncryptInit(mechanism uint, key uint) error
+	Encrypt(data []byte) ([]byte, error)
+}
+
+// PKCS11MasterKey is a MasterKeyOp for a master key stored as an object in an
+// HSM. No standard PKCS#11 mechanism covers the groups of bn256, so the HSM
+// must provide the operations as vendor-defined mechanisms, applied to the
+// key object with C_Encrypt:
+//
+//   - AddMechanism maps a point p of G1, encoded by Marshal, to master + p,
+//     encoded the same way.
+//   - MultMechanism maps a scalar k, encoded as 32 big-endian bytes, to
+//     k * master, encoded by Marshal.
+//
+// Calls are serialized, since PKCS#11 sessions must not be used
+// concurrently.
+type PKCS11MasterKey struct {
+	Session       PKCS11Session
+	Key           uint
+	AddMechanism  uint
+	MultMechanism uint
+
+	lock sync.Mutex
+}
+
+func (master *PKCS11MasterKey) operate(mechanism uint, input []byte) (*bn256.G1, error) {
+	master.lock.Lock()
+	defer master.lock.Unlock()
+	if err := master.Session.EncryptInit(mechanism, master.Key); err != nil {
+		return nil, err
+	}
+	output, err := master.Session.Encrypt(input)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroizeBytes(output)
+	return unmarshalG1(output)
+}
+
+func (master *PKCS11MasterKey) AddMaster(p *bn256.G1) (*bn256.G1, error) {
+	input := p.Marshal()
+	defer zeroizeBytes(input)
+	return master.operate(master.AddMechanism, input)
+}
+
+func (master *PKCS11MasterKey) MultMaster(k *big.Int) (*bn256.G1, error) {
+	input := new(big.Int).Mod(k, bn256.Order).FillBytes(make([]byte, 32))
+	defer zeroizeBytes(input)
+	return master.operate(master.MultMechanism, input)
+}
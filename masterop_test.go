@@ -0,0 +1,92 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"golang.org/x/crypto/bn256"
+	"math/big"
+	"testing"
+)
+
+const (
+	testAddMechanism  = 0x80000001
+	testMultMechanism = 0x80000002
+)
+
+// softHSM implements the vendor mechanisms expected by PKCS11MasterKey in
+// software.
+type softHSM struct {
+	master    MasterKey
+	mechanism uint
+}
+
+func (hsm *softHSM) EncryptInit(mechanism uint, key uint) error {
+	if key != 7 {
+		return errors.New("no such object")
+	}
+	hsm.mechanism = mechanism
+	return nil
+}
+
+func (hsm *softHSM) Encrypt(data []byte) ([]byte, error) {
+	switch hsm.mechanism {
+	case testAddMechanism:
+		p, err := unmarshalG1(data)
+		if err != nil {
+			return nil, err
+		}
+		return p.Add(p, hsm.master).Marshal(), nil
+	case testMultMechanism:
+		return new(bn256.G1).ScalarMult(hsm.master, new(big.Int).SetBytes(data)).Marshal(), nil
+	}
+	return nil, errors.New("mechanism invalid")
+}
+
+func TestMasterKeyOp(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := &PKCS11MasterKey{
+		Session:       &softHSM{master: master},
+		Key:           7,
+		AddMechanism:  testAddMechanism,
+		MultMechanism: testMultMechanism,
+	}
+	if err = VerifyMasterKeyOp(rand.Reader, params, op); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := KeyGenFromMasterOp(rand.Reader, params, op, LINEAR_HIERARCHY[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := NewMessage()
+	ciphertext, err := Encrypt(rand.Reader, params, LINEAR_HIERARCHY[:2], message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(message.Marshal(), mustDecrypt(t, key, ciphertext).Marshal()) {
+		t.Fatal("Key generated through the HSM does not decrypt")
+	}
+
+	otherParams, otherMaster, err := Setup(rand.Reader, 3, WithAnonymity())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(VerifyMasterKeyOp(rand.Reader, otherParams, op), ErrCurveMismatch) {
+		t.Fatal("Verified an HSM holding a different master key")
+	}
+	if _, err = KeyGenFromMasterOp(rand.Reader, otherParams, op, LINEAR_HIERARCHY[:1]); err != errMasterKeyOpAnonymous {
+		t.Fatal("Generated an anonymous key without the master key")
+	}
+	if _, err = KeyGenFromMasterOp(rand.Reader, otherParams, SoftwareMasterKey{otherMaster}, LINEAR_HIERARCHY[:1]); err != nil {
+		t.Fatal(err)
+	}
+
+	op.Key = 8
+	if _, err = KeyGenFromMasterOp(rand.Reader, params, op, LINEAR_HIERARCHY[:1]); err == nil {
+		t.Fatal("HSM error was not returned")
+	}
+}
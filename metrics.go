@@ -0,0 +1,58 @@
+package hibe_sm9
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Operation names an operation reported to Metrics.
+type Operation string
+
+// Operations reported to Metrics. Operations built on these, such as
+// Encapsulate or EncryptBytes, are reported as the encryptions and
+// decryptions they perform.
+const (
+	OpSetup   Operation = "setup"
+	OpKeyGen  Operation = "keygen"
+	OpEncrypt Operation = "encrypt"
+	OpDecrypt Operation = "decrypt"
+)
+
+// Metrics receives the outcome and duration of every Setup, KeyGenFromMaster,
+// KeyGenFromParent, Encrypt and Decrypt, once installed with SetMetrics, so
+// that services built on this package can export throughput and latency to
+// their monitoring system (see package promexport). The calls are
+// synchronous and may come from many goroutines at once, so implementations
+// must be fast and safe for concurrent use.
+type Metrics interface {
+	Observe(op Operation, duration time.Duration, err error)
+}
+
+// metricsHolder lets a nil Metrics be stored in an atomic.Value.
+type metricsHolder struct {
+	metrics Metrics
+}
+
+var installedMetrics atomic.Value
+
+// SetMetrics installs metrics for the whole package, replacing any previous
+// one. A nil metrics turns reporting off.
+func SetMetrics(metrics Metrics) {
+	installedMetrics.Store(metricsHolder{metrics})
+}
+
+// observe reports an operation that started at start to the installed
+// Metrics, if any. It is deferred with a pointer to the named error result of
+// the operation.
+func observe(op Operation, start time.Time, err *error) {
+	holder, _ := installedMetrics.Load().(metricsHolder)
+	if holder.metrics != nil {
+		holder.metrics.Observe(op, time.Since(start), *err)
+	}
+}
+
+// Pairings returns the number of pairings computed by the package since the
+// program started.
+func Pairings() uint64 {
+	return atomic.LoadUint64(&pairings)
+}
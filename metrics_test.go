@@ -0,0 +1,71 @@
+package hibe_sm9
+
+import (
+	"crypto/rand"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordedMetrics struct {
+	lock   sync.Mutex
+	counts map[Operation]int
+	failed map[Operation]int
+}
+
+func (metrics *recordedMetrics) Observe(op Operation, duration time.Duration, err error) {
+	metrics.lock.Lock()
+	defer metrics.lock.Unlock()
+	if err != nil {
+		metrics.failed[op]++
+	} else {
+		metrics.counts[op]++
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	metrics := &recordedMetrics{counts: make(map[Operation]int), failed: make(map[Operation]int)}
+	SetMetrics(metrics)
+	defer SetMetrics(nil)
+
+	before := Pairings()
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := KeyGenFromParent(rand.Reader, params, key, LINEAR_HIERARCHY[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := Encrypt(rand.Reader, params, LINEAR_HIERARCHY[:2], NewMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustDecrypt(t, child, ciphertext)
+	if _, err = Encrypt(rand.Reader, params, LINEAR_HIERARCHY[:0], NewMessage()); !errors.Is(err, ErrInvalidID) {
+		t.Fatal("Empty identity was accepted")
+	}
+
+	if metrics.counts[OpSetup] != 1 || metrics.counts[OpKeyGen] != 2 || metrics.counts[OpEncrypt] != 1 || metrics.counts[OpDecrypt] != 1 {
+		t.Fatal("Operations were not reported")
+	}
+	if metrics.failed[OpEncrypt] != 1 {
+		t.Fatal("Failed operation was not reported")
+	}
+	if Pairings() <= before {
+		t.Fatal("Pairings were not counted")
+	}
+
+	SetMetrics(nil)
+	if _, err = Encrypt(rand.Reader, params, LINEAR_HIERARCHY[:2], NewMessage()); err != nil {
+		t.Fatal(err)
+	}
+	if metrics.counts[OpEncrypt] != 1 {
+		t.Fatal("Operation was reported after the metrics were removed")
+	}
+}
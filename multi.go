@@ -0,0 +1,71 @@
+package hibe_sm9
+
+import (
+	"context"
+	"golang.org/x/crypto/bn256"
+	"io"
+	"math/big"
+)
+
+// MultiCiphertext represents a message encrypted for several identities at
+// once. Each recipient has an ordinary ciphertext with its own randomness.
+type MultiCiphertext struct {
+	Ciphertexts []*Ciphertext
+}
+
+// EncryptMulti encrypts the provided message for each of the provided IDs.
+// The parameters are checked and the pairing e(g2, g1) computed only once, but
+// otherwise this costs as much as calling Encrypt once per recipient.
+//
+// Every recipient gets fresh randomness s. Sharing s across recipients would
+// not be safe: the C components of two identities with a common prefix differ
+// by a power of h_k^s, from which anyone could compute C for any other
+// identity under that prefix, and decrypt with its key. In anonymous
+// hierarchies, it would also let anyone test a guessed pair of recipients
+// with a pairing equation.
+func EncryptMulti(random io.Reader, params *Params, ids [][]*big.Int, message *bn256.GT) (*MultiCiphertext, error) {
+	return EncryptMultiContext(context.Background(), random, params, ids, message)
+}
+
+// EncryptMultiContext is like EncryptMulti, but gives up with ctx.Err() once
+// ctx is done. The context is checked before each recipient.
+func EncryptMultiContext(ctx context.Context, random io.Reader, params *Params, ids [][]*big.Int, message *bn256.GT) (*MultiCiphertext, error) {
+	for _, id := range ids {
+		if err := checkID(params, id); err != nil {
+			return nil, err
+		}
+	}
+	random = randomSource(random)
+	params.Precache()
+
+	multi := &MultiCiphertext{Ciphertexts: make([]*Ciphertext, len(ids))}
+	for i, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		ciphertext, err := encrypt(random, params, message, nil, func(ciphertext *Ciphertext, s *big.Int) (err error) {
+			if params.Anonymous() {
+				ciphertext.CHat, err = idProductHatPower(params, id, s)
+			} else {
+				ciphertext.C, err = idProductPower(params, id, s)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		multi.Ciphertexts[i] = ciphertext
+	}
+	return multi, nil
+}
+
+// Recipients returns the number of recipients of the ciphertext.
+func (ciphertext *MultiCiphertext) Recipients() int {
+	return len(ciphertext.Ciphertexts)
+}
+
+// Ciphertext extracts the ordinary ciphertext for the i-th recipient (in the
+// order the IDs were passed to EncryptMulti), which can be passed to Decrypt.
+func (ciphertext *MultiCiphertext) Ciphertext(i int) *Ciphertext {
+	return ciphertext.Ciphertexts[i]
+}
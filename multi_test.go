@@ -0,0 +1,111 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"golang.org/x/crypto/bn256"
+	"math/big"
+	"testing"
+)
+
+func TestEncryptMulti(t *testing.T) {
+	// Set up parameters
+	params, key, err := Setup(rand.Reader, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := [][]*big.Int{
+		LINEAR_HIERARCHY[:1],
+		LINEAR_HIERARCHY,
+		{big.NewInt(4), big.NewInt(5)},
+	}
+
+	// Come up with a message to encrypt
+	message := NewMessage()
+
+	ciphertext, err := EncryptMulti(rand.Reader, params, ids, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ciphertext.Recipients() != len(ids) {
+		t.Fatal("Wrong number of recipients")
+	}
+
+	for i, id := range ids {
+		privkey, err := KeyGenFromMaster(rand.Reader, params, key, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decrypted := mustDecrypt(t, privkey, ciphertext.Ciphertext(i))
+		if !bytes.Equal(message.Marshal(), decrypted.Marshal()) {
+			t.Fatal("Original and decrypted messages differ")
+		}
+	}
+}
+
+// siblingC computes C for the identity whose last component is v from the C
+// components c0 and c1 for siblings with last components v0 and v1, assuming
+// they share the randomness s: c0 - c1 is h_k^(s(v0 - v1)).
+func siblingC(c0 *bn256.G1, c1 *bn256.G1, v0 *big.Int, v1 *big.Int, v *big.Int) *bn256.G1 {
+	difference := new(bn256.G1).Neg(c1)
+	difference.Add(c0, difference)
+	factor := new(big.Int).Sub(v0, v1)
+	factor.ModInverse(factor.Mod(factor, bn256.Order), bn256.Order)
+	factor.Mul(factor, new(big.Int).Sub(v, v0))
+	factor.Mod(factor, bn256.Order)
+	return new(bn256.G1).Add(c0, new(bn256.G1).ScalarMult(difference, factor))
+}
+
+func TestEncryptMultiSibling(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v0, v1, v := big.NewInt(2), big.NewInt(3), big.NewInt(4)
+	ids := [][]*big.Int{{big.NewInt(1), v0}, {big.NewInt(1), v1}}
+	message := NewMessage()
+	multi, err := EncryptMulti(rand.Reader, params, ids, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A non-recipient sibling must not be able to decrypt
+	sibling, err := KeyGenFromMaster(rand.Reader, params, master, []*big.Int{big.NewInt(1), v})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := multi.Ciphertext(0)
+	forged := &Ciphertext{A: first.A, B: first.B, C: siblingC(first.C, multi.Ciphertext(1).C, v0, v1, v)}
+	if bytes.Equal(message.Marshal(), mustDecrypt(t, sibling, forged).Marshal()) {
+		t.Fatal("Non-recipient sibling decrypted a multi-recipient ciphertext")
+	}
+}
+
+func BenchmarkEncryptMulti(b *testing.B) {
+	b.StopTimer()
+
+	// Set up parameters
+	params, _, err := Setup(rand.Reader, 10)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	ids := make([][]*big.Int, 10)
+	for j := range ids {
+		ids[j] = []*big.Int{big.NewInt(int64(j + 1)), big.NewInt(2)}
+	}
+
+	for i := 0; i < b.N; i++ {
+		message, err := NewRandomMessage(rand.Reader)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+		_, err = EncryptMulti(rand.Reader, params, ids, message)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.StopTimer()
+	}
+}
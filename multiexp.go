@@ -0,0 +1,88 @@
+package hibe_sm9
+
+import (
+	"golang.org/x/crypto/bn256"
+	"math/big"
+)
+
+// multiExpWindow is the number of scalar bits handled per step of
+// multiExpG1 and multiExpG2, which precompute 2^multiExpWindow - 1 multiples of
+// each point.
+const multiExpWindow = 4
+
+// multiExpThreshold is the number of terms from which multiExpG1 and
+// multiExpG2 beat one ScalarMult per term (see BenchmarkIDProduct).
+const multiExpThreshold = 2
+
+// multiExpDigit returns the i-th window of k.
+func multiExpDigit(k *big.Int, i int) int {
+	d := 0
+	for b := multiExpWindow - 1; b >= 0; b-- {
+		d = d<<1 | int(k.Bit(multiExpWindow*i+b))
+	}
+	return d
+}
+
+// multiExpWindows returns the number of windows covering the longest scalar.
+func multiExpWindows(scalars []*big.Int) int {
+	bits := 0
+	for _, k := range scalars {
+		if k.BitLen() > bits {
+			bits = k.BitLen()
+		}
+	}
+	return (bits + multiExpWindow - 1) / multiExpWindow
+}
+
+// multiExpG1 computes base + sum(scalars[i] * points[i]) with Straus'
+// interleaved method: the terms share a single chain of doublings, instead of
+// each ScalarMult doing its own. The running time depends on the scalars, so
+// they must be public, such as identity components.
+func multiExpG1(base *bn256.G1, points []*bn256.G1, scalars []*big.Int) *bn256.G1 {
+	tables := make([][]*bn256.G1, len(points))
+	for i, p := range points {
+		row := make([]*bn256.G1, 1<<multiExpWindow)
+		row[1] = p
+		for j := 2; j < len(row); j++ {
+			row[j] = new(bn256.G1).Add(row[j-1], p)
+		}
+		tables[i] = row
+	}
+
+	shift := big.NewInt(1 << multiExpWindow)
+	sum := new(bn256.G1).ScalarBaseMult(new(big.Int))
+	for w := multiExpWindows(scalars) - 1; w >= 0; w-- {
+		sum.ScalarMult(sum, shift)
+		for i, k := range scalars {
+			if d := multiExpDigit(k, w); d != 0 {
+				sum.Add(sum, tables[i][d])
+			}
+		}
+	}
+	return sum.Add(sum, base)
+}
+
+// multiExpG2 is the mirror of multiExpG1 in G2.
+func multiExpG2(base *bn256.G2, points []*bn256.G2, scalars []*big.Int) *bn256.G2 {
+	tables := make([][]*bn256.G2, len(points))
+	for i, p := range points {
+		row := make([]*bn256.G2, 1<<multiExpWindow)
+		row[1] = p
+		for j := 2; j < len(row); j++ {
+			row[j] = new(bn256.G2).Add(row[j-1], p)
+		}
+		tables[i] = row
+	}
+
+	shift := big.NewInt(1 << multiExpWindow)
+	sum := new(bn256.G2).ScalarBaseMult(new(big.Int))
+	for w := multiExpWindows(scalars) - 1; w >= 0; w-- {
+		sum.ScalarMult(sum, shift)
+		for i, k := range scalars {
+			if d := multiExpDigit(k, w); d != 0 {
+				sum.Add(sum, tables[i][d])
+			}
+		}
+	}
+	return sum.Add(sum, base)
+}
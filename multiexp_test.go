@@ -0,0 +1,64 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"golang.org/x/crypto/bn256"
+	"math/big"
+	"testing"
+)
+
+func TestMultiExp(t *testing.T) {
+	params, _, err := Setup(rand.Reader, 5, WithAnonymity())
+	if err != nil {
+		t.Fatal(err)
+	}
+	scalars := []*big.Int{big.NewInt(1), big.NewInt(0), new(big.Int).Sub(bn256.Order, big.NewInt(1))}
+	for len(scalars) != 5 {
+		k, err := rand.Int(rand.Reader, bn256.Order)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scalars = append(scalars, k)
+	}
+
+	expected := deepClone(params.G3)
+	expectedHat := deepCloneG2(params.G3Hat)
+	for i, k := range scalars {
+		expected.Add(expected, new(bn256.G1).ScalarMult(params.H[i], k))
+		expectedHat.Add(expectedHat, new(bn256.G2).ScalarMult(params.HHat[i], k))
+	}
+	if !bytes.Equal(multiExpG1(params.G3, params.H, scalars).Marshal(), expected.Marshal()) {
+		t.Fatal("Wrong multi-exponentiation in G1")
+	}
+	if !bytes.Equal(multiExpG2(params.G3Hat, params.HHat, scalars).Marshal(), expectedHat.Marshal()) {
+		t.Fatal("Wrong multi-exponentiation in G2")
+	}
+	if !bytes.Equal(multiExpG1(params.G3, nil, nil).Marshal(), params.G3.Marshal()) {
+		t.Fatal("Empty multi-exponentiation is not the base")
+	}
+}
+
+func BenchmarkIDProduct(b *testing.B) {
+	params, _, err := Setup(rand.Reader, 20)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, depth := range []int{1, 2, 5, 10, 20} {
+		id := HashID(make([][]byte, depth))
+		b.Run(fmt.Sprintf("sequential-%d", depth), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				product := deepClone(params.G3)
+				for j := range id {
+					product.Add(product, new(bn256.G1).ScalarMult(params.H[j], id[j]))
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("straus-%d", depth), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				multiExpG1(params.G3, params.H[:depth], id)
+			}
+		})
+	}
+}
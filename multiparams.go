@@ -0,0 +1,106 @@
+package hibe_sm9
+
+import (
+	"errors"
+	"golang.org/x/crypto/bn256"
+	"sync"
+)
+
+var (
+	errMultiParamsUnknown   = wrapError(ErrCurveMismatch, "hibe: no key for the parameters of the ciphertext")
+	errMultiParamsAmbiguous = wrapError(ErrCurveMismatch, "hibe: ciphertext does not name its parameters, and several keys are held")
+	errMultiParamsBinding   = errors.New("hibe: key does not belong to the parameters")
+)
+
+// MultiParams holds the keys of one identity in several hierarchies, such as
+// the old and new hierarchies during a rotation of the master key (see
+// RotateMaster), and decrypts each ciphertext with the key for the parameters
+// whose fingerprint it carries. A recipient can then decrypt everything sent
+// to it while senders switch over, and drop the old key with Remove once the
+// rotation window closes. A ciphertext without a fingerprint, in a headerless
+// encoding from an earlier version, is only accepted while a single key is
+// held.
+//
+// A MultiParams is safe for concurrent use.
+type MultiParams struct {
+	lock sync.RWMutex
+	keys map[string]*PrivateKey
+}
+
+// NewMultiParams returns an empty MultiParams.
+func NewMultiParams() *MultiParams {
+	return &MultiParams{keys: make(map[string]*PrivateKey)}
+}
+
+// Add adds key, the key in the hierarchy with parameters params, replacing
+// any key held for the same parameters.
+func (multi *MultiParams) Add(params *Params, key *PrivateKey) error {
+	fingerprint := params.Fingerprint()
+	if key.ParamsFingerprint != nil && string(key.ParamsFingerprint) != string(fingerprint) {
+		return errMultiParamsBinding
+	}
+	multi.lock.Lock()
+	defer multi.lock.Unlock()
+	multi.keys[string(fingerprint)] = key
+	return nil
+}
+
+// Remove drops the key for params, if one is held.
+func (multi *MultiParams) Remove(params *Params) {
+	multi.lock.Lock()
+	defer multi.lock.Unlock()
+	delete(multi.keys, string(params.Fingerprint()))
+}
+
+// KeyFor returns the key for the parameters with the given fingerprint. A nil
+// fingerprint selects the only key held, if there is exactly one.
+func (multi *MultiParams) KeyFor(fingerprint []byte) (*PrivateKey, error) {
+	multi.lock.RLock()
+	defer multi.lock.RUnlock()
+	if fingerprint == nil {
+		if len(multi.keys) != 1 {
+			return nil, errMultiParamsAmbiguous
+		}
+		for _, key := range multi.keys {
+			return key, nil
+		}
+	}
+	key, ok := multi.keys[string(fingerprint)]
+	if !ok {
+		return nil, errMultiParamsUnknown
+	}
+	return key, nil
+}
+
+// Decrypt is Decrypt with the key for the parameters of ciphertext.
+func (multi *MultiParams) Decrypt(ciphertext *Ciphertext, opts ...DecryptOption) (*bn256.GT, error) {
+	key, err := multi.KeyFor(ciphertext.ParamsFingerprint)
+	if err != nil {
+		return nil, err
+	}
+	return Decrypt(key, ciphertext, opts...)
+}
+
+// Decapsulate is Decapsulate with the key for the parameters of
+// encapsulation.
+func (multi *MultiParams) Decapsulate(encapsulation *Ciphertext) ([]byte, error) {
+	key, err := multi.KeyFor(encapsulation.ParamsFingerprint)
+	if err != nil {
+		return nil, err
+	}
+	return Decapsulate(key, encapsulation)
+}
+
+// DecryptBytes is DecryptBytes with the key for the parameters of the
+// encapsulation in ciphertext.
+func (multi *MultiParams) DecryptBytes(ciphertext []byte, opts ...DecryptOption) ([]byte, error) {
+	dem, encapsulation, sealed, err := parseHybrid(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	key, err := multi.KeyFor(encapsulation.ParamsFingerprint)
+	if err != nil {
+		return nil, err
+	}
+	return openHybrid(key, dem, encapsulation, sealed, decryptOptions(opts).aad)
+}
@@ -0,0 +1,81 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+func TestMultiParams(t *testing.T) {
+	oldParams, oldMaster, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newParams, newMaster, err := RotateMaster(rand.Reader, oldParams, oldMaster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldKey, err := KeyGenFromMaster(rand.Reader, oldParams, oldMaster, LINEAR_HIERARCHY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, err := KeyGenFromMaster(rand.Reader, newParams, newMaster, LINEAR_HIERARCHY)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	multi := NewMultiParams()
+	if err = multi.Add(newParams, oldKey); err == nil {
+		t.Fatal("Key was added for other parameters")
+	}
+	if err = multi.Add(oldParams, oldKey); err != nil {
+		t.Fatal(err)
+	}
+	if err = multi.Add(newParams, newKey); err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext := []byte("during the rotation window")
+	var ciphertexts [][]byte
+	for _, params := range []*Params{oldParams, newParams} {
+		ciphertext, err := EncryptBytes(rand.Reader, params, LINEAR_HIERARCHY, plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decrypted, err := multi.DecryptBytes(ciphertext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Fatal("Original and decrypted plaintexts differ")
+		}
+		ciphertexts = append(ciphertexts, ciphertext)
+	}
+	message := NewMessage()
+	ciphertext, err := Encrypt(rand.Reader, oldParams, LINEAR_HIERARCHY, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := multi.Decrypt(ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted.Marshal(), message.Marshal()) {
+		t.Fatal("Original and decrypted messages differ")
+	}
+
+	// A ciphertext without a fingerprint is ambiguous while both keys are held
+	ciphertext.ParamsFingerprint = nil
+	if _, err = multi.Decrypt(ciphertext); !errors.Is(err, ErrCurveMismatch) {
+		t.Fatal("Ciphertext without a fingerprint was decrypted with two keys held")
+	}
+
+	multi.Remove(oldParams)
+	if _, err = multi.DecryptBytes(ciphertexts[0]); !errors.Is(err, ErrCurveMismatch) {
+		t.Fatal("Ciphertext was decrypted after its key was removed")
+	}
+	if _, err = multi.DecryptBytes(ciphertexts[1]); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,96 @@
+package hibe_sm9
+
+import (
+	"errors"
+	"io"
+	"math/big"
+)
+
+// onionDomain is authenticated with every layer of an onion, so that layers
+// and ordinary EncryptBytes messages cannot be swapped.
+var onionDomain = []byte("HIBE-ONION")
+
+// Markers that start the plaintext of each layer.
+const (
+	onionInner byte = iota // the rest is the next layer
+	onionCore              // the rest is the message
+)
+
+var (
+	errOnionEmpty     = errors.New("hibe: onion needs at least one layer")
+	errOnionMalformed = wrapError(ErrMalformedCiphertext, "hibe: malformed onion layer")
+	errOnionKeys      = errors.New("hibe: number of keys does not match the layers of the onion")
+)
+
+// OnionLayer is one layer of an onion: an identity in a hierarchy.
+type OnionLayer struct {
+	Params *Params
+	ID     []*big.Int
+}
+
+// EncryptOnion encrypts plaintext in nested layers, so that it can only be
+// recovered by peeling every layer in turn with the keys of all of the
+// identities, which may be in independent hierarchies (for example a company
+// and the regulator that escrows its data). layers[0] is the outermost layer,
+// which is peeled first. Each layer is encrypted as with EncryptBytes, and
+// adds the same overhead. To let any one of several identities decrypt
+// instead, use EncryptMulti.
+func EncryptOnion(random io.Reader, layers []OnionLayer, plaintext []byte) ([]byte, error) {
+	if len(layers) == 0 {
+		return nil, errOnionEmpty
+	}
+	random = randomSource(random)
+	onion := append([]byte{onionCore}, plaintext...)
+	for i := len(layers) - 1; i >= 0; i-- {
+		sealed, err := encryptBytes(random, layers[i].Params, layers[i].ID, onion, onionDomain)
+		if err != nil {
+			return nil, err
+		}
+		if i != 0 {
+			sealed = append([]byte{onionInner}, sealed...)
+		}
+		onion = sealed
+	}
+	return onion, nil
+}
+
+// PeelOnion removes the outer layer of an onion from EncryptOnion with the
+// key of its identity. If it was the last layer, it returns the plaintext and
+// done is true; otherwise it returns the rest of the onion, to be peeled with
+// the key of the next layer.
+func PeelOnion(key *PrivateKey, onion []byte) (rest []byte, done bool, err error) {
+	opened, err := decryptBytes(key, onion, onionDomain)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(opened) == 0 {
+		return nil, false, errOnionMalformed
+	}
+	switch opened[0] {
+	case onionInner:
+		return opened[1:], false, nil
+	case onionCore:
+		return opened[1:], true, nil
+	default:
+		return nil, false, errOnionMalformed
+	}
+}
+
+// DecryptOnion peels every layer of an onion, with keys in the same order as
+// the layers passed to EncryptOnion.
+func DecryptOnion(keys []*PrivateKey, onion []byte) ([]byte, error) {
+	for i, key := range keys {
+		rest, done, err := PeelOnion(key, onion)
+		if err != nil {
+			return nil, err
+		}
+		if done != (i == len(keys)-1) {
+			return nil, errOnionKeys
+		}
+		onion = rest
+	}
+	if len(keys) == 0 {
+		return nil, errOnionKeys
+	}
+	return onion, nil
+}
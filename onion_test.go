@@ -0,0 +1,66 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func TestOnion(t *testing.T) {
+	company, companyMaster, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	regulator, regulatorMaster, err := Setup(rand.Reader, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	escrowID := []*big.Int{big.NewInt(42)}
+	companyKey, err := KeyGenFromMaster(rand.Reader, company, companyMaster, LINEAR_HIERARCHY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	regulatorKey, err := KeyGenFromMaster(rand.Reader, regulator, regulatorMaster, escrowID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext := []byte("quarterly figures")
+	layers := []OnionLayer{{Params: company, ID: LINEAR_HIERARCHY}, {Params: regulator, ID: escrowID}}
+	onion, err := EncryptOnion(rand.Reader, layers, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decrypted, err := DecryptOnion([]*PrivateKey{companyKey, regulatorKey}, onion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Fatal("Original and decrypted plaintexts differ")
+	}
+
+	// Peeling one layer at a time
+	rest, done, err := PeelOnion(companyKey, onion)
+	if err != nil || done {
+		t.Fatal("Outer layer did not peel", err)
+	}
+	if _, _, err = PeelOnion(companyKey, rest); err == nil {
+		t.Fatal("Inner layer peeled with the outer key")
+	}
+	if _, err = DecryptOnion([]*PrivateKey{regulatorKey, companyKey}, onion); err == nil {
+		t.Fatal("Layers peeled in the wrong order")
+	}
+	if _, err = DecryptOnion([]*PrivateKey{companyKey}, onion); err != errOnionKeys {
+		t.Fatal("Onion was not fully peeled")
+	}
+
+	// Layers are not ordinary messages
+	if _, err = DecryptBytes(companyKey, onion); err == nil {
+		t.Fatal("Onion layer decrypted as a message")
+	}
+	if _, err = EncryptOnion(rand.Reader, nil, plaintext); err == nil {
+		t.Fatal("Onion without layers was accepted")
+	}
+}
@@ -0,0 +1,101 @@
+package hibe_sm9
+
+import "errors"
+
+var (
+	errNotAnonymous      = errors.New("hibe: WithAnonymous requires the parameters of an anonymous hierarchy")
+	errPrecomputedTarget = wrapError(ErrInvalidID, "hibe: WithPrecomputed encryptor is for other parameters or another identity")
+)
+
+// EncryptOption configures Encrypt, and the modes built on it: Encapsulate,
+// EncryptBytes and the Encryptor. New modes are added as options rather than
+// as new functions, so that each combination does not need its own entry
+// point. Options that do not apply to a mode are ignored by it.
+type EncryptOption func(*encryptConfig)
+
+type encryptConfig struct {
+	tag       bool
+	dem       DEM
+	anonymous bool
+	aad       []byte
+	encryptor *Encryptor
+}
+
+// encryptOptions applies the encryption options.
+func encryptOptions(opts []EncryptOption) *encryptConfig {
+	config := &encryptConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+	return config
+}
+
+// WithAnonymous makes encryption fail unless params belong to an anonymous
+// hierarchy (see WithAnonymity), so that a sender who relies on ciphertexts
+// hiding their recipient does not silently encrypt under parameters that do
+// not. Anonymity is a property of the hierarchy, chosen at Setup; the option
+// only asserts it.
+func WithAnonymous() EncryptOption {
+	return func(config *encryptConfig) {
+		config.anonymous = true
+	}
+}
+
+// WithAAD makes EncryptBytes, Seal and NewEncryptingWriter authenticate
+// additionalData, such as a routing header or a policy identifier, along with
+// the plaintext, without encrypting or storing it. The same data must be
+// given to DecryptBytes, Open or the stream readers with WithDecryptAAD, and
+// decryption fails if it differs. Encrypt and Encapsulate ignore it.
+func WithAAD(additionalData []byte) EncryptOption {
+	additionalData = append([]byte{}, additionalData...)
+	return func(config *encryptConfig) {
+		config.aad = additionalData
+	}
+}
+
+// WithPrecomputed makes Encrypt, Encapsulate and EncryptBytes use the
+// precomputed identity of encryptor, which must have been created for the
+// same parameters and identity. This gives the speed of an Encryptor to the
+// other modes.
+func WithPrecomputed(encryptor *Encryptor) EncryptOption {
+	return func(config *encryptConfig) {
+		config.encryptor = encryptor
+	}
+}
+
+// DecryptOption configures Decrypt, DecryptBatch, DecryptReEncrypted,
+// DecryptBytes, Open and the stream readers.
+type DecryptOption func(*decryptConfig)
+
+type decryptConfig struct {
+	aad        []byte
+	requireTag bool
+}
+
+// decryptOptions applies the decryption options.
+func decryptOptions(opts []DecryptOption) decryptConfig {
+	var config decryptConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return config
+}
+
+// WithDecryptAAD gives the additional data that was given to encryption with
+// WithAAD.
+func WithDecryptAAD(additionalData []byte) DecryptOption {
+	additionalData = append([]byte{}, additionalData...)
+	return func(config *decryptConfig) {
+		config.aad = additionalData
+	}
+}
+
+// WithTagRequired makes Decrypt, DecryptBatch and DecryptReEncrypted reject
+// ciphertexts without an integrity tag (see WithIntegrityTag) with an error
+// wrapping ErrMalformedCiphertext. Without it, a tag is checked when there is
+// one, so whoever can change a ciphertext can also strip its tag.
+func WithTagRequired() DecryptOption {
+	return func(config *decryptConfig) {
+		config.requireTag = true
+	}
+}
@@ -0,0 +1,83 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+func TestWithAAD(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := []byte("payload")
+	ciphertext, err := EncryptBytes(rand.Reader, params, LINEAR_HIERARCHY, plaintext, WithAAD([]byte("header")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := DecryptBytes(key, ciphertext, WithDecryptAAD([]byte("header")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Fatal("Original and decrypted plaintexts differ")
+	}
+	if _, err = DecryptBytes(key, ciphertext); !errors.Is(err, ErrDecryptFailed) {
+		t.Fatal("Decrypted without the additional data")
+	}
+	if _, err = DecryptBytes(key, ciphertext, WithDecryptAAD([]byte("other"))); !errors.Is(err, ErrDecryptFailed) {
+		t.Fatal("Decrypted with other additional data")
+	}
+}
+
+func TestWithAnonymous(t *testing.T) {
+	params, _, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Encrypt(rand.Reader, params, LINEAR_HIERARCHY, NewMessage(), WithAnonymous()); err != errNotAnonymous {
+		t.Fatal("Encrypted under non-anonymous parameters")
+	}
+	if params, _, err = Setup(rand.Reader, 3, WithAnonymity()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Encrypt(rand.Reader, params, LINEAR_HIERARCHY, NewMessage(), WithAnonymous()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithPrecomputed(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryptor, err := EncryptorFor(params, LINEAR_HIERARCHY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := []byte("payload")
+	ciphertext, err := EncryptBytes(rand.Reader, params, LINEAR_HIERARCHY, plaintext, WithPrecomputed(encryptor))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := DecryptBytes(key, ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Fatal("Original and decrypted plaintexts differ")
+	}
+	if _, err = Encrypt(rand.Reader, params, LINEAR_HIERARCHY[:2], NewMessage(), WithPrecomputed(encryptor)); !errors.Is(err, ErrInvalidID) {
+		t.Fatal("Encryptor for another identity was used")
+	}
+}
@@ -0,0 +1,119 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"golang.org/x/crypto/bn256"
+	"math/big"
+	"testing"
+)
+
+// The toy parameters reproduce the construction of Boneh, Boyen and Goh
+// (Eurocrypt 2005, section 3) with fixed, small scalars instead of random
+// ones, so that every value can be written as a power of the generators P1
+// of G1 and P2 of G2, or of e(P1, P2) in GT, and checked against the
+// equations of the paper. In this implementation, g and g1 lie in G2, and
+// g2, g3 and h1 ... hl in G1.
+//
+//	Setup(l = 3)   g = P2^2, alpha = 3, g1 = g^alpha = P2^6
+//	               g2 = P1^5, g3 = P1^7, h1 = P1^11, h2 = P1^13, h3 = P1^17
+//	               master key g2^alpha = P1^15
+//	KeyGen(I)      I = (1, 2), r = 19, with the identity product
+//	               g3 * h1^I1 * h2^I2 = P1^(7 + 11 + 26) = P1^44
+//	               A0 = g2^alpha * (P1^44)^r = P1^(15 + 836) = P1^851
+//	               A1 = g^r = P2^38, B3 = h3^r = P1^323
+//	Encrypt(M)     M = e(P1, P2)^23, s = 29
+//	               A = e(g2, g1)^s * M = e(P1, P2)^(870 + 23)
+//	               B = g^s = P2^58, C = (P1^44)^s = P1^1276
+//	Decrypt        e(C, A1) = e(P1, P2)^(1276 * 38) = e(P1, P2)^48488
+//	               e(A0, B) = e(P1, P2)^(851 * 58) = e(P1, P2)^49358
+//	               A * e(C, A1) / e(A0, B) = e(P1, P2)^(893 + 48488 - 49358) = M
+//
+// The scalars are fed to the algorithms in the order they draw them: the
+// exponent of g, alpha, those of g2, g3 and h1 ... h3 in Setup, r in
+// KeyGenFromMaster and s in Encrypt.
+var (
+	toyID      = []*big.Int{big.NewInt(1), big.NewInt(2)}
+	toySetup   = []int64{2, 3, 5, 7, 11, 13, 17}
+	toyR       = int64(19)
+	toyS       = int64(29)
+	toyMessage = int64(23)
+)
+
+// scalarReader returns fixed scalars to rand.Int(random, bn256.Order), which
+// reads them as 32-byte big-endian integers.
+type scalarReader []int64
+
+func (reader *scalarReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	new(big.Int).SetInt64((*reader)[0]).FillBytes(p)
+	*reader = (*reader)[1:]
+	return len(p), nil
+}
+
+func toyG1(k int64) *bn256.G1 {
+	return new(bn256.G1).ScalarBaseMult(big.NewInt(k))
+}
+
+func toyG2(k int64) *bn256.G2 {
+	return new(bn256.G2).ScalarBaseMult(big.NewInt(k))
+}
+
+func toyGT(k int64) *bn256.GT {
+	return new(bn256.GT).ScalarMult(gtBase, big.NewInt(k))
+}
+
+func expectElement(t *testing.T, name string, got interface{ Marshal() []byte }, want interface{ Marshal() []byte }) {
+	t.Helper()
+	if !bytes.Equal(got.Marshal(), want.Marshal()) {
+		t.Fatalf("%s does not match the paper", name)
+	}
+}
+
+// toyRun runs the algorithms on the toy parameters.
+func toyRun(t *testing.T) (*Params, MasterKey, *PrivateKey, *Ciphertext) {
+	scalars := append(append(append([]int64{}, toySetup...), toyR), toyS)
+	random := scalarReader(scalars)
+	params, master, err := Setup(&random, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := KeyGenFromMaster(&random, params, master, toyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := Encrypt(&random, params, toyID, toyGT(toyMessage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return params, master, key, ciphertext
+}
+
+func TestBBG04ToyParameters(t *testing.T) {
+	params, master, key, ciphertext := toyRun(t)
+
+	expectElement(t, "g", params.G, toyG2(2))
+	expectElement(t, "g1", params.G1, toyG2(6))
+	expectElement(t, "g2", params.G2, toyG1(5))
+	expectElement(t, "g3", params.G3, toyG1(7))
+	for i, k := range []int64{11, 13, 17} {
+		expectElement(t, "h", params.H[i], toyG1(k))
+	}
+	expectElement(t, "master key", (*bn256.G1)(master), toyG1(15))
+
+	expectElement(t, "A0", key.A0, toyG1(851))
+	expectElement(t, "A1", key.A1, toyG2(38))
+	if len(key.B) != 1 {
+		t.Fatal("Key does not have one delegation component")
+	}
+	expectElement(t, "B3", key.B[0], toyG1(323))
+
+	expectElement(t, "A", ciphertext.A, toyGT(893))
+	expectElement(t, "B", ciphertext.B, toyG2(58))
+	expectElement(t, "C", ciphertext.C, toyG1(1276))
+
+	expectElement(t, "e(C, A1)", bn256.Pair(ciphertext.C, key.A1), toyGT(48488))
+	expectElement(t, "e(A0, B)", bn256.Pair(key.A0, ciphertext.B), toyGT(49358))
+	expectElement(t, "decrypted message", mustDecrypt(t, key, ciphertext), toyGT(toyMessage))
+}
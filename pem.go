@@ -0,0 +1,606 @@
+package hibe_sm9
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bn256"
+	"golang.org/x/crypto/scrypt"
+	"io"
+)
+
+// PEM block types used by this package.
+const (
+	PEMTypeParams              = "HIBE PARAMETERS"
+	PEMTypeMasterKey           = "HIBE MASTER KEY"
+	PEMTypePrivateKey          = "HIBE PRIVATE KEY"
+	PEMTypeEncryptedPrivateKey = "ENCRYPTED HIBE PRIVATE KEY"
+	PEMTypeEncryptedMasterKey  = "ENCRYPTED HIBE MASTER KEY"
+	PEMTypeSignedParams        = "HIBE SIGNED PARAMETERS"
+)
+
+// pemVersion is the version of the ASN.1 structures below.
+const pemVersion = 1
+
+// Parameters for deriving the key that protects an encrypted private key.
+// Imports accept other parameters up to the bounds below, which keep a
+// crafted file from making the loader spend more than 1 GiB of memory or 16
+// passes of scrypt.
+const (
+	pemScryptN = 1 << 15
+	pemScryptR = 8
+	pemScryptP = 1
+
+	maxPEMScryptN      = 1 << 20
+	maxPEMScryptR      = 32
+	maxPEMScryptP      = 16
+	maxPEMScryptMemory = 1 << 30
+)
+
+// Bounds on the length of the salts of the KDFs, which are 16 bytes when
+// exporting.
+const (
+	minPEMSaltSize = 8
+	maxPEMSaltSize = 64
+)
+
+// Parameters for deriving the key that protects an encrypted master key with
+// Argon2id: the second recommended option of RFC 9106, with 64 MiB of memory.
+// Imports accept other parameters up to the bounds below, so that they can be
+// raised without breaking older files.
+const (
+	masterArgon2Time    = 3
+	masterArgon2Memory  = 64 * 1024
+	masterArgon2Threads = 4
+
+	maxMasterArgon2Time   = 64
+	maxMasterArgon2Memory = 4 * 1024 * 1024
+)
+
+var (
+	oidScrypt    = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11591, 4, 11}
+	oidAES256GCM = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 46}
+)
+
+var (
+	errPEMType       = errors.New("hibe: unexpected PEM block type")
+	errPEMMalformed  = errors.New("hibe: malformed PEM contents")
+	errPEMPassword   = wrapError(ErrDecryptFailed, "hibe: incorrect password or corrupted private key")
+	errPEMPassphrase = wrapError(ErrDecryptFailed, "hibe: incorrect passphrase or corrupted master key")
+)
+
+// asn1Params is the ASN.1 structure of encoded parameters:
+//
+//	HIBEParameters ::= SEQUENCE {
+//	  version INTEGER,
+//	  g OCTET STRING, g1 OCTET STRING, g2 OCTET STRING, g3 OCTET STRING,
+//	  h SEQUENCE OF OCTET STRING,
+//	  g3Hat [0] OCTET STRING OPTIONAL,
+//	  hHat [1] SEQUENCE OF OCTET STRING OPTIONAL,
+//	  idHash [2] EXPLICIT INTEGER DEFAULT 0,
+//	  hSeed [3] OCTET STRING OPTIONAL,
+//	  depth [4] EXPLICIT INTEGER DEFAULT 0 }
+//
+// Derived h1 ... hl (see WithDerivedH) are encoded as hSeed and depth, with h
+// empty.
+type asn1Params struct {
+	Version int
+	G       []byte
+	G1      []byte
+	G2      []byte
+	G3      []byte
+	H       [][]byte
+	G3Hat   []byte   `asn1:"optional,tag:0"`
+	HHat    [][]byte `asn1:"optional,tag:1"`
+	IDHash  int      `asn1:"optional,explicit,default:0,tag:2"`
+	HSeed   []byte   `asn1:"optional,tag:3"`
+	Depth   int      `asn1:"optional,explicit,default:0,tag:4"`
+}
+
+// asn1MasterKey is the ASN.1 structure of an encoded master key:
+//
+//	HIBEMasterKey ::= SEQUENCE { version INTEGER, key OCTET STRING }
+type asn1MasterKey struct {
+	Version int
+	Key     []byte
+}
+
+// asn1PrivateKey is the ASN.1 structure of an encoded private key:
+//
+//	HIBEPrivateKey ::= SEQUENCE {
+//	  version INTEGER,
+//	  a0 OCTET STRING,
+//	  a1 [0] OCTET STRING OPTIONAL,
+//	  b SEQUENCE OF OCTET STRING,
+//	  a1Hat [1] OCTET STRING OPTIONAL,
+//	  params [2] OCTET STRING OPTIONAL }
+type asn1PrivateKey struct {
+	Version int
+	A0      []byte
+	A1      []byte `asn1:"optional,tag:0"`
+	B       [][]byte
+	A1Hat   []byte `asn1:"optional,tag:1"`
+	Params  []byte `asn1:"optional,tag:2"`
+}
+
+// asn1EncryptedPrivateKey is the ASN.1 structure of an encrypted private key:
+//
+//	EncryptedHIBEPrivateKey ::= SEQUENCE {
+//	  kdf SEQUENCE { algorithm OBJECT IDENTIFIER, parameters scrypt-params },
+//	  encryption SEQUENCE { algorithm OBJECT IDENTIFIER, nonce OCTET STRING },
+//	  encryptedData OCTET STRING }
+//
+// This follows the shape of PKCS#8's EncryptedPrivateKeyInfo, with scrypt
+// (RFC 7914) as the KDF and AES-256-GCM as the cipher.
+type asn1EncryptedPrivateKey struct {
+	KDF           asn1KDF
+	Encryption    asn1Encryption
+	EncryptedData []byte
+}
+
+type asn1KDF struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1ScryptParams
+}
+
+type asn1ScryptParams struct {
+	Salt            []byte
+	CostParameter   int
+	BlockSize       int
+	Parallelization int
+	KeyLength       int
+}
+
+// asn1EncryptedMasterKey is the ASN.1 structure of an encrypted master key:
+//
+//	EncryptedHIBEMasterKey ::= SEQUENCE {
+//	  version INTEGER,
+//	  kdf SEQUENCE {
+//	    version INTEGER, salt OCTET STRING, time INTEGER,
+//	    memory INTEGER, threads INTEGER, keyLength INTEGER },
+//	  encryption SEQUENCE { algorithm OBJECT IDENTIFIER, nonce OCTET STRING },
+//	  encryptedData OCTET STRING }
+//
+// The KDF is Argon2id (RFC 9106) with the given Argon2 version and memory in
+// KiB, and the encrypted data is a HIBEMasterKey structure. Version 1 fixes
+// the KDF to Argon2id; a later version may name another.
+type asn1EncryptedMasterKey struct {
+	Version       int
+	KDF           asn1Argon2Params
+	Encryption    asn1Encryption
+	EncryptedData []byte
+}
+
+type asn1Argon2Params struct {
+	Version   int
+	Salt      []byte
+	Time      int
+	Memory    int
+	Threads   int
+	KeyLength int
+}
+
+type asn1Encryption struct {
+	Algorithm asn1.ObjectIdentifier
+	Nonce     []byte
+}
+
+// decodePEM extracts the contents of a PEM block of the expected type.
+func decodePEM(data []byte, blockType string) ([]byte, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errPEMMalformed
+	}
+	if block.Type != blockType {
+		return nil, errPEMType
+	}
+	return block.Bytes, nil
+}
+
+// parseDER decodes DER into out, rejecting trailing data.
+func parseDER(der []byte, out interface{}) error {
+	rest, err := asn1.Unmarshal(der, out)
+	if err != nil || len(rest) != 0 {
+		return errPEMMalformed
+	}
+	return nil
+}
+
+// MarshalPEM encodes the parameters as a "HIBE PARAMETERS" PEM block.
+func (params *Params) MarshalPEM() ([]byte, error) {
+	structure := asn1Params{
+		Version: pemVersion,
+		G:       params.G.Marshal(),
+		G1:      params.G1.Marshal(),
+		G2:      params.G2.Marshal(),
+		G3:      params.G3.Marshal(),
+		H:       make([][]byte, len(params.H)),
+		IDHash:  int(params.IdentityHash),
+	}
+	for i, hi := range params.H {
+		structure.H[i] = hi.Marshal()
+	}
+	if params.HSeed != nil {
+		structure.H, structure.HSeed, structure.Depth = [][]byte{}, params.HSeed, len(params.H)
+	}
+	if params.Anonymous() {
+		structure.G3Hat = params.G3Hat.Marshal()
+		structure.HHat = make([][]byte, len(params.HHat))
+		for i, hi := range params.HHat {
+			structure.HHat[i] = hi.Marshal()
+		}
+	}
+
+	der, err := asn1.Marshal(structure)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: PEMTypeParams, Bytes: der}), nil
+}
+
+// ParsePEM recovers the parameters from a "HIBE PARAMETERS" PEM block. The
+// decoded parameters are validated.
+func (params *Params) ParsePEM(data []byte) (*Params, error) {
+	der, err := decodePEM(data, PEMTypeParams)
+	if err != nil {
+		return nil, err
+	}
+	var structure asn1Params
+	if err = parseDER(der, &structure); err != nil {
+		return nil, err
+	}
+	if structure.Version != pemVersion || structure.IDHash < 0 || structure.IDHash > 0xff {
+		return nil, errPEMMalformed
+	}
+
+	params.IdentityHash = IdentityHash(structure.IDHash)
+	if params.G, err = unmarshalG2(structure.G); err != nil {
+		return nil, err
+	}
+	if params.G1, err = unmarshalG2(structure.G1); err != nil {
+		return nil, err
+	}
+	if params.G2, err = unmarshalG1(structure.G2); err != nil {
+		return nil, err
+	}
+	if params.G3, err = unmarshalG1(structure.G3); err != nil {
+		return nil, err
+	}
+	params.H = make([]*bn256.G1, len(structure.H))
+	for i, hi := range structure.H {
+		if params.H[i], err = unmarshalG1(hi); err != nil {
+			return nil, err
+		}
+	}
+	params.HSeed = nil
+	if structure.HSeed != nil {
+		if len(structure.H) != 0 || params.setHSeed(structure.HSeed, structure.Depth) != nil {
+			return nil, errPEMMalformed
+		}
+	}
+	params.G3Hat, params.HHat = nil, nil
+	if structure.G3Hat != nil {
+		if params.G3Hat, err = unmarshalG2(structure.G3Hat); err != nil {
+			return nil, err
+		}
+		params.HHat = make([]*bn256.G2, len(structure.HHat))
+		for i, hi := range structure.HHat {
+			if params.HHat[i], err = unmarshalG2(hi); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Clear any cached values
+	params.Pairing = nil
+
+	if err = params.Validate(); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+// MarshalMasterKeyPEM encodes a master key as a "HIBE MASTER KEY" PEM block.
+// The result is as sensitive as the master key itself.
+func MarshalMasterKeyPEM(master MasterKey) ([]byte, error) {
+	der, err := asn1.Marshal(asn1MasterKey{
+		Version: pemVersion,
+		Key:     (*bn256.G1)(master).Marshal(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: PEMTypeMasterKey, Bytes: der}), nil
+}
+
+// ParseMasterKeyPEM recovers a master key from a "HIBE MASTER KEY" PEM block.
+func ParseMasterKeyPEM(data []byte) (MasterKey, error) {
+	der, err := decodePEM(data, PEMTypeMasterKey)
+	if err != nil {
+		return nil, err
+	}
+	return parseMasterKeyDER(der)
+}
+
+// parseMasterKeyDER recovers a master key from a DER HIBEMasterKey structure.
+func parseMasterKeyDER(der []byte) (MasterKey, error) {
+	var structure asn1MasterKey
+	if err := parseDER(der, &structure); err != nil {
+		return nil, err
+	}
+	if structure.Version != pemVersion {
+		return nil, errPEMMalformed
+	}
+	master, err := unmarshalG1(structure.Key)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkG1(master); err != nil {
+		return nil, err
+	}
+	return master, nil
+}
+
+// ExportMasterEncrypted encodes a master key as an "ENCRYPTED HIBE MASTER
+// KEY" PEM block, protected by a key derived from passphrase with Argon2id.
+// The KDF parameters are recorded in the block, so that they can be raised
+// later without breaking existing exports.
+func ExportMasterEncrypted(random io.Reader, master MasterKey, passphrase []byte) ([]byte, error) {
+	der, err := asn1.Marshal(asn1MasterKey{
+		Version: pemVersion,
+		Key:     (*bn256.G1)(master).Marshal(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer zeroizeBytes(der)
+
+	random = randomSource(random)
+	kdf := asn1Argon2Params{
+		Version:   argon2.Version,
+		Salt:      make([]byte, 16),
+		Time:      masterArgon2Time,
+		Memory:    masterArgon2Memory,
+		Threads:   masterArgon2Threads,
+		KeyLength: 32,
+	}
+	if _, err = io.ReadFull(random, kdf.Salt); err != nil {
+		return nil, err
+	}
+	aead, err := passphraseAEAD(passphrase, kdf)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(random, nonce); err != nil {
+		return nil, err
+	}
+
+	encrypted, err := asn1.Marshal(asn1EncryptedMasterKey{
+		Version:       pemVersion,
+		KDF:           kdf,
+		Encryption:    asn1Encryption{Algorithm: oidAES256GCM, Nonce: nonce},
+		EncryptedData: aead.Seal(nil, nonce, der, nil),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: PEMTypeEncryptedMasterKey, Bytes: encrypted}), nil
+}
+
+// ImportMasterEncrypted recovers a master key from an "ENCRYPTED HIBE MASTER
+// KEY" PEM block produced by ExportMasterEncrypted.
+func ImportMasterEncrypted(data []byte, passphrase []byte) (MasterKey, error) {
+	encrypted, err := decodePEM(data, PEMTypeEncryptedMasterKey)
+	if err != nil {
+		return nil, err
+	}
+	var structure asn1EncryptedMasterKey
+	if err = parseDER(encrypted, &structure); err != nil {
+		return nil, err
+	}
+	kdf := structure.KDF
+	if structure.Version != pemVersion || !structure.Encryption.Algorithm.Equal(oidAES256GCM) ||
+		kdf.Version != argon2.Version || kdf.KeyLength != 32 ||
+		len(kdf.Salt) < minPEMSaltSize || len(kdf.Salt) > maxPEMSaltSize ||
+		kdf.Time < 1 || kdf.Time > maxMasterArgon2Time ||
+		kdf.Memory < 8*kdf.Threads || kdf.Memory > maxMasterArgon2Memory ||
+		kdf.Threads < 1 || kdf.Threads > 255 {
+		return nil, errPEMMalformed
+	}
+	aead, err := passphraseAEAD(passphrase, kdf)
+	if err != nil {
+		return nil, errPEMMalformed
+	}
+	if len(structure.Encryption.Nonce) != aead.NonceSize() {
+		return nil, errPEMMalformed
+	}
+	der, err := aead.Open(nil, structure.Encryption.Nonce, structure.EncryptedData, nil)
+	if err != nil {
+		return nil, errPEMPassphrase
+	}
+	defer zeroizeBytes(der)
+	return parseMasterKeyDER(der)
+}
+
+// passphraseAEAD derives the cipher that protects an encrypted master key.
+func passphraseAEAD(passphrase []byte, params asn1Argon2Params) (cipher.AEAD, error) {
+	derived := argon2.IDKey(passphrase, params.Salt, uint32(params.Time), uint32(params.Memory),
+		uint8(params.Threads), uint32(params.KeyLength))
+	defer zeroizeBytes(derived)
+	block, err := aes.NewCipher(derived)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// marshalASN1 encodes the private key as a DER HIBEPrivateKey structure.
+func (key *PrivateKey) marshalASN1() ([]byte, error) {
+	structure := asn1PrivateKey{
+		Version: pemVersion,
+		A0:      key.A0.Marshal(),
+		B:       make([][]byte, len(key.B)),
+		Params:  key.ParamsFingerprint,
+	}
+	if key.A1Hat != nil {
+		structure.A1Hat = key.A1Hat.Marshal()
+	} else {
+		structure.A1 = key.A1.Marshal()
+	}
+	for i, bi := range key.B {
+		structure.B[i] = bi.Marshal()
+	}
+	return asn1.Marshal(structure)
+}
+
+// unmarshalASN1 decodes a DER HIBEPrivateKey structure into the private key.
+func (key *PrivateKey) unmarshalASN1(der []byte) (*PrivateKey, error) {
+	var structure asn1PrivateKey
+	err := parseDER(der, &structure)
+	if err != nil {
+		return nil, err
+	}
+	if structure.Version != pemVersion {
+		return nil, errPEMMalformed
+	}
+
+	if key.A0, err = unmarshalG1(structure.A0); err != nil {
+		return nil, err
+	}
+	key.A1, key.A1Hat = nil, nil
+	if structure.A1Hat != nil {
+		if key.A1Hat, err = unmarshalG1(structure.A1Hat); err != nil {
+			return nil, err
+		}
+	} else if key.A1, err = unmarshalG2(structure.A1); err != nil {
+		return nil, err
+	}
+	key.B = nil
+	if len(structure.B) != 0 {
+		key.B = make([]*bn256.G1, len(structure.B))
+	}
+	for i, bi := range structure.B {
+		if key.B[i], err = unmarshalG1(bi); err != nil {
+			return nil, err
+		}
+	}
+	if structure.Params != nil && len(structure.Params) != FingerprintSize {
+		return nil, errPEMMalformed
+	}
+	key.ParamsFingerprint = structure.Params
+
+	if err = key.validatePoints(); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// MarshalPEM encodes the private key as an unencrypted "HIBE PRIVATE KEY" PEM
+// block.
+func (key *PrivateKey) MarshalPEM() ([]byte, error) {
+	der, err := key.marshalASN1()
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: PEMTypePrivateKey, Bytes: der}), nil
+}
+
+// passwordAEAD derives the cipher that protects an encrypted private key.
+func passwordAEAD(password []byte, params asn1ScryptParams) (cipher.AEAD, error) {
+	derived, err := scrypt.Key(password, params.Salt, params.CostParameter, params.BlockSize,
+		params.Parallelization, params.KeyLength)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(derived)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// MarshalEncryptedPEM encodes the private key as an "ENCRYPTED HIBE PRIVATE
+// KEY" PEM block, protected by a key derived from password with scrypt.
+func (key *PrivateKey) MarshalEncryptedPEM(random io.Reader, password []byte) ([]byte, error) {
+	random = randomSource(random)
+	der, err := key.marshalASN1()
+	if err != nil {
+		return nil, err
+	}
+
+	kdf := asn1ScryptParams{
+		Salt:            make([]byte, 16),
+		CostParameter:   pemScryptN,
+		BlockSize:       pemScryptR,
+		Parallelization: pemScryptP,
+		KeyLength:       32,
+	}
+	if _, err = io.ReadFull(random, kdf.Salt); err != nil {
+		return nil, err
+	}
+	aead, err := passwordAEAD(password, kdf)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(random, nonce); err != nil {
+		return nil, err
+	}
+
+	encrypted, err := asn1.Marshal(asn1EncryptedPrivateKey{
+		KDF:           asn1KDF{Algorithm: oidScrypt, Parameters: kdf},
+		Encryption:    asn1Encryption{Algorithm: oidAES256GCM, Nonce: nonce},
+		EncryptedData: aead.Seal(nil, nonce, der, nil),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: PEMTypeEncryptedPrivateKey, Bytes: encrypted}), nil
+}
+
+// ParsePEM recovers the private key from a "HIBE PRIVATE KEY" or "ENCRYPTED
+// HIBE PRIVATE KEY" PEM block. The password is only used, and is required,
+// for the latter.
+func (key *PrivateKey) ParsePEM(data []byte, password []byte) (*PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errPEMMalformed
+	}
+	switch block.Type {
+	case PEMTypePrivateKey:
+		return key.unmarshalASN1(block.Bytes)
+	case PEMTypeEncryptedPrivateKey:
+	default:
+		return nil, errPEMType
+	}
+
+	var structure asn1EncryptedPrivateKey
+	if err := parseDER(block.Bytes, &structure); err != nil {
+		return nil, err
+	}
+	kdf := structure.KDF.Parameters
+	if !structure.KDF.Algorithm.Equal(oidScrypt) || !structure.Encryption.Algorithm.Equal(oidAES256GCM) ||
+		kdf.KeyLength != 32 || len(kdf.Salt) < minPEMSaltSize || len(kdf.Salt) > maxPEMSaltSize ||
+		kdf.CostParameter < 2 || kdf.CostParameter > maxPEMScryptN ||
+		kdf.BlockSize < 1 || kdf.BlockSize > maxPEMScryptR ||
+		kdf.Parallelization < 1 || kdf.Parallelization > maxPEMScryptP ||
+		128*int64(kdf.CostParameter)*int64(kdf.BlockSize) > maxPEMScryptMemory {
+		return nil, errPEMMalformed
+	}
+	aead, err := passwordAEAD(password, kdf)
+	if err != nil {
+		return nil, errPEMMalformed
+	}
+	if len(structure.Encryption.Nonce) != aead.NonceSize() {
+		return nil, errPEMMalformed
+	}
+	der, err := aead.Open(nil, structure.Encryption.Nonce, structure.EncryptedData, nil)
+	if err != nil {
+		return nil, errPEMPassword
+	}
+	return key.unmarshalASN1(der)
+}
@@ -0,0 +1,174 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"golang.org/x/crypto/bn256"
+	"testing"
+)
+
+func TestPEM(t *testing.T) {
+	for _, anonymous := range []bool{false, true} {
+		var opts []SetupOption
+		if anonymous {
+			opts = append(opts, WithAnonymity())
+		}
+		params, master, err := Setup(rand.Reader, 5, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[:2])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		encoded, err := params.MarshalPEM()
+		if err != nil {
+			t.Fatal(err)
+		}
+		decodedParams, err := new(Params).ParsePEM(encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(params.Marshal(), decodedParams.Marshal()) {
+			t.Fatal("Parameters differ after PEM round trip")
+		}
+
+		encoded, err = MarshalMasterKeyPEM(master)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decodedMaster, err := ParseMasterKeyPEM(encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal((*bn256.G1)(master).Marshal(), (*bn256.G1)(decodedMaster).Marshal()) {
+			t.Fatal("Master keys differ after PEM round trip")
+		}
+
+		encoded, err = key.MarshalPEM()
+		if err != nil {
+			t.Fatal(err)
+		}
+		decodedKey, err := new(PrivateKey).ParsePEM(encoded, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(key.Marshal(), decodedKey.Marshal()) {
+			t.Fatal("Private keys differ after PEM round trip")
+		}
+
+		if _, err = new(Params).ParsePEM(encoded); err == nil {
+			t.Fatal("Parsed a private key as parameters")
+		}
+	}
+}
+
+func TestEncryptedPEM(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	password := []byte("correct horse battery staple")
+	encoded, err := key.MarshalEncryptedPEM(rand.Reader, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(encoded, []byte(PEMTypePrivateKey+"-----")) && !bytes.Contains(encoded, []byte(PEMTypeEncryptedPrivateKey)) {
+		t.Fatal("Private key was not encrypted")
+	}
+
+	decoded, err := new(PrivateKey).ParsePEM(encoded, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key.Marshal(), decoded.Marshal()) {
+		t.Fatal("Private keys differ after encrypted PEM round trip")
+	}
+
+	if _, err = new(PrivateKey).ParsePEM(encoded, []byte("wrong")); err == nil {
+		t.Fatal("Decrypted private key with the wrong password")
+	}
+}
+
+func TestEncryptedPEMBounds(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	password := []byte("password")
+	encoded, err := key.MarshalEncryptedPEM(rand.Reader, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(encoded)
+	var structure asn1EncryptedPrivateKey
+	if err = parseDER(block.Bytes, &structure); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, change := range map[string]func(*asn1ScryptParams){
+		"N":      func(kdf *asn1ScryptParams) { kdf.CostParameter = 1 << 21 },
+		"r":      func(kdf *asn1ScryptParams) { kdf.BlockSize = 1 << 20 },
+		"p":      func(kdf *asn1ScryptParams) { kdf.Parallelization = 1 << 20 },
+		"memory": func(kdf *asn1ScryptParams) { kdf.CostParameter, kdf.BlockSize = 1<<20, 32 },
+		"salt":   func(kdf *asn1ScryptParams) { kdf.Salt = make([]byte, 1<<16) },
+	} {
+		crafted := structure
+		change(&crafted.KDF.Parameters)
+		der, err := asn1.Marshal(crafted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := pem.EncodeToMemory(&pem.Block{Type: PEMTypeEncryptedPrivateKey, Bytes: der})
+		if _, err = new(PrivateKey).ParsePEM(data, password); err != errPEMMalformed {
+			t.Fatalf("Oversized %s was not rejected: %v", name, err)
+		}
+	}
+}
+
+func TestExportMasterEncrypted(t *testing.T) {
+	_, master, err := Setup(rand.Reader, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	passphrase := []byte("correct horse battery staple")
+	encoded, err := ExportMasterEncrypted(rand.Reader, master, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(encoded, []byte(PEMTypeEncryptedMasterKey)) {
+		t.Fatal("Master key was not encrypted")
+	}
+
+	decoded, err := ImportMasterEncrypted(encoded, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal((*bn256.G1)(master).Marshal(), (*bn256.G1)(decoded).Marshal()) {
+		t.Fatal("Master keys differ after encrypted export")
+	}
+
+	if _, err = ImportMasterEncrypted(encoded, []byte("wrong")); !errors.Is(err, ErrDecryptFailed) {
+		t.Fatal("Imported master key with the wrong passphrase")
+	}
+	plain, err := MarshalMasterKeyPEM(master)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ImportMasterEncrypted(plain, passphrase); err == nil {
+		t.Fatal("Imported an unencrypted master key")
+	}
+}
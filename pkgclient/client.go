@@ -0,0 +1,311 @@
+// Package pkgclient retrieves private keys from a PKG server (see package
+// pkgserver). A Client talks to one server, retries transient failures, and
+// checks every key it receives against the parameters it was configured
+// with, so a misbehaving or impersonated server cannot hand out keys for a
+// different hierarchy or identity:
+//
+//	client, err := pkgclient.New(pkgclient.Config{
+//		URL:    "https://pkg.example.com",
+//		Params: params,
+//	})
+//	key, err := client.RequestKey(ctx, id, pkgclient.BearerToken(token))
+//
+// Instead of pinning the parameters, a client can pin the PKG's Ed25519 key
+// with ParamsKey, and load the parameters from the server's signed bundle.
+package pkgclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hibe_sm9"
+	"hibe_sm9/pkgserver"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxResponseSize bounds the size of a response body.
+const maxResponseSize = 1 << 20
+
+var (
+	// ErrUnauthenticated is returned when the server rejects the
+	// credentials.
+	ErrUnauthenticated = errors.New("pkgclient: unauthenticated")
+
+	// ErrForbidden is returned when the requester may not obtain the key.
+	ErrForbidden = errors.New("pkgclient: forbidden")
+
+	// ErrRateLimited is returned when the server is still rate limiting the
+	// requester after the last retry.
+	ErrRateLimited = errors.New("pkgclient: rate limit exceeded")
+
+	// ErrInvalidKey is returned when the server returns a key that does not
+	// belong to the pinned parameters or does not decrypt for the identity.
+	ErrInvalidKey = errors.New("pkgclient: server returned an invalid key")
+
+	// ErrParamsMismatch is returned by Params when the server publishes
+	// different parameters from the pinned ones.
+	ErrParamsMismatch = errors.New("pkgclient: server parameters do not match the pinned parameters")
+
+	// ErrParamsSignature is returned when the server's parameter bundle is
+	// not signed by ParamsKey.
+	ErrParamsSignature = errors.New("pkgclient: server parameters are not signed by the params key")
+)
+
+// Credentials authenticate a key request.
+type Credentials interface {
+	Authorize(r *http.Request) error
+}
+
+// BearerToken authenticates with a token, as accepted by
+// pkgserver.TokenAuthenticator.
+type BearerToken string
+
+// Authorize implements Credentials.
+func (token BearerToken) Authorize(r *http.Request) error {
+	r.Header.Set("Authorization", "Bearer "+string(token))
+	return nil
+}
+
+// Config configures a Client.
+type Config struct {
+	// URL is the base URL of the server, without the /v1 path.
+	URL string
+
+	// Params are the pinned parameters of the hierarchy. Every key is
+	// checked against them.
+	Params *hibe_sm9.Params
+
+	// ParamsKey is the public key that signs the server's parameter bundle
+	// (see pkgserver.Config.ParamsSigner). If it is set, Params may be nil,
+	// in which case the parameters are fetched from the bundle and pinned
+	// on first use.
+	ParamsKey ed25519.PublicKey
+
+	// TLSConfig configures TLS connections to the server, for example with
+	// a client certificate for pkgserver.MTLSAuthenticator, in which case
+	// RequestKey can be called with nil credentials. It is ignored if
+	// HTTPClient is set.
+	TLSConfig *tls.Config
+
+	// HTTPClient is used for requests. If nil, a client with TLSConfig and a
+	// 30 second timeout is used.
+	HTTPClient *http.Client
+
+	// Retries is the number of times a request is retried after a network
+	// error, a server error, or rate limiting. Backoff is the delay before
+	// the first retry, which doubles for every further retry; if zero, it
+	// is 500ms.
+	Retries int
+	Backoff time.Duration
+}
+
+// Client retrieves keys from a PKG server. It is safe for concurrent use.
+type Client struct {
+	config Config
+	base   string
+
+	lock   sync.Mutex
+	params *hibe_sm9.Params
+}
+
+// New creates a Client from config.
+func New(config Config) (*Client, error) {
+	if config.URL == "" || (config.Params == nil && config.ParamsKey == nil) {
+		return nil, errors.New("pkgclient: URL and params or a params key are required")
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{
+			Transport: &http.Transport{TLSClientConfig: config.TLSConfig, Proxy: http.ProxyFromEnvironment},
+			Timeout:   30 * time.Second,
+		}
+	}
+	if config.Backoff == 0 {
+		config.Backoff = 500 * time.Millisecond
+	}
+	if config.Params != nil {
+		config.Params.Precache()
+	}
+	return &Client{config: config, base: strings.TrimRight(config.URL, "/"), params: config.Params}, nil
+}
+
+// statusError converts an unsuccessful response to an error, and reports
+// whether the request may succeed if retried.
+func statusError(response *http.Response) (error, bool) {
+	switch response.StatusCode {
+	case http.StatusUnauthorized:
+		return ErrUnauthenticated, false
+	case http.StatusForbidden:
+		return ErrForbidden, false
+	case http.StatusTooManyRequests:
+		return ErrRateLimited, true
+	}
+	message, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+	err := fmt.Errorf("pkgclient: server returned %s: %s", response.Status, strings.TrimSpace(string(message)))
+	return err, response.StatusCode >= 500
+}
+
+// do sends the request built by newRequest, retrying transient failures, and
+// decodes the JSON response into out.
+func (client *Client) do(ctx context.Context, newRequest func() (*http.Request, error), out interface{}) error {
+	backoff := client.config.Backoff
+	for attempt := 0; ; attempt++ {
+		request, err := newRequest()
+		if err != nil {
+			return err
+		}
+		retry := true
+		response, err := client.config.HTTPClient.Do(request.WithContext(ctx))
+		if err == nil {
+			if response.StatusCode == http.StatusOK {
+				err = json.NewDecoder(io.LimitReader(response.Body, maxResponseSize)).Decode(out)
+				retry = false
+			} else {
+				err, retry = statusError(response)
+			}
+			response.Body.Close()
+		}
+		if err == nil || !retry || attempt == client.config.Retries || ctx.Err() != nil {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// Params fetches the parameters published by the server and checks that
+// they are the pinned ones. If ParamsKey is set, the signed bundle is fetched
+// instead and its signature checked, and the parameters are pinned if they
+// were not already.
+func (client *Client) Params(ctx context.Context) (*hibe_sm9.Params, error) {
+	var params *hibe_sm9.Params
+	if client.config.ParamsKey != nil {
+		var response pkgserver.SignedParamsResponse
+		err := client.do(ctx, func() (*http.Request, error) {
+			return http.NewRequest(http.MethodGet, client.base+"/v1/params/signed", nil)
+		}, &response)
+		if err != nil {
+			return nil, err
+		}
+		params, err = hibe_sm9.VerifyParams([]byte(response.Bundle), client.config.ParamsKey)
+		if errors.Is(err, hibe_sm9.ErrParamsSignature) {
+			return nil, ErrParamsSignature
+		} else if err != nil {
+			return nil, err
+		}
+	} else {
+		params = new(hibe_sm9.Params)
+		err := client.do(ctx, func() (*http.Request, error) {
+			return http.NewRequest(http.MethodGet, client.base+"/v1/params", nil)
+		}, params)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	if client.params == nil {
+		params.Precache()
+		client.params = params
+	}
+	if !bytes.Equal(params.Fingerprint(), client.params.Fingerprint()) {
+		return nil, ErrParamsMismatch
+	}
+	return params, nil
+}
+
+// pinned returns the pinned parameters, fetching them with Params first if
+// they are only pinned through ParamsKey.
+func (client *Client) pinned(ctx context.Context) (*hibe_sm9.Params, error) {
+	client.lock.Lock()
+	params := client.params
+	client.lock.Unlock()
+	if params != nil {
+		return params, nil
+	}
+	if _, err := client.Params(ctx); err != nil {
+		return nil, err
+	}
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	return client.params, nil
+}
+
+// RequestKey obtains the private key for id, authenticating with credentials
+// (which may be nil if the transport authenticates the client). The key is
+// validated against the pinned parameters, and a test message is encrypted
+// to id and decrypted with it, so that ErrInvalidKey is returned instead of
+// a key for another identity.
+func (client *Client) RequestKey(ctx context.Context, id []*big.Int, credentials Credentials) (*hibe_sm9.PrivateKey, error) {
+	params, err := client.pinned(ctx)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(pkgserver.KeyRequest{ID: pkgserver.FormatID(id)})
+	if err != nil {
+		return nil, err
+	}
+
+	var response pkgserver.KeyResponse
+	err = client.do(ctx, func() (*http.Request, error) {
+		request, err := http.NewRequest(http.MethodPost, client.base+"/v1/keys", bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("Content-Type", "application/json")
+		if credentials != nil {
+			if err = credentials.Authorize(request); err != nil {
+				return nil, err
+			}
+		}
+		return request, nil
+	}, &response)
+	if err != nil {
+		return nil, err
+	}
+	if response.Key == nil {
+		return nil, ErrInvalidKey
+	}
+	if err = check(params, response.Key, id); err != nil {
+		return nil, err
+	}
+	return response.Key, nil
+}
+
+// check validates key as the key of id in the hierarchy of params.
+func check(params *hibe_sm9.Params, key *hibe_sm9.PrivateKey, id []*big.Int) error {
+	if key.Validate(params) != nil {
+		return ErrInvalidKey
+	}
+	var seed [32]byte
+	if _, err := io.ReadFull(rand.Reader, seed[:]); err != nil {
+		return err
+	}
+	message := hibe_sm9.HashToGT(seed[:])
+	ciphertext, err := hibe_sm9.Encrypt(rand.Reader, params, id, message, hibe_sm9.WithIntegrityTag())
+	if err != nil {
+		return err
+	}
+	decrypted, err := hibe_sm9.Decrypt(key, ciphertext)
+	if err != nil || !bytes.Equal(decrypted.Marshal(), message.Marshal()) {
+		return ErrInvalidKey
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package pkgclient
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"hibe_sm9"
+	"hibe_sm9/pkgserver"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testID = []*big.Int{big.NewInt(1), big.NewInt(2)}
+
+func newTestServer(t *testing.T) (*httptest.Server, *hibe_sm9.Params) {
+	params, master, err := hibe_sm9.Setup(rand.Reader, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := pkgserver.New(pkgserver.Config{
+		Params:        params,
+		Master:        master,
+		Authenticator: pkgserver.TokenAuthenticator{"secret": "alice"},
+		Authorize: func(requester string, id []*big.Int) bool {
+			return id[0].Int64() == 1
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return httptest.NewTLSServer(server), params
+}
+
+func TestRequestKey(t *testing.T) {
+	server, params := newTestServer(t)
+	defer server.Close()
+
+	client, err := New(Config{URL: server.URL, Params: params, HTTPClient: server.Client()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := client.RequestKey(context.Background(), testID, BearerToken("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = key.Validate(params); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.Params(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = client.RequestKey(context.Background(), testID, BearerToken("wrong")); !errors.Is(err, ErrUnauthenticated) {
+		t.Fatal("Wrong token was not reported")
+	}
+	if _, err = client.RequestKey(context.Background(), []*big.Int{big.NewInt(2)}, BearerToken("secret")); !errors.Is(err, ErrForbidden) {
+		t.Fatal("Forbidden identity was not reported")
+	}
+}
+
+func TestPinnedParams(t *testing.T) {
+	server, _ := newTestServer(t)
+	defer server.Close()
+	other, _, err := hibe_sm9.Setup(rand.Reader, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := New(Config{URL: server.URL, Params: other, HTTPClient: server.Client()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.RequestKey(context.Background(), testID, BearerToken("secret")); !errors.Is(err, ErrInvalidKey) {
+		t.Fatal("Key for other parameters was accepted")
+	}
+	if _, err = client.Params(context.Background()); !errors.Is(err, ErrParamsMismatch) {
+		t.Fatal("Other parameters were accepted")
+	}
+}
+
+func TestRetries(t *testing.T) {
+	server, params := newTestServer(t)
+	defer server.Close()
+
+	// Fail the first two requests, as an overloaded server would
+	var attempts int32
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) <= 2 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		http.Redirect(w, r, server.URL+r.URL.Path, http.StatusTemporaryRedirect)
+	}))
+	defer flaky.Close()
+
+	config := Config{URL: flaky.URL, Params: params, HTTPClient: server.Client(), Backoff: time.Millisecond}
+	client, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.RequestKey(context.Background(), testID, BearerToken("secret")); err == nil {
+		t.Fatal("Request succeeded without retries")
+	}
+
+	atomic.StoreInt32(&attempts, 0)
+	config.Retries = 2
+	if client, err = New(config); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.RequestKey(context.Background(), testID, BearerToken("secret")); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = client.RequestKey(ctx, testID, BearerToken("secret")); err == nil {
+		t.Fatal("Request succeeded with a cancelled context")
+	}
+}
+
+func TestSignedParams(t *testing.T) {
+	params, master, err := hibe_sm9.Setup(rand.Reader, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler, err := pkgserver.New(pkgserver.Config{
+		Params:        params,
+		Master:        master,
+		ParamsSigner:  private,
+		Authenticator: pkgserver.TokenAuthenticator{"secret": "alice"},
+		Authorize:     pkgserver.PrefixAuthorizer{"alice": {{big.NewInt(1)}}}.Authorize,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewTLSServer(handler)
+	defer server.Close()
+
+	// The parameters are loaded from the signed bundle on first use
+	client, err := New(Config{URL: server.URL, ParamsKey: public, HTTPClient: server.Client()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := client.RequestKey(context.Background(), testID, BearerToken("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = key.Validate(params); err != nil {
+		t.Fatal(err)
+	}
+	fetched, err := client.Params(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fetched.Equal(params) {
+		t.Fatal("Fetched parameters differ")
+	}
+
+	// A bundle signed with another key is rejected
+	other, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err = New(Config{URL: server.URL, ParamsKey: other, HTTPClient: server.Client()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.RequestKey(context.Background(), testID, BearerToken("secret")); !errors.Is(err, ErrParamsSignature) {
+		t.Fatal("Bundle signed with another key was accepted")
+	}
+}
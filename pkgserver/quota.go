@@ -0,0 +1,167 @@
+package pkgserver
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Quota bounds the number of keys the server issues, so that a compromised
+// credential, or a compromised delegation to a subtree administrator, cannot
+// mint keys without limit. Unlike the rate limit, which smooths bursts, a
+// quota is a hard cap per window, and is kept in a QuotaStore so that it
+// holds across restarts and across servers that share the store.
+type Quota struct {
+	// PerRequester, if positive, is the number of keys each requester may
+	// obtain per window.
+	PerRequester int64
+
+	// Subtrees limit the number of keys issued per window for identities
+	// under given prefixes, whoever requests them.
+	Subtrees []SubtreeLimit
+
+	// Window is the period after which the counters start again from zero.
+	// If zero, they never do, and the limits are totals.
+	Window time.Duration
+
+	// Store keeps the counters. If nil, a MemoryQuotaStore is used, which
+	// forgets them on restart.
+	Store QuotaStore
+}
+
+// SubtreeLimit is the number of keys that may be issued per window for
+// Prefix and the identities below it.
+type SubtreeLimit struct {
+	Prefix []*big.Int
+	Limit  int64
+}
+
+// QuotaCounter is one counter that a key request draws on.
+type QuotaCounter struct {
+	// Key names the counter and its window, for instance
+	// "requester/alice/1700000000".
+	Key string
+
+	// Limit is the largest value the counter may reach.
+	Limit int64
+
+	// Expires is the end of the window, after which the store may discard
+	// the counter, or the zero time if it never ends.
+	Expires time.Time
+}
+
+// QuotaStore persists quota counters. Take must be atomic across all the
+// counters it is given, including with other servers sharing the store: a
+// store on SQL does it in one transaction, and one on Redis in one script.
+// Implementations are safe for concurrent use.
+//
+// This package provides only MemoryQuotaStore. Stores on SQLite, Redis or
+// other databases are not provided, since they would need drivers that this
+// module does not depend on; deployments that share quotas implement
+// QuotaStore over their own database.
+type QuotaStore interface {
+	// Take increments every counter by one and returns true if none of
+	// them would exceed its limit, and otherwise changes nothing and
+	// returns false.
+	Take(counters []QuotaCounter, now time.Time) (bool, error)
+}
+
+// MemoryQuotaStore is a QuotaStore in memory, for a single server.
+type MemoryQuotaStore struct {
+	lock   sync.Mutex
+	counts map[string]*quotaCount
+}
+
+type quotaCount struct {
+	value   int64
+	expires time.Time
+}
+
+// NewMemoryQuotaStore returns an empty MemoryQuotaStore.
+func NewMemoryQuotaStore() *MemoryQuotaStore {
+	return &MemoryQuotaStore{counts: make(map[string]*quotaCount)}
+}
+
+// Take implements QuotaStore.
+func (store *MemoryQuotaStore) Take(counters []QuotaCounter, now time.Time) (bool, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	for key, count := range store.counts {
+		if !count.expires.IsZero() && !now.Before(count.expires) {
+			delete(store.counts, key)
+		}
+	}
+	for _, counter := range counters {
+		if count, ok := store.counts[counter.Key]; ok && count.value >= counter.Limit {
+			return false, nil
+		}
+	}
+	for _, counter := range counters {
+		count, ok := store.counts[counter.Key]
+		if !ok {
+			count = &quotaCount{expires: counter.Expires}
+			store.counts[counter.Key] = count
+		}
+		count.value++
+	}
+	return true, nil
+}
+
+// counters returns the counters that a request by requester for id draws on
+// at now.
+func (quota *Quota) counters(requester string, id []*big.Int, now time.Time) []QuotaCounter {
+	var window string
+	var expires time.Time
+	if quota.Window > 0 {
+		start := now.Truncate(quota.Window)
+		window = "/" + strconv.FormatInt(start.Unix(), 10)
+		expires = start.Add(quota.Window)
+	}
+
+	var counters []QuotaCounter
+	if quota.PerRequester > 0 {
+		counters = append(counters, QuotaCounter{
+			Key:     "requester/" + strconv.Quote(requester) + window,
+			Limit:   quota.PerRequester,
+			Expires: expires,
+		})
+	}
+	for _, subtree := range quota.Subtrees {
+		if len(subtree.Prefix) <= len(id) && hasPrefix(id, subtree.Prefix) {
+			counters = append(counters, QuotaCounter{
+				Key:     "subtree/" + strings.Join(FormatID(subtree.Prefix), ".") + window,
+				Limit:   subtree.Limit,
+				Expires: expires,
+			})
+		}
+	}
+	return counters
+}
+
+// hasPrefix reports whether id starts with prefix, which is no longer.
+func hasPrefix(id []*big.Int, prefix []*big.Int) bool {
+	for i, component := range prefix {
+		if component.Cmp(id[i]) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// takeQuota draws on the counters of a request, if the server has a quota. A
+// request whose key generation then fails still counts, which errs on the
+// side of the limit.
+func (server *Server) takeQuota(requester string, id []*big.Int) (bool, error) {
+	quota := server.config.Quota
+	if quota == nil {
+		return true, nil
+	}
+	now := time.Now()
+	counters := quota.counters(requester, id, now)
+	if len(counters) == 0 {
+		return true, nil
+	}
+	return quota.Store.Take(counters, now)
+}
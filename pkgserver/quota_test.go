@@ -0,0 +1,60 @@
+package pkgserver
+
+import (
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestQuota(t *testing.T) {
+	server, _ := newTestServer(t, Config{
+		Authenticator: TokenAuthenticator{"a": "alice", "b": "bob"},
+		Quota: &Quota{
+			PerRequester: 2,
+			Subtrees:     []SubtreeLimit{{Prefix: []*big.Int{big.NewInt(7)}, Limit: 1}},
+		},
+	})
+	defer server.Close()
+
+	for _, request := range []struct {
+		token  string
+		id     []string
+		status int
+	}{
+		{"a", []string{"1"}, http.StatusOK},
+		{"a", []string{"1", "0"}, http.StatusBadRequest},
+		{"a", []string{"7", "1"}, http.StatusOK},
+		{"a", []string{"1"}, http.StatusTooManyRequests},
+		{"b", []string{"7", "2"}, http.StatusTooManyRequests},
+		{"b", []string{"1"}, http.StatusOK},
+	} {
+		if response := requestKey(t, server.URL, request.token, request.id); response.StatusCode != request.status {
+			t.Fatalf("Request for %v by %s got status %d", request.id, request.token, response.StatusCode)
+		}
+	}
+}
+
+func TestMemoryQuotaStore(t *testing.T) {
+	store := NewMemoryQuotaStore()
+	now := time.Now()
+	counters := []QuotaCounter{
+		{Key: "a", Limit: 2, Expires: now.Add(time.Minute)},
+		{Key: "b", Limit: 1},
+	}
+	if ok, err := store.Take(counters, now); err != nil || !ok {
+		t.Fatal("First take was refused")
+	}
+	if ok, _ := store.Take(counters, now); ok {
+		t.Fatal("Take exceeded a limit")
+	}
+	if ok, _ := store.Take(counters[:1], now); !ok {
+		t.Fatal("Refused take changed a counter")
+	}
+	if ok, _ := store.Take(counters[:1], now); ok {
+		t.Fatal("Take exceeded a limit")
+	}
+	if ok, _ := store.Take(counters[:1], now.Add(time.Minute)); !ok {
+		t.Fatal("Counter did not expire")
+	}
+}
@@ -0,0 +1,387 @@
+// Package pkgserver implements a Private Key Generator (PKG) service for a
+// HIBE hierarchy. The server holds the master key, authenticates requesters,
+// and issues private keys for the identities they are authorized to obtain,
+// with per-requester rate limiting and an audit log of every request.
+//
+// The API is JSON over HTTP(S), using the JSON encodings of the hibe_sm9
+// package:
+//
+//	GET  /v1/params         returns the public parameters
+//	GET  /v1/params/signed  returns {"bundle": <signed parameters>}
+//	POST /v1/keys           {"id": ["1", "2"]} returns {"key": <private key>}
+//
+// The signed bundle (see hibe_sm9.SignParams) is only served if the server
+// has a ParamsSigner.
+//
+// Identity components are decimal strings, and every request must be allowed
+// by the Authorizer of the server.
+package pkgserver
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"golang.org/x/crypto/bn256"
+	"hibe_sm9"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxRequestSize bounds the size of a key request body.
+const maxRequestSize = 1 << 16
+
+var (
+	// ErrUnauthenticated is returned by an Authenticator when the request
+	// carries no valid credentials.
+	ErrUnauthenticated = errors.New("pkgserver: unauthenticated")
+)
+
+// Authenticator identifies the requester of an HTTP request.
+type Authenticator interface {
+	Authenticate(r *http.Request) (requester string, err error)
+}
+
+// TokenAuthenticator authenticates requests by a bearer token in the
+// Authorization header. It maps tokens to requester names.
+type TokenAuthenticator map[string]string
+
+// Authenticate implements Authenticator.
+func (tokens TokenAuthenticator) Authenticate(r *http.Request) (string, error) {
+	const prefix = "Bearer "
+	header := r.Header.Get("Authorization")
+	if len(header) <= len(prefix) || header[:len(prefix)] != prefix {
+		return "", ErrUnauthenticated
+	}
+	presented := []byte(header[len(prefix):])
+
+	// Compare against every token, so the time taken does not depend on
+	// which token matched
+	requester := ""
+	for token, name := range tokens {
+		if subtle.ConstantTimeCompare([]byte(token), presented) == 1 {
+			requester = name
+		}
+	}
+	if requester == "" {
+		return "", ErrUnauthenticated
+	}
+	return requester, nil
+}
+
+// MTLSAuthenticator authenticates requests by the verified TLS client
+// certificate, using its subject common name as the requester name. The
+// server's tls.Config must set ClientAuth to tls.RequireAndVerifyClientCert.
+type MTLSAuthenticator struct{}
+
+// Authenticate implements Authenticator.
+func (MTLSAuthenticator) Authenticate(r *http.Request) (string, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return "", ErrUnauthenticated
+	}
+	name := r.TLS.VerifiedChains[0][0].Subject.CommonName
+	if name == "" {
+		return "", ErrUnauthenticated
+	}
+	return name, nil
+}
+
+// Authorizer decides whether a requester may obtain the key for id.
+type Authorizer func(requester string, id []*big.Int) bool
+
+// PrefixAuthorizer maps requester names to the identities whose keys they may
+// obtain, along with the keys of every identity below them. A key is enough
+// to derive the keys below it anyway, so granting an identity grants its
+// whole subtree.
+type PrefixAuthorizer map[string][][]*big.Int
+
+// Authorize is an Authorizer.
+func (prefixes PrefixAuthorizer) Authorize(requester string, id []*big.Int) bool {
+	for _, prefix := range prefixes[requester] {
+		if len(prefix) <= len(id) && hasPrefix(id, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Config configures a Server.
+type Config struct {
+	Params *hibe_sm9.Params
+	Master hibe_sm9.MasterKey
+
+	// ParamsSigner, if not nil, signs the parameters served at
+	// /v1/params/signed.
+	ParamsSigner ed25519.PrivateKey
+
+	Authenticator Authenticator
+	// Authorize is consulted for every key request, and is required: a
+	// server that let every authenticated requester obtain any key would
+	// hand out the top-level keys, from which every other key derives.
+	Authorize Authorizer
+
+	// Rate is the sustained number of keys per second each requester may
+	// obtain, and Burst the number it may obtain at once, which must be at
+	// least 1 when rate limiting is enabled. A zero Rate disables rate
+	// limiting.
+	Rate  float64
+	Burst int
+
+	// Quota, if not nil, caps the number of keys issued per requester and
+	// per subtree.
+	Quota *Quota
+
+	// AuditLog receives one line per key request. If nil, requests are not
+	// logged.
+	AuditLog io.Writer
+
+	// Auditor, if not nil, receives an event for every key request, with the
+	// reason for denied requests as the error.
+	Auditor hibe_sm9.Auditor
+
+	// Random is the source of randomness for key generation. If nil,
+	// crypto/rand is used.
+	Random io.Reader
+}
+
+// Server is an http.Handler implementing the PKG API.
+type Server struct {
+	config Config
+	audit  *log.Logger
+	mux    *http.ServeMux
+	bundle []byte
+
+	lock    sync.Mutex
+	buckets map[string]*bucket
+}
+
+// bucket is a token bucket for one requester.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// New creates a Server from config.
+func New(config Config) (*Server, error) {
+	if config.Params == nil || config.Master == nil || config.Authenticator == nil || config.Authorize == nil {
+		return nil, errors.New("pkgserver: params, master key, authenticator, and authorizer are required")
+	}
+	if config.Rate < 0 || config.Rate > 0 && config.Burst < 1 {
+		return nil, errors.New("pkgserver: rate limiting requires a positive rate and a burst of at least 1")
+	}
+	if config.Random == nil {
+		config.Random = rand.Reader
+	}
+	config.Params.Precache()
+	if config.Quota != nil && config.Quota.Store == nil {
+		quota := *config.Quota
+		quota.Store = NewMemoryQuotaStore()
+		config.Quota = &quota
+	}
+
+	server := &Server{
+		config:  config,
+		mux:     http.NewServeMux(),
+		buckets: make(map[string]*bucket),
+	}
+	if config.AuditLog != nil {
+		server.audit = log.New(config.AuditLog, "", log.LstdFlags|log.LUTC)
+	}
+	if config.ParamsSigner != nil {
+		bundle, err := hibe_sm9.SignParams(config.Params, config.ParamsSigner)
+		if err != nil {
+			return nil, err
+		}
+		server.bundle = bundle
+		server.mux.HandleFunc("/v1/params/signed", server.handleSignedParams)
+	}
+	server.mux.HandleFunc("/v1/params", server.handleParams)
+	server.mux.HandleFunc("/v1/keys", server.handleKeys)
+	return server, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server.mux.ServeHTTP(w, r)
+}
+
+// allow takes a token from the requester's bucket, if one is available.
+func (server *Server) allow(requester string) bool {
+	if server.config.Rate == 0 {
+		return true
+	}
+	server.lock.Lock()
+	defer server.lock.Unlock()
+
+	now := time.Now()
+	b, ok := server.buckets[requester]
+	if !ok {
+		b = &bucket{tokens: float64(server.config.Burst), last: now}
+		server.buckets[requester] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * server.config.Rate
+	if b.tokens > float64(server.config.Burst) {
+		b.tokens = float64(server.config.Burst)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+func (server *Server) logf(format string, args ...interface{}) {
+	if server.audit != nil {
+		server.audit.Printf(format, args...)
+	}
+}
+
+// Reasons reported to the Auditor for denied key requests.
+var (
+	errRateLimited = errors.New("pkgserver: rate limit exceeded")
+	errBadRequest  = errors.New("pkgserver: invalid identity")
+	errForbidden   = errors.New("pkgserver: forbidden")
+	errQuota       = errors.New("pkgserver: issuance quota exceeded")
+)
+
+// auditKeyGen reports a key request to the Auditor, if there is one.
+func (server *Server) auditKeyGen(id []*big.Int, requester string, err error) {
+	if server.config.Auditor != nil {
+		server.config.Auditor.OnKeyGen(id, requester, time.Now(), err)
+	}
+}
+
+// KeyRequest is the body of a key request.
+type KeyRequest struct {
+	ID []string `json:"id"`
+}
+
+// KeyResponse is the body of a successful key response.
+type KeyResponse struct {
+	Key *hibe_sm9.PrivateKey `json:"key"`
+}
+
+// SignedParamsResponse is the body of a signed parameters response.
+type SignedParamsResponse struct {
+	Bundle string `json:"bundle"`
+}
+
+// ParseID converts the decimal identity components of a KeyRequest, which
+// must lie in [1, Order) as hibe_sm9 requires.
+func ParseID(components []string) ([]*big.Int, error) {
+	if len(components) == 0 {
+		return nil, errors.New("pkgserver: empty identity")
+	}
+	id := make([]*big.Int, len(components))
+	for i, component := range components {
+		var ok bool
+		if id[i], ok = new(big.Int).SetString(component, 10); !ok {
+			return nil, errors.New("pkgserver: malformed identity component")
+		}
+		if id[i].Sign() <= 0 || id[i].Cmp(bn256.Order) >= 0 {
+			return nil, errors.New("pkgserver: identity component is not in [1, Order)")
+		}
+	}
+	return id, nil
+}
+
+// FormatID converts an identity to the decimal components of a KeyRequest.
+func FormatID(id []*big.Int) []string {
+	components := make([]string, len(id))
+	for i, component := range id {
+		components[i] = component.String()
+	}
+	return components
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
+
+func (server *Server) handleParams(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, server.config.Params)
+}
+
+func (server *Server) handleSignedParams(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, SignedParamsResponse{Bundle: string(server.bundle)})
+}
+
+func (server *Server) handleKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	requester, err := server.config.Authenticator.Authenticate(r)
+	if err != nil {
+		server.logf("remote=%s outcome=unauthenticated", r.RemoteAddr)
+		server.auditKeyGen(nil, "", ErrUnauthenticated)
+		http.Error(w, "unauthenticated", http.StatusUnauthorized)
+		return
+	}
+	if !server.allow(requester) {
+		server.logf("requester=%q outcome=rate-limited", requester)
+		server.auditKeyGen(nil, requester, errRateLimited)
+		http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+		return
+	}
+
+	var request KeyRequest
+	if err = json.NewDecoder(io.LimitReader(r.Body, maxRequestSize)).Decode(&request); err != nil {
+		server.auditKeyGen(nil, requester, errBadRequest)
+		http.Error(w, "malformed request", http.StatusBadRequest)
+		return
+	}
+	id, err := ParseID(request.ID)
+	if err != nil || len(id) > server.config.Params.MaximumDepth() {
+		server.logf("requester=%q id=%q outcome=bad-request", requester, request.ID)
+		server.auditKeyGen(id, requester, errBadRequest)
+		http.Error(w, "invalid identity", http.StatusBadRequest)
+		return
+	}
+	if !server.config.Authorize(requester, id) {
+		server.logf("requester=%q id=%q outcome=forbidden", requester, request.ID)
+		server.auditKeyGen(id, requester, errForbidden)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if ok, err := server.takeQuota(requester, id); err != nil || !ok {
+		if err == nil {
+			err = errQuota
+		}
+		server.logf("requester=%q id=%q outcome=quota-exceeded err=%q", requester, request.ID, err)
+		server.auditKeyGen(id, requester, err)
+		if err == errQuota {
+			http.Error(w, "quota exceeded", http.StatusTooManyRequests)
+		} else {
+			http.Error(w, "quota unavailable", http.StatusServiceUnavailable)
+		}
+		return
+	}
+
+	key, err := hibe_sm9.KeyGenFromMaster(server.config.Random, server.config.Params, server.config.Master, id)
+	if err != nil {
+		server.logf("requester=%q id=%q outcome=error err=%q", requester, request.ID, err)
+		server.auditKeyGen(id, requester, err)
+		http.Error(w, "key generation failed", http.StatusInternalServerError)
+		return
+	}
+	server.logf("requester=%q id=%q outcome=issued", requester, request.ID)
+	server.auditKeyGen(id, requester, nil)
+	writeJSON(w, KeyResponse{Key: key})
+}
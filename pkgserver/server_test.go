@@ -0,0 +1,224 @@
+package pkgserver
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"hibe_sm9"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestServer(t *testing.T, config Config) (*httptest.Server, *hibe_sm9.Params) {
+	params, master, err := hibe_sm9.Setup(rand.Reader, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Params = params
+	config.Master = master
+	if config.Authorize == nil {
+		config.Authorize = allowAll
+	}
+	server, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return httptest.NewServer(server), params
+}
+
+// allowAll authorizes every request, for tests that are not about
+// authorization.
+func allowAll(requester string, id []*big.Int) bool {
+	return true
+}
+
+func requestKey(t *testing.T, url string, token string, id []string) *http.Response {
+	body, err := json.Marshal(KeyRequest{ID: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	request, err := http.NewRequest(http.MethodPost, url+"/v1/keys", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Authorization", "Bearer "+token)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+// recordingAuditor records the outcomes of key requests.
+type recordingAuditor struct {
+	lock     sync.Mutex
+	outcomes []error
+}
+
+func (auditor *recordingAuditor) OnKeyGen(id []*big.Int, requester string, at time.Time, err error) {
+	auditor.lock.Lock()
+	defer auditor.lock.Unlock()
+	auditor.outcomes = append(auditor.outcomes, err)
+}
+
+func (auditor *recordingAuditor) OnDecrypt(id []*big.Int, requester string, at time.Time, err error) {
+}
+
+func TestIssueKey(t *testing.T) {
+	var audit bytes.Buffer
+	auditor := &recordingAuditor{}
+	server, params := newTestServer(t, Config{
+		Authenticator: TokenAuthenticator{"secret": "alice"},
+		Authorize: func(requester string, id []*big.Int) bool {
+			return id[0].Cmp(big.NewInt(1)) == 0
+		},
+		AuditLog: &audit,
+		Auditor:  auditor,
+	})
+	defer server.Close()
+
+	response := requestKey(t, server.URL, "secret", []string{"1", "2"})
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Unexpected status %d", response.StatusCode)
+	}
+	var issued KeyResponse
+	if err := json.NewDecoder(response.Body).Decode(&issued); err != nil {
+		t.Fatal(err)
+	}
+	if err := issued.Key.Validate(params); err != nil {
+		t.Fatal(err)
+	}
+
+	// The key decrypts messages for the requested identity
+	message := hibe_sm9.HashToGT([]byte("message"))
+	id := []*big.Int{big.NewInt(1), big.NewInt(2)}
+	ciphertext, err := hibe_sm9.Encrypt(rand.Reader, params, id, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := hibe_sm9.Decrypt(issued.Key, ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(message.Marshal(), decrypted.Marshal()) {
+		t.Fatal("Issued key does not decrypt")
+	}
+
+	if response := requestKey(t, server.URL, "wrong", []string{"1"}); response.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Unauthenticated request got status %d", response.StatusCode)
+	}
+	if response := requestKey(t, server.URL, "secret", []string{"2"}); response.StatusCode != http.StatusForbidden {
+		t.Fatalf("Unauthorized request got status %d", response.StatusCode)
+	}
+	if response := requestKey(t, server.URL, "secret", []string{"x"}); response.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Malformed request got status %d", response.StatusCode)
+	}
+	if response := requestKey(t, server.URL, "secret", []string{"1", "0"}); response.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Out-of-range request got status %d", response.StatusCode)
+	}
+
+	if !strings.Contains(audit.String(), `requester="alice" id=["1" "2"] outcome=issued`) {
+		t.Fatalf("Audit log is missing the issued key:\n%s", audit.String())
+	}
+	auditor.lock.Lock()
+	defer auditor.lock.Unlock()
+	expected := []error{nil, ErrUnauthenticated, errForbidden, errBadRequest, errBadRequest}
+	if len(auditor.outcomes) != len(expected) {
+		t.Fatalf("Auditor received %d events", len(auditor.outcomes))
+	}
+	for i, err := range expected {
+		if auditor.outcomes[i] != err {
+			t.Fatalf("Auditor received %v instead of %v", auditor.outcomes[i], err)
+		}
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	server, _ := newTestServer(t, Config{
+		Authenticator: TokenAuthenticator{"secret": "alice"},
+		Rate:          0.001,
+		Burst:         2,
+	})
+	defer server.Close()
+
+	for i := 0; i != 2; i++ {
+		if response := requestKey(t, server.URL, "secret", []string{"1"}); response.StatusCode != http.StatusOK {
+			t.Fatalf("Request within burst got status %d", response.StatusCode)
+		}
+	}
+	if response := requestKey(t, server.URL, "secret", []string{"1"}); response.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("Request beyond burst got status %d", response.StatusCode)
+	}
+}
+
+func TestRateLimitConfig(t *testing.T) {
+	params, master, err := hibe_sm9.Setup(rand.Reader, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := Config{
+		Params:        params,
+		Master:        master,
+		Authenticator: TokenAuthenticator{"secret": "alice"},
+		Authorize:     PrefixAuthorizer{}.Authorize,
+		Rate:          1,
+	}
+	if _, err = New(config); err == nil {
+		t.Fatal("Server created with a rate limit and no burst")
+	}
+	config.Rate = -1
+	config.Burst = 1
+	if _, err = New(config); err == nil {
+		t.Fatal("Server created with a negative rate")
+	}
+}
+
+func TestAuthorizeRequired(t *testing.T) {
+	params, master, err := hibe_sm9.Setup(rand.Reader, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = New(Config{Params: params, Master: master, Authenticator: TokenAuthenticator{"secret": "alice"}})
+	if err == nil {
+		t.Fatal("Server created without an authorizer")
+	}
+}
+
+func TestPrefixAuthorizer(t *testing.T) {
+	authorizer := PrefixAuthorizer{"alice": {{big.NewInt(1), big.NewInt(2)}}}
+	for _, test := range []struct {
+		requester string
+		id        []*big.Int
+		allowed   bool
+	}{
+		{"alice", []*big.Int{big.NewInt(1), big.NewInt(2)}, true},
+		{"alice", []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(3)}, true},
+		{"alice", []*big.Int{big.NewInt(1)}, false},
+		{"alice", []*big.Int{big.NewInt(1), big.NewInt(3)}, false},
+		{"bob", []*big.Int{big.NewInt(1), big.NewInt(2)}, false},
+	} {
+		if authorizer.Authorize(test.requester, test.id) != test.allowed {
+			t.Fatalf("Request by %s for %v was not decided correctly", test.requester, test.id)
+		}
+	}
+}
+
+func TestAuditLogQuoting(t *testing.T) {
+	var audit bytes.Buffer
+	server, _ := newTestServer(t, Config{
+		Authenticator: TokenAuthenticator{"secret": "alice"},
+		AuditLog:      &audit,
+	})
+	defer server.Close()
+
+	requestKey(t, server.URL, "secret", []string{"1\n2023/01/01 00:00:00 requester=\"mallory\" outcome=issued"})
+	if strings.Count(audit.String(), "\n") != 1 {
+		t.Fatalf("Request injected lines into the audit log:\n%s", audit.String())
+	}
+}
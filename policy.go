@@ -0,0 +1,174 @@
+package hibe_sm9
+
+import (
+	"errors"
+	"golang.org/x/crypto/bn256"
+	"math/big"
+)
+
+// ErrDelegationDenied is returned by KeyGenFromParent when the delegation
+// policy of the parent key does not allow the requested child.
+var ErrDelegationDenied = errors.New("hibe: delegation denied by key policy")
+
+// DelegationPolicy restricts which descendants a private key can generate
+// keys for. It is attached to a key with Restrict.
+//
+// MaxDepth is enforced cryptographically: Restrict discards the delegation
+// components of the key beyond MaxDepth levels, so even the raw key material
+// cannot produce deeper keys. A key restricted to MaxDepth 0 can decrypt but
+// can neither delegate nor sign.
+//
+// Subtrees is enforced by this package only. If it is non-empty, each
+// generated key must be for an identity that lies on the path to, or below,
+// one of the listed identities (which are full identities, starting at the
+// root). The policy is not part of the key encodings, and children inherit
+// it from their parent.
+type DelegationPolicy struct {
+	MaxDepth int
+	Subtrees [][]*big.Int
+
+	// Policy of the key that was restricted, which still applies.
+	outer *DelegationPolicy
+}
+
+// allows reports whether the policy allows a key to be generated for id.
+func (policy *DelegationPolicy) allows(id []*big.Int) bool {
+	for ; policy != nil; policy = policy.outer {
+		if len(policy.Subtrees) == 0 {
+			continue
+		}
+		allowed := false
+		for _, subtree := range policy.Subtrees {
+			if idsAgree(id, subtree) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
+
+// idsAgree reports whether one of a and b is a prefix of the other.
+func idsAgree(a []*big.Int, b []*big.Int) bool {
+	for i := 0; i != len(a) && i != len(b); i++ {
+		if a[i].Cmp(b[i]) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Restrict returns a copy of the private key, restricted by policy. If the key
+// is already restricted, both policies apply to the result. The original key
+// is left unchanged.
+func (privkey *PrivateKey) Restrict(policy DelegationPolicy) *PrivateKey {
+	if policy.MaxDepth < 0 {
+		policy.MaxDepth = 0
+	}
+	if policy.MaxDepth > privkey.DepthLeft() {
+		policy.MaxDepth = privkey.DepthLeft()
+	}
+	policy.outer = privkey.Policy
+
+	key := &PrivateKey{Policy: &policy, ParamsFingerprint: privkey.ParamsFingerprint}
+	if policy.MaxDepth < privkey.levelsBelow() {
+		key.levels = privkey.levelsBelow()
+	}
+	if privkey.A0 != nil {
+		key.A0 = deepClone(privkey.A0)
+	}
+	if privkey.A1 != nil {
+		key.A1 = deepCloneG2(privkey.A1)
+	}
+	if privkey.A1Hat != nil {
+		key.A1Hat = deepClone(privkey.A1Hat)
+	}
+	if privkey.B != nil {
+		key.B = make([]*bn256.G1, policy.MaxDepth)
+		for j := range key.B {
+			key.B[j] = deepClone(privkey.B[j])
+		}
+	}
+	return key
+}
+
+// isKeyAtDepth reports whether the private key can be the key of an identity
+// with k components. Restricted keys, and keys issued before the hierarchy was
+// extended with ExtendDepth, have fewer delegation components than their depth
+// implies, so only an upper bound can be checked.
+func (privkey *PrivateKey) isKeyAtDepth(params *Params, k int) bool {
+	return privkey.DepthLeft() <= params.MaximumDepth()-k
+}
+
+// isParentAtDepth reports whether the private key is the key of an identity
+// with exactly k components, as keys must be to delegate: a deeper key would
+// produce child keys that cannot decrypt. Restricted keys are checked against
+// the number of levels below them that Restrict recorded. Keys issued before
+// the hierarchy was extended with ExtendDepth are rejected, and must delegate
+// with the parameters they were issued under.
+func (privkey *PrivateKey) isParentAtDepth(params *Params, k int) bool {
+	return privkey.levelsBelow() == params.MaximumDepth()-k
+}
+
+// levelsBelow returns the number of levels below the key in its hierarchy,
+// counting those that Restrict dropped the delegation components for.
+func (privkey *PrivateKey) levelsBelow() int {
+	if privkey.levels != 0 {
+		return privkey.levels
+	}
+	return privkey.DepthLeft()
+}
+
+// PolicyChecker decides whether a key may be issued for an identity. It is
+// consulted by KeyGenFromMaster, KeyGenFromMasterOp and KeyGenFromParent when
+// passed with WithPolicyChecker; the policy package implements it with rules
+// set by an administrator.
+type PolicyChecker interface {
+	CheckIssue(id []*big.Int) error
+}
+
+// KeyGenOption configures KeyGenFromMaster, KeyGenFromMasterOp and
+// KeyGenFromParent.
+type KeyGenOption func(*keyGenConfig)
+
+type keyGenConfig struct {
+	checkers []PolicyChecker
+	record   *DelegationRecord
+	leafOnly bool
+}
+
+// keyGenOptions applies the key generation options.
+func keyGenOptions(opts []KeyGenOption) keyGenConfig {
+	var config keyGenConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return config
+}
+
+// WithPolicyChecker makes key generation fail with ErrDelegationDenied if
+// checker does not allow the identity. The option can be given more than
+// once, and then every checker must allow the identity.
+func WithPolicyChecker(checker PolicyChecker) KeyGenOption {
+	return func(config *keyGenConfig) {
+		config.checkers = append(config.checkers, checker)
+	}
+}
+
+// checkIssue applies the key generation options for id.
+func checkIssue(id []*big.Int, opts []KeyGenOption) error {
+	for _, checker := range keyGenOptions(opts).checkers {
+		err := checker.CheckIssue(id)
+		if err == nil {
+			continue
+		}
+		if errors.Is(err, ErrDelegationDenied) {
+			return err
+		}
+		return wrapError(ErrDelegationDenied, err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+// Package policy lets administrators of a hierarchy define the rules under
+// which keys are issued: how deep each subtree may go, which names are
+// allowed at each level, and until when keys for a level may be issued. A
+// Checker evaluates the rules, and is passed to key generation with
+// hibe_sm9.WithPolicyChecker:
+//
+//	checker := policy.NewChecker(policy.Rule{
+//		Subtree:  []*big.Int{engineering},
+//		MaxDepth: 3,
+//	})
+//	key, err := hibe_sm9.KeyGenFromParent(rand.Reader, params, parent, id,
+//		hibe_sm9.WithPolicyChecker(checker))
+//
+// The rules are enforced by the issuer only: a key holder that does not use
+// the checker can still delegate anything its key allows. Use
+// PrivateKey.Restrict to limit what the holder of a key can derive.
+package policy
+
+import (
+	"fmt"
+	"hibe_sm9"
+	"math/big"
+	"regexp"
+	"time"
+)
+
+var (
+	// ErrTooDeep is returned for identities below the maximum depth of a
+	// rule.
+	ErrTooDeep = fmt.Errorf("policy: identity is too deep: %w", hibe_sm9.ErrDelegationDenied)
+
+	// ErrName is returned for identities with a component whose name does
+	// not match the pattern of a rule.
+	ErrName = fmt.Errorf("policy: identity component has a forbidden name: %w", hibe_sm9.ErrDelegationDenied)
+
+	// ErrExpired is returned for identities at a level whose keys may no
+	// longer be issued.
+	ErrExpired = fmt.Errorf("policy: keys for the level have expired: %w", hibe_sm9.ErrDelegationDenied)
+)
+
+// Rule constrains the identities in a subtree of the hierarchy. Levels are
+// counted from 1 for the top level, so the level of an identity is its
+// length.
+type Rule struct {
+	// Subtree is the identity at the root of the subtree the rule applies
+	// to, including that identity itself. An empty Subtree applies the rule
+	// to the whole hierarchy.
+	Subtree []*big.Int
+
+	// MaxDepth, if positive, is the deepest level at which keys are issued
+	// in the subtree.
+	MaxDepth int
+
+	// Names maps levels to the pattern that the names of components at that
+	// level must match. Patterns match anywhere in the name unless anchored
+	// with ^ and $.
+	Names map[int]*regexp.Regexp
+
+	// Expiry maps levels to the time from which keys at that level are no
+	// longer issued in the subtree.
+	Expiry map[int]time.Time
+}
+
+// applies reports whether the rule applies to id.
+func (rule *Rule) applies(id []*big.Int) bool {
+	if len(id) < len(rule.Subtree) {
+		return false
+	}
+	for i, component := range rule.Subtree {
+		if id[i].Cmp(component) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Checker evaluates a set of rules for key issuance. Every rule that applies
+// to an identity must allow it. A Checker implements hibe_sm9.PolicyChecker,
+// and is safe for concurrent use as long as its fields are not modified.
+type Checker struct {
+	Rules []Rule
+
+	// Name returns the name of the component of an identity at level, which
+	// is matched against the patterns of the rules. If nil, the decimal form
+	// of the component is used, as in the identities of pkgserver.
+	Name func(level int, component *big.Int) string
+
+	// Now returns the current time, against which expiry is checked. If nil,
+	// time.Now is used.
+	Now func() time.Time
+}
+
+// NewChecker returns a Checker for rules.
+func NewChecker(rules ...Rule) *Checker {
+	return &Checker{Rules: rules}
+}
+
+// CheckIssue returns an error wrapping hibe_sm9.ErrDelegationDenied, and one
+// of ErrTooDeep, ErrName or ErrExpired, if a rule does not allow a key to be
+// issued for id.
+func (checker *Checker) CheckIssue(id []*big.Int) error {
+	now := time.Now
+	if checker.Now != nil {
+		now = checker.Now
+	}
+	level := len(id)
+	for i := range checker.Rules {
+		rule := &checker.Rules[i]
+		if !rule.applies(id) {
+			continue
+		}
+		if rule.MaxDepth > 0 && level > rule.MaxDepth {
+			return fmt.Errorf("%w (level %d, maximum %d)", ErrTooDeep, level, rule.MaxDepth)
+		}
+		for j := len(rule.Subtree); j < level; j++ {
+			pattern, ok := rule.Names[j+1]
+			if !ok {
+				continue
+			}
+			if name := checker.name(j+1, id[j]); !pattern.MatchString(name) {
+				return fmt.Errorf("%w (%q at level %d)", ErrName, name, j+1)
+			}
+		}
+		if expiry, ok := rule.Expiry[level]; ok && !now().Before(expiry) {
+			return fmt.Errorf("%w (level %d, since %s)", ErrExpired, level, expiry.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+func (checker *Checker) name(level int, component *big.Int) string {
+	if checker.Name != nil {
+		return checker.Name(level, component)
+	}
+	return component.String()
+}
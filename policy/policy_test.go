@@ -0,0 +1,113 @@
+package policy
+
+import (
+	"crypto/rand"
+	"errors"
+	"hibe_sm9"
+	"math/big"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func id(components ...int64) []*big.Int {
+	result := make([]*big.Int, len(components))
+	for i, component := range components {
+		result[i] = big.NewInt(component)
+	}
+	return result
+}
+
+func TestMaxDepth(t *testing.T) {
+	checker := NewChecker(Rule{Subtree: id(1), MaxDepth: 2})
+	if err := checker.CheckIssue(id(1, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := checker.CheckIssue(id(1, 2, 3)); !errors.Is(err, ErrTooDeep) || !errors.Is(err, hibe_sm9.ErrDelegationDenied) {
+		t.Fatal("Identity below the maximum depth was allowed")
+	}
+	if err := checker.CheckIssue(id(2, 2, 3)); err != nil {
+		t.Fatal("Rule applied outside its subtree")
+	}
+}
+
+func TestNames(t *testing.T) {
+	checker := NewChecker(Rule{
+		Subtree: id(7),
+		Names:   map[int]*regexp.Regexp{2: regexp.MustCompile(`^1[0-9]$`)},
+	})
+	if err := checker.CheckIssue(id(7, 12, 99)); err != nil {
+		t.Fatal(err)
+	}
+	if err := checker.CheckIssue(id(7, 21)); !errors.Is(err, ErrName) {
+		t.Fatal("Component with a forbidden name was allowed")
+	}
+	if err := checker.CheckIssue(id(7)); err != nil {
+		t.Fatal("Pattern applied to the subtree root")
+	}
+
+	checker.Name = func(level int, component *big.Int) string {
+		return map[int64]string{12: "alice", 21: "mallory"}[component.Int64()]
+	}
+	checker.Rules[0].Names[2] = regexp.MustCompile(`^[a-l]`)
+	if err := checker.CheckIssue(id(7, 12)); err != nil {
+		t.Fatal(err)
+	}
+	if err := checker.CheckIssue(id(7, 21)); !errors.Is(err, ErrName) {
+		t.Fatal("Name function was not used")
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	expiry := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := expiry.Add(-time.Second)
+	checker := NewChecker(Rule{Expiry: map[int]time.Time{2: expiry}})
+	checker.Now = func() time.Time { return now }
+
+	if err := checker.CheckIssue(id(1, 2)); err != nil {
+		t.Fatal(err)
+	}
+	now = expiry
+	if err := checker.CheckIssue(id(1, 2)); !errors.Is(err, ErrExpired) {
+		t.Fatal("Key for an expired level was allowed")
+	}
+	if err := checker.CheckIssue(id(1)); err != nil {
+		t.Fatal("Expiry applied to another level")
+	}
+}
+
+func TestAllRulesApply(t *testing.T) {
+	checker := NewChecker(Rule{MaxDepth: 3}, Rule{Subtree: id(1, 2), MaxDepth: 2})
+	if err := checker.CheckIssue(id(1, 3, 4)); err != nil {
+		t.Fatal(err)
+	}
+	if err := checker.CheckIssue(id(1, 2, 4)); !errors.Is(err, ErrTooDeep) {
+		t.Fatal("Narrower rule was not applied")
+	}
+	if err := checker.CheckIssue(id(1, 3, 4, 5)); !errors.Is(err, ErrTooDeep) {
+		t.Fatal("Global rule was not applied")
+	}
+}
+
+func TestKeyGen(t *testing.T) {
+	params, master, err := hibe_sm9.Setup(rand.Reader, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	option := hibe_sm9.WithPolicyChecker(NewChecker(Rule{Subtree: id(1), MaxDepth: 2}))
+
+	parent, err := hibe_sm9.KeyGenFromMaster(rand.Reader, params, master, id(1), option)
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := hibe_sm9.KeyGenFromParent(rand.Reader, params, parent, id(1, 2), option)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = hibe_sm9.KeyGenFromParent(rand.Reader, params, child, id(1, 2, 3), option); !errors.Is(err, ErrTooDeep) {
+		t.Fatal("KeyGenFromParent ignored the policy")
+	}
+	if _, err = hibe_sm9.KeyGenFromMaster(rand.Reader, params, master, id(1, 2, 3), option); !errors.Is(err, ErrTooDeep) {
+		t.Fatal("KeyGenFromMaster ignored the policy")
+	}
+}
@@ -0,0 +1,126 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestDelegationPolicy(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	org := []*big.Int{big.NewInt(1)}
+	sales := []*big.Int{big.NewInt(1), big.NewInt(2)}
+	alice := []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(3)}
+	support := []*big.Int{big.NewInt(1), big.NewInt(4)}
+
+	orgkey, err := KeyGenFromMaster(rand.Reader, params, master, org)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restricted := orgkey.Restrict(DelegationPolicy{MaxDepth: 2, Subtrees: [][]*big.Int{sales}})
+	if restricted.DepthLeft() != 2 || orgkey.DepthLeft() != 3 {
+		t.Fatal("Restrict did not truncate a copy of the key")
+	}
+
+	// The restricted key can delegate within its subtree, down to MaxDepth
+	saleskey, err := KeyGenFromParent(rand.Reader, params, restricted, sales)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alicekey, err := KeyGenFromParent(rand.Reader, params, saleskey, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alicekey.DepthLeft() != 0 {
+		t.Fatal("Delegated key is deeper than the policy allows")
+	}
+	_, err = KeyGenFromParent(rand.Reader, params, alicekey, append(alice, big.NewInt(5)))
+	if err != ErrDelegationDenied {
+		t.Fatal("Delegated beyond the maximum depth")
+	}
+
+	// but not outside it
+	if _, err = KeyGenFromParent(rand.Reader, params, restricted, support); err != ErrDelegationDenied {
+		t.Fatal("Delegated outside the allowed subtrees")
+	}
+
+	// and the keys it issues still decrypt
+	message := NewMessage()
+	ciphertext, err := Encrypt(rand.Reader, params, alice, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(message.Marshal(), mustDecrypt(t, alicekey, ciphertext).Marshal()) {
+		t.Fatal("Key issued under a policy does not decrypt")
+	}
+
+	// Restricting further keeps the outer policy
+	narrowed := restricted.Restrict(DelegationPolicy{MaxDepth: 2})
+	if _, err = KeyGenFromParent(rand.Reader, params, narrowed, support); err != ErrDelegationDenied {
+		t.Fatal("Narrowed key escaped the outer policy")
+	}
+	if narrowed.Restrict(DelegationPolicy{MaxDepth: 0}).DepthLeft() != 0 {
+		t.Fatal("Could not remove delegation entirely")
+	}
+}
+
+func TestKeyGenFromParentDepth(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sales := []*big.Int{big.NewInt(1), big.NewInt(2)}
+	saleskey, err := KeyGenFromMaster(rand.Reader, params, master, sales)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A key deeper than the parent of the identity is rejected, restricted
+	// or not
+	support := []*big.Int{big.NewInt(1), big.NewInt(4)}
+	for _, parent := range []*PrivateKey{saleskey, saleskey.Restrict(DelegationPolicy{MaxDepth: 1})} {
+		if _, err = KeyGenFromParent(rand.Reader, params, parent, support); !errors.Is(err, ErrInvalidID) {
+			t.Fatal("Generated a key from a parent that is too deep")
+		}
+	}
+}
+
+// denyBelow is a PolicyChecker that denies identities deeper than its value.
+type denyBelow int
+
+func (depth denyBelow) CheckIssue(id []*big.Int) error {
+	if len(id) > int(depth) {
+		return errors.New("too deep")
+	}
+	return nil
+}
+
+func TestPolicyChecker(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	option := WithPolicyChecker(denyBelow(2))
+
+	parent, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[:2], option)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = KeyGenFromParent(rand.Reader, params, parent, LINEAR_HIERARCHY, option); !errors.Is(err, ErrDelegationDenied) {
+		t.Fatal("KeyGenFromParent ignored the checker")
+	}
+	if _, err = KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY, option); !errors.Is(err, ErrDelegationDenied) {
+		t.Fatal("KeyGenFromMaster ignored the checker")
+	}
+	if _, err = KeyGenFromMasterOp(rand.Reader, params, SoftwareMasterKey{master}, LINEAR_HIERARCHY, WithPolicyChecker(denyBelow(3)), option); err == nil {
+		t.Fatal("Not every checker was consulted")
+	}
+	if _, err = KeyGenFromParent(rand.Reader, params, parent, LINEAR_HIERARCHY); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,237 @@
+package hibe_sm9
+
+import (
+	"golang.org/x/crypto/bn256"
+	"math/big"
+)
+
+// tableWindow is the number of scalar bits handled by each row of a
+// precomputed table, and tableRows the number of rows needed to cover a
+// scalar modulo bn256.Order.
+const (
+	tableWindow = 6
+	tableRows   = (256 + tableWindow - 1) / tableWindow
+)
+
+// Precomputed tables for fixed-base multiplication. Row i of the table for a
+// base P holds j * 2^(tableWindow*i) * P for j = 1 ... 2^tableWindow - 1, so a
+// multiple of P is a sum of one entry per row, with no doublings.
+type (
+	g1Table [][]*bn256.G1
+	g2Table [][]*bn256.G2
+	gtTable [][]*bn256.GT
+)
+
+// precomputed holds the tables built by Params.Precompute.
+type precomputed struct {
+	g       g2Table
+	pairing gtTable
+	g3      g1Table
+	h       []g1Table
+
+	// Tables for G3Hat and HHat, in anonymous hierarchies
+	g3Hat g2Table
+	hHat  []g2Table
+}
+
+// digit returns the i-th window of k.
+func digit(k *big.Int, i int) int {
+	d := 0
+	for b := tableWindow - 1; b >= 0; b-- {
+		d = d<<1 | int(k.Bit(tableWindow*i+b))
+	}
+	return d
+}
+
+func newG1Table(base *bn256.G1) g1Table {
+	table := make(g1Table, tableRows)
+	current := deepClone(base)
+	for i := range table {
+		row := make([]*bn256.G1, 1<<tableWindow)
+		row[1] = current
+		for j := 2; j < len(row); j++ {
+			row[j] = new(bn256.G1).Add(row[j-1], current)
+		}
+		table[i] = row
+		current = new(bn256.G1).Add(row[len(row)-1], current)
+	}
+	return table
+}
+
+func (table g1Table) mult(k *big.Int) *bn256.G1 {
+	return table.multAdd(nil, k)
+}
+
+// multAdd adds k times the base of the table to acc, in place, and returns
+// acc. If acc is nil, a new element is returned instead. Accumulating in place
+// saves the temporary element, and the addition of the identity it would
+// start from, for each base in a product.
+func (table g1Table) multAdd(acc *bn256.G1, k *big.Int) *bn256.G1 {
+	reduced := new(big.Int).Mod(k, bn256.Order)
+	defer zeroizeScalar(reduced)
+	for i, row := range table {
+		d := digit(reduced, i)
+		switch {
+		case d == 0:
+		case acc == nil:
+			// Copy the entry, without disturbing the shared table
+			acc = new(bn256.G1).Neg(row[d])
+			acc.Neg(acc)
+		default:
+			acc.Add(acc, row[d])
+		}
+	}
+	if acc == nil {
+		acc = new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+	}
+	return acc
+}
+
+func newG2Table(base *bn256.G2) g2Table {
+	table := make(g2Table, tableRows)
+	current := deepCloneG2(base)
+	for i := range table {
+		row := make([]*bn256.G2, 1<<tableWindow)
+		row[1] = current
+		for j := 2; j < len(row); j++ {
+			row[j] = new(bn256.G2).Add(row[j-1], current)
+		}
+		table[i] = row
+		current = new(bn256.G2).Add(row[len(row)-1], current)
+	}
+	return table
+}
+
+func (table g2Table) mult(k *big.Int) *bn256.G2 {
+	return table.multAdd(new(bn256.G2).ScalarBaseMult(big.NewInt(0)), k)
+}
+
+// multAdd adds k times the base of the table to acc, in place, and returns
+// acc. G2 has no cheap copy, so unlike g1Table.multAdd, acc must not be nil.
+func (table g2Table) multAdd(acc *bn256.G2, k *big.Int) *bn256.G2 {
+	reduced := new(big.Int).Mod(k, bn256.Order)
+	defer zeroizeScalar(reduced)
+	for i, row := range table {
+		if d := digit(reduced, i); d != 0 {
+			acc.Add(acc, row[d])
+		}
+	}
+	return acc
+}
+
+func newGTTable(base *bn256.GT) gtTable {
+	table := make(gtTable, tableRows)
+	current := new(bn256.GT).Add(base, gtOne)
+	for i := range table {
+		row := make([]*bn256.GT, 1<<tableWindow)
+		row[1] = current
+		for j := 2; j < len(row); j++ {
+			row[j] = new(bn256.GT).Add(row[j-1], current)
+		}
+		table[i] = row
+		current = new(bn256.GT).Add(row[len(row)-1], current)
+	}
+	return table
+}
+
+func (table gtTable) mult(k *big.Int) *bn256.GT {
+	k = new(big.Int).Mod(k, bn256.Order)
+	defer zeroizeScalar(k)
+	result := new(bn256.GT).Add(gtOne, gtOne)
+	for i, row := range table {
+		if d := digit(k, i); d != 0 {
+			result.Add(result, row[d])
+		}
+	}
+	return result
+}
+
+// Precompute builds tables for the fixed bases that Encrypt, KeyGenFromMaster
+// and KeyGenFromParent multiply (g, e(g2, g1), g3 and h1 ... hl, and their
+// mirrors in anonymous hierarchies), which makes those operations several times
+// faster at the cost of a few thousand precomputed elements per base. Like
+// Precache, which it implies, it must be called before the parameters are
+// used concurrently.
+//
+// Table lookups depend on the digits of the scalar, so hardened builds do not
+// use the tables for secret scalars.
+func (params *Params) Precompute() {
+	params.Precache()
+	tables := &precomputed{
+		g:       newG2Table(params.G),
+		pairing: newGTTable(params.Pairing),
+		g3:      newG1Table(params.G3),
+		h:       make([]g1Table, len(params.H)),
+	}
+	for i, hi := range params.H {
+		tables.h[i] = newG1Table(hi)
+	}
+	if params.Anonymous() {
+		tables.g3Hat = newG2Table(params.G3Hat)
+		tables.hHat = make([]g2Table, len(params.HHat))
+		for i, hi := range params.HHat {
+			tables.hHat[i] = newG2Table(hi)
+		}
+	}
+	params.tables = tables
+}
+
+// useTables reports whether the fast paths may be used.
+func (params *Params) useTables() bool {
+	return params.tables != nil && !hardened
+}
+
+// idProductPower computes (g3 * h1^id1 * ... * hk^idk)^s, for a secret s.
+func idProductPower(params *Params, id []*big.Int, s *big.Int) (*bn256.G1, error) {
+	if !params.useTables() {
+		return secretMultG1(idProduct(params, id), s)
+	}
+	result := params.tables.g3.mult(s)
+	exponent := new(big.Int)
+	defer zeroizeScalar(exponent)
+	for i := range id {
+		exponent.Mul(id[i], s)
+		params.tables.h[i].multAdd(result, exponent)
+	}
+	return result, nil
+}
+
+// idProductHatPower is the mirror of idProductPower in G2.
+func idProductHatPower(params *Params, id []*big.Int, s *big.Int) (*bn256.G2, error) {
+	if !params.useTables() {
+		return secretMultG2(idProductHat(params, id), s)
+	}
+	result := params.tables.g3Hat.mult(s)
+	exponent := new(big.Int)
+	defer zeroizeScalar(exponent)
+	for i := range id {
+		exponent.Mul(id[i], s)
+		params.tables.hHat[i].multAdd(result, exponent)
+	}
+	return result, nil
+}
+
+// powerG computes g^s for a secret s.
+func powerG(params *Params, s *big.Int) (*bn256.G2, error) {
+	if !params.useTables() {
+		return secretMultG2(params.G, s)
+	}
+	return params.tables.g.mult(s), nil
+}
+
+// powerH computes hi^s for a secret s.
+func powerH(params *Params, i int, s *big.Int) (*bn256.G1, error) {
+	if !params.useTables() {
+		return secretMultG1(params.H[i], s)
+	}
+	return params.tables.h[i].mult(s), nil
+}
+
+// powerPairing computes e(g2, g1)^s for a secret s. The pairing must already
+// be cached.
+func powerPairing(params *Params, s *big.Int) (*bn256.GT, error) {
+	if !params.useTables() {
+		return secretMultGT(params.Pairing, s)
+	}
+	return params.tables.pairing.mult(s), nil
+}
@@ -0,0 +1,125 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"golang.org/x/crypto/bn256"
+	"math/big"
+	"testing"
+)
+
+func TestTables(t *testing.T) {
+	_, g1, err := bn256.RandomG1(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, g2, err := bn256.RandomG2(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gt := pair(g1, g2)
+	table1, table2, tableT := newG1Table(g1), newG2Table(g2), newGTTable(gt)
+
+	scalars := []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(16), new(big.Int).Sub(bn256.Order, big.NewInt(1))}
+	for i := 0; i != 5; i++ {
+		k, err := rand.Int(rand.Reader, bn256.Order)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scalars = append(scalars, k)
+	}
+	for _, k := range scalars {
+		if !bytes.Equal(table1.mult(k).Marshal(), new(bn256.G1).ScalarMult(g1, k).Marshal()) {
+			t.Fatalf("G1 table gives the wrong multiple for %v", k)
+		}
+		if !bytes.Equal(table2.mult(k).Marshal(), new(bn256.G2).ScalarMult(g2, k).Marshal()) {
+			t.Fatalf("G2 table gives the wrong multiple for %v", k)
+		}
+		if !bytes.Equal(tableT.mult(k).Marshal(), new(bn256.GT).ScalarMult(gt, k).Marshal()) {
+			t.Fatalf("GT table gives the wrong power for %v", k)
+		}
+	}
+}
+
+func TestTableMultAdd(t *testing.T) {
+	base := new(bn256.G1).ScalarBaseMult(big.NewInt(7))
+	table := newG1Table(base)
+	identity := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+
+	if !bytes.Equal(table.multAdd(nil, big.NewInt(0)).Marshal(), identity.Marshal()) {
+		t.Fatal("Zero multiple is not the identity")
+	}
+	if !bytes.Equal(table.multAdd(nil, bn256.Order).Marshal(), identity.Marshal()) {
+		t.Fatal("Multiple by the group order is not the identity")
+	}
+
+	k := new(big.Int).Lsh(big.NewInt(1), 200)
+	k.Add(k, big.NewInt(99))
+	acc := new(bn256.G1).ScalarBaseMult(big.NewInt(5))
+	expected := new(bn256.G1).ScalarMult(base, k)
+	expected.Add(expected, new(bn256.G1).ScalarBaseMult(big.NewInt(5)))
+	if !bytes.Equal(table.multAdd(acc, k).Marshal(), expected.Marshal()) {
+		t.Fatal("Table multiple was not added to the accumulator")
+	}
+	if !bytes.Equal(table[0][1].Marshal(), base.Marshal()) {
+		t.Fatal("Table was modified")
+	}
+}
+
+func TestPrecompute(t *testing.T) {
+	for _, anonymous := range []bool{false, true} {
+		var opts []SetupOption
+		if anonymous {
+			opts = append(opts, WithAnonymity())
+		}
+		params, master, err := Setup(rand.Reader, 10, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		params.Precompute()
+
+		key, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY)
+		if err != nil {
+			t.Fatal(err)
+		}
+		message := NewMessage()
+		ciphertext, err := Encrypt(rand.Reader, params, LINEAR_HIERARCHY, message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(message.Marshal(), mustDecrypt(t, key, ciphertext).Marshal()) {
+			t.Fatal("Precomputed parameters produce keys or ciphertexts that do not decrypt")
+		}
+		if err = key.Validate(params); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncryptPrecomputed(b *testing.B) {
+	for _, depth := range benchmarkDepths {
+		b.Run(fmt.Sprintf("depth=%d", depth), func(b *testing.B) {
+			params, _, id, _, _ := benchmarkHierarchy(b, depth)
+			params.Precompute()
+			message := NewMessage()
+			benchmarkOperation(b, func() error {
+				_, err := Encrypt(rand.Reader, params, id, message)
+				return err
+			})
+		})
+	}
+}
+
+func BenchmarkKeyGenFromMasterPrecomputed(b *testing.B) {
+	for _, depth := range benchmarkDepths {
+		b.Run(fmt.Sprintf("depth=%d", depth), func(b *testing.B) {
+			params, master, id, _, _ := benchmarkHierarchy(b, depth)
+			params.Precompute()
+			benchmarkOperation(b, func() error {
+				_, err := KeyGenFromMaster(rand.Reader, params, master, id)
+				return err
+			})
+		})
+	}
+}
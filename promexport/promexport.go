@@ -0,0 +1,125 @@
+// Package promexport exports the metrics of package hibe_sm9 in the
+// Prometheus text format, without depending on the Prometheus client library:
+//
+//	exporter := promexport.New()
+//	hibe_sm9.SetMetrics(exporter)
+//	http.Handle("/metrics", exporter)
+//
+// It exports
+//
+//	hibe_operations_total{operation, outcome}  counter
+//	hibe_operation_duration_seconds{operation} histogram
+//	hibe_pairings_total                        counter
+//
+// where outcome is "ok" or "error".
+package promexport
+
+import (
+	"fmt"
+	"hibe_sm9"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of the latency histogram
+// buckets. Pairing-based operations take from a few hundred microseconds to
+// tens of milliseconds.
+var DefaultBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 1}
+
+// Exporter is a hibe_sm9.Metrics that serves the collected metrics over HTTP.
+type Exporter struct {
+	buckets []float64
+
+	lock       sync.Mutex
+	operations map[hibe_sm9.Operation]*operationStats
+}
+
+type operationStats struct {
+	ok, failed uint64
+	counts     []uint64
+	sum        float64
+}
+
+// New creates an Exporter with DefaultBuckets, or with the given bucket upper
+// bounds in seconds, which must be increasing.
+func New(buckets ...float64) *Exporter {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	return &Exporter{
+		buckets:    append([]float64{}, buckets...),
+		operations: make(map[hibe_sm9.Operation]*operationStats),
+	}
+}
+
+// Observe implements hibe_sm9.Metrics.
+func (exporter *Exporter) Observe(op hibe_sm9.Operation, duration time.Duration, err error) {
+	seconds := duration.Seconds()
+	exporter.lock.Lock()
+	defer exporter.lock.Unlock()
+	stats := exporter.operations[op]
+	if stats == nil {
+		stats = &operationStats{counts: make([]uint64, len(exporter.buckets))}
+		exporter.operations[op] = stats
+	}
+	if err == nil {
+		stats.ok++
+	} else {
+		stats.failed++
+	}
+	stats.sum += seconds
+	for i, bound := range exporter.buckets {
+		if seconds <= bound {
+			stats.counts[i]++
+		}
+	}
+}
+
+// WriteTo writes the metrics in the Prometheus text format.
+func (exporter *Exporter) WriteTo(w io.Writer) (int64, error) {
+	exporter.lock.Lock()
+	ops := make([]string, 0, len(exporter.operations))
+	for op := range exporter.operations {
+		ops = append(ops, string(op))
+	}
+	sort.Strings(ops)
+
+	var out []byte
+	out = append(out, "# HELP hibe_operations_total Number of HIBE operations by outcome.\n"...)
+	out = append(out, "# TYPE hibe_operations_total counter\n"...)
+	for _, op := range ops {
+		stats := exporter.operations[hibe_sm9.Operation(op)]
+		out = fmt.Appendf(out, "hibe_operations_total{operation=%q,outcome=\"ok\"} %d\n", op, stats.ok)
+		out = fmt.Appendf(out, "hibe_operations_total{operation=%q,outcome=\"error\"} %d\n", op, stats.failed)
+	}
+	out = append(out, "# HELP hibe_operation_duration_seconds Latency of HIBE operations.\n"...)
+	out = append(out, "# TYPE hibe_operation_duration_seconds histogram\n"...)
+	for _, op := range ops {
+		stats := exporter.operations[hibe_sm9.Operation(op)]
+		for i, bound := range exporter.buckets {
+			le := strconv.FormatFloat(bound, 'g', -1, 64)
+			out = fmt.Appendf(out, "hibe_operation_duration_seconds_bucket{operation=%q,le=%q} %d\n", op, le, stats.counts[i])
+		}
+		total := stats.ok + stats.failed
+		out = fmt.Appendf(out, "hibe_operation_duration_seconds_bucket{operation=%q,le=\"+Inf\"} %d\n", op, total)
+		out = fmt.Appendf(out, "hibe_operation_duration_seconds_sum{operation=%q} %s\n", op, strconv.FormatFloat(stats.sum, 'g', -1, 64))
+		out = fmt.Appendf(out, "hibe_operation_duration_seconds_count{operation=%q} %d\n", op, total)
+	}
+	exporter.lock.Unlock()
+
+	out = append(out, "# HELP hibe_pairings_total Number of pairings computed.\n"...)
+	out = append(out, "# TYPE hibe_pairings_total counter\n"...)
+	out = fmt.Appendf(out, "hibe_pairings_total %d\n", hibe_sm9.Pairings())
+	n, err := w.Write(out)
+	return int64(n), err
+}
+
+// ServeHTTP serves the metrics, as the handler for a /metrics endpoint.
+func (exporter *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	exporter.WriteTo(w)
+}
@@ -0,0 +1,55 @@
+package promexport
+
+import (
+	"crypto/rand"
+	"hibe_sm9"
+	"io"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExporter(t *testing.T) {
+	exporter := New()
+	hibe_sm9.SetMetrics(exporter)
+	defer hibe_sm9.SetMetrics(nil)
+
+	params, master, err := hibe_sm9.Setup(rand.Reader, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = hibe_sm9.KeyGenFromMaster(rand.Reader, params, master, []*big.Int{big.NewInt(1)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = hibe_sm9.KeyGenFromMaster(rand.Reader, params, master, nil); err == nil {
+		t.Fatal("Empty identity was accepted")
+	}
+	exporter.Observe(hibe_sm9.OpDecrypt, 3*time.Millisecond, nil)
+
+	server := httptest.NewServer(exporter)
+	defer server.Close()
+	response, err := server.Client().Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`hibe_operations_total{operation="setup",outcome="ok"} 1`,
+		`hibe_operations_total{operation="keygen",outcome="ok"} 1`,
+		`hibe_operations_total{operation="keygen",outcome="error"} 1`,
+		`hibe_operation_duration_seconds_bucket{operation="decrypt",le="0.0025"} 0`,
+		`hibe_operation_duration_seconds_bucket{operation="decrypt",le="0.005"} 1`,
+		`hibe_operation_duration_seconds_count{operation="keygen"} 2`,
+		"# TYPE hibe_pairings_total counter",
+	} {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Fatalf("Missing %s", line)
+		}
+	}
+}
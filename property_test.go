@@ -0,0 +1,157 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	mathrand "math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+// propertyDepth is the maximum depth of the hierarchy in the property tests.
+const propertyDepth = 5
+
+// hierarchyCase is a random identity, a point at which to switch from the
+// master key to delegation, and an identity that differs from it.
+type hierarchyCase struct {
+	ID []*big.Int
+	// The master key issues the key for ID[:Split], and each level below is
+	// delegated from its parent
+	Split int
+	// Other has the same depth as ID, but differs in one component
+	Other []*big.Int
+}
+
+func randomComponent(r *mathrand.Rand) *big.Int {
+	return big.NewInt(r.Int63n(1<<32) + 1)
+}
+
+// Generate implements quick.Generator.
+func (hierarchyCase) Generate(r *mathrand.Rand, size int) reflect.Value {
+	depth := 1 + r.Intn(propertyDepth)
+	c := hierarchyCase{
+		ID:    make([]*big.Int, depth),
+		Split: 1 + r.Intn(depth),
+		Other: make([]*big.Int, depth),
+	}
+	for i := range c.ID {
+		c.ID[i] = randomComponent(r)
+		c.Other[i] = c.ID[i]
+	}
+	differ := r.Intn(depth)
+	c.Other[differ] = new(big.Int).Add(c.ID[differ], big.NewInt(1+r.Int63n(1000)))
+	return reflect.ValueOf(c)
+}
+
+func quickConfig() *quick.Config {
+	count := 12
+	if testing.Short() {
+		count = 3
+	}
+	return &quick.Config{MaxCount: count}
+}
+
+func TestPropertyDelegationChains(t *testing.T) {
+	params, master, err := Setup(rand.Reader, propertyDepth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	property := func(c hierarchyCase) bool {
+		// Keys issued directly and through a delegation chain both decrypt
+		direct, err := KeyGenFromMaster(rand.Reader, params, master, c.ID)
+		if err != nil {
+			t.Log(err)
+			return false
+		}
+		delegated, err := KeyGenFromMaster(rand.Reader, params, master, c.ID[:c.Split])
+		if err != nil {
+			t.Log(err)
+			return false
+		}
+		for k := c.Split + 1; k <= len(c.ID); k++ {
+			if delegated, err = KeyGenFromParent(rand.Reader, params, delegated, c.ID[:k]); err != nil {
+				t.Log(err)
+				return false
+			}
+		}
+		if direct.DepthLeft() != delegated.DepthLeft() || direct.DepthLeft() != propertyDepth-len(c.ID) {
+			return false
+		}
+
+		message, err := NewRandomMessage(rand.Reader)
+		if err != nil {
+			t.Log(err)
+			return false
+		}
+		ciphertext, err := Encrypt(rand.Reader, params, c.ID, message, WithIntegrityTag())
+		if err != nil {
+			t.Log(err)
+			return false
+		}
+		for _, key := range []*PrivateKey{direct, delegated} {
+			decrypted, err := Decrypt(key, ciphertext)
+			if err != nil || !bytes.Equal(decrypted.Marshal(), message.Marshal()) {
+				return false
+			}
+		}
+
+		// A key for a different identity of the same depth fails
+		other, err := KeyGenFromMaster(rand.Reader, params, master, c.Other)
+		if err != nil {
+			t.Log(err)
+			return false
+		}
+		_, err = Decrypt(other, ciphertext)
+		return errors.Is(err, ErrDecryptFailed)
+	}
+	if err = quick.Check(property, quickConfig()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPropertyAncestorScope(t *testing.T) {
+	params, master, err := Setup(rand.Reader, propertyDepth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	property := func(c hierarchyCase) bool {
+		// An ancestor key can derive the key of every descendant, but not of
+		// identities outside its subtree
+		ancestor, err := KeyGenFromMaster(rand.Reader, params, master, c.ID[:c.Split])
+		if err != nil {
+			t.Log(err)
+			return false
+		}
+		message, err := NewRandomMessage(rand.Reader)
+		if err != nil {
+			t.Log(err)
+			return false
+		}
+		for _, target := range [][]*big.Int{c.ID, c.Other} {
+			ciphertext, err := Encrypt(rand.Reader, params, target, message, WithIntegrityTag())
+			if err != nil {
+				t.Log(err)
+				return false
+			}
+			key := ancestor
+			for k := c.Split + 1; k <= len(target); k++ {
+				if key, err = KeyGenFromParent(rand.Reader, params, key, target[:k]); err != nil {
+					t.Log(err)
+					return false
+				}
+			}
+			_, err = Decrypt(key, ciphertext)
+			inSubtree := idsAgree(target, c.ID[:c.Split])
+			if inSubtree != (err == nil) {
+				return false
+			}
+		}
+		return true
+	}
+	if err = quick.Check(property, quickConfig()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,151 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"golang.org/x/crypto/bn256"
+	"io"
+	"math/big"
+)
+
+// proxyDomain separates the hash of the re-encryption secret from other uses
+// of HashToG1.
+var proxyDomain = []byte("HIBE-PROXY")
+
+var errReEncryptedSource = wrapError(ErrMalformedCiphertext, "hibe: re-encrypted ciphertext does not match its source")
+
+// ReEncryptionKey lets a proxy turn ciphertexts for one identity into
+// ciphertexts for another, without being able to decrypt either. It is the
+// construction of Green and Ateniese (ACNS 2007) adapted to this scheme: the
+// delegator's A0 is blinded by the hash of a random element X of GT, and X is
+// encrypted for the delegatee.
+//
+// The proxy and the delegatee together can recover the delegator's key, so
+// the delegatee must be trusted not to collude with the proxy. Re-encryption
+// is single-hop: re-encrypted ciphertexts cannot be re-encrypted again.
+type ReEncryptionKey struct {
+	R0    *bn256.G1
+	R1    *bn256.G2
+	R1Hat *bn256.G1
+	X     *Ciphertext
+
+	// Fingerprint of the parameters the key was generated under, or nil if
+	// it is unknown.
+	ParamsFingerprint []byte
+}
+
+// ReEncryptedCiphertext is a ciphertext transformed by ReEncrypt, which the
+// delegatee decrypts with DecryptReEncrypted.
+type ReEncryptedCiphertext struct {
+	A *bn256.GT
+	B *bn256.G2
+	X *Ciphertext
+
+	// Source is the ciphertext before re-encryption, kept when it has an
+	// integrity tag so that the delegatee can check the tag.
+	Source *Ciphertext
+}
+
+// proxyBlinding maps the re-encryption secret onto G1.
+func proxyBlinding(x *bn256.GT) *bn256.G1 {
+	encoded := x.Marshal()
+	defer zeroizeBytes(encoded)
+	return HashToG1(append(append([]byte{}, proxyDomain...), encoded...))
+}
+
+// GenerateReKey creates a re-encryption key from the holder of fromKey to
+// toID. Only ciphertexts encrypted for exactly the identity of fromKey can be
+// re-encrypted with it.
+func GenerateReKey(random io.Reader, params *Params, fromKey *PrivateKey, toID []*big.Int) (*ReEncryptionKey, error) {
+	random = randomSource(random)
+	z, err := rand.Int(random, bn256.Order)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroizeScalar(z)
+	params.Precache()
+	x, err := powerPairing(params, z)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroizeGT(x)
+
+	rekey := &ReEncryptionKey{ParamsFingerprint: params.Fingerprint()}
+	if rekey.X, err = Encrypt(random, params, toID, x, WithIntegrityTag()); err != nil {
+		return nil, err
+	}
+	blinding := proxyBlinding(x)
+	defer zeroizeG1(blinding)
+	rekey.R0 = new(bn256.G1).Neg(blinding)
+	rekey.R0.Add(fromKey.A0, rekey.R0)
+	if fromKey.A1Hat != nil {
+		rekey.R1Hat = deepClone(fromKey.A1Hat)
+	} else {
+		rekey.R1 = deepCloneG2(fromKey.A1)
+	}
+	return rekey, nil
+}
+
+// ReEncrypt transforms a ciphertext for the delegator into one for the
+// delegatee. Decrypt would compute A * e(C, A1) / e(A0, B); with the blinded
+// A0, the result is the message times e(H(X), B), which only the delegatee
+// can remove. As with Decrypt, the ciphertext must have all its components and
+// belong to the hierarchy of the re-encryption key.
+func ReEncrypt(rekey *ReEncryptionKey, ciphertext *Ciphertext) (*ReEncryptedCiphertext, error) {
+	if rekey == nil {
+		return nil, errIncompleteKey
+	}
+	key := &PrivateKey{A0: rekey.R0, A1: rekey.R1, A1Hat: rekey.R1Hat}
+	if err := checkComponents(key, ciphertext); err != nil {
+		return nil, err
+	}
+	if err := checkBinding(rekey.ParamsFingerprint, ciphertext.ParamsFingerprint); err != nil {
+		return nil, err
+	}
+	partial := decrypt(key, ciphertext)
+	reencrypted := &ReEncryptedCiphertext{
+		A: partial,
+		B: ciphertext.B,
+		X: rekey.X,
+	}
+	if ciphertext.Tag != nil {
+		reencrypted.Source = ciphertext.Clone()
+	}
+	return reencrypted, nil
+}
+
+// DecryptReEncrypted recovers the message from a re-encrypted ciphertext with
+// the delegatee's key. As with Decrypt, the ciphertexts must belong to the
+// hierarchy of the key, and the integrity tag of the source ciphertext, if
+// any, is checked; WithTagRequired makes it mandatory.
+func DecryptReEncrypted(key *PrivateKey, ciphertext *ReEncryptedCiphertext, opts ...DecryptOption) (*bn256.GT, error) {
+	config := decryptOptions(opts)
+	source := ciphertext.Source
+	if source == nil && config.requireTag {
+		return nil, errNoTag
+	}
+	if source != nil {
+		if err := checkBinding(key.ParamsFingerprint, source.ParamsFingerprint); err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(source.B.Marshal(), ciphertext.B.Marshal()) {
+			return nil, errReEncryptedSource
+		}
+	}
+	x, err := Decrypt(key, ciphertext.X, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroizeGT(x)
+	blinding := proxyBlinding(x)
+	defer zeroizeG1(blinding)
+	mask := new(bn256.GT).Neg(pair(blinding, ciphertext.B))
+	plaintext := mask.Add(ciphertext.A, mask)
+	if source != nil {
+		if err = checkIntegrity(plaintext, source); err != nil {
+			zeroizeGT(plaintext)
+			return nil, err
+		}
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,134 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func testProxyReEncryption(t *testing.T, opts ...SetupOption) {
+	params, master, err := Setup(rand.Reader, 3, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := []*big.Int{big.NewInt(1), big.NewInt(2)}
+	bob := []*big.Int{big.NewInt(1), big.NewInt(3)}
+	aliceKey, err := KeyGenFromMaster(rand.Reader, params, master, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobKey, err := KeyGenFromMaster(rand.Reader, params, master, bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rekey, err := GenerateReKey(rand.Reader, params, aliceKey, bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := NewMessage()
+	ciphertext, err := Encrypt(rand.Reader, params, alice, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reencrypted, err := ReEncrypt(rekey, ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Equal(message.Marshal(), reencrypted.A.Marshal()) {
+		t.Fatal("Proxy learned the message")
+	}
+	decrypted, err := DecryptReEncrypted(bobKey, reencrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(message.Marshal(), decrypted.Marshal()) {
+		t.Fatal("Delegatee cannot decrypt the re-encrypted ciphertext")
+	}
+	if _, err = DecryptReEncrypted(aliceKey, reencrypted); err == nil {
+		t.Fatal("Re-encrypted ciphertext decrypted without the delegatee's key")
+	}
+	if _, err = DecryptReEncrypted(bobKey, reencrypted, WithTagRequired()); !errors.Is(err, ErrMalformedCiphertext) {
+		t.Fatal("Untagged ciphertext decrypted with a tag required")
+	}
+
+	tagged, err := Encrypt(rand.Reader, params, alice, message, WithIntegrityTag())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reencrypted, err = ReEncrypt(rekey, tagged); err != nil {
+		t.Fatal(err)
+	}
+	if decrypted, err = DecryptReEncrypted(bobKey, reencrypted, WithTagRequired()); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(message.Marshal(), decrypted.Marshal()) {
+		t.Fatal("Delegatee cannot decrypt the re-encrypted tagged ciphertext")
+	}
+	reencrypted.A.Add(reencrypted.A, gtBase)
+	if _, err = DecryptReEncrypted(bobKey, reencrypted); !errors.Is(err, ErrDecryptFailed) {
+		t.Fatal("Corrupted re-encrypted ciphertext decrypted")
+	}
+	if !bytes.Equal(message.Marshal(), mustDecrypt(t, aliceKey, ciphertext).Marshal()) {
+		t.Fatal("Re-encryption changed the original ciphertext")
+	}
+}
+
+func TestProxyReEncryption(t *testing.T) {
+	testProxyReEncryption(t)
+}
+
+func TestProxyReEncryptionAnonymous(t *testing.T) {
+	testProxyReEncryption(t, WithAnonymity())
+}
+
+func TestReEncryptMalformed(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	anonymousParams, _, err := Setup(rand.Reader, 3, WithAnonymity())
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherParams, _, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := []*big.Int{big.NewInt(1), big.NewInt(2)}
+	bob := []*big.Int{big.NewInt(1), big.NewInt(3)}
+	aliceKey, err := KeyGenFromMaster(rand.Reader, params, master, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rekey, err := GenerateReKey(rand.Reader, params, aliceKey, bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	incomplete, err := Encrypt(rand.Reader, params, alice, NewMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	incomplete.C = nil
+	if _, err = ReEncrypt(rekey, incomplete); !errors.Is(err, ErrMalformedCiphertext) {
+		t.Fatal("Re-encrypted a ciphertext with a missing component")
+	}
+	anonymous, err := Encrypt(rand.Reader, anonymousParams, alice, NewMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ReEncrypt(rekey, anonymous); !errors.Is(err, ErrCurveMismatch) {
+		t.Fatal("Re-encrypted an anonymous ciphertext with a non-anonymous key")
+	}
+	other, err := Encrypt(rand.Reader, otherParams, alice, NewMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ReEncrypt(rekey, other); !errors.Is(err, ErrCurveMismatch) {
+		t.Fatal("Re-encrypted a ciphertext for other parameters")
+	}
+}
@@ -0,0 +1,188 @@
+package hibe_sm9
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"golang.org/x/crypto/bn256"
+	"io"
+	"math/big"
+)
+
+// ErrPunctured is returned when decrypting a ciphertext whose tag has been
+// punctured from the key (or, with small probability, whose slots have all
+// been removed by punctures of other tags).
+var ErrPunctured = wrapError(ErrDecryptFailed, "hibe: key has been punctured for the ciphertext tag")
+
+var errPunctureConfig = wrapError(ErrMalformedCiphertext, "hibe: ciphertext does not match the puncturable key")
+
+var (
+	punctureSlotDomain = []byte("HIBE-PUNCTURE-SLOT")
+	punctureTagDomain  = []byte("HIBE-PUNCTURE-TAG")
+)
+
+// PunctureConfig sizes the Bloom filter behind puncturable keys. A key holds
+// one private key per slot, and each tag selects Hashes of the slots. After n
+// punctures, a tag that was not punctured becomes undecryptable with
+// probability about (1 - e^(-Hashes*n/Slots))^Hashes, so Slots should be
+// chosen well above the number of punctures expected over the life of a key.
+// Encryptors and the key holder must use the same configuration.
+type PunctureConfig struct {
+	Slots  int
+	Hashes int
+}
+
+// DefaultPunctureConfig allows a few hundred punctures with a false-positive
+// rate below one in a million.
+var DefaultPunctureConfig = PunctureConfig{Slots: 1 << 14, Hashes: 10}
+
+// slotID returns the identity of a slot below id.
+func (config PunctureConfig) slotID(id []*big.Int, slot int) []*big.Int {
+	var encoded [4]byte
+	binary.BigEndian.PutUint32(encoded[:], uint32(slot))
+	component := HashToZp(append(append([]byte{}, punctureSlotDomain...), encoded[:]...))
+
+	slotid := make([]*big.Int, len(id), len(id)+1)
+	copy(slotid, id)
+	return append(slotid, component)
+}
+
+// slots returns the slots selected by tag, in order. The same slot may occur
+// more than once.
+func (config PunctureConfig) slots(tag []byte) []int {
+	slots := make([]int, config.Hashes)
+	for i := range slots {
+		hash := sha256.New()
+		hash.Write(punctureTagDomain)
+		hash.Write([]byte{byte(i >> 8), byte(i)})
+		hash.Write(tag)
+		digest := hash.Sum(nil)
+		slots[i] = int(binary.BigEndian.Uint64(digest) % uint64(config.Slots))
+	}
+	return slots
+}
+
+// PuncturableCiphertext is a message encrypted under a tag, such as a message
+// identifier. It holds one ciphertext for each slot selected by the tag, each
+// with its own randomness, so that the ciphertexts for the selected slots do
+// not yield ciphertexts for any other slot.
+type PuncturableCiphertext struct {
+	Tag []byte
+	MultiCiphertext
+}
+
+// EncryptPuncturable encrypts message for id under tag. The hierarchy must
+// have room for one level below id, which holds the slots. Anonymous
+// hierarchies are rejected, because their keys cannot be delegated to the
+// slots.
+func EncryptPuncturable(random io.Reader, params *Params, config PunctureConfig, id []*big.Int, tag []byte, message *bn256.GT) (*PuncturableCiphertext, error) {
+	if params.Anonymous() {
+		return nil, errAnonymousDelegation
+	}
+	if len(id) >= params.MaximumDepth() {
+		return nil, ErrDepthExceeded
+	}
+	slots := config.slots(tag)
+	ids := make([][]*big.Int, len(slots))
+	for i, slot := range slots {
+		ids[i] = config.slotID(id, slot)
+	}
+	multi, err := EncryptMulti(random, params, ids, message)
+	if err != nil {
+		return nil, err
+	}
+	return &PuncturableCiphertext{
+		Tag:             append([]byte{}, tag...),
+		MultiCiphertext: *multi,
+	}, nil
+}
+
+// PuncturableKey decrypts messages encrypted for an identity with
+// EncryptPuncturable, and can be punctured so that it no longer decrypts
+// messages with a given tag. This is the Bloom filter encryption of Derler,
+// Jager, Slamanig and Striecks: the key is a private key for every slot below
+// the identity, and puncturing a tag deletes the keys for its slots. Deleting
+// the ability to decrypt a message once it has been read gives forward secrecy
+// for that message even if the key is later compromised.
+type PuncturableKey struct {
+	config PunctureConfig
+	slots  []*PrivateKey
+}
+
+// NewPuncturableKey derives a puncturable key from the private key for id.
+// This costs one delegation per slot. Each slot key is restricted so that it
+// cannot delegate further.
+func NewPuncturableKey(random io.Reader, params *Params, config PunctureConfig, key *PrivateKey, id []*big.Int) (*PuncturableKey, error) {
+	if len(id) >= params.MaximumDepth() {
+		return nil, ErrDepthExceeded
+	}
+	punctured := &PuncturableKey{
+		config: config,
+		slots:  make([]*PrivateKey, config.Slots),
+	}
+	for slot := range punctured.slots {
+		child, err := KeyGenFromParent(random, params, key, config.slotID(id, slot))
+		if err != nil {
+			punctured.Zeroize()
+			return nil, err
+		}
+		punctured.slots[slot] = child.Restrict(DelegationPolicy{})
+		child.Zeroize()
+	}
+	return punctured, nil
+}
+
+// Puncture returns a key that can no longer decrypt ciphertexts carrying tag.
+// The slot keys selected by tag are zeroized in place, so key is punctured as
+// well; keeping a copy that could still decrypt would defeat the purpose.
+func Puncture(key *PuncturableKey, tag []byte) *PuncturableKey {
+	result := &PuncturableKey{
+		config: key.config,
+		slots:  make([]*PrivateKey, len(key.slots)),
+	}
+	copy(result.slots, key.slots)
+	for _, slot := range key.config.slots(tag) {
+		if key.slots[slot] != nil {
+			key.slots[slot].Zeroize()
+			key.slots[slot] = nil
+		}
+		result.slots[slot] = nil
+	}
+	return result
+}
+
+// Remaining returns the number of slots that have not been punctured.
+func (key *PuncturableKey) Remaining() int {
+	remaining := 0
+	for _, slot := range key.slots {
+		if slot != nil {
+			remaining++
+		}
+	}
+	return remaining
+}
+
+// Decrypt decrypts a ciphertext with the first slot selected by its tag that
+// has not been punctured.
+func (key *PuncturableKey) Decrypt(ciphertext *PuncturableCiphertext) (*bn256.GT, error) {
+	slots := key.config.slots(ciphertext.Tag)
+	if ciphertext.Recipients() != len(slots) {
+		return nil, errPunctureConfig
+	}
+	for i, slot := range slots {
+		if key.slots[slot] != nil {
+			return Decrypt(key.slots[slot], ciphertext.Ciphertext(i))
+		}
+	}
+	return nil, ErrPunctured
+}
+
+// Zeroize zeroizes every remaining slot key. The key must not be used
+// afterwards.
+func (key *PuncturableKey) Zeroize() {
+	for i, slot := range key.slots {
+		if slot != nil {
+			slot.Zeroize()
+			key.slots[i] = nil
+		}
+	}
+}
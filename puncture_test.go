@@ -0,0 +1,145 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestPuncture(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := LINEAR_HIERARCHY[:2]
+	parent, err := KeyGenFromMaster(rand.Reader, params, master, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := PunctureConfig{Slots: 64, Hashes: 3}
+	key, err := NewPuncturableKey(rand.Reader, params, config, parent, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := NewMessage()
+	read, err := EncryptPuncturable(rand.Reader, params, config, id, []byte("message 1"), message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unread, err := EncryptPuncturable(rand.Reader, params, config, id, []byte("message 2"), message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decrypted, err := key.Decrypt(read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(message.Marshal(), decrypted.Marshal()) {
+		t.Fatal("Original and decrypted messages differ")
+	}
+
+	punctured := Puncture(key, read.Tag)
+	if punctured.Remaining() == config.Slots || punctured.Remaining() != key.Remaining() {
+		t.Fatal("Puncturing did not remove the slots of the tag")
+	}
+	if _, err = punctured.Decrypt(read); err != ErrPunctured {
+		t.Fatal("Punctured key decrypted a message with the punctured tag")
+	}
+	if _, err = key.Decrypt(read); err != ErrPunctured {
+		t.Fatal("Original key still decrypts a message with the punctured tag")
+	}
+
+	// The slots of the two tags are disjoint unless the hashes collide.
+	decrypted, err = punctured.Decrypt(unread)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(message.Marshal(), decrypted.Marshal()) {
+		t.Fatal("Punctured key does not decrypt other tags")
+	}
+
+	// A different tag on the same ciphertext selects other slots.
+	forged := *unread
+	forged.Tag = []byte("message 3")
+	if decrypted, err = punctured.Decrypt(&forged); err == nil && bytes.Equal(message.Marshal(), decrypted.Marshal()) {
+		t.Fatal("Ciphertext decrypted under a different tag")
+	}
+
+	if _, err = key.Decrypt(&PuncturableCiphertext{Tag: read.Tag}); err != errPunctureConfig {
+		t.Fatal("Decrypted a ciphertext with the wrong number of slots")
+	}
+}
+
+func TestPunctureAnonymous(t *testing.T) {
+	params, _, err := Setup(rand.Reader, 3, WithAnonymity())
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := PunctureConfig{Slots: 64, Hashes: 3}
+	_, err = EncryptPuncturable(rand.Reader, params, config, LINEAR_HIERARCHY[:2], []byte("message"), NewMessage())
+	if !errors.Is(err, ErrDelegationDenied) {
+		t.Fatal("EncryptPuncturable accepted anonymous parameters")
+	}
+}
+
+func TestPunctureSurvivingSlot(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := LINEAR_HIERARCHY[:2]
+	parent, err := KeyGenFromMaster(rand.Reader, params, master, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := PunctureConfig{Slots: 16, Hashes: 3}
+	key, err := NewPuncturableKey(rand.Reader, params, config, parent, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tag := []byte("message")
+	message := NewMessage()
+	ciphertext, err := EncryptPuncturable(rand.Reader, params, config, id, tag, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key = Puncture(key, tag)
+
+	// Combine the ciphertexts for two distinct slots of the tag into one for
+	// a slot that survived the puncture
+	slots := config.slots(tag)
+	i, j := 0, 1
+	for j < len(slots) && slots[j] == slots[i] {
+		j++
+	}
+	if j == len(slots) {
+		t.Skip("Tag selects a single slot")
+	}
+	survivor := -1
+	for slot, slotKey := range key.slots {
+		if slotKey != nil {
+			survivor = slot
+			break
+		}
+	}
+	if survivor < 0 {
+		t.Fatal("No slot survived the puncture")
+	}
+	component := func(slot int) *big.Int {
+		return config.slotID(id, slot)[len(id)]
+	}
+	first := ciphertext.Ciphertext(i)
+	forged := &Ciphertext{
+		A: first.A,
+		B: first.B,
+		C: siblingC(first.C, ciphertext.Ciphertext(j).C, component(slots[i]), component(slots[j]), component(survivor)),
+	}
+	if bytes.Equal(message.Marshal(), mustDecrypt(t, key.slots[survivor], forged).Marshal()) {
+		t.Fatal("Surviving slot decrypted a ciphertext for a punctured tag")
+	}
+}
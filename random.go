@@ -0,0 +1,76 @@
+package hibe_sm9
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+)
+
+// minimumStuckCheck is the shortest read that is checked for stuck output.
+const minimumStuckCheck = 16
+
+var (
+	errShortRead   = errors.New("hibe: random source returned a short read")
+	errRandomStuck = errors.New("hibe: random source returned constant output")
+)
+
+// checkedReader guards the package against broken random sources. Every read
+// is filled completely or fails, and a read of at least minimumStuckCheck
+// bytes that are all the same, as a stuck or zeroed source produces, fails.
+// The check cannot detect subtler low-entropy output; for that, see
+// NewRandom.
+type checkedReader struct {
+	source io.Reader
+}
+
+// randomSource returns the source of randomness that functions of this
+// package use in place of random: crypto/rand if random is nil, and random
+// wrapped in a checkedReader otherwise.
+func randomSource(random io.Reader) io.Reader {
+	if random == nil {
+		random = rand.Reader
+	}
+	if _, ok := random.(*checkedReader); ok {
+		return random
+	}
+	return &checkedReader{source: random}
+}
+
+func (reader *checkedReader) Read(p []byte) (int, error) {
+	n, err := io.ReadFull(reader.source, p)
+	if err == io.ErrUnexpectedEOF || (err == io.EOF && len(p) != 0) {
+		return n, errShortRead
+	} else if err != nil {
+		return n, err
+	}
+	if len(p) >= minimumStuckCheck {
+		stuck := true
+		for _, b := range p[1:] {
+			if b != p[0] {
+				stuck = false
+				break
+			}
+		}
+		if stuck {
+			return 0, errRandomStuck
+		}
+	}
+	return n, nil
+}
+
+// NewRandom returns a deterministic random bit generator seeded with
+// MinimumSeedSize bytes from random (crypto/rand if nil) and personalization,
+// which should identify the application or instance. Using it in place of
+// random means that two instances with the same weak source, such as cloned
+// virtual machines, still draw different values if their personalizations
+// differ. The generator is not reseeded, so create one per process or long
+// task rather than keeping one forever.
+func NewRandom(random io.Reader, personalization []byte) (io.Reader, error) {
+	seed := make([]byte, MinimumSeedSize)
+	if _, err := io.ReadFull(randomSource(random), seed); err != nil {
+		return nil, err
+	}
+	defer zeroizeBytes(seed)
+	salt := append(append([]byte{}, drbgLabel...), personalization...)
+	return newSeededReader(seed, salt), nil
+}
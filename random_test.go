@@ -0,0 +1,103 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestRandomSource(t *testing.T) {
+	// nil means crypto/rand
+	params, master, err := Setup(nil, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := KeyGenFromMaster(nil, params, master, LINEAR_HIERARCHY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := NewMessage()
+	ciphertext, err := Encrypt(nil, params, LINEAR_HIERARCHY, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(message.Marshal(), mustDecrypt(t, key, ciphertext).Marshal()) {
+		t.Fatal("Original and decrypted messages differ")
+	}
+
+	// One byte at a time is fine, running dry is not
+	if _, _, err = Setup(iotest.OneByteReader(rand.Reader), 3); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = Setup(io.LimitReader(rand.Reader, 100), 3); err != errShortRead {
+		t.Fatal("Short read was not detected")
+	}
+	if _, err = Encrypt(bytes.NewReader(make([]byte, 1024)), params, LINEAR_HIERARCHY, message); err != errRandomStuck {
+		t.Fatal("Zero output was not detected")
+	}
+}
+
+func TestNewRandom(t *testing.T) {
+	seed := bytes.Repeat([]byte{1, 2, 3, 4}, MinimumSeedSize)
+	a, err := NewRandom(bytes.NewReader(seed), []byte("instance a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewRandom(bytes.NewReader(seed), []byte("instance b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputA := make([]byte, 64)
+	outputB := make([]byte, 64)
+	if _, err = io.ReadFull(a, outputA); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = io.ReadFull(b, outputB); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(outputA, outputB) {
+		t.Fatal("Personalization did not change the output")
+	}
+
+	if _, _, err = Setup(a, 3); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewRandom(bytes.NewReader(seed[:8]), nil); err == nil {
+		t.Fatal("Short seed was accepted")
+	}
+}
+
+func TestNilRandom(t *testing.T) {
+	params, master, err := Setup(nil, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := LINEAR_HIERARCHY[:1]
+	key, err := KeyGenFromMaster(nil, params, master, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Sign(nil, params, key, id, []byte("message")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = GenerateReKey(nil, params, key, LINEAR_HIERARCHY[:2]); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = BlindKeyRequestFor(nil, params, id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ExportShares(nil, key, 3, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = SplitMaster(nil, master, 3, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = WildcardKeyGen(nil, params, master, Pattern{id[0], nil}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = Initiate(nil, params, key, id, LINEAR_HIERARCHY[:2]); err != nil {
+		t.Fatal(err)
+	}
+}
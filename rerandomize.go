@@ -0,0 +1,131 @@
+package hibe_sm9
+
+import (
+	"crypto/rand"
+	"errors"
+	"golang.org/x/crypto/bn256"
+	"io"
+	"math/big"
+)
+
+var errReRandomizeTagged = errors.New("hibe: ciphertexts with an integrity tag cannot be re-randomized")
+
+// ReRandomize returns a copy of the key for id with fresh randomness: for a
+// random t, A0 is multiplied by (g3 * h1^I1 * ... * hk^Ik)^t, A1 by g^t, and
+// each Bj by h(k+j)^t, as if the key had been issued with r + t. The copy
+// decrypts and delegates exactly like the original, but the two cannot be
+// linked by their components, so the same logical key can be given to several
+// devices, or taken out of escrow, without the copies being traceable to each
+// other. The delegation policy and the parameters binding are kept.
+//
+// Since the key does not record its identity, id must be given; the key is
+// checked against it with VerifyKey first. Keys in anonymous hierarchies
+// cannot be re-randomized without the master key, and are rejected.
+func ReRandomize(random io.Reader, params *Params, key *PrivateKey, id []*big.Int) (*PrivateKey, error) {
+	if err := VerifyKey(params, id, key); err != nil {
+		return nil, err
+	}
+	random = randomSource(random)
+
+	// Randomly choose t in Zp
+	t, err := rand.Int(random, bn256.Order)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroizeScalar(t)
+
+	fresh := &PrivateKey{
+		Policy:            key.Policy.clone(),
+		ParamsFingerprint: params.Fingerprint(),
+		levels:            key.levels,
+	}
+	product, err := secretMultG1(idProduct(params, id), t)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroizeG1(product)
+	fresh.A0 = new(bn256.G1).Add(key.A0, product)
+
+	fresh.A1, err = secretMultG2(params.G, t)
+	if err != nil {
+		return nil, err
+	}
+	fresh.A1.Add(key.A1, fresh.A1)
+
+	k := len(id)
+	fresh.B = make([]*bn256.G1, len(key.B))
+	for j := range fresh.B {
+		if fresh.B[j], err = secretMultG1(params.H[k+j], t); err != nil {
+			return nil, err
+		}
+		fresh.B[j].Add(key.B[j], fresh.B[j])
+	}
+	return fresh, nil
+}
+
+// ReRandomizeCiphertext returns a ciphertext for the same identity and
+// message as ciphertext, with fresh randomness: for a random t, A is
+// multiplied by e(g2, g1)^t, B by g^t, and C by (g3 * h1^I1 * ... * hk^Ik)^t
+// (or CHat by its mirror in anonymous hierarchies), as if it had been
+// encrypted with s + t. Relays and mixes that forward ciphertexts can use it
+// to break the link between the messages they receive and those they send.
+// Like ReRandomize, it needs the identity, which the ciphertext does not
+// reveal; a wrong id yields a ciphertext that no key decrypts.
+//
+// The integrity tag of WithIntegrityTag is keyed by the blinding element, so
+// tagged ciphertexts are rejected. Encapsulations from Encapsulate can be
+// re-randomized, but their shared secret is bound to their encoding, so the
+// result decapsulates to a different secret.
+func ReRandomizeCiphertext(random io.Reader, params *Params, id []*big.Int, ciphertext *Ciphertext) (*Ciphertext, error) {
+	if err := checkID(params, id); err != nil {
+		return nil, err
+	}
+	if err := ciphertext.Validate(); err != nil {
+		return nil, err
+	}
+	fingerprint := params.Fingerprint()
+	if err := checkBinding(fingerprint, ciphertext.ParamsFingerprint); err != nil {
+		return nil, err
+	}
+	if ciphertext.Tag != nil {
+		return nil, errReRandomizeTagged
+	}
+	if params.Anonymous() != (ciphertext.CHat != nil) {
+		return nil, errCiphertextRelation
+	}
+	random = randomSource(random)
+	params.Precache()
+
+	// Randomly choose t in Zp
+	t, err := rand.Int(random, bn256.Order)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroizeScalar(t)
+
+	fresh := &Ciphertext{ParamsFingerprint: fingerprint}
+	mask, err := powerPairing(params, t)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroizeGT(mask)
+	fresh.A = new(bn256.GT).Add(ciphertext.A, mask)
+
+	if fresh.B, err = powerG(params, t); err != nil {
+		return nil, err
+	}
+	fresh.B.Add(ciphertext.B, fresh.B)
+
+	if params.Anonymous() {
+		if fresh.CHat, err = idProductHatPower(params, id, t); err != nil {
+			return nil, err
+		}
+		fresh.CHat.Add(ciphertext.CHat, fresh.CHat)
+	} else {
+		if fresh.C, err = idProductPower(params, id, t); err != nil {
+			return nil, err
+		}
+		fresh.C.Add(ciphertext.C, fresh.C)
+	}
+	return fresh, nil
+}
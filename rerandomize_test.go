@@ -0,0 +1,98 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestReRandomize(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	fresh, err := ReRandomize(rand.Reader, params, key, LINEAR_HIERARCHY[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fresh.Equal(key) || bytes.Equal(fresh.A1.Marshal(), key.A1.Marshal()) {
+		t.Fatal("Re-randomized key shares components with the original")
+	}
+	if err = VerifyKey(params, LINEAR_HIERARCHY[:1], fresh); err != nil {
+		t.Fatal(err)
+	}
+
+	// The copy decrypts and delegates like the original
+	message := NewMessage()
+	ciphertext, err := Encrypt(rand.Reader, params, LINEAR_HIERARCHY[:1], message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(message.Marshal(), mustDecrypt(t, fresh, ciphertext).Marshal()) {
+		t.Fatal("Re-randomized key did not decrypt")
+	}
+	child, err := KeyGenFromParent(rand.Reader, params, fresh, LINEAR_HIERARCHY[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = VerifyKey(params, LINEAR_HIERARCHY[:2], child); err != nil {
+		t.Fatal(err)
+	}
+
+	// The identity must be the key's
+	if _, err = ReRandomize(rand.Reader, params, key, LINEAR_HIERARCHY[1:2]); err == nil {
+		t.Fatal("Key was re-randomized for another identity")
+	}
+
+	anonymous, anonymousMaster, err := Setup(rand.Reader, 3, WithAnonymity())
+	if err != nil {
+		t.Fatal(err)
+	}
+	anonymousKey, err := KeyGenFromMaster(rand.Reader, anonymous, anonymousMaster, LINEAR_HIERARCHY[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ReRandomize(rand.Reader, anonymous, anonymousKey, LINEAR_HIERARCHY[:1]); err == nil {
+		t.Fatal("Anonymous key was re-randomized")
+	}
+}
+
+func TestReRandomizeCiphertext(t *testing.T) {
+	for _, opts := range [][]SetupOption{nil, {WithAnonymity()}} {
+		params, master, err := Setup(rand.Reader, 3, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[:2])
+		if err != nil {
+			t.Fatal(err)
+		}
+		message := NewMessage()
+		ciphertext, err := Encrypt(rand.Reader, params, LINEAR_HIERARCHY[:2], message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fresh, err := ReRandomizeCiphertext(rand.Reader, params, LINEAR_HIERARCHY[:2], ciphertext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fresh.Equal(ciphertext) || bytes.Equal(fresh.B.Marshal(), ciphertext.B.Marshal()) {
+			t.Fatal("Re-randomized ciphertext shares components with the original")
+		}
+		if !bytes.Equal(message.Marshal(), mustDecrypt(t, key, fresh).Marshal()) {
+			t.Fatal("Re-randomized ciphertext did not decrypt")
+		}
+
+		tagged, err := Encrypt(rand.Reader, params, LINEAR_HIERARCHY[:2], message, WithIntegrityTag())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = ReRandomizeCiphertext(rand.Reader, params, LINEAR_HIERARCHY[:2], tagged); err == nil {
+			t.Fatal("Tagged ciphertext was re-randomized")
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package hibe_sm9
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+	"strings"
+	"sync"
+)
+
+// ErrRevoked is returned when a key is requested for an identity that, or an
+// ancestor of which, has been revoked.
+var ErrRevoked = errors.New("hibe: identity has been revoked")
+
+// epochDomain separates epoch components from ordinary identity components.
+var epochDomain = []byte("HIBE-EPOCH")
+
+// EpochID returns the identity that id takes on during the given epoch. The
+// epoch is appended as one more level of the hierarchy, so id must be at
+// least one level shallower than the maximum depth. Encryptors should encrypt
+// to EpochID(id, current epoch) so that only keys issued for that epoch can
+// decrypt.
+func EpochID(id []*big.Int, epoch uint64) []*big.Int {
+	var encoded [8]byte
+	binary.BigEndian.PutUint64(encoded[:], epoch)
+	component := HashToZp(append(append([]byte{}, epochDomain...), encoded[:]...))
+
+	epochid := make([]*big.Int, len(id), len(id)+1)
+	copy(epochid, id)
+	return append(epochid, component)
+}
+
+// idString encodes an identity as a string suitable for use as a map key.
+func idString(id []*big.Int) string {
+	parts := make([]string, len(id))
+	for i, component := range id {
+		parts[i] = component.Text(16)
+	}
+	return strings.Join(parts, "/")
+}
+
+// Revoker issues per-epoch keys on behalf of the holder of the master key and
+// maintains a list of revoked identities. Revoking an identity also revokes
+// all of its descendants. Once an identity is revoked, ReKey refuses to issue
+// it keys for any further epoch, so a leaked key stops being useful as soon as
+// encryptors move on to the next epoch. It is safe for concurrent use.
+//
+// Revocation holds only if every key outside the PKG was issued by ReKey.
+// The Revoker cannot stop a key issued for id itself, by KeyGenFromMaster or
+// by delegation, from deriving the keys of all epochs of id and its
+// descendants (see EpochID), and revoking id does not affect such a key.
+type Revoker struct {
+	params *Params
+	master MasterKey
+
+	lock    sync.RWMutex
+	revoked map[string]struct{}
+}
+
+// NewRevoker creates a Revoker for the hierarchy with the provided parameters
+// and master key, with an empty revocation list.
+func NewRevoker(params *Params, master MasterKey) *Revoker {
+	return &Revoker{
+		params:  params,
+		master:  master,
+		revoked: make(map[string]struct{}),
+	}
+}
+
+// Revoke adds id (and therefore all of its descendants) to the revocation
+// list.
+func (revoker *Revoker) Revoke(id []*big.Int) {
+	revoker.lock.Lock()
+	defer revoker.lock.Unlock()
+	revoker.revoked[idString(id)] = struct{}{}
+}
+
+// IsRevoked returns true if id or any of its ancestors has been revoked.
+func (revoker *Revoker) IsRevoked(id []*big.Int) bool {
+	revoker.lock.RLock()
+	defer revoker.lock.RUnlock()
+	for i := 1; i <= len(id); i++ {
+		if _, ok := revoker.revoked[idString(id[:i])]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ReKey issues the key for id during the given epoch, i.e. the key for
+// EpochID(id, epoch), unless id has been revoked, in which case it returns
+// ErrRevoked.
+func (revoker *Revoker) ReKey(random io.Reader, id []*big.Int, epoch uint64) (*PrivateKey, error) {
+	if revoker.IsRevoked(id) {
+		return nil, ErrRevoked
+	}
+	return KeyGenFromMaster(random, revoker.params, revoker.master, EpochID(id, epoch))
+}
@@ -0,0 +1,81 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func TestRevocation(t *testing.T) {
+	// Set up parameters
+	params, key, err := Setup(rand.Reader, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoker := NewRevoker(params, key)
+
+	// Come up with a message to encrypt
+	message := NewMessage()
+
+	// Keys work for the epoch they were issued for, and only that epoch
+	epochkey, err := revoker.ReKey(rand.Reader, LINEAR_HIERARCHY, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := Encrypt(rand.Reader, params, EpochID(LINEAR_HIERARCHY, 1), message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted := mustDecrypt(t, epochkey, ciphertext)
+	if !bytes.Equal(message.Marshal(), decrypted.Marshal()) {
+		t.Fatal("Original and decrypted messages differ")
+	}
+	ciphertext, err = Encrypt(rand.Reader, params, EpochID(LINEAR_HIERARCHY, 2), message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted = mustDecrypt(t, epochkey, ciphertext)
+	if bytes.Equal(message.Marshal(), decrypted.Marshal()) {
+		t.Fatal("Key for one epoch decrypted a message for another")
+	}
+
+	// Revoking an ancestor stops issuance for the whole subtree
+	revoker.Revoke(LINEAR_HIERARCHY[:2])
+	if _, err = revoker.ReKey(rand.Reader, LINEAR_HIERARCHY, 2); err != ErrRevoked {
+		t.Fatal("Issued a key to a revoked identity")
+	}
+	sibling := []*big.Int{big.NewInt(1), big.NewInt(4)}
+	if _, err = revoker.ReKey(rand.Reader, sibling, 2); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRevocationNonEpochKey(t *testing.T) {
+	params, key, err := Setup(rand.Reader, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoker := NewRevoker(params, key)
+	revoker.Revoke(LINEAR_HIERARCHY)
+
+	// A key for the identity itself, rather than for one of its epochs,
+	// derives the keys of every epoch, whatever the revocation list says
+	privkey, err := KeyGenFromMaster(rand.Reader, params, key, LINEAR_HIERARCHY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	epochkey, err := KeyGenFromParent(rand.Reader, params, privkey, EpochID(LINEAR_HIERARCHY, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := NewMessage()
+	ciphertext, err := Encrypt(rand.Reader, params, EpochID(LINEAR_HIERARCHY, 2), message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted := mustDecrypt(t, epochkey, ciphertext)
+	if !bytes.Equal(message.Marshal(), decrypted.Marshal()) {
+		t.Fatal("Key derived for an epoch did not decrypt")
+	}
+}
@@ -0,0 +1,69 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"golang.org/x/crypto/bn256"
+	"io"
+	"math/big"
+)
+
+var errMasterMismatch = wrapError(ErrCurveMismatch, "hibe: master key does not match the parameters")
+
+// checkMaster verifies that master is the master key for params, that is,
+// e(master, g) = e(g2, g1).
+func checkMaster(params *Params, master MasterKey) error {
+	lhs := pair((*bn256.G1)(master), params.G).Marshal()
+	rhs := pair(params.G2, params.G1).Marshal()
+	if !bytes.Equal(lhs, rhs) {
+		return errMasterMismatch
+	}
+	return nil
+}
+
+// RotateMaster sets up a new hierarchy to replace the one with parameters
+// oldParams and master key oldMaster, for instance after the master key is
+// suspected to be compromised. The new hierarchy has the same depth and
+// identity hash, so byte identities map to the same identities, is anonymous
+// if the old one is, and derives h1 ... hl from a fresh seed if the old one
+// derives them from a seed. Its master key is independent of the old one,
+// which is only checked against oldParams to catch mix-ups.
+//
+// Rotation does not need a flag day:
+//
+//  1. Publish the new parameters, and have encryptors switch to them.
+//  2. Reissue keys under the new master key as holders ask for them. This
+//     must be authenticated independently of the old keys, since whoever
+//     compromised the old master key can produce any old key.
+//  3. Holders move their stored ciphertexts over with ReEncrypt, while they
+//     still have their old keys. Meanwhile, MultiParams decrypts messages
+//     sent under either hierarchy.
+//  4. Destroy the old master key with ZeroizeMasterKey, and later the old
+//     private keys.
+func RotateMaster(random io.Reader, oldParams *Params, oldMaster MasterKey) (*Params, MasterKey, error) {
+	if err := checkMaster(oldParams, oldMaster); err != nil {
+		return nil, nil, err
+	}
+
+	opts := []SetupOption{WithIdentityHash(oldParams.IdentityHash)}
+	if oldParams.Anonymous() {
+		opts = append(opts, WithAnonymity())
+	}
+	if oldParams.HSeed != nil {
+		opts = append(opts, WithDerivedH())
+	}
+	return Setup(random, oldParams.MaximumDepth(), opts...)
+}
+
+// ReEncrypt moves a ciphertext for id over to the hierarchy with parameters
+// newParams (see RotateMaster). The ciphertext is decrypted with oldKey, the
+// key for id in the old hierarchy, and the message is encrypted afresh for id
+// under newParams. The message only ever exists in the memory of the key
+// holder, and is zeroized before returning.
+func (newParams *Params) ReEncrypt(random io.Reader, oldKey *PrivateKey, id []*big.Int, ciphertext *Ciphertext) (*Ciphertext, error) {
+	message, err := Decrypt(oldKey, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroizeGT(message)
+	return Encrypt(random, newParams, id, message)
+}
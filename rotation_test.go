@@ -0,0 +1,87 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+func TestRotateMaster(t *testing.T) {
+	oldParams, oldMaster, err := Setup(rand.Reader, 3, WithAnonymity())
+	if err != nil {
+		t.Fatal(err)
+	}
+	newParams, newMaster, err := RotateMaster(rand.Reader, oldParams, oldMaster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newParams.MaximumDepth() != oldParams.MaximumDepth() || !newParams.Anonymous() {
+		t.Fatal("New hierarchy has a different shape")
+	}
+
+	id := LINEAR_HIERARCHY[:2]
+	oldKey, err := KeyGenFromMaster(rand.Reader, oldParams, oldMaster, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, err := KeyGenFromMaster(rand.Reader, newParams, newMaster, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := NewMessage()
+	ciphertext, err := Encrypt(rand.Reader, oldParams, id, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrated, err := newParams.ReEncrypt(rand.Reader, oldKey, id, ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(message.Marshal(), mustDecrypt(t, newKey, migrated).Marshal()) {
+		t.Fatal("New key does not decrypt the migrated ciphertext")
+	}
+	if _, err = Decrypt(oldKey, migrated); !errors.Is(err, ErrCurveMismatch) {
+		t.Fatal("Old key was used on the migrated ciphertext")
+	}
+
+	if _, _, err = RotateMaster(rand.Reader, newParams, oldMaster); !errors.Is(err, ErrCurveMismatch) {
+		t.Fatal("Rotated with a master key for other parameters")
+	}
+}
+
+func TestRotateMasterIdentityHash(t *testing.T) {
+	oldParams, oldMaster, err := Setup(rand.Reader, 3, WithIdentityHash(IdentityHashSM3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	newParams, _, err := RotateMaster(rand.Reader, oldParams, oldMaster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newParams.IdentityHash != oldParams.IdentityHash {
+		t.Fatal("New hierarchy has a different identity hash")
+	}
+	alice := [][]byte{[]byte("example.com"), []byte("alice")}
+	if newParams.HashID(alice)[1].Cmp(oldParams.HashID(alice)[1]) != 0 {
+		t.Fatal("Byte identity maps to a different identity after rotation")
+	}
+}
+
+func TestRotateMasterDerivedH(t *testing.T) {
+	oldParams, oldMaster, err := Setup(rand.Reader, 3, WithDerivedH())
+	if err != nil {
+		t.Fatal(err)
+	}
+	newParams, _, err := RotateMaster(rand.Reader, oldParams, oldMaster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newParams.HSeed == nil {
+		t.Fatal("New hierarchy does not derive h1 ... hl from a seed")
+	}
+	if err = newParams.checkHSeed(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,130 @@
+// Package scheme defines a HIBE interface that is independent of any
+// particular construction or curve, along with a registry of
+// implementations. Consumers program against Scheme and only link the
+// implementations they import, usually for their side effect of registering
+// themselves, as with database/sql drivers:
+//
+//	import (
+//		"hibe_sm9/scheme"
+//		_ "hibe_sm9/bbg04"
+//	)
+//
+//	s, err := scheme.Lookup(bbg04.Name)
+//
+// All values cross the interface in encoded form, so this package does not
+// depend on any curve library.
+//
+// Package bbg04 is the only implementation in this module. There is no sm9
+// package: the module has no implementation of the SM9 curve and its pairing,
+// and an SM9 scheme would register itself here once there is one.
+//
+// GmSSL's SM9 key formats are not supported either; see package interop.
+package scheme
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrUnknownScheme is returned by Lookup for names that no imported package
+// has registered.
+var ErrUnknownScheme = errors.New("scheme: unknown scheme")
+
+// ID is an identity in a hierarchy: one component per level, starting at the
+// root. Components are arbitrary byte strings, which each scheme maps onto
+// its own identity space.
+type ID [][]byte
+
+// ParseID splits a slash-separated path such as "org/dept/alice" into an ID.
+func ParseID(path string) ID {
+	components := strings.Split(path, "/")
+	id := make(ID, len(components))
+	for i, component := range components {
+		id[i] = []byte(component)
+	}
+	return id
+}
+
+// String joins the components of id with slashes.
+func (id ID) String() string {
+	components := make([]string, len(id))
+	for i, component := range id {
+		components[i] = string(component)
+	}
+	return strings.Join(components, "/")
+}
+
+// Parent returns the identity one level up, or nil for a top-level identity.
+func (id ID) Parent() ID {
+	if len(id) <= 1 {
+		return nil
+	}
+	return id[:len(id)-1]
+}
+
+// Scheme is a hierarchical identity-based key-encapsulation mechanism.
+// Parameters, master keys, private keys and encapsulations are opaque
+// encodings defined by the scheme.
+type Scheme interface {
+	// Name identifies the scheme in the registry.
+	Name() string
+
+	// Setup creates a hierarchy of the given maximum depth.
+	Setup(random io.Reader, depth int) (params []byte, master []byte, err error)
+
+	// KeyGen generates the private key for id with the master key.
+	KeyGen(random io.Reader, params []byte, master []byte, id ID) ([]byte, error)
+
+	// Delegate generates the private key for id with the private key of its
+	// parent.
+	Delegate(random io.Reader, params []byte, parent []byte, id ID) ([]byte, error)
+
+	// Encapsulate generates a shared secret for id, and its encapsulation.
+	Encapsulate(random io.Reader, params []byte, id ID) (secret []byte, encapsulation []byte, err error)
+
+	// Decapsulate recovers the shared secret from an encapsulation with the
+	// private key of its identity.
+	Decapsulate(key []byte, encapsulation []byte) ([]byte, error)
+}
+
+var (
+	registryLock sync.RWMutex
+	registry     = make(map[string]Scheme)
+)
+
+// Register makes a scheme available by its name. It panics if the name is
+// already taken, since that means two packages claim the same scheme.
+func Register(s Scheme) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if _, ok := registry[s.Name()]; ok {
+		panic("scheme: Register called twice for " + s.Name())
+	}
+	registry[s.Name()] = s
+}
+
+// Lookup returns the registered scheme with the given name.
+func Lookup(name string) (Scheme, error) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	s, ok := registry[name]
+	if !ok {
+		return nil, ErrUnknownScheme
+	}
+	return s, nil
+}
+
+// Names returns the names of the registered schemes, sorted.
+func Names() []string {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,74 @@
+package scheme
+
+import (
+	"io"
+	"testing"
+)
+
+type fakeScheme struct{}
+
+func (fakeScheme) Name() string { return "fake" }
+
+func (fakeScheme) Setup(random io.Reader, depth int) ([]byte, []byte, error) {
+	return nil, nil, nil
+}
+
+func (fakeScheme) KeyGen(random io.Reader, params []byte, master []byte, id ID) ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+func (fakeScheme) Delegate(random io.Reader, params []byte, parent []byte, id ID) ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+func (fakeScheme) Encapsulate(random io.Reader, params []byte, id ID) ([]byte, []byte, error) {
+	return []byte(id.String()), []byte(id.String()), nil
+}
+
+func (fakeScheme) Decapsulate(key []byte, encapsulation []byte) ([]byte, error) {
+	return encapsulation, nil
+}
+
+func TestParseID(t *testing.T) {
+	id := ParseID("org/dept/alice")
+	if len(id) != 3 || string(id[2]) != "alice" {
+		t.Fatal("ParseID split the path incorrectly")
+	}
+	if id.String() != "org/dept/alice" {
+		t.Fatal("String does not invert ParseID")
+	}
+	if id.Parent().String() != "org/dept" {
+		t.Fatal("Parent returned the wrong identity")
+	}
+	if ParseID("org").Parent() != nil {
+		t.Fatal("Top-level identity has a parent")
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	Register(fakeScheme{})
+	s, err := Lookup("fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name() != "fake" {
+		t.Fatal("Lookup returned the wrong scheme")
+	}
+	found := false
+	for _, name := range Names() {
+		found = found || name == "fake"
+	}
+	if !found {
+		t.Fatal("Names does not list the registered scheme")
+	}
+	if _, err = Lookup("missing"); err != ErrUnknownScheme {
+		t.Fatal("Lookup found an unregistered scheme")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Registering a name twice did not panic")
+		}
+	}()
+	Register(fakeScheme{})
+}
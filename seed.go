@@ -0,0 +1,61 @@
+package hibe_sm9
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"golang.org/x/crypto/hkdf"
+)
+
+// MinimumSeedSize is the smallest seed, in bytes, accepted by SetupFromSeed.
+const MinimumSeedSize = 32
+
+// drbgLabel separates the output of the DRBG from other uses of the seed.
+var drbgLabel = []byte("HIBE-DRBG")
+
+// seededReader is a deterministic random bit generator in the spirit of NIST
+// SP 800-90A: the seed is condensed into a pseudorandom key with
+// HKDF-Extract, and output block i is HMAC-SHA256(key, label || i).
+type seededReader struct {
+	key     []byte
+	counter uint64
+	buffer  []byte
+}
+
+// newSeededReader returns an io.Reader that deterministically produces an
+// unbounded pseudorandom stream from seed.
+func newSeededReader(seed []byte, salt []byte) *seededReader {
+	return &seededReader{key: hkdf.Extract(sha256.New, seed, salt)}
+}
+
+func (drbg *seededReader) Read(p []byte) (int, error) {
+	n := 0
+	for n != len(p) {
+		if len(drbg.buffer) == 0 {
+			var counter [8]byte
+			binary.BigEndian.PutUint64(counter[:], drbg.counter)
+			drbg.counter++
+
+			mac := hmac.New(sha256.New, drbg.key)
+			mac.Write(drbgLabel)
+			mac.Write(counter[:])
+			drbg.buffer = mac.Sum(nil)
+		}
+		copied := copy(p[n:], drbg.buffer)
+		drbg.buffer = drbg.buffer[copied:]
+		n += copied
+	}
+	return n, nil
+}
+
+// SetupFromSeed deterministically generates the system parameters and master
+// key from seed, so that the same seed and depth always yield identical
+// results. This is useful for test vectors and for recovering a hierarchy from
+// a backed-up seed; the seed is then as sensitive as the master key itself.
+func SetupFromSeed(seed []byte, l int) (*Params, MasterKey, error) {
+	if len(seed) < MinimumSeedSize {
+		return nil, nil, errors.New("hibe: seed is too short")
+	}
+	return Setup(newSeededReader(seed, []byte("HIBE-SETUP")), l)
+}
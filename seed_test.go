@@ -0,0 +1,39 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"golang.org/x/crypto/bn256"
+	"testing"
+)
+
+func TestSetupFromSeed(t *testing.T) {
+	seed := bytes.Repeat([]byte{0x2a}, MinimumSeedSize)
+
+	params1, master1, err := SetupFromSeed(seed, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params2, master2, err := SetupFromSeed(seed, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(params1.Marshal(), params2.Marshal()) {
+		t.Fatal("Same seed yielded different parameters")
+	}
+	if !bytes.Equal((*bn256.G1)(master1).Marshal(), (*bn256.G1)(master2).Marshal()) {
+		t.Fatal("Same seed yielded different master keys")
+	}
+
+	seed[0] ^= 1
+	params3, _, err := SetupFromSeed(seed, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(params1.Marshal(), params3.Marshal()) {
+		t.Fatal("Different seeds yielded the same parameters")
+	}
+
+	if _, _, err = SetupFromSeed(seed[:MinimumSeedSize-1], 5); err == nil {
+		t.Fatal("Accepted a short seed")
+	}
+}
@@ -0,0 +1,102 @@
+package hibe_sm9
+
+import (
+	"crypto/rand"
+	"golang.org/x/crypto/bn256"
+	"io"
+	"math/big"
+)
+
+// Signature represents a signature on a message, produced by the holder of a
+// private key in the hierarchy (HIBS). Following the standard conversion of a
+// HIBE into a signature scheme, a signature on message m by identity
+// (id1, ..., idk) is a (re-randomized) key for the identity
+// (id1, ..., idk, H(m)), minus its delegation components.
+type Signature struct {
+	A0 *bn256.G1
+	A1 *bn256.G2
+}
+
+// hibsDomain separates message hashes from identity components, so that a
+// signature is never the key for an identity that was derived from a name.
+var hibsDomain = []byte("HIBE-SIGNATURE")
+
+// messageToZp maps a message onto the identity component used to sign it.
+func messageToZp(message []byte) *big.Int {
+	return HashToZp(append(append([]byte{}, hibsDomain...), message...))
+}
+
+// Sign produces a signature on message using the private key for id. The key
+// must be able to delegate at least one more level, since the message is
+// treated as an additional level in the hierarchy.
+//
+// Note that the signature is a decryption key for the child identity
+// (id1, ..., idk, H(m)); ciphertexts should not be addressed to such
+// identities when the same hierarchy is used for signatures.
+func Sign(random io.Reader, params *Params, privkey *PrivateKey, id []*big.Int, message []byte) (*Signature, error) {
+	random = randomSource(random)
+	if err := checkID(params, id); err != nil {
+		return nil, err
+	}
+	k := len(id)
+	if !privkey.isKeyAtDepth(params, k) {
+		return nil, errSigningKey
+	}
+	if privkey.DepthLeft() == 0 {
+		return nil, ErrDepthExceeded
+	}
+
+	// Randomly choose t in Zp
+	t, err := rand.Int(random, bn256.Order)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroizeScalar(t)
+
+	m := messageToZp(message)
+	product := idProduct(params, id)
+	product.Add(product, new(bn256.G1).ScalarMult(params.H[k], m))
+	product, err = secretMultG1(product, t)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroizeG1(product)
+
+	signature := &Signature{}
+	signature.A0 = new(bn256.G1).ScalarMult(privkey.B[0], m)
+	signature.A0.Add(signature.A0, privkey.A0)
+	signature.A0.Add(signature.A0, product)
+
+	signature.A1, err = secretMultG2(params.G, t)
+	if err != nil {
+		return nil, err
+	}
+	signature.A1.Add(privkey.A1, signature.A1)
+
+	return signature, nil
+}
+
+// Verify checks that signature is a valid signature on message by the
+// identity id, by checking that e(A0, g) = e(g2, g1) * e(g3 * h1^id1 * ... *
+// hk^idk * h(k+1)^H(m), A1).
+func Verify(params *Params, id []*big.Int, message []byte, signature *Signature) bool {
+	k := len(id)
+	if k >= params.MaximumDepth() || checkID(params, id) != nil {
+		return false
+	}
+	if checkG1(signature.A0) != nil || checkG2(signature.A1) != nil {
+		return false
+	}
+
+	if params.Pairing == nil {
+		params.Pairing = pair(params.G2, params.G1)
+	}
+
+	product := idProduct(params, id)
+	product.Add(product, new(bn256.G1).ScalarMult(params.H[k], messageToZp(message)))
+
+	lhs := pair(signature.A0, params.G)
+	rhs := pair(product, signature.A1)
+	rhs.Add(rhs, params.Pairing)
+	return string(lhs.Marshal()) == string(rhs.Marshal())
+}
@@ -0,0 +1,42 @@
+package hibe_sm9
+
+import (
+	"crypto/rand"
+	"testing"
+)
+
+func TestSignVerify(t *testing.T) {
+	// Set up parameters
+	params, key, err := Setup(rand.Reader, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Generate second level key from master key
+	secondlevelkey, err := KeyGenFromMaster(rand.Reader, params, key, LINEAR_HIERARCHY[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := []byte("hello, hierarchy")
+	signature, err := Sign(rand.Reader, params, secondlevelkey, LINEAR_HIERARCHY[:2], message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Round-trip the signature through its encoding
+	signature, ok := new(Signature).Unmarshal(signature.Marshal())
+	if !ok {
+		t.Fatal("Could not unmarshal signature")
+	}
+
+	if !Verify(params, LINEAR_HIERARCHY[:2], message, signature) {
+		t.Fatal("Valid signature did not verify")
+	}
+	if Verify(params, LINEAR_HIERARCHY[:2], []byte("another message"), signature) {
+		t.Fatal("Signature verified for a different message")
+	}
+	if Verify(params, LINEAR_HIERARCHY[:1], message, signature) {
+		t.Fatal("Signature verified for a different identity")
+	}
+}
@@ -0,0 +1,94 @@
+package hibe_sm9
+
+import (
+	"golang.org/x/crypto/bn256"
+	"io"
+	"math/big"
+)
+
+// signcryptDomain separates the data signed by Signcrypt from ordinary
+// messages signed with Sign.
+var signcryptDomain = []byte("HIBE-SIGNCRYPT")
+
+var (
+	errSigncryptMalformed = wrapError(ErrMalformedCiphertext, "hibe: malformed signcrypted message")
+	errSigncryptInvalid   = wrapError(ErrDecryptFailed, "hibe: signcrypted message failed verification")
+)
+
+// idSize is the size of an encoded identity component.
+const idSize = 32
+
+// encodeID encodes an identity as its number of components (one byte)
+// followed by each component in idSize bytes.
+func encodeID(id []*big.Int) []byte {
+	encoded := make([]byte, 1+idSize*len(id))
+	encoded[0] = byte(len(id))
+	for i, component := range id {
+		new(big.Int).Mod(component, bn256.Order).FillBytes(encoded[1+idSize*i : 1+idSize*(i+1)])
+	}
+	return encoded
+}
+
+// decodeID decodes an identity encoded by encodeID from the start of encoded,
+// returning the rest of the input.
+func decodeID(encoded []byte) ([]*big.Int, []byte, bool) {
+	if len(encoded) == 0 || len(encoded) < 1+idSize*int(encoded[0]) {
+		return nil, nil, false
+	}
+	id := make([]*big.Int, encoded[0])
+	for i := range id {
+		id[i] = new(big.Int).SetBytes(encoded[1+idSize*i : 1+idSize*(i+1)])
+	}
+	return id, encoded[1+idSize*len(id):], true
+}
+
+// signcryptSigned returns the data that the sender signs: the recipient is
+// included so that a signcrypted message cannot be re-encrypted to another
+// recipient and still verify.
+func signcryptSigned(recipient []*big.Int, message []byte) []byte {
+	signed := append([]byte{}, signcryptDomain...)
+	signed = append(signed, encodeID(recipient)...)
+	return append(signed, message...)
+}
+
+// Signcrypt encrypts message for recipient and authenticates it as coming from
+// sender, whose private key is senderKey. The sender signs the message
+// together with the recipient's identity, and the sender's identity, the
+// signature and the message are encrypted together with EncryptBytes, so only
+// the recipient learns who sent it. As with Sign, senderKey must be able to
+// delegate one more level.
+func Signcrypt(random io.Reader, params *Params, senderKey *PrivateKey, sender []*big.Int, recipient []*big.Int, message []byte) ([]byte, error) {
+	signature, err := Sign(random, params, senderKey, sender, signcryptSigned(recipient, message))
+	if err != nil {
+		return nil, err
+	}
+
+	payload := encodeID(sender)
+	payload = append(payload, signature.Marshal()...)
+	payload = append(payload, message...)
+	return EncryptBytes(random, params, recipient, payload)
+}
+
+// Unsigncrypt decrypts a message produced by Signcrypt with the private key of
+// recipient, verifies it, and returns the sender's identity and the message.
+func Unsigncrypt(params *Params, recipientKey *PrivateKey, recipient []*big.Int, ciphertext []byte) (sender []*big.Int, message []byte, err error) {
+	payload, err := DecryptBytes(recipientKey, ciphertext)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sender, rest, ok := decodeID(payload)
+	if !ok || len(rest) < 3<<geShift {
+		return nil, nil, errSigncryptMalformed
+	}
+	signature, ok := new(Signature).Unmarshal(rest[:3<<geShift])
+	if !ok {
+		return nil, nil, errSigncryptMalformed
+	}
+	message = rest[3<<geShift:]
+
+	if !Verify(params, sender, signcryptSigned(recipient, message), signature) {
+		return nil, nil, errSigncryptInvalid
+	}
+	return sender, message, nil
+}
@@ -0,0 +1,59 @@
+package hibe_sm9
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func TestSigncrypt(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := []*big.Int{big.NewInt(1), big.NewInt(2)}
+	bob := []*big.Int{big.NewInt(1), big.NewInt(3)}
+	carol := []*big.Int{big.NewInt(1), big.NewInt(4)}
+	alicekey, err := KeyGenFromMaster(rand.Reader, params, master, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobkey, err := KeyGenFromMaster(rand.Reader, params, master, bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	carolkey, err := KeyGenFromMaster(rand.Reader, params, master, carol)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ciphertext, err := Signcrypt(rand.Reader, params, alicekey, alice, bob, []byte("hello bob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender, message, err := Unsigncrypt(params, bobkey, bob, ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(message) != "hello bob" || len(sender) != 2 || sender[1].Cmp(alice[1]) != 0 {
+		t.Fatal("Unsigncrypt returned the wrong sender or message")
+	}
+
+	if _, _, err = Unsigncrypt(params, carolkey, carol, ciphertext); err == nil {
+		t.Fatal("Other recipient unsigncrypted the message")
+	}
+
+	// Bob cannot pass Alice's message on to Carol as if Alice had sent it
+	// to her
+	payload, err := DecryptBytes(bobkey, ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forwarded, err := EncryptBytes(rand.Reader, params, carol, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = Unsigncrypt(params, carolkey, carol, forwarded); err != errSigncryptInvalid {
+		t.Fatal("Forwarded message verified for a different recipient")
+	}
+}
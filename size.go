@@ -0,0 +1,92 @@
+package hibe_sm9
+
+// MaxEncodedSize bounds the length of the encodings that Unmarshal accepts
+// for parameters, private keys and ciphertexts. Longer inputs are rejected
+// before anything is decoded, so that untrusted input cannot make a service
+// decode and validate an arbitrarily deep hierarchy. The default admits
+// hierarchies of several hundred levels; a service that knows its hierarchy
+// may lower it, for instance to the Size of its parameters, at startup.
+var MaxEncodedSize = 1 << 16
+
+// hybridOverhead is the DEM and length prefix of a hybrid ciphertext and the
+// tag of its AEAD, which is the same for AES-GCM and SM4-GCM.
+const hybridOverhead = 4 + 16
+
+// Size returns the length of Marshal(opts...), without encoding anything.
+func (params *Params) Size(opts ...MarshalOption) int {
+	size := headerSize
+	if params.IdentityHash != IdentityHashSHA256 {
+		size += 1 << geShift
+	}
+	levels := len(params.H)
+	if params.HSeed != nil {
+		size += 1 << geShift
+		levels = 0
+	}
+	if compressed(opts) {
+		size += 1 + 2*compressedG2Size + (2+levels)*compressedG1Size
+		if params.Anonymous() {
+			size += (1 + levels) * compressedG2Size
+		}
+		return size
+	}
+	size += (6 + levels) << geShift
+	if params.Anonymous() {
+		size += (3 + 2*levels) << geShift
+	}
+	return size
+}
+
+// Size returns the length of Marshal(opts...), without encoding anything.
+func (key *PrivateKey) Size(opts ...MarshalOption) int {
+	if compressed(opts) {
+		if key.A1Hat != nil {
+			return headerSize + 1 + 2*compressedG1Size
+		}
+		return headerSize + 1 + compressedG2Size + (1+len(key.B))*compressedG1Size
+	}
+	if key.A1Hat != nil {
+		return headerSize + 2<<geShift
+	}
+	return headerSize + (3+len(key.B))<<geShift
+}
+
+// Size returns the length of Marshal(opts...), without encoding anything.
+func (ciphertext *Ciphertext) Size(opts ...MarshalOption) int {
+	anonymous := ciphertext.CHat != nil
+	return ciphertextSize(anonymous, compressed(opts)) + len(ciphertext.Tag)
+}
+
+// ciphertextSize is the length of an encoded ciphertext without a tag.
+func ciphertextSize(anonymous bool, compressed bool) int {
+	switch {
+	case compressed && anonymous:
+		return headerSize + 1 + gtSize + 2*compressedG2Size
+	case compressed:
+		return headerSize + 1 + gtSize + compressedG2Size + compressedG1Size
+	case anonymous:
+		return headerSize + 10<<geShift
+	}
+	return headerSize + 9<<geShift
+}
+
+// MaxPrivateKeySize returns the length of the largest encoded private key in
+// the hierarchy, that of a key at the first level.
+func MaxPrivateKeySize(params *Params) int {
+	if params.Anonymous() {
+		return headerSize + 2<<geShift
+	}
+	return headerSize + (2+params.MaximumDepth())<<geShift
+}
+
+// MaxCiphertextSize returns the length of the largest encoded ciphertext
+// under the parameters, uncompressed and with an integrity tag.
+func MaxCiphertextSize(params *Params) int {
+	return ciphertextSize(params.Anonymous(), false) + IntegrityTagSize
+}
+
+// MaxHybridOverhead returns the largest difference in length between the
+// output of EncryptBytes and its plaintext under the parameters.
+func MaxHybridOverhead(params *Params) int {
+	return hybridOverhead + MaxCiphertextSize(params)
+}
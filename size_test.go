@@ -0,0 +1,72 @@
+package hibe_sm9
+
+import (
+	"crypto/rand"
+	"testing"
+)
+
+func TestSize(t *testing.T) {
+	for name, opts := range map[string][]SetupOption{
+		"default":       nil,
+		"anonymous":     {WithAnonymity()},
+		"derived H":     {WithDerivedH()},
+		"identity hash": {WithIdentityHash(IdentityHashSM3)},
+	} {
+		params, master, err := Setup(rand.Reader, 4, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[:1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		plain, err := Encrypt(rand.Reader, params, LINEAR_HIERARCHY[:1], NewMessage())
+		if err != nil {
+			t.Fatal(err)
+		}
+		tagged, err := Encrypt(rand.Reader, params, LINEAR_HIERARCHY[:1], NewMessage(), WithIntegrityTag())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, marshalOpts := range [][]MarshalOption{nil, {WithCompression()}} {
+			if params.Size(marshalOpts...) != len(params.Marshal(marshalOpts...)) {
+				t.Fatalf("%s: wrong size of parameters", name)
+			}
+			if key.Size(marshalOpts...) != len(key.Marshal(marshalOpts...)) {
+				t.Fatalf("%s: wrong size of private key", name)
+			}
+			for _, ciphertext := range []*Ciphertext{plain, tagged} {
+				if ciphertext.Size(marshalOpts...) != len(ciphertext.Marshal(marshalOpts...)) {
+					t.Fatalf("%s: wrong size of ciphertext", name)
+				}
+			}
+		}
+		if MaxPrivateKeySize(params) != key.Size() || MaxCiphertextSize(params) != tagged.Size() {
+			t.Fatalf("%s: wrong maximum size", name)
+		}
+		hybrid, err := EncryptBytes(rand.Reader, params, LINEAR_HIERARCHY[:1], []byte("data"), WithIntegrityTag())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(hybrid)-len("data") > MaxHybridOverhead(params) {
+			t.Fatalf("%s: hybrid overhead exceeds the maximum", name)
+		}
+	}
+}
+
+func TestMaxEncodedSize(t *testing.T) {
+	params, _, err := Setup(rand.Reader, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded := params.Marshal()
+	defer func(max int) { MaxEncodedSize = max }(MaxEncodedSize)
+	MaxEncodedSize = len(encoded)
+	if _, ok := new(Params).Unmarshal(encoded); !ok {
+		t.Fatal("Encoding of the maximum size was rejected")
+	}
+	MaxEncodedSize = len(encoded) - 1
+	if _, ok := new(Params).Unmarshal(encoded); ok {
+		t.Fatal("Encoding above the maximum size was accepted")
+	}
+}
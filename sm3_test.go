@@ -0,0 +1,43 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestSM3(t *testing.T) {
+	vectors := []struct {
+		message string
+		digest  string
+	}{
+		// GB/T 32905-2016, appendix A
+		{"abc", "66c7f0f462eeedd9d1f2d46bdc10e4e24167c4875cf2f7a2297da02b8f4ba8e0"},
+		{"abcdabcdabcdabcdabcdabcdabcdabcdabcdabcdabcdabcdabcdabcdabcdabcd", "debe9ff92275b8a138604889c18e5a4d6fdb70e5387e5765293dcba39c0c5732"},
+	}
+	for _, vector := range vectors {
+		digest := newSM3()
+		digest.Write([]byte(vector.message))
+		if hex.EncodeToString(digest.Sum(nil)) != vector.digest {
+			t.Fatal("SM3 digest does not match the test vector")
+		}
+	}
+
+	// Writing in pieces gives the same digest, and Sum does not disturb the
+	// state
+	message := bytes.Repeat([]byte("abcdefg"), 40)
+	whole := newSM3()
+	whole.Write(message)
+	pieces := newSM3()
+	for i := 0; i < len(message); i += 13 {
+		end := i + 13
+		if end > len(message) {
+			end = len(message)
+		}
+		pieces.Write(message[i:end])
+		pieces.Sum(nil)
+	}
+	if !bytes.Equal(whole.Sum(nil), pieces.Sum(nil)) {
+		t.Fatal("Incremental SM3 digest differs")
+	}
+}
@@ -0,0 +1,30 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestSM4(t *testing.T) {
+	// GB/T 32907-2016, appendix A
+	key, _ := hex.DecodeString("0123456789abcdeffedcba9876543210")
+	block, err := newSM4(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext := make([]byte, sm4BlockSize)
+	block.Encrypt(ciphertext, key)
+	if hex.EncodeToString(ciphertext) != "681edf34d206965e86b3e94f536e4246" {
+		t.Fatal("SM4 ciphertext does not match the test vector")
+	}
+	plaintext := make([]byte, sm4BlockSize)
+	block.Decrypt(plaintext, ciphertext)
+	if !bytes.Equal(plaintext, key) {
+		t.Fatal("SM4 decryption did not invert encryption")
+	}
+
+	if _, err = newSM4(key[:8]); err == nil {
+		t.Fatal("Accepted a short SM4 key")
+	}
+}
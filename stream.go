@@ -0,0 +1,241 @@
+package hibe_sm9
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+)
+
+// StreamChunkSize is the number of bytes of plaintext in each chunk of an
+// encrypted stream, other than the last one.
+const StreamChunkSize = 64 * 1024
+
+// maxStreamHeaderSize bounds the size of the encapsulation at the start of a
+// stream, so that a corrupted length field cannot trigger a huge allocation.
+const maxStreamHeaderSize = 1 << 12
+
+var (
+	errStreamClosed    = errors.New("hibe: write to closed stream")
+	errStreamMalformed = wrapError(ErrMalformedCiphertext, "hibe: malformed encrypted stream")
+	errStreamAuth      = wrapError(ErrDecryptFailed, "hibe: encrypted stream failed authentication")
+)
+
+// streamNonce returns the AEAD nonce for the chunk with the given sequence
+// number. The last byte marks the final chunk, so that a stream cannot be
+// truncated at a chunk boundary without detection.
+func streamNonce(sequence uint64, final bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], sequence)
+	if final {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+func newStreamAEAD(secret []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptingWriter encrypts everything written to it for an identity and
+// writes the result to an underlying io.Writer. The HIBE encapsulation is
+// done once, and the data is then split into chunks of StreamChunkSize bytes,
+// each sealed with AES-GCM under a sequence-numbered nonce. Every chunk but
+// the last is full, which lets DecryptingReaderAt locate any chunk without
+// reading the ones before it. Close must be called to write the final chunk.
+type EncryptingWriter struct {
+	w        io.Writer
+	aead     cipher.AEAD
+	aad      []byte
+	buffer   []byte
+	sequence uint64
+	closed   bool
+}
+
+// NewEncryptingWriter starts an encrypted stream for id, writing the
+// encapsulation header to w immediately. The options are those of
+// Encapsulate; data given with WithAAD is authenticated with every chunk, and
+// must be given to the reader.
+func NewEncryptingWriter(random io.Reader, params *Params, id []*big.Int, w io.Writer, opts ...EncryptOption) (*EncryptingWriter, error) {
+	secret, encapsulation, err := Encapsulate(random, params, id, opts...)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newStreamAEAD(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	header := encapsulation.Marshal()
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(header)))
+	if _, err = w.Write(length[:]); err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &EncryptingWriter{
+		w:      w,
+		aead:   aead,
+		aad:    encryptOptions(opts).aad,
+		buffer: make([]byte, 0, StreamChunkSize),
+	}, nil
+}
+
+// writeChunk seals and writes one chunk, framed by a final flag and its
+// length.
+func (writer *EncryptingWriter) writeChunk(final bool) error {
+	sealed := writer.aead.Seal(nil, streamNonce(writer.sequence, final), writer.buffer, writer.aad)
+	writer.sequence++
+	writer.buffer = writer.buffer[:0]
+
+	var frame [5]byte
+	if final {
+		frame[0] = 1
+	}
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(sealed)))
+	if _, err := writer.w.Write(frame[:]); err != nil {
+		return err
+	}
+	_, err := writer.w.Write(sealed)
+	return err
+}
+
+// Write encrypts p. Data is buffered until a full chunk is available.
+func (writer *EncryptingWriter) Write(p []byte) (int, error) {
+	if writer.closed {
+		return 0, errStreamClosed
+	}
+	written := 0
+	for len(p) != 0 {
+		n := copy(writer.buffer[len(writer.buffer):StreamChunkSize], p)
+		writer.buffer = writer.buffer[:len(writer.buffer)+n]
+		p = p[n:]
+		written += n
+		if len(writer.buffer) == StreamChunkSize && len(p) != 0 {
+			if err := writer.writeChunk(false); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Close writes the final chunk. It does not close the underlying writer.
+func (writer *EncryptingWriter) Close() error {
+	if writer.closed {
+		return nil
+	}
+	writer.closed = true
+	return writer.writeChunk(true)
+}
+
+// DecryptingReader decrypts a stream produced by an EncryptingWriter. Each
+// chunk is authenticated before any of its plaintext is returned; a stream
+// that is truncated or reordered results in an error rather than io.EOF.
+type DecryptingReader struct {
+	r        io.Reader
+	aead     cipher.AEAD
+	aad      []byte
+	buffer   []byte
+	sequence uint64
+	done     bool
+}
+
+// NewDecryptingReader reads the encapsulation header from r and recovers the
+// stream key with the provided private key.
+func NewDecryptingReader(key *PrivateKey, r io.Reader, opts ...DecryptOption) (*DecryptingReader, error) {
+	aead, _, err := readStreamHeader(key, r)
+	if err != nil {
+		return nil, err
+	}
+	return &DecryptingReader{r: r, aead: aead, aad: decryptOptions(opts).aad}, nil
+}
+
+// readStreamHeader reads the encapsulation header of a stream from r, and
+// returns the AEAD for its chunks and the size of the header.
+func readStreamHeader(key *PrivateKey, r io.Reader) (cipher.AEAD, int64, error) {
+	var length [4]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, 0, err
+	}
+	size := binary.BigEndian.Uint32(length[:])
+	if size > maxStreamHeaderSize {
+		return nil, 0, errStreamMalformed
+	}
+	header := make([]byte, size)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, 0, err
+	}
+	encapsulation, ok := new(Ciphertext).Unmarshal(header)
+	if !ok {
+		return nil, 0, errStreamMalformed
+	}
+
+	secret, err := Decapsulate(key, encapsulation)
+	if err != nil {
+		return nil, 0, err
+	}
+	aead, err := newStreamAEAD(secret)
+	if err != nil {
+		return nil, 0, err
+	}
+	return aead, int64(len(length)) + int64(size), nil
+}
+
+// readChunk reads, authenticates, and decrypts the next chunk.
+func (reader *DecryptingReader) readChunk() error {
+	var frame [5]byte
+	if _, err := io.ReadFull(reader.r, frame[:]); err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	final := frame[0] == 1
+	size := binary.BigEndian.Uint32(frame[1:])
+	if frame[0] > 1 || size > StreamChunkSize+uint32(reader.aead.Overhead()) {
+		return errStreamMalformed
+	}
+
+	// Past the frame header, the end of the stream is a truncation: only
+	// the final chunk may end it.
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(reader.r, sealed); err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	plaintext, err := reader.aead.Open(sealed[:0], streamNonce(reader.sequence, final), sealed, reader.aad)
+	if err != nil {
+		return errStreamAuth
+	}
+	reader.sequence++
+	reader.buffer = plaintext
+	reader.done = final
+	return nil
+}
+
+// Read decrypts into p.
+func (reader *DecryptingReader) Read(p []byte) (int, error) {
+	for len(reader.buffer) == 0 {
+		if reader.done {
+			return 0, io.EOF
+		}
+		if err := reader.readChunk(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, reader.buffer)
+	reader.buffer = reader.buffer[n:]
+	return n, nil
+}
@@ -0,0 +1,149 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+func TestStream(t *testing.T) {
+	// Set up parameters
+	params, key, err := Setup(rand.Reader, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privkey, err := KeyGenFromMaster(rand.Reader, params, key, LINEAR_HIERARCHY)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, size := range []int{0, 1, StreamChunkSize, 3*StreamChunkSize + 17} {
+		message := make([]byte, size)
+		if _, err = rand.Read(message); err != nil {
+			t.Fatal(err)
+		}
+
+		var encrypted bytes.Buffer
+		writer, err := NewEncryptingWriter(rand.Reader, params, LINEAR_HIERARCHY, &encrypted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = writer.Write(message); err != nil {
+			t.Fatal(err)
+		}
+		if err = writer.Close(); err != nil {
+			t.Fatal(err)
+		}
+		stream := encrypted.Bytes()
+
+		reader, err := NewDecryptingReader(privkey, bytes.NewReader(stream))
+		if err != nil {
+			t.Fatal(err)
+		}
+		decrypted, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(message, decrypted) {
+			t.Fatal("Original and decrypted streams differ")
+		}
+
+		// Truncating the stream must be detected
+		reader, err = NewDecryptingReader(privkey, bytes.NewReader(stream[:len(stream)-1]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = io.ReadAll(reader); err == nil {
+			t.Fatal("Truncated stream decrypted without error")
+		}
+	}
+}
+
+func TestStreamTruncatedAfterFrame(t *testing.T) {
+	params, key, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privkey, err := KeyGenFromMaster(rand.Reader, params, key, LINEAR_HIERARCHY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var encrypted bytes.Buffer
+	writer, err := NewEncryptingWriter(rand.Reader, params, LINEAR_HIERARCHY, &encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = writer.Write(make([]byte, StreamChunkSize+10)); err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	stream := encrypted.Bytes()
+
+	// The stream is the header, a full chunk and a final chunk of 10 bytes,
+	// each chunk behind a 5-byte frame header.
+	header := 4 + int(binary.BigEndian.Uint32(stream))
+	last := len(stream) - (5 + 10 + 16)
+	for _, cut := range []int{header + 5, last, last + 5, len(stream) - 1} {
+		reader, err := NewDecryptingReader(privkey, bytes.NewReader(stream[:cut]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = io.ReadAll(reader); err == nil {
+			t.Fatalf("Stream cut at %d of %d decrypted without error", cut, len(stream))
+		}
+	}
+}
+
+func TestStreamAAD(t *testing.T) {
+	params, key, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privkey, err := KeyGenFromMaster(rand.Reader, params, key, LINEAR_HIERARCHY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := make([]byte, StreamChunkSize+5)
+	if _, err = rand.Read(message); err != nil {
+		t.Fatal(err)
+	}
+
+	var encrypted bytes.Buffer
+	writer, err := NewEncryptingWriter(rand.Reader, params, LINEAR_HIERARCHY, &encrypted, WithAAD([]byte("policy-42")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = writer.Write(message); err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	stream := encrypted.Bytes()
+
+	reader, err := NewDecryptingReader(privkey, bytes.NewReader(stream), WithDecryptAAD([]byte("policy-42")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(message, decrypted) {
+		t.Fatal("Original and decrypted streams differ")
+	}
+
+	if reader, err = NewDecryptingReader(privkey, bytes.NewReader(stream)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = io.ReadAll(reader); err == nil {
+		t.Fatal("Stream decrypted without its additional data")
+	}
+	if _, err = NewDecryptingReaderAt(privkey, bytes.NewReader(stream), int64(len(stream)), WithDecryptAAD([]byte("policy-43"))); err == nil {
+		t.Fatal("Stream decrypted with other additional data")
+	}
+}
@@ -0,0 +1,145 @@
+package hibe_sm9
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+)
+
+var errStreamOffset = errors.New("hibe: negative offset in encrypted stream")
+
+// DecryptingReaderAt decrypts arbitrary byte ranges of a stream produced by
+// an EncryptingWriter, such as a video in object storage, without reading it
+// from the beginning. Every chunk but the last holds exactly StreamChunkSize
+// bytes of plaintext, so the chunks themselves form the index: the chunk
+// holding a given offset is found by division, and only the chunks that
+// overlap a read are fetched and authenticated. Since the last chunk is
+// marked as final, truncating the stream at a chunk boundary is detected when
+// the reader is created.
+//
+// A DecryptingReaderAt is safe for concurrent use. It keeps the last chunk it
+// decrypted, so small sequential reads, such as those through an
+// io.SectionReader, decrypt each chunk once.
+type DecryptingReaderAt struct {
+	r      io.ReaderAt
+	aead   cipher.AEAD
+	aad    []byte
+	start  int64
+	frame  int64
+	chunks int64
+	total  int64
+	size   int64
+
+	lock   sync.Mutex
+	index  int64
+	cached []byte
+}
+
+// NewDecryptingReaderAt reads the encapsulation header from r, which holds an
+// encrypted stream of size bytes, recovers the stream key with the provided
+// private key, and authenticates the last chunk to find the size of the
+// plaintext.
+func NewDecryptingReaderAt(key *PrivateKey, r io.ReaderAt, size int64, opts ...DecryptOption) (*DecryptingReaderAt, error) {
+	aead, start, err := readStreamHeader(key, io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return nil, err
+	}
+	reader := &DecryptingReaderAt{
+		r:     r,
+		aead:  aead,
+		aad:   decryptOptions(opts).aad,
+		start: start,
+		frame: 5 + StreamChunkSize + int64(aead.Overhead()),
+		total: size,
+		index: -1,
+	}
+	body := size - start
+	if body < 5+int64(aead.Overhead()) {
+		return nil, errStreamMalformed
+	}
+	reader.chunks = (body + reader.frame - 1) / reader.frame
+
+	reader.lock.Lock()
+	defer reader.lock.Unlock()
+	last, err := reader.chunk(reader.chunks - 1)
+	if err != nil {
+		return nil, err
+	}
+	reader.size = (reader.chunks-1)*StreamChunkSize + int64(len(last))
+	return reader, nil
+}
+
+// Size returns the size of the plaintext.
+func (reader *DecryptingReaderAt) Size() int64 {
+	return reader.size
+}
+
+// chunk reads, authenticates and decrypts the chunk with the given index.
+// The caller must hold the lock.
+func (reader *DecryptingReaderAt) chunk(index int64) ([]byte, error) {
+	if index == reader.index {
+		return reader.cached, nil
+	}
+	offset := reader.start + index*reader.frame
+	final := index == reader.chunks-1
+	var frame [5]byte
+	if err := readFullAt(reader.r, frame[:], offset); err != nil {
+		return nil, err
+	}
+	size := int64(binary.BigEndian.Uint32(frame[1:]))
+	if (frame[0] == 1) != final || frame[0] > 1 || size > reader.frame-5 ||
+		(!final && size != reader.frame-5) || (final && offset+5+size != reader.total) {
+		return nil, errStreamMalformed
+	}
+
+	sealed := make([]byte, size)
+	if err := readFullAt(reader.r, sealed, offset+5); err != nil {
+		return nil, err
+	}
+	plaintext, err := reader.aead.Open(sealed[:0], streamNonce(uint64(index), final), sealed, reader.aad)
+	if err != nil {
+		return nil, errStreamAuth
+	}
+	reader.index, reader.cached = index, plaintext
+	return plaintext, nil
+}
+
+// ReadAt decrypts len(p) bytes of plaintext starting at offset off into p. As
+// required by io.ReaderAt, it returns io.EOF if fewer bytes are available.
+func (reader *DecryptingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errStreamOffset
+	}
+	reader.lock.Lock()
+	defer reader.lock.Unlock()
+	n := 0
+	for n != len(p) && off < reader.size {
+		plaintext, err := reader.chunk(off / StreamChunkSize)
+		if err != nil {
+			return n, err
+		}
+		copied := copy(p[n:], plaintext[off%StreamChunkSize:])
+		n += copied
+		off += int64(copied)
+	}
+	if n != len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// readFullAt reads exactly len(p) bytes at offset off, reporting a stream
+// that ends early as io.ErrUnexpectedEOF. Readers may return io.EOF along
+// with a full read at the end of their input.
+func readFullAt(r io.ReaderAt, p []byte, off int64) error {
+	n, err := r.ReadAt(p, off)
+	if n == len(p) {
+		return nil
+	}
+	if err == nil || err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,78 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+)
+
+func TestDecryptingReaderAt(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privkey, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, size := range []int{0, 1, StreamChunkSize, 3*StreamChunkSize + 17} {
+		message := make([]byte, size)
+		if _, err = rand.Read(message); err != nil {
+			t.Fatal(err)
+		}
+		var encrypted bytes.Buffer
+		writer, err := NewEncryptingWriter(rand.Reader, params, LINEAR_HIERARCHY, &encrypted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = writer.Write(message); err != nil {
+			t.Fatal(err)
+		}
+		if err = writer.Close(); err != nil {
+			t.Fatal(err)
+		}
+		stream := encrypted.Bytes()
+
+		reader, err := NewDecryptingReaderAt(privkey, bytes.NewReader(stream), int64(len(stream)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reader.Size() != int64(size) {
+			t.Fatal("Wrong plaintext size")
+		}
+		decrypted, err := io.ReadAll(io.NewSectionReader(reader, 0, reader.Size()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(message, decrypted) {
+			t.Fatal("Original and decrypted streams differ")
+		}
+
+		// A range across a chunk boundary, and one past the end
+		if size > StreamChunkSize {
+			part := make([]byte, 100)
+			if _, err = reader.ReadAt(part, StreamChunkSize-50); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(part, message[StreamChunkSize-50:StreamChunkSize+50]) {
+				t.Fatal("Decrypted range differs")
+			}
+		}
+		if size >= 5 {
+			if n, err := reader.ReadAt(make([]byte, 10), int64(size)-5); err != io.EOF || n != 5 {
+				t.Fatal("Read past the end did not stop at the end")
+			}
+		}
+
+		// Truncating the stream, even at a chunk boundary, must be detected
+		cut := len(stream) - 1
+		if size > StreamChunkSize {
+			cut = len(stream) - (5 + 17 + 16)
+		}
+		if _, err = NewDecryptingReaderAt(privkey, bytes.NewReader(stream[:cut]), int64(cut)); err == nil {
+			t.Fatal("Truncated stream accepted")
+		}
+	}
+}
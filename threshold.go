@@ -0,0 +1,195 @@
+package hibe_sm9
+
+import (
+	"errors"
+	"golang.org/x/crypto/bn256"
+	"io"
+	"math/big"
+)
+
+// MasterKeyShare is one share of a master key that has been split with
+// Shamir's secret sharing. Any Threshold shares together can issue keys, but
+// fewer reveal nothing about the master key.
+type MasterKeyShare struct {
+	Index     int
+	Threshold int
+	Share     *bn256.G1
+}
+
+// PrivateKeyShare is the result of running key generation with a single
+// MasterKeyShare. It is safe to send to the combiner; only Threshold of them
+// together form a usable PrivateKey.
+type PrivateKeyShare struct {
+	Index     int
+	Threshold int
+	Key       *PrivateKey
+}
+
+// checkThreshold validates the parameters of a t-of-n sharing.
+func checkThreshold(n int, t int) error {
+	if t < 1 || n < t {
+		return errors.New("hibe: threshold must satisfy 1 <= t <= n")
+	}
+	return nil
+}
+
+// splitG1 shares the point secret among n parties with threshold t, by
+// evaluating f(x) = secret + a1 x + ... + a(t-1) x^(t-1) at x = 1, ..., n,
+// where the coefficients are random points. Interpolating in the exponent
+// works because G1 is a vector space over Zp.
+func splitG1(random io.Reader, secret *bn256.G1, n int, t int) ([]*bn256.G1, error) {
+	random = randomSource(random)
+	coefficients := make([]*bn256.G1, t-1)
+	for j := range coefficients {
+		var err error
+		if _, coefficients[j], err = bn256.RandomG1(random); err != nil {
+			return nil, err
+		}
+	}
+	defer func() {
+		for _, aj := range coefficients {
+			zeroizeG1(aj)
+		}
+	}()
+
+	shares := make([]*bn256.G1, n)
+	for i := range shares {
+		x := big.NewInt(int64(i + 1))
+		power := big.NewInt(1)
+		shares[i] = deepClone(secret)
+		for _, aj := range coefficients {
+			power.Mul(power, x)
+			shares[i].Add(shares[i], new(bn256.G1).ScalarMult(aj, power))
+		}
+	}
+	return shares, nil
+}
+
+// lagrangeAtZero computes the Lagrange coefficients for interpolating a
+// polynomial at zero from its values at the provided (distinct, non-zero)
+// indices.
+func lagrangeAtZero(indices []int) ([]*big.Int, error) {
+	coefficients := make([]*big.Int, len(indices))
+	for i, xi := range indices {
+		if xi <= 0 {
+			return nil, errors.New("hibe: share index must be positive")
+		}
+		numerator := big.NewInt(1)
+		denominator := big.NewInt(1)
+		for j, xj := range indices {
+			if i == j {
+				continue
+			}
+			if xi == xj {
+				return nil, errors.New("hibe: duplicate share index")
+			}
+			numerator.Mul(numerator, big.NewInt(int64(xj)))
+			denominator.Mul(denominator, big.NewInt(int64(xj-xi)))
+		}
+		denominator.Mod(denominator, bn256.Order)
+		denominator.ModInverse(denominator, bn256.Order)
+		coefficients[i] = numerator.Mul(numerator, denominator)
+		coefficients[i].Mod(coefficients[i], bn256.Order)
+	}
+	return coefficients, nil
+}
+
+// SplitMaster splits the master key into n shares, any t of which can be used
+// (via PartialKeyGen and KeyGenFromMasterShares) to issue keys. The caller
+// should destroy the master key afterwards with ZeroizeMasterKey.
+func SplitMaster(random io.Reader, master MasterKey, n int, t int) ([]*MasterKeyShare, error) {
+	if err := checkThreshold(n, t); err != nil {
+		return nil, err
+	}
+	points, err := splitG1(random, master, n, t)
+	if err != nil {
+		return nil, err
+	}
+	shares := make([]*MasterKeyShare, n)
+	for i, point := range points {
+		shares[i] = &MasterKeyShare{Index: i + 1, Threshold: t, Share: point}
+	}
+	return shares, nil
+}
+
+// SetupThreshold is like Setup, but returns the master key split into n
+// shares with threshold t rather than the master key itself.
+func SetupThreshold(random io.Reader, l int, n int, t int) (*Params, []*MasterKeyShare, error) {
+	if err := checkThreshold(n, t); err != nil {
+		return nil, nil, err
+	}
+	params, master, err := Setup(random, l)
+	if err != nil {
+		return nil, nil, err
+	}
+	shares, err := SplitMaster(random, master, n, t)
+	ZeroizeMasterKey(master)
+	if err != nil {
+		return nil, nil, err
+	}
+	return params, shares, nil
+}
+
+// PartialKeyGen runs key generation for id using a single master key share.
+// Each share holder uses its own randomness; the combined key is still
+// correctly distributed, with randomness equal to the interpolation of the
+// share holders' randomness.
+func PartialKeyGen(random io.Reader, params *Params, share *MasterKeyShare, id []*big.Int) (*PrivateKeyShare, error) {
+	if params.Anonymous() {
+		// The private generator of an anonymous hierarchy is derived from the
+		// whole master key.
+		return nil, errors.New("hibe: anonymous hierarchies do not support split master keys")
+	}
+	key, err := KeyGenFromMaster(random, params, share.Share, id)
+	if err != nil {
+		return nil, err
+	}
+	return &PrivateKeyShare{Index: share.Index, Threshold: share.Threshold, Key: key}, nil
+}
+
+// KeyGenFromMasterShares combines the results of PartialKeyGen from at least
+// Threshold distinct share holders into the private key for their common ID.
+// Every component of the key is interpolated in the exponent, so no single
+// machine ever needs to hold the master key.
+func KeyGenFromMasterShares(partials []*PrivateKeyShare) (*PrivateKey, error) {
+	if len(partials) == 0 || len(partials) < partials[0].Threshold {
+		return nil, errors.New("hibe: not enough key shares")
+	}
+	partials = partials[:partials[0].Threshold]
+
+	indices := make([]int, len(partials))
+	for i, partial := range partials {
+		if partial.Key.DepthLeft() != partials[0].Key.DepthLeft() {
+			return nil, errors.New("hibe: key shares are for different depths")
+		}
+		indices[i] = partial.Index
+	}
+	lambda, err := lagrangeAtZero(indices)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &PrivateKey{
+		B:                 make([]*bn256.G1, partials[0].Key.DepthLeft()),
+		ParamsFingerprint: partials[0].Key.ParamsFingerprint,
+	}
+	for i, partial := range partials {
+		a0 := new(bn256.G1).ScalarMult(partial.Key.A0, lambda[i])
+		a1 := new(bn256.G2).ScalarMult(partial.Key.A1, lambda[i])
+		if i == 0 {
+			key.A0, key.A1 = a0, a1
+		} else {
+			key.A0.Add(key.A0, a0)
+			key.A1.Add(key.A1, a1)
+		}
+		for j, bj := range partial.Key.B {
+			term := new(bn256.G1).ScalarMult(bj, lambda[i])
+			if i == 0 {
+				key.B[j] = term
+			} else {
+				key.B[j].Add(key.B[j], term)
+			}
+		}
+	}
+	return key, nil
+}
@@ -0,0 +1,59 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestThresholdKeyGen(t *testing.T) {
+	// Set up parameters with a 3-of-5 split master key
+	params, shares, err := SetupThreshold(rand.Reader, 10, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Come up with a message to encrypt
+	message := NewMessage()
+	ciphertext, err := Encrypt(rand.Reader, params, LINEAR_HIERARCHY[:2], message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Any three share holders can generate the key together
+	var partials []*PrivateKeyShare
+	for _, share := range []*MasterKeyShare{shares[4], shares[1], shares[2]} {
+		partial, err := PartialKeyGen(rand.Reader, params, share, LINEAR_HIERARCHY[:2])
+		if err != nil {
+			t.Fatal(err)
+		}
+		partials = append(partials, partial)
+	}
+
+	if _, err = KeyGenFromMasterShares(partials[:2]); err == nil {
+		t.Fatal("Combined fewer shares than the threshold")
+	}
+
+	key, err := KeyGenFromMasterShares(partials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted := mustDecrypt(t, key, ciphertext)
+	if !bytes.Equal(message.Marshal(), decrypted.Marshal()) {
+		t.Fatal("Original and decrypted messages differ")
+	}
+
+	// The combined key can delegate like any other
+	child, err := KeyGenFromParent(rand.Reader, params, key, LINEAR_HIERARCHY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err = Encrypt(rand.Reader, params, LINEAR_HIERARCHY, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted = mustDecrypt(t, child, ciphertext)
+	if !bytes.Equal(message.Marshal(), decrypted.Marshal()) {
+		t.Fatal("Original and decrypted messages differ")
+	}
+}
@@ -0,0 +1,189 @@
+// Package timelock encrypts messages that cannot be decrypted before a given
+// time, using the last three levels of a HIBE hierarchy for the release day
+// (year, month and day, as in .../2025/06/11) below the identity of a trusted
+// release service.
+//
+// Anyone can encrypt until a day with the public parameters alone. The
+// release service holds the key for its identity and, from the start of each
+// day (UTC), publishes the key for that day, with which every message locked
+// until then can be decrypted. Since keys delegate, the key for a month or a
+// year decrypts every message locked until a day within it, which lets the
+// service catch up late subscribers with a few keys instead of one per day
+// (see Schedule).
+//
+// The service must be trusted both to release keys on time and not to release
+// them early; nothing in the scheme enforces either.
+package timelock
+
+import (
+	"errors"
+	"golang.org/x/crypto/bn256"
+	"hibe_sm9"
+	"io"
+	"math/big"
+	"time"
+)
+
+// Levels is the number of hierarchy levels used for the release day.
+const Levels = 3
+
+var (
+	// ErrNotYetReleased is returned by a release service for keys whose
+	// release time has not come.
+	ErrNotYetReleased = errors.New("timelock: key has not been released yet")
+
+	// ErrHierarchyTooShallow is returned when the hierarchy has no room for
+	// the time levels below the identity.
+	ErrHierarchyTooShallow = errors.New("timelock: hierarchy is too shallow for the identity and time levels")
+)
+
+// ReleaseDay returns the day from whose start a message locked until t can be
+// decrypted: the day of t in UTC if t is midnight, and the next day
+// otherwise, so that keys are never released before t.
+func ReleaseDay(t time.Time) time.Time {
+	day := t.UTC().Truncate(24 * time.Hour)
+	if day.Before(t) {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day
+}
+
+// Identity returns the identity in the hierarchy, below the identity id of a
+// release service, of the given number of time levels of day: 1 for its
+// year, 2 for its month and 3 for the day itself.
+func Identity(id []*big.Int, day time.Time, levels int) []*big.Int {
+	day = day.UTC()
+	path := []int{day.Year(), int(day.Month()), day.Day()}[:levels]
+	full := make([]*big.Int, len(id), len(id)+levels)
+	copy(full, id)
+	for _, component := range path {
+		full = append(full, big.NewInt(int64(component)))
+	}
+	return full
+}
+
+// Ciphertext is a message locked until its release day.
+type Ciphertext struct {
+	Release time.Time
+	*hibe_sm9.Ciphertext
+}
+
+// EncryptUntil encrypts message so that it can only be decrypted once the
+// release service with identity id has released the key for ReleaseDay(t).
+func EncryptUntil(random io.Reader, params *hibe_sm9.Params, id []*big.Int, t time.Time, message *bn256.GT) (*Ciphertext, error) {
+	if len(id)+Levels > params.MaximumDepth() {
+		return nil, ErrHierarchyTooShallow
+	}
+	release := ReleaseDay(t)
+	ciphertext, err := hibe_sm9.Encrypt(random, params, Identity(id, release, Levels), message)
+	if err != nil {
+		return nil, err
+	}
+	return &Ciphertext{Release: release, Ciphertext: ciphertext}, nil
+}
+
+// ReleaseService hands out the keys of a release service once their time has
+// come. It may be a remote service; Service is the implementation run by the
+// holder of the service key.
+type ReleaseService interface {
+	// Release returns the key for the identity of the given day, or
+	// ErrNotYetReleased before the start of that day.
+	Release(day time.Time) (*hibe_sm9.PrivateKey, error)
+}
+
+// Decrypt decrypts a locked message with the key for its release day,
+// obtained from service.
+func Decrypt(service ReleaseService, ciphertext *Ciphertext) (*bn256.GT, error) {
+	key, err := service.Release(ciphertext.Release)
+	if err != nil {
+		return nil, err
+	}
+	return hibe_sm9.Decrypt(key, ciphertext.Ciphertext)
+}
+
+// Service is a release service holding the key for its identity.
+type Service struct {
+	params *hibe_sm9.Params
+	key    *hibe_sm9.PrivateKey
+	id     []*big.Int
+	random io.Reader
+
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+}
+
+// NewService creates a release service from the key for its identity id.
+func NewService(random io.Reader, params *hibe_sm9.Params, key *hibe_sm9.PrivateKey, id []*big.Int) (*Service, error) {
+	if len(id)+Levels > params.MaximumDepth() {
+		return nil, ErrHierarchyTooShallow
+	}
+	return &Service{params: params, key: key, id: id, random: random, Now: time.Now}, nil
+}
+
+// Release implements ReleaseService.
+func (service *Service) Release(day time.Time) (*hibe_sm9.PrivateKey, error) {
+	return service.Key(Release{Period: day, Levels: Levels})
+}
+
+// Key derives the key identified by release, once its release time has come.
+// The At field of release is ignored: the release time is computed from the
+// period.
+func (service *Service) Key(release Release) (*hibe_sm9.PrivateKey, error) {
+	if service.Now().Before(releaseTime(release.Period, release.Levels)) {
+		return nil, ErrNotYetReleased
+	}
+	key := service.key
+	for k := 1; k <= release.Levels; k++ {
+		child, err := hibe_sm9.KeyGenFromParent(service.random, service.params, key, Identity(service.id, release.Period, k))
+		if err != nil {
+			return nil, err
+		}
+		if key != service.key {
+			key.Zeroize()
+		}
+		key = child
+	}
+	return key, nil
+}
+
+// releaseTime returns the start of the last day of the year (for 1 level),
+// month (for 2) or day (for 3) of day, from which on its key decrypts nothing
+// locked until a day that has not started.
+func releaseTime(day time.Time, levels int) time.Time {
+	day = day.UTC()
+	switch levels {
+	case 1:
+		return time.Date(day.Year(), time.December, 31, 0, 0, 0, 0, time.UTC)
+	case 2:
+		return time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// Release identifies a key that a release service publishes: the key for
+// Identity(id, Period, Levels), published at At.
+type Release struct {
+	At     time.Time
+	Period time.Time
+	Levels int
+}
+
+// Schedule lists the keys a release service publishes from the start of the
+// day of from until to: the key for each day at its start, and the keys for
+// each month and year at the start of their last day. Subscribers that missed
+// some releases only need the latest year and month keys and the day keys
+// since.
+func Schedule(from time.Time, to time.Time) []Release {
+	var releases []Release
+	for day := releaseTime(from, Levels); !day.After(to); day = day.AddDate(0, 0, 1) {
+		releases = append(releases, Release{At: day, Period: day, Levels: Levels})
+		if next := day.AddDate(0, 0, 1); next.Day() == 1 {
+			releases = append(releases, Release{At: day, Period: day, Levels: 2})
+			if next.Month() == time.January {
+				releases = append(releases, Release{At: day, Period: day, Levels: 1})
+			}
+		}
+	}
+	return releases
+}
@@ -0,0 +1,90 @@
+package timelock
+
+import (
+	"bytes"
+	"crypto/rand"
+	"hibe_sm9"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestTimelock(t *testing.T) {
+	params, master, err := hibe_sm9.Setup(rand.Reader, 1+Levels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := []*big.Int{big.NewInt(7)}
+	key, err := hibe_sm9.KeyGenFromMaster(rand.Reader, params, master, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service, err := NewService(rand.Reader, params, key, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := hibe_sm9.HashToGT([]byte("message"))
+	ciphertext, err := EncryptUntil(rand.Reader, params, id, time.Date(2025, time.June, 11, 10, 0, 0, 0, time.UTC), message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ciphertext.Release.Equal(time.Date(2025, time.June, 12, 0, 0, 0, 0, time.UTC)) {
+		t.Fatal("Release day is not the next midnight")
+	}
+
+	service.Now = func() time.Time { return time.Date(2025, time.June, 11, 23, 59, 0, 0, time.UTC) }
+	if _, err = Decrypt(service, ciphertext); err != ErrNotYetReleased {
+		t.Fatal("Key was released early")
+	}
+	if _, err = service.Key(Release{Period: ciphertext.Release, Levels: 2}); err != ErrNotYetReleased {
+		t.Fatal("Month key was released early")
+	}
+
+	service.Now = func() time.Time { return time.Date(2025, time.June, 12, 0, 0, 0, 0, time.UTC) }
+	decrypted, err := Decrypt(service, ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(message.Marshal(), decrypted.Marshal()) {
+		t.Fatal("Original and decrypted messages differ")
+	}
+
+	// The month key, released on the last day of the month, covers the day
+	service.Now = func() time.Time { return time.Date(2025, time.June, 30, 0, 0, 0, 0, time.UTC) }
+	monthKey, err := service.Key(Release{Period: ciphertext.Release, Levels: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dayKey, err := hibe_sm9.KeyGenFromParent(rand.Reader, params, monthKey, Identity(id, ciphertext.Release, Levels))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decrypted, err = hibe_sm9.Decrypt(dayKey, ciphertext.Ciphertext); err != nil || !bytes.Equal(message.Marshal(), decrypted.Marshal()) {
+		t.Fatal("Month key does not decrypt")
+	}
+
+	shallow, _, err := hibe_sm9.Setup(rand.Reader, Levels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = EncryptUntil(rand.Reader, shallow, id, time.Now(), message); err != ErrHierarchyTooShallow {
+		t.Fatal("Encrypted in a hierarchy without room for the time levels")
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	releases := Schedule(time.Date(2025, time.December, 30, 12, 0, 0, 0, time.UTC), time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC))
+	levels := []int{3, 3, 2, 1, 3}
+	if len(releases) != len(levels) {
+		t.Fatalf("Schedule has %d releases", len(releases))
+	}
+	for i, release := range releases {
+		if release.Levels != levels[i] || release.At.Before(releaseTime(release.Period, release.Levels)) {
+			t.Fatal("Wrong release at", i)
+		}
+	}
+	if midnight := time.Date(2025, time.June, 12, 0, 0, 0, 0, time.UTC); !ReleaseDay(midnight).Equal(midnight) {
+		t.Fatal("Midnight was rounded up")
+	}
+}
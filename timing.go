@@ -0,0 +1,61 @@
+package hibe_sm9
+
+import (
+	"crypto/rand"
+	"math"
+	"time"
+)
+
+// TimingLeakageThreshold is the conventional bound on the absolute value of
+// the statistic returned by TimingLeakage. Values above it are strong evidence
+// that the running time of the measured operation depends on its input class.
+const TimingLeakageThreshold = 4.5
+
+// timingClass accumulates running mean and variance with Welford's method.
+type timingClass struct {
+	n    float64
+	mean float64
+	m2   float64
+}
+
+func (class *timingClass) add(x float64) {
+	class.n++
+	delta := x - class.mean
+	class.mean += delta / class.n
+	class.m2 += delta * (x - class.mean)
+}
+
+func (class *timingClass) variance() float64 {
+	if class.n < 2 {
+		return 0
+	}
+	return class.m2 / (class.n - 1)
+}
+
+// TimingLeakage is a helper for auditors, in the style of dudect. It runs
+// operation the provided number of times, each time with a randomly chosen
+// input class (0 or 1), and returns Welch's t-statistic comparing the running
+// times of the two classes. For example, class 0 may decrypt with a fixed key
+// and class 1 with a random key. Compare the absolute value of the result to
+// TimingLeakageThreshold; build with the hibe_hardened tag to measure the
+// hardened code path.
+func TimingLeakage(samples int, operation func(class int)) (float64, error) {
+	classes := make([]byte, samples)
+	if _, err := rand.Read(classes); err != nil {
+		return 0, err
+	}
+
+	var measured [2]timingClass
+	for _, c := range classes {
+		class := int(c & 1)
+		start := time.Now()
+		operation(class)
+		measured[class].add(float64(time.Since(start)))
+	}
+
+	denominator := math.Sqrt(measured[0].variance()/measured[0].n + measured[1].variance()/measured[1].n)
+	if denominator == 0 || math.IsNaN(denominator) {
+		return 0, nil
+	}
+	return (measured[0].mean - measured[1].mean) / denominator, nil
+}
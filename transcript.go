@@ -0,0 +1,118 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"errors"
+	"golang.org/x/crypto/bn256"
+	"math/big"
+	"time"
+)
+
+var (
+	errDelegationChain = errors.New("hibe: delegation records do not lead to the key")
+	errDelegationEmpty = errors.New("hibe: no delegation records")
+)
+
+// DelegationRecord is the transcript of one KeyGenFromParent call, filled in
+// when it is given WithDelegationRecord. Commitment is g^t for the randomness
+// t added by the delegation, so that the A1 component of the child is
+// ParentA1 + Commitment. Records hold no secrets, and can be kept in an audit
+// log by whoever delegates.
+//
+// Records are not signed: VerifyDelegationChain shows that a chain of them is
+// consistent with a key, and they are only evidence of how the key was
+// derived if they come from a log the auditor trusts.
+type DelegationRecord struct {
+	ParamsFingerprint []byte
+	ParentFingerprint []byte
+	ChildFingerprint  []byte
+	ID                []*big.Int
+	Time              time.Time
+	ParentA1          *bn256.G2
+	Commitment        *bn256.G2
+}
+
+// WithDelegationRecord makes KeyGenFromParent fill in record for the key it
+// generates. The option has no effect on KeyGenFromMaster and
+// KeyGenFromMasterOp.
+func WithDelegationRecord(record *DelegationRecord) KeyGenOption {
+	return func(config *keyGenConfig) {
+		config.record = record
+	}
+}
+
+// recordDelegation fills in the record requested in opts, if any, for the
+// delegation of key from parent.
+func recordDelegation(params *Params, parent *PrivateKey, key *PrivateKey, id []*big.Int, opts []KeyGenOption) {
+	config := keyGenOptions(opts)
+	if config.record == nil {
+		return
+	}
+	// G2 has no Neg, so subtract parent.A1 by multiplying it by p-1
+	commitment := new(bn256.G2).ScalarMult(parent.A1, new(big.Int).Sub(bn256.Order, big.NewInt(1)))
+	*config.record = DelegationRecord{
+		ParamsFingerprint: params.Fingerprint(),
+		ParentFingerprint: parent.Fingerprint(),
+		ChildFingerprint:  key.Fingerprint(),
+		ID:                append([]*big.Int{}, id...),
+		Time:              time.Now().UTC(),
+		ParentA1:          deepCloneG2(parent.A1),
+		Commitment:        commitment.Add(commitment, key.A1),
+	}
+}
+
+// childA1 returns the A1 component of the key a record was made for.
+func (record *DelegationRecord) childA1() *bn256.G2 {
+	return new(bn256.G2).Add(record.ParentA1, record.Commitment)
+}
+
+// VerifyDelegationChain checks that records, ordered from the top of the
+// hierarchy down, describe a chain of delegations that ends with leafKey:
+// each record delegates one level below the previous one, from the key the
+// previous one produced and no earlier than it, and leafKey is a valid key
+// (see VerifyKey) produced by the last one.
+func VerifyDelegationChain(params *Params, records []*DelegationRecord, leafKey *PrivateKey) error {
+	if len(records) == 0 {
+		return errDelegationEmpty
+	}
+	fingerprint := params.Fingerprint()
+	for i, record := range records {
+		if record.ParentA1 == nil || record.Commitment == nil {
+			return errDelegationChain
+		}
+		if err := checkBinding(record.ParamsFingerprint, fingerprint); err != nil {
+			return err
+		}
+		if i == 0 {
+			continue
+		}
+		previous := records[i-1]
+		if !bytes.Equal(record.ParentFingerprint, previous.ChildFingerprint) ||
+			!isChildID(previous.ID, record.ID) ||
+			record.Time.Before(previous.Time) ||
+			!bytes.Equal(record.ParentA1.Marshal(), previous.childA1().Marshal()) {
+			return errDelegationChain
+		}
+	}
+
+	last := records[len(records)-1]
+	if leafKey.A1 == nil ||
+		!bytes.Equal(last.ChildFingerprint, leafKey.Fingerprint()) ||
+		!bytes.Equal(leafKey.A1.Marshal(), last.childA1().Marshal()) {
+		return errDelegationChain
+	}
+	return VerifyKey(params, last.ID, leafKey)
+}
+
+// isChildID reports whether child is parent with one more component.
+func isChildID(parent []*big.Int, child []*big.Int) bool {
+	if len(child) != len(parent)+1 {
+		return false
+	}
+	for i, component := range parent {
+		if component.Cmp(child[i]) != 0 {
+			return false
+		}
+	}
+	return true
+}
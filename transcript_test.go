@@ -0,0 +1,49 @@
+package hibe_sm9
+
+import (
+	"crypto/rand"
+	"golang.org/x/crypto/bn256"
+	"math/big"
+	"testing"
+)
+
+func TestDelegationChain(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := make([]*DelegationRecord, 2)
+	for i := range records {
+		records[i] = new(DelegationRecord)
+		key, err = KeyGenFromParent(rand.Reader, params, key, LINEAR_HIERARCHY[:i+2], WithDelegationRecord(records[i]))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = VerifyDelegationChain(params, records, key); err != nil {
+		t.Fatal(err)
+	}
+	if err = VerifyDelegationChain(params, records[1:], key); err != nil {
+		t.Fatal(err)
+	}
+
+	// Out of order, another leaf, or a tampered commitment
+	if VerifyDelegationChain(params, []*DelegationRecord{records[1], records[0]}, key) == nil {
+		t.Fatal("Verified records out of order")
+	}
+	if VerifyDelegationChain(params, records[:1], key) == nil {
+		t.Fatal("Verified a chain that does not end with the key")
+	}
+	tampered := *records[0]
+	tampered.Commitment = new(bn256.G2).ScalarBaseMult(big.NewInt(7))
+	if VerifyDelegationChain(params, []*DelegationRecord{&tampered, records[1]}, key) == nil {
+		t.Fatal("Verified a tampered commitment")
+	}
+	if VerifyDelegationChain(params, nil, key) == nil {
+		t.Fatal("Verified an empty chain")
+	}
+}
@@ -0,0 +1,61 @@
+package hibe_sm9
+
+import "strings"
+
+// KeyUsage is a set of operations that a private key can perform.
+type KeyUsage int
+
+const (
+	// KeyUsageDecrypt is the usage of every key.
+	KeyUsageDecrypt KeyUsage = 1 << iota
+
+	// KeyUsageDelegate is the usage of keys with delegation components,
+	// which can generate keys for their descendants.
+	KeyUsageDelegate
+
+	// KeyUsageSign is the usage of keys that can sign (see Sign), which
+	// needs a delegation component as well.
+	KeyUsageSign
+)
+
+// String returns the usages separated by "|", such as "decrypt|delegate".
+func (usage KeyUsage) String() string {
+	var names []string
+	for _, name := range []struct {
+		usage KeyUsage
+		name  string
+	}{
+		{KeyUsageDecrypt, "decrypt"},
+		{KeyUsageDelegate, "delegate"},
+		{KeyUsageSign, "sign"},
+	} {
+		if usage&name.usage != 0 {
+			names = append(names, name.name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// WithLeafOnly makes key generation issue a decryption-only key, without the
+// delegation components that delegation and signing need. This is enforced
+// cryptographically, like the MaxDepth of a DelegationPolicy: the key holds
+// no material from which descendants' keys could be computed. It is also
+// cheaper to generate.
+func WithLeafOnly() KeyGenOption {
+	return func(config *keyGenConfig) {
+		config.leafOnly = true
+	}
+}
+
+// Usage returns the operations the key can perform, as determined by the key
+// material: keys without delegation components (leaf-only keys, keys
+// restricted to MaxDepth 0, and keys at the bottom of the hierarchy) and keys
+// in anonymous hierarchies can only decrypt. A DelegationPolicy with Subtrees
+// may further restrict which keys can be delegated.
+func (privkey *PrivateKey) Usage() KeyUsage {
+	usage := KeyUsageDecrypt
+	if privkey.DepthLeft() > 0 {
+		usage |= KeyUsageDelegate | KeyUsageSign
+	}
+	return usage
+}
@@ -0,0 +1,53 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestLeafOnly(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent.Usage() != KeyUsageDecrypt|KeyUsageDelegate|KeyUsageSign {
+		t.Fatal("Ordinary key lacks a usage")
+	}
+	fromMaster, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[:1], WithLeafOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromParent, err := KeyGenFromParent(rand.Reader, params, parent, LINEAR_HIERARCHY[:2], WithLeafOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, key := range []*PrivateKey{fromMaster, fromParent} {
+		id := LINEAR_HIERARCHY[:i+1]
+		if key.Usage() != KeyUsageDecrypt || key.Usage().String() != "decrypt" {
+			t.Fatal("Leaf-only key has the wrong usage")
+		}
+		if err = VerifyKey(params, id, key); err != nil {
+			t.Fatal(err)
+		}
+		message := NewMessage()
+		ciphertext, err := Encrypt(rand.Reader, params, id, message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(message.Marshal(), mustDecrypt(t, key, ciphertext).Marshal()) {
+			t.Fatal("Leaf-only key did not decrypt")
+		}
+		if _, err = KeyGenFromParent(rand.Reader, params, key, LINEAR_HIERARCHY[:i+2]); err == nil {
+			t.Fatal("Leaf-only key delegated")
+		}
+		if _, err = Sign(rand.Reader, params, key, id, []byte("message")); err == nil {
+			t.Fatal("Leaf-only key signed")
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package hibe_sm9
 
 import (
+	"bytes"
 	"crypto/sha256"
+	"errors"
 	"golang.org/x/crypto/bn256"
 	"math/big"
+	"sync/atomic"
 )
 
 // geSize is the base size in bytes of a marshalled group element. The size of
@@ -18,26 +21,159 @@ func geIndex(encoded []byte, index int, len int) []byte {
 	return encoded[index<<geShift : (index+len)<<geShift]
 }
 
-// Marshal encodes the parameters as a byte slice.
-func (params *Params) Marshal() []byte {
+// anonymousMarker is the leading slot of the encoding of the parameters of an
+// anonymous hierarchy. It cannot be confused with the first half of an
+// encoded G2 element, since 0xff...ff is larger than the field prime.
+var anonymousMarker = bytesOf(0xff, 1<<geShift)
+
+// identityHashMarker returns the leading slot of the encoding of parameters
+// with a non-default identity hash: 0xfc...fc followed by the hash. Like
+// anonymousMarker, it is larger than the field prime, and 0xfc is neither a
+// compressed tag nor the first byte of the header.
+func identityHashMarker(h IdentityHash) []byte {
+	marker := bytesOf(0xfc, 1<<geShift)
+	marker[len(marker)-1] = byte(h)
+	return marker
+}
+
+func bytesOf(b byte, n int) []byte {
+	out := make([]byte, n)
+	for i := range out {
+		out[i] = b
+	}
+	return out
+}
+
+// Marshal encodes the parameters as a byte slice, starting with a header
+// (see ParseHeader).
+func (params *Params) Marshal(opts ...MarshalOption) []byte {
+	return appendHeader(KindParams, len(params.H), params.Fingerprint(), params.marshalBody(opts))
+}
+
+// marshalBody encodes the parameters without a header. The parameters of an
+// anonymous hierarchy are prefixed with a marker slot and followed by the
+// mirrors of g3 and h1 ... hl in G2. Derived h1 ... hl are replaced by a
+// marker slot holding the depth and the seed. A non-default identity hash is
+// recorded in a further marker slot in front of everything else.
+func (params *Params) marshalBody(opts []MarshalOption) []byte {
+	var encoded []byte
+	if params.IdentityHash != IdentityHashSHA256 {
+		encoded = identityHashMarker(params.IdentityHash)
+	}
+	if params.HSeed != nil {
+		encoded = append(encoded, hSeedMarker(len(params.H), params.HSeed)...)
+		seeded := *params
+		seeded.H = nil
+		return append(encoded, seeded.marshalElements(opts)...)
+	}
+	return append(encoded, params.marshalElements(opts)...)
+}
+
+func (params *Params) marshalElements(opts []MarshalOption) []byte {
+	if compressed(opts) {
+		return params.marshalCompressed()
+	}
+
 	marshalled := make([]byte, (6+len(params.H))<<geShift)
 
 	copy(geIndex(marshalled, 0, 2), params.G.Marshal())
 	copy(geIndex(marshalled, 2, 2), params.G1.Marshal())
 	copy(geIndex(marshalled, 4, 1), params.G2.Marshal())
-	copy(geIndex(marshalled, 5, 2), params.G3.Marshal())
+	copy(geIndex(marshalled, 5, 1), params.G3.Marshal())
 	for i, hi := range params.H {
 		copy(geIndex(marshalled, 6+i, 1), hi.Marshal())
 	}
 
-	return marshalled
+	if !params.Anonymous() {
+		return marshalled
+	}
+
+	hats := make([]byte, (2+2*len(params.HHat))<<geShift)
+	copy(geIndex(hats, 0, 2), params.G3Hat.Marshal())
+	for i, hi := range params.HHat {
+		copy(geIndex(hats, 2+2*i, 2), hi.Marshal())
+	}
+
+	encoded := make([]byte, 0, len(anonymousMarker)+len(marshalled)+len(hats))
+	encoded = append(encoded, anonymousMarker...)
+	encoded = append(encoded, marshalled...)
+	return append(encoded, hats...)
 }
 
-// Unmarshal recovers the parameters from an encoded byte slice.
+// Unmarshal recovers the parameters from an encoded byte slice. The decoded
+// parameters are validated, and encodings longer than MaxEncodedSize are
+// rejected, so it is safe to call on untrusted input.
+// Compressed encodings, and headerless encodings from earlier versions, are
+// detected automatically.
 func (params *Params) Unmarshal(marshalled []byte) (*Params, bool) {
-	if len(marshalled)&((1<<geShift)-1) != 0 {
+	if len(marshalled) > MaxEncodedSize {
 		return nil, false
 	}
+	body, header, ok := stripHeader(marshalled, KindParams)
+	if !ok {
+		return nil, false
+	}
+	if _, ok = params.unmarshalBody(body); !ok || (header.Depth >= 0 && header.Depth != len(params.H)) {
+		return nil, false
+	}
+	if header.Fingerprint != nil && !bytes.Equal(header.Fingerprint, params.Fingerprint()) {
+		return nil, false
+	}
+	return params, true
+}
+
+func (params *Params) unmarshalBody(marshalled []byte) (*Params, bool) {
+	h := IdentityHashSHA256
+	if len(marshalled) >= 1<<geShift && marshalled[0] == 0xfc {
+		h = IdentityHash(marshalled[(1<<geShift)-1])
+		if h == IdentityHashSHA256 || !h.valid() || string(geIndex(marshalled, 0, 1)) != string(identityHashMarker(h)) {
+			return nil, false
+		}
+		marshalled = marshalled[1<<geShift:]
+	}
+	var seed []byte
+	var depth int
+	if len(marshalled) >= 1<<geShift && marshalled[0] == 0xfb {
+		var ok bool
+		if depth, seed, ok = parseHSeedMarker(geIndex(marshalled, 0, 1)); !ok {
+			return nil, false
+		}
+		marshalled = marshalled[1<<geShift:]
+	}
+	params.IdentityHash, params.HSeed = h, nil
+	if _, ok := params.unmarshalElements(marshalled); !ok {
+		return nil, false
+	}
+	if seed != nil {
+		if len(params.H) != 0 || params.Anonymous() {
+			return nil, false
+		}
+		if params.setHSeed(seed, depth) != nil {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+func (params *Params) unmarshalElements(marshalled []byte) (*Params, bool) {
+	if isCompressed(marshalled) {
+		return params.unmarshalCompressed(marshalled)
+	}
+	if len(marshalled)&((1<<geShift)-1) != 0 || len(marshalled) < 6<<geShift {
+		return nil, false
+	}
+
+	// Split off the G2 mirrors of an anonymous hierarchy
+	var hats []byte
+	if string(geIndex(marshalled, 0, 1)) == string(anonymousMarker) {
+		slots := (len(marshalled) >> geShift) - 1
+		if slots < 8 || (slots-8)%3 != 0 {
+			return nil, false
+		}
+		hlen := (slots - 8) / 3
+		hats = geIndex(marshalled, 7+hlen, 2+2*hlen)
+		marshalled = geIndex(marshalled, 1, 6+hlen)
+	}
 
 	params.G = new(bn256.G2)
 	if _, ok := params.G.Unmarshal(geIndex(marshalled, 0, 2)); !ok {
@@ -69,14 +205,54 @@ func (params *Params) Unmarshal(marshalled []byte) (*Params, bool) {
 		}
 	}
 
+	params.G3Hat, params.HHat = nil, nil
+	if hats != nil {
+		params.G3Hat = new(bn256.G2)
+		if _, ok := params.G3Hat.Unmarshal(geIndex(hats, 0, 2)); !ok {
+			return nil, false
+		}
+		params.HHat = make([]*bn256.G2, hlen, hlen)
+		for i := range params.HHat {
+			hi := new(bn256.G2)
+			params.HHat[i] = hi
+			if _, ok := hi.Unmarshal(geIndex(hats, 2+2*i, 2)); !ok {
+				return nil, false
+			}
+		}
+	}
+
 	// Clear any cached values
 	params.Pairing = nil
+	params.tables = nil
+
+	if params.Validate() != nil {
+		return nil, false
+	}
 
 	return params, true
 }
 
-// Marshal encodes the private key as a byte slice.
-func (key *PrivateKey) Marshal() []byte {
+// Marshal encodes the private key as a byte slice, starting with a header
+// (see ParseHeader).
+func (key *PrivateKey) Marshal(opts ...MarshalOption) []byte {
+	return appendHeader(KindPrivateKey, len(key.B), key.ParamsFingerprint, key.marshalBody(opts))
+}
+
+// marshalBody encodes the private key without a header. Keys in anonymous
+// hierarchies, which have no delegation components, are encoded as A0
+// followed by A1Hat.
+func (key *PrivateKey) marshalBody(opts []MarshalOption) []byte {
+	if compressed(opts) {
+		return key.marshalCompressed()
+	}
+
+	if key.A1Hat != nil {
+		marshalled := make([]byte, 2<<geShift)
+		copy(geIndex(marshalled, 0, 1), key.A0.Marshal())
+		copy(geIndex(marshalled, 1, 1), key.A1Hat.Marshal())
+		return marshalled
+	}
+
 	marshalled := make([]byte, (3+len(key.B))<<geShift)
 
 	copy(geIndex(marshalled, 0, 1), key.A0.Marshal())
@@ -88,9 +264,30 @@ func (key *PrivateKey) Marshal() []byte {
 	return marshalled
 }
 
-// Unmarshal recovers the private key from an encoded byte slice.
+// Unmarshal recovers the private key from an encoded byte slice. The group
+// elements of the decoded key are validated; use Validate to also check the
+// key against the parameters of its hierarchy. Compressed encodings, and
+// headerless encodings from earlier versions, are detected automatically.
 func (key *PrivateKey) Unmarshal(marshalled []byte) (*PrivateKey, bool) {
-	if len(marshalled)&((1<<geShift)-1) != 0 {
+	if len(marshalled) > MaxEncodedSize {
+		return nil, false
+	}
+	body, header, ok := stripHeader(marshalled, KindPrivateKey)
+	if !ok {
+		return nil, false
+	}
+	if _, ok = key.unmarshalBody(body); !ok || (header.Depth >= 0 && header.Depth != len(key.B)) {
+		return nil, false
+	}
+	key.ParamsFingerprint = header.Fingerprint
+	return key, true
+}
+
+func (key *PrivateKey) unmarshalBody(marshalled []byte) (*PrivateKey, bool) {
+	if isCompressed(marshalled) {
+		return key.unmarshalCompressed(marshalled)
+	}
+	if len(marshalled)&((1<<geShift)-1) != 0 || len(marshalled) < 2<<geShift {
 		return nil, false
 	}
 
@@ -99,6 +296,18 @@ func (key *PrivateKey) Unmarshal(marshalled []byte) (*PrivateKey, bool) {
 		return nil, false
 	}
 
+	key.A1, key.A1Hat, key.B = nil, nil, nil
+	if len(marshalled) == 2<<geShift {
+		key.A1Hat = new(bn256.G1)
+		if _, ok := key.A1Hat.Unmarshal(geIndex(marshalled, 1, 1)); !ok {
+			return nil, false
+		}
+		if key.validatePoints() != nil {
+			return nil, false
+		}
+		return key, true
+	}
+
 	key.A1 = new(bn256.G2)
 	if _, ok := key.A1.Unmarshal(geIndex(marshalled, 1, 2)); !ok {
 		return nil, false
@@ -114,11 +323,41 @@ func (key *PrivateKey) Unmarshal(marshalled []byte) (*PrivateKey, bool) {
 		}
 	}
 
+	if key.validatePoints() != nil {
+		return nil, false
+	}
+
 	return key, true
 }
 
-// Marshal encodes the ciphertext as a byte slice.
-func (ciphertext *Ciphertext) Marshal() []byte {
+// Marshal encodes the ciphertext as a byte slice, starting with a header
+// (see ParseHeader).
+func (ciphertext *Ciphertext) Marshal(opts ...MarshalOption) []byte {
+	return appendHeader(KindCiphertext, 0, ciphertext.ParamsFingerprint, ciphertext.marshalBody(opts))
+}
+
+// marshalBody encodes the ciphertext without a header. The integrity tag, if
+// any, follows the group elements.
+func (ciphertext *Ciphertext) marshalBody(opts []MarshalOption) []byte {
+	return append(ciphertext.marshalComponents(opts), ciphertext.Tag...)
+}
+
+// marshalComponents encodes the group elements of the ciphertext. Ciphertexts
+// in anonymous hierarchies are one slot longer, since CHat is an element of
+// G2.
+func (ciphertext *Ciphertext) marshalComponents(opts []MarshalOption) []byte {
+	if compressed(opts) {
+		return ciphertext.marshalCompressed()
+	}
+
+	if ciphertext.CHat != nil {
+		marshalled := make([]byte, 10<<geShift)
+		copy(geIndex(marshalled, 0, 6), ciphertext.A.Marshal())
+		copy(geIndex(marshalled, 6, 2), ciphertext.B.Marshal())
+		copy(geIndex(marshalled, 8, 2), ciphertext.CHat.Marshal())
+		return marshalled
+	}
+
 	marshalled := make([]byte, 9<<geShift)
 
 	copy(geIndex(marshalled, 0, 6), ciphertext.A.Marshal())
@@ -128,9 +367,52 @@ func (ciphertext *Ciphertext) Marshal() []byte {
 	return marshalled
 }
 
-// Unmarshal recovers the ciphertext from an encoded byte slice.
+// Unmarshal recovers the ciphertext from an encoded byte slice. The decoded
+// ciphertext is validated, so it is safe to call on untrusted input.
+// Compressed encodings, and headerless encodings from earlier versions, are
+// detected automatically.
 func (ciphertext *Ciphertext) Unmarshal(marshalled []byte) (*Ciphertext, bool) {
-	if len(marshalled) != 9<<geShift {
+	if len(marshalled) > MaxEncodedSize {
+		return nil, false
+	}
+	body, header, ok := stripHeader(marshalled, KindCiphertext)
+	if !ok || header.Depth > 0 {
+		return nil, false
+	}
+	if _, ok = ciphertext.unmarshalBody(body); !ok {
+		return nil, false
+	}
+	ciphertext.ParamsFingerprint = header.Fingerprint
+	return ciphertext, true
+}
+
+func (ciphertext *Ciphertext) unmarshalBody(marshalled []byte) (*Ciphertext, bool) {
+	marshalled, tag := splitIntegrityTag(marshalled)
+	if _, ok := ciphertext.unmarshalComponents(marshalled); !ok {
+		return nil, false
+	}
+	ciphertext.Tag = tag
+	return ciphertext, true
+}
+
+// splitIntegrityTag separates the integrity tag from an encoded ciphertext,
+// which is recognized by its length. No two encodings differ in length by
+// exactly IntegrityTagSize, so this is unambiguous.
+func splitIntegrityTag(marshalled []byte) ([]byte, []byte) {
+	switch len(marshalled) - IntegrityTagSize {
+	case 9 << geShift, 10 << geShift,
+		1 + gtSize + compressedG2Size + compressedG1Size, 1 + gtSize + 2*compressedG2Size:
+		split := len(marshalled) - IntegrityTagSize
+		return marshalled[:split], append([]byte{}, marshalled[split:]...)
+	}
+	return marshalled, nil
+}
+
+func (ciphertext *Ciphertext) unmarshalComponents(marshalled []byte) (*Ciphertext, bool) {
+	if isCompressed(marshalled) {
+		return ciphertext.unmarshalCompressed(marshalled)
+	}
+	if len(marshalled) != 9<<geShift && len(marshalled) != 10<<geShift {
 		return nil, false
 	}
 
@@ -142,14 +424,93 @@ func (ciphertext *Ciphertext) Unmarshal(marshalled []byte) (*Ciphertext, bool) {
 	if _, ok := ciphertext.B.Unmarshal(geIndex(marshalled, 6, 2)); !ok {
 		return nil, false
 	}
-	ciphertext.C = new(bn256.G1)
-	if _, ok := ciphertext.C.Unmarshal(geIndex(marshalled, 8, 1)); !ok {
+	ciphertext.C, ciphertext.CHat = nil, nil
+	if len(marshalled) == 10<<geShift {
+		ciphertext.CHat = new(bn256.G2)
+		if _, ok := ciphertext.CHat.Unmarshal(geIndex(marshalled, 8, 2)); !ok {
+			return nil, false
+		}
+	} else {
+		ciphertext.C = new(bn256.G1)
+		if _, ok := ciphertext.C.Unmarshal(geIndex(marshalled, 8, 1)); !ok {
+			return nil, false
+		}
+	}
+
+	// Reject encodings that do not re-encode byte for byte, so that a
+	// signature over an encoded ciphertext is not malleable.
+	if string(ciphertext.marshalComponents(nil)) != string(marshalled) || ciphertext.Validate() != nil {
 		return nil, false
 	}
 
 	return ciphertext, true
 }
 
+// Marshal encodes the signature as a byte slice.
+func (signature *Signature) Marshal(opts ...MarshalOption) []byte {
+	if compressed(opts) {
+		return signature.marshalCompressed()
+	}
+
+	marshalled := make([]byte, 3<<geShift)
+
+	copy(geIndex(marshalled, 0, 1), signature.A0.Marshal())
+	copy(geIndex(marshalled, 1, 2), signature.A1.Marshal())
+
+	return marshalled
+}
+
+// Unmarshal recovers the signature from an encoded byte slice. Compressed
+// encodings are detected automatically.
+func (signature *Signature) Unmarshal(marshalled []byte) (*Signature, bool) {
+	if isCompressed(marshalled) {
+		return signature.unmarshalCompressed(marshalled)
+	}
+	if len(marshalled) != 3<<geShift {
+		return nil, false
+	}
+
+	signature.A0 = new(bn256.G1)
+	if _, ok := signature.A0.Unmarshal(geIndex(marshalled, 0, 1)); !ok {
+		return nil, false
+	}
+	signature.A1 = new(bn256.G2)
+	if _, ok := signature.A1.Unmarshal(geIndex(marshalled, 1, 2)); !ok {
+		return nil, false
+	}
+
+	return signature, true
+}
+
+// errMalformedElement is returned when an encoded group element cannot be
+// decoded.
+var errMalformedElement = errors.New("hibe: malformed group element")
+
+// unmarshalG1, unmarshalG2 and unmarshalGT only accept canonical encodings,
+// which are re-encoded byte for byte. bn256 accepts coordinates that are not
+// reduced modulo p, so that an element has several encodings, of which
+// Marshal only produces the reduced one.
+func unmarshalG1(encoded []byte) (*bn256.G1, error) {
+	if point, ok := new(bn256.G1).Unmarshal(encoded); ok && string(point.Marshal()) == string(encoded) {
+		return point, nil
+	}
+	return nil, errMalformedElement
+}
+
+func unmarshalG2(encoded []byte) (*bn256.G2, error) {
+	if point, ok := new(bn256.G2).Unmarshal(encoded); ok && string(point.Marshal()) == string(encoded) {
+		return point, nil
+	}
+	return nil, errMalformedElement
+}
+
+func unmarshalGT(encoded []byte) (*bn256.GT, error) {
+	if element, ok := new(bn256.GT).Unmarshal(encoded); ok && string(element.Marshal()) == string(encoded) {
+		return element, nil
+	}
+	return nil, errMalformedElement
+}
+
 // HashToZp hashes a byte slice to an integer in Zp*.
 func HashToZp(bytestring []byte) *big.Int {
 	digest := sha256.Sum256(bytestring)
@@ -159,15 +520,62 @@ func HashToZp(bytestring []byte) *big.Int {
 	return bigint
 }
 
+// fieldPrime is the characteristic of the field over which bn256 is defined.
+var fieldPrime, _ = new(big.Int).SetString("65000549695646603732796438742359905742825358107623003571877145026864184071783", 10)
+
+// curveB is the constant term of the curve equation y^2 = x^3 + 3 of G1.
+var curveB = big.NewInt(3)
+
+// HashToG1 hashes a byte slice to a group element in G1, by trying successive
+// counters until the hash is the x-coordinate of a point on the curve. Unlike
+// ScalarBaseMult(HashToZp(...)), nobody knows the discrete logarithm of the
+// result. G1 has cofactor 1, so every point on the curve is in the group.
+func HashToG1(bytestring []byte) *bn256.G1 {
+	input := make([]byte, 4+len(bytestring))
+	copy(input[4:], bytestring)
+	for counter := uint32(0); ; counter++ {
+		input[0], input[1], input[2], input[3] = byte(counter>>24), byte(counter>>16), byte(counter>>8), byte(counter)
+		digest := sha256.Sum256(input)
+		x := new(big.Int).SetBytes(digest[:])
+		x.Mod(x, fieldPrime)
+
+		rhs := new(big.Int).Exp(x, big.NewInt(3), fieldPrime)
+		rhs.Add(rhs, curveB)
+		rhs.Mod(rhs, fieldPrime)
+		y := new(big.Int).ModSqrt(rhs, fieldPrime)
+		if y == nil {
+			continue
+		}
+
+		marshalled := make([]byte, geSize)
+		x.FillBytes(marshalled[:geSize/2])
+		y.FillBytes(marshalled[geSize/2:])
+		if point, ok := new(bn256.G1).Unmarshal(marshalled); ok {
+			return point
+		}
+	}
+}
+
+// pairings counts the pairings computed by the package, for benchmarks.
+var pairings uint64
+
+// pair computes the pairing e(g1, g2), counting it in pairings. Every
+// pairing of the package goes through it, so a faster implementation of the
+// bn256 pairing would only need to replace it. Note that the cgo pairing
+// libraries RELIC and mcl do not implement this curve: their 254- and
+// 256-bit BN curves are different ones, on which no existing hierarchy,
+// key or ciphertext is valid.
+func pair(g1 *bn256.G1, g2 *bn256.G2) *bn256.GT {
+	atomic.AddUint64(&pairings, 1)
+	return bn256.Pair(g1, g2)
+}
+
 // gtBase is e(g1, g2) where g1 and g2 are the base generators of G2 and G1
-var gtBase *bn256.GT
+var gtBase = bn256.Pair(new(bn256.G1).ScalarBaseMult(big.NewInt(1)),
+	new(bn256.G2).ScalarBaseMult(big.NewInt(1)))
 
 // HashToGT hashes a byte slice to a group element in GT.
 func HashToGT(bytestring []byte) *bn256.GT {
-	if gtBase == nil {
-		gtBase = bn256.Pair(new(bn256.G1).ScalarBaseMult(big.NewInt(1)),
-			new(bn256.G2).ScalarBaseMult(big.NewInt(1)))
-	}
 	return new(bn256.GT).ScalarMult(gtBase, HashToZp(bytestring))
 }
 
@@ -177,3 +585,9 @@ func deepClone(src *bn256.G1) *bn256.G1 {
 	clone, _ := new(bn256.G1).Unmarshal(data)
 	return clone
 }
+
+func deepCloneG2(src *bn256.G2) *bn256.G2 {
+	data := src.Marshal()
+	clone, _ := new(bn256.G2).Unmarshal(data)
+	return clone
+}
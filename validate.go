@@ -0,0 +1,181 @@
+package hibe_sm9
+
+import (
+	"errors"
+	"golang.org/x/crypto/bn256"
+	"math/big"
+)
+
+var (
+	errMissingElement  = errors.New("hibe: missing group element")
+	errIdentityElement = errors.New("hibe: group element is the identity")
+	errNotInSubgroup   = errors.New("hibe: group element is not in the prime-order subgroup")
+	errDepthMismatch   = wrapError(ErrDepthExceeded, "hibe: key depth is inconsistent with the parameters")
+	errModeMismatch    = wrapError(ErrCurveMismatch, "hibe: anonymous and ordinary elements are mixed")
+)
+
+// gtOne is the identity element of GT.
+var gtOne = new(bn256.GT).ScalarMult(gtBase, big.NewInt(0))
+
+func allZero(b []byte) bool {
+	for _, x := range b {
+		if x != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// checkG1 verifies that a point of G1 is present and is not the identity. G1
+// has cofactor 1, so any point on the curve (which Unmarshal already checks)
+// is in the correct subgroup.
+func checkG1(p *bn256.G1) error {
+	if p == nil {
+		return errMissingElement
+	}
+	if allZero(p.Marshal()) {
+		return errIdentityElement
+	}
+	return nil
+}
+
+// checkG2 verifies that a point of G2 is present, is not the identity, and
+// lies in the subgroup of order bn256.Order. Unlike G1, the twist has a
+// non-trivial cofactor, so being on the curve is not enough.
+func checkG2(p *bn256.G2) error {
+	if p == nil {
+		return errMissingElement
+	}
+	if allZero(p.Marshal()) {
+		return errIdentityElement
+	}
+	if !allZero(new(bn256.G2).ScalarMult(p, bn256.Order).Marshal()) {
+		return errNotInSubgroup
+	}
+	return nil
+}
+
+// checkGT verifies that an element of GT is present, is not the identity, and
+// lies in the subgroup of order bn256.Order.
+func checkGT(e *bn256.GT) error {
+	if e == nil {
+		return errMissingElement
+	}
+	one := gtOne.Marshal()
+	if string(e.Marshal()) == string(one) {
+		return errIdentityElement
+	}
+	if !inGT(e) {
+		return errNotInSubgroup
+	}
+	return nil
+}
+
+// inGT reports whether e lies in GT, the subgroup of order bn256.Order of
+// the multiplicative group of Fp12 in which pairings take their values.
+func inGT(e *bn256.GT) bool {
+	return string(new(bn256.GT).ScalarMult(e, bn256.Order).Marshal()) == string(gtOne.Marshal())
+}
+
+// Validate checks that all of the group elements in the parameters are
+// present, in the correct subgroup, and not the identity, that the identity
+// hash is known, and that h1 ... hl match the seed, if there is one.
+func (params *Params) Validate() error {
+	if !params.IdentityHash.valid() {
+		return errIdentityHash
+	}
+	if err := checkG2(params.G); err != nil {
+		return err
+	}
+	if err := checkG2(params.G1); err != nil {
+		return err
+	}
+	if err := checkG1(params.G2); err != nil {
+		return err
+	}
+	if err := checkG1(params.G3); err != nil {
+		return err
+	}
+	for _, hi := range params.H {
+		if err := checkG1(hi); err != nil {
+			return err
+		}
+	}
+	if err := params.checkHSeed(); err != nil {
+		return err
+	}
+	if params.G3Hat == nil {
+		if params.HHat != nil {
+			return errModeMismatch
+		}
+		return nil
+	}
+	if len(params.HHat) != len(params.H) {
+		return errModeMismatch
+	}
+	if err := checkG2(params.G3Hat); err != nil {
+		return err
+	}
+	for _, hi := range params.HHat {
+		if err := checkG2(hi); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validatePoints checks the group elements of the private key without
+// reference to any parameters.
+func (privkey *PrivateKey) validatePoints() error {
+	if err := checkG1(privkey.A0); err != nil {
+		return err
+	}
+	if privkey.A1Hat != nil {
+		if privkey.A1 != nil || privkey.B != nil {
+			return errModeMismatch
+		}
+		return checkG1(privkey.A1Hat)
+	}
+	if err := checkG2(privkey.A1); err != nil {
+		return err
+	}
+	for _, bi := range privkey.B {
+		if err := checkG1(bi); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate checks that all of the group elements in the private key are
+// present, in the correct subgroup, and not the identity, and that the number
+// of delegation components is consistent with the maximum depth of params. A
+// key always sits at least one level below the root, so it can have at most
+// MaximumDepth() - 1 of them.
+func (privkey *PrivateKey) Validate(params *Params) error {
+	if privkey.DepthLeft() >= params.MaximumDepth() {
+		return errDepthMismatch
+	}
+	if params.Anonymous() != (privkey.A1Hat != nil) {
+		return errModeMismatch
+	}
+	return privkey.validatePoints()
+}
+
+// Validate checks that all of the group elements in the ciphertext are present,
+// in the correct subgroup, and not the identity.
+func (ciphertext *Ciphertext) Validate() error {
+	if err := checkGT(ciphertext.A); err != nil {
+		return err
+	}
+	if err := checkG2(ciphertext.B); err != nil {
+		return err
+	}
+	if ciphertext.CHat != nil {
+		if ciphertext.C != nil {
+			return errModeMismatch
+		}
+		return checkG2(ciphertext.CHat)
+	}
+	return checkG1(ciphertext.C)
+}
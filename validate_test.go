@@ -0,0 +1,100 @@
+package hibe_sm9
+
+import (
+	"crypto/rand"
+	"errors"
+	"golang.org/x/crypto/bn256"
+	"testing"
+)
+
+func TestValidateRoundTrip(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := Encrypt(rand.Reader, params, LINEAR_HIERARCHY, NewMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = params.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err = key.Validate(params); err != nil {
+		t.Fatal(err)
+	}
+	if err = ciphertext.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := new(Params).Unmarshal(params.Marshal()); !ok {
+		t.Fatal("Could not unmarshal valid parameters")
+	}
+	if _, ok := new(PrivateKey).Unmarshal(key.Marshal()); !ok {
+		t.Fatal("Could not unmarshal valid private key")
+	}
+	if _, ok := new(Ciphertext).Unmarshal(ciphertext.Marshal()); !ok {
+		t.Fatal("Could not unmarshal valid ciphertext")
+	}
+}
+
+func TestValidateRejectsIdentity(t *testing.T) {
+	params, _, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Replace h1 with the point at infinity
+	marshalled := params.Marshal()
+	for i := range geIndex(marshalled[headerSize:], 6, 1) {
+		marshalled[headerSize+(6<<geShift)+i] = 0
+	}
+	if _, ok := new(Params).Unmarshal(marshalled); ok {
+		t.Fatal("Accepted parameters containing the identity")
+	}
+
+	ciphertext, err := Encrypt(rand.Reader, params, LINEAR_HIERARCHY, NewMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext.B = new(bn256.G2).ScalarMult(params.G, bn256.Order)
+	if ciphertext.Validate() == nil {
+		t.Fatal("Accepted ciphertext containing the identity")
+	}
+}
+
+func TestValidateRejectsMalformed(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Truncated encodings must not cause a panic
+	if _, ok := new(Params).Unmarshal(params.Marshal()[:2<<geShift]); ok {
+		t.Fatal("Accepted truncated parameters")
+	}
+	if _, ok := new(PrivateKey).Unmarshal(key.Marshal()[:1<<geShift]); ok {
+		t.Fatal("Accepted truncated private key")
+	}
+
+	// A point that is not on the curve
+	marshalled := key.Marshal()
+	marshalled[headerSize+5] ^= 1
+	if _, ok := new(PrivateKey).Unmarshal(marshalled); ok {
+		t.Fatal("Accepted private key with a point off the curve")
+	}
+
+	// A key that claims more delegation power than the hierarchy allows
+	key.B = append(key.B, key.B[0])
+	if err = key.Validate(params); !errors.Is(err, ErrDepthExceeded) || errors.Is(err, ErrCurveMismatch) {
+		t.Fatal("Accepted private key deeper than the hierarchy")
+	}
+}
@@ -0,0 +1,134 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bn256"
+	"math/big"
+)
+
+// verifyCoefficientBits is the size of the random coefficients with which
+// VerifyKey and VerifyKeys combine equations. A false key passes with
+// probability about 2^-verifyCoefficientBits.
+const verifyCoefficientBits = 128
+
+var (
+	errKeyMismatch     = wrapError(ErrInvalidID, "hibe: private key is not the key of the identity")
+	errVerifyAnonymous = errors.New("hibe: keys in an anonymous hierarchy cannot be verified publicly")
+	errVerifyCount     = errors.New("hibe: number of identities and keys differ")
+)
+
+func verifyCoefficient() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), verifyCoefficientBits))
+}
+
+// verifyTerms checks the delegation components of key, and returns the terms
+// of its main equation e(A0, g) = e(g2, g1) * e(g3 * h1^I1 * ... * hk^Ik, A1)
+// scaled by c: c*A0 for the left, and c*(g3 * h1^I1 * ... * hk^Ik) to pair
+// with A1 on the right.
+func verifyTerms(params *Params, id []*big.Int, key *PrivateKey, c *big.Int) (*bn256.G1, *bn256.G1, error) {
+	if err := checkID(params, id); err != nil {
+		return nil, nil, err
+	}
+	if params.Anonymous() || key.A1 == nil {
+		return nil, nil, errVerifyAnonymous
+	}
+	if err := key.Validate(params); err != nil {
+		return nil, nil, err
+	}
+	if !key.isKeyAtDepth(params, len(id)) {
+		return nil, nil, errKeyMismatch
+	}
+	if err := checkBinding(key.ParamsFingerprint, params.Fingerprint()); err != nil {
+		return nil, nil, err
+	}
+
+	// Each Bj = h(k+j)^r shares the randomness of A1 = g^r, so
+	// e(sum dj*Bj, g) = e(sum dj*h(k+j), A1) checks them all at once
+	if len(key.B) != 0 {
+		bs := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+		hs := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+		term := new(bn256.G1)
+		for j, bj := range key.B {
+			d, err := verifyCoefficient()
+			if err != nil {
+				return nil, nil, err
+			}
+			bs.Add(bs, term.ScalarMult(bj, d))
+			hs.Add(hs, term.ScalarMult(params.H[len(id)+j], d))
+		}
+		if !bytes.Equal(pair(bs, params.G).Marshal(), pair(hs, key.A1).Marshal()) {
+			return nil, nil, errKeyMismatch
+		}
+	}
+
+	return new(bn256.G1).ScalarMult(key.A0, c), new(bn256.G1).ScalarMult(idProduct(params, id), c), nil
+}
+
+// VerifyKey checks that key is the private key of id in the hierarchy with
+// the provided parameters, with the pairing equations
+//
+//	e(A0, g) = e(g2, g1) * e(g3 * h1^I1 * ... * hk^Ik, A1)
+//	e(Bj, g) = e(h(k+j), A1)
+//
+// so that a recipient can trust a key received from a PKG before using it.
+// It returns an error wrapping ErrInvalidID if the key does not match.
+// Keys in anonymous hierarchies cannot be verified this way.
+func VerifyKey(params *Params, id []*big.Int, key *PrivateKey) error {
+	return VerifyKeys(params, [][]*big.Int{id}, []*PrivateKey{key})
+}
+
+// VerifyKeys is VerifyKey for many keys, such as those of a fleet of devices.
+// The main equations of all keys are combined with random coefficients, which
+// saves one pairing per key; if the combined check fails, the keys are
+// checked one at a time, and the error names the first key that does not
+// match.
+func VerifyKeys(params *Params, ids [][]*big.Int, keys []*PrivateKey) error {
+	if len(ids) != len(keys) {
+		return errVerifyCount
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	params.Precache()
+
+	left := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+	right := new(bn256.GT).ScalarMult(gtBase, big.NewInt(0))
+	sum := new(big.Int)
+	for i := range keys {
+		c, err := verifyCoefficient()
+		if err != nil {
+			return err
+		}
+		a0, product, err := verifyTerms(params, ids[i], keys[i], c)
+		if err != nil {
+			return keyError(i, len(keys), err)
+		}
+		left.Add(left, a0)
+		right.Add(right, pair(product, keys[i].A1))
+		sum.Add(sum, c)
+	}
+	right.Add(right, new(bn256.GT).ScalarMult(params.Pairing, sum))
+	if bytes.Equal(pair(left, params.G).Marshal(), right.Marshal()) {
+		return nil
+	}
+
+	if len(keys) > 1 {
+		for i := range keys {
+			if err := VerifyKey(params, ids[i], keys[i]); err != nil {
+				return keyError(i, len(keys), err)
+			}
+		}
+	}
+	return errKeyMismatch
+}
+
+// keyError names the key that err is about, if there are several.
+func keyError(i int, n int, err error) error {
+	if n == 1 {
+		return err
+	}
+	return fmt.Errorf("key %d: %w", i, err)
+}
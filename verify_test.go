@@ -0,0 +1,87 @@
+package hibe_sm9
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestVerifyKey(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := KeyGenFromParent(rand.Reader, params, parent, LINEAR_HIERARCHY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = VerifyKey(params, LINEAR_HIERARCHY, key); err != nil {
+		t.Fatal(err)
+	}
+	if err = VerifyKey(params, LINEAR_HIERARCHY[:2], parent.Restrict(DelegationPolicy{MaxDepth: 1})); err != nil {
+		t.Fatal(err)
+	}
+
+	sibling := []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(4)}
+	if err = VerifyKey(params, sibling, key); !errors.Is(err, ErrInvalidID) {
+		t.Fatal("Key verified for another identity")
+	}
+
+	// A tampered delegation component is caught
+	tampered := key.Clone()
+	tampered.B[0].Add(tampered.B[0], params.G3)
+	if err = VerifyKey(params, LINEAR_HIERARCHY, tampered); !errors.Is(err, ErrInvalidID) {
+		t.Fatal("Tampered key verified")
+	}
+
+	other, _, err := Setup(rand.Reader, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key.ParamsFingerprint = nil
+	if err = VerifyKey(other, LINEAR_HIERARCHY, key); err == nil {
+		t.Fatal("Key verified against other parameters")
+	}
+}
+
+func TestVerifyKeys(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([][]*big.Int, 4)
+	keys := make([]*PrivateKey, len(ids))
+	for i := range ids {
+		ids[i] = []*big.Int{big.NewInt(7), big.NewInt(int64(i + 1))}
+		if keys[i], err = KeyGenFromMaster(rand.Reader, params, master, ids[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = VerifyKeys(params, ids, keys); err != nil {
+		t.Fatal(err)
+	}
+
+	keys[1], keys[2] = keys[2], keys[1]
+	err = VerifyKeys(params, ids, keys)
+	if !errors.Is(err, ErrInvalidID) || !strings.Contains(err.Error(), "key 1") {
+		t.Fatal("Swapped keys were not identified:", err)
+	}
+
+	anonymous, anonymousMaster, err := Setup(rand.Reader, 3, WithAnonymity())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := KeyGenFromMaster(rand.Reader, anonymous, anonymousMaster, ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = VerifyKey(anonymous, ids[0], key); err == nil {
+		t.Fatal("Anonymous key was verified")
+	}
+}
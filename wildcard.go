@@ -0,0 +1,318 @@
+package hibe_sm9
+
+import (
+	"crypto/rand"
+	"errors"
+	"golang.org/x/crypto/bn256"
+	"io"
+	"math/big"
+)
+
+// ErrPatternMismatch is returned when an identity or pattern does not match
+// the pattern of a wildcard key or ciphertext.
+var ErrPatternMismatch = wrapError(ErrInvalidID, "hibe: identity does not match the pattern")
+
+// errWildcardAnonymous is returned for wildcard operations in anonymous
+// hierarchies, whose keys have no delegation components.
+var errWildcardAnonymous = errors.New("hibe: anonymous hierarchies do not support wildcards")
+
+// Pattern is an identity in which some components are wildcards, represented
+// by nil. For example, {org, nil, reports} matches {org, dept, reports} for
+// every dept.
+type Pattern []*big.Int
+
+// Matches reports whether id is an instance of the pattern. Identities with
+// missing components, or components outside [1, Order), match no pattern.
+func (pattern Pattern) Matches(id []*big.Int) bool {
+	if len(id) != len(pattern) || checkIDComponents(id) != nil {
+		return false
+	}
+	for i, component := range pattern {
+		if component != nil && component.Cmp(id[i]) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// checkPattern verifies that pattern can be used in the hierarchy with the
+// provided parameters. As in checkID, fixed components must be in [1, Order).
+func checkPattern(params *Params, pattern Pattern) error {
+	if len(pattern) > len(params.H) {
+		return ErrDepthExceeded
+	}
+	for _, component := range pattern {
+		if component != nil && (component.Sign() <= 0 || component.Cmp(bn256.Order) >= 0) {
+			return errComponentRange
+		}
+	}
+	return nil
+}
+
+// patternProduct computes g3 * h1^p1 * ... * hk^pk over the fixed components
+// of the pattern.
+func patternProduct(params *Params, pattern Pattern) *bn256.G1 {
+	product := deepClone(params.G3)
+	for i, component := range pattern {
+		if component != nil {
+			product.Add(product, new(bn256.G1).ScalarMult(params.H[i], component))
+		}
+	}
+	return product
+}
+
+// WildcardKey is a private key for a pattern, following the wildcard key
+// derivation (WKD-IBE) construction of Abdalla, Kiltz and Neven on top of
+// BBG. Besides the delegation components B for the levels below the pattern,
+// it keeps components W for the wildcard positions of the pattern (W[i] is nil
+// for fixed positions), which allow the wildcards to be filled in later.
+type WildcardKey struct {
+	PrivateKey
+	Pattern Pattern
+	W       []*bn256.G1
+}
+
+// WildcardKeyGen generates a key for a pattern using the master key. The key
+// can be turned into the key for any identity matching the pattern with
+// KeyFor, or narrowed with Delegate.
+func WildcardKeyGen(random io.Reader, params *Params, master MasterKey, pattern Pattern) (*WildcardKey, error) {
+	random = randomSource(random)
+	if params.Anonymous() {
+		return nil, errWildcardAnonymous
+	}
+	if err := checkPattern(params, pattern); err != nil {
+		return nil, err
+	}
+	k := len(pattern)
+	l := len(params.H)
+
+	// Randomly choose r in Zp.
+	r, err := rand.Int(random, bn256.Order)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroizeScalar(r)
+
+	product, err := secretMultG1(patternProduct(params, pattern), r)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroizeG1(product)
+
+	key := &WildcardKey{Pattern: append(Pattern{}, pattern...)}
+	key.ParamsFingerprint = params.Fingerprint()
+	key.A0 = new(bn256.G1).Add(master, product)
+	key.A1, err = secretMultG2(params.G, r)
+	if err != nil {
+		return nil, err
+	}
+	key.W = make([]*bn256.G1, k)
+	for i, component := range pattern {
+		if component == nil {
+			if key.W[i], err = secretMultG1(params.H[i], r); err != nil {
+				return nil, err
+			}
+		}
+	}
+	key.B = make([]*bn256.G1, l-k)
+	for j := range key.B {
+		if key.B[j], err = secretMultG1(params.H[k+j], r); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// narrow fills in wildcards of the key and extends it to the longer pattern,
+// without re-randomizing it. The result has no W entries for the fixed
+// positions of pattern.
+func (key *WildcardKey) narrow(pattern Pattern) (*WildcardKey, error) {
+	k := len(key.Pattern)
+	if len(pattern) < k || len(pattern) > k+key.DepthLeft() {
+		return nil, ErrPatternMismatch
+	}
+
+	narrowed := &WildcardKey{Pattern: append(Pattern{}, pattern...)}
+	narrowed.ParamsFingerprint = key.ParamsFingerprint
+	narrowed.A0 = deepClone(key.A0)
+	narrowed.A1 = deepCloneG2(key.A1)
+	narrowed.W = make([]*bn256.G1, len(pattern))
+	for i, component := range pattern {
+		// The component that h_i^r is held in, if any
+		var hr *bn256.G1
+		if i < k {
+			if key.Pattern[i] != nil {
+				if component == nil || component.Cmp(key.Pattern[i]) != 0 {
+					return nil, ErrPatternMismatch
+				}
+				continue
+			}
+			hr = key.W[i]
+		} else {
+			hr = key.B[i-k]
+		}
+
+		if component == nil {
+			narrowed.W[i] = deepClone(hr)
+		} else {
+			narrowed.A0.Add(narrowed.A0, new(bn256.G1).ScalarMult(hr, component))
+		}
+	}
+	narrowed.B = make([]*bn256.G1, key.DepthLeft()-(len(pattern)-k))
+	for j := range narrowed.B {
+		narrowed.B[j] = deepClone(key.B[len(pattern)-k+j])
+	}
+	return narrowed, nil
+}
+
+// Delegate generates a key for a narrower pattern: one that fixes some of the
+// wildcards of the key's pattern, and may extend it by further (fixed or
+// wildcard) components.
+func (key *WildcardKey) Delegate(random io.Reader, params *Params, pattern Pattern) (*WildcardKey, error) {
+	random = randomSource(random)
+	if err := checkPattern(params, pattern); err != nil {
+		return nil, err
+	}
+	narrowed, err := key.narrow(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	// Re-randomize with t, so the delegated key is independent of the parent
+	t, err := rand.Int(random, bn256.Order)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroizeScalar(t)
+
+	product, err := secretMultG1(patternProduct(params, pattern), t)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroizeG1(product)
+	narrowed.A0.Add(narrowed.A0, product)
+
+	a1, err := secretMultG2(params.G, t)
+	if err != nil {
+		return nil, err
+	}
+	narrowed.A1.Add(narrowed.A1, a1)
+
+	for i, wi := range narrowed.W {
+		if wi != nil {
+			ht, err := secretMultG1(params.H[i], t)
+			if err != nil {
+				return nil, err
+			}
+			wi.Add(wi, ht)
+		}
+	}
+	for j, bj := range narrowed.B {
+		ht, err := secretMultG1(params.H[len(pattern)+j], t)
+		if err != nil {
+			return nil, err
+		}
+		bj.Add(bj, ht)
+	}
+	return narrowed, nil
+}
+
+// KeyFor returns the ordinary private key for an identity matching the
+// pattern of the key. The result shares its randomness with the wildcard
+// key; use Delegate instead to hand out independent keys.
+func (key *WildcardKey) KeyFor(id []*big.Int) (*PrivateKey, error) {
+	if err := checkIDComponents(id); err != nil {
+		return nil, err
+	}
+	if !key.Pattern.Matches(id) {
+		return nil, ErrPatternMismatch
+	}
+	narrowed, err := key.narrow(Pattern(id))
+	if err != nil {
+		return nil, err
+	}
+	return &narrowed.PrivateKey, nil
+}
+
+// WildcardCiphertext is a ciphertext for a pattern, which any key for an
+// identity matching the pattern can decrypt. This is the BBG-based wildcard
+// encryption (WIBE) of Abdalla, Catalano, Dent, Malone-Lee, Neven and Smart:
+// C only covers the fixed components, and W[i] = h_i^s for each wildcard
+// position i lets the recipient add in their own component.
+type WildcardCiphertext struct {
+	Ciphertext
+	Pattern Pattern
+	W       []*bn256.G1
+}
+
+// WildcardEncrypt encrypts message for every identity matching pattern.
+func WildcardEncrypt(random io.Reader, params *Params, pattern Pattern, message *bn256.GT) (*WildcardCiphertext, error) {
+	random = randomSource(random)
+	if params.Anonymous() {
+		return nil, errWildcardAnonymous
+	}
+	if err := checkPattern(params, pattern); err != nil {
+		return nil, err
+	}
+	ciphertext := &WildcardCiphertext{Pattern: append(Pattern{}, pattern...)}
+	ciphertext.ParamsFingerprint = params.Fingerprint()
+
+	// Randomly choose s in Zp
+	s, err := rand.Int(random, bn256.Order)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroizeScalar(s)
+
+	if params.Pairing == nil {
+		params.Pairing = pair(params.G2, params.G1)
+	}
+
+	ciphertext.A, err = secretMultGT(params.Pairing, s)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext.A.Add(ciphertext.A, message)
+
+	ciphertext.B, err = secretMultG2(params.G, s)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext.C, err = secretMultG1(patternProduct(params, pattern), s)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext.W = make([]*bn256.G1, len(pattern))
+	for i, component := range pattern {
+		if component == nil {
+			if ciphertext.W[i], err = secretMultG1(params.H[i], s); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return ciphertext, nil
+}
+
+// For returns the ordinary ciphertext for an identity matching the pattern,
+// which can be decrypted with the key for that identity.
+func (ciphertext *WildcardCiphertext) For(id []*big.Int) (*Ciphertext, error) {
+	if err := checkIDComponents(id); err != nil {
+		return nil, err
+	}
+	if !ciphertext.Pattern.Matches(id) {
+		return nil, ErrPatternMismatch
+	}
+	result := &Ciphertext{
+		A:                 ciphertext.A,
+		B:                 ciphertext.B,
+		C:                 deepClone(ciphertext.C),
+		ParamsFingerprint: ciphertext.ParamsFingerprint,
+	}
+	for i, wi := range ciphertext.W {
+		if wi != nil {
+			result.C.Add(result.C, new(bn256.G1).ScalarMult(wi, id[i]))
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,148 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"golang.org/x/crypto/bn256"
+	"math/big"
+	"testing"
+)
+
+func TestWildcardKey(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	org, reports := big.NewInt(1), big.NewInt(3)
+	sales := []*big.Int{org, big.NewInt(20), reports}
+	support := []*big.Int{org, big.NewInt(21), reports}
+
+	key, err := WildcardKeyGen(rand.Reader, params, master, Pattern{org, nil, reports})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := NewMessage()
+	for _, id := range [][]*big.Int{sales, support} {
+		ciphertext, err := Encrypt(rand.Reader, params, id, message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		instance, err := key.KeyFor(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(message.Marshal(), mustDecrypt(t, instance, ciphertext).Marshal()) {
+			t.Fatal("Wildcard key does not decrypt for a matching identity")
+		}
+	}
+	if _, err = key.KeyFor([]*big.Int{big.NewInt(2), big.NewInt(20), reports}); err != ErrPatternMismatch {
+		t.Fatal("Wildcard key instantiated for a non-matching identity")
+	}
+
+	// Delegating fixes the wildcard and extends the pattern
+	delegated, err := key.Delegate(rand.Reader, params, Pattern{org, big.NewInt(20), reports, nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := append(append([]*big.Int{}, sales...), big.NewInt(7))
+	ciphertext, err := Encrypt(rand.Reader, params, id, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	instance, err := delegated.KeyFor(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(message.Marshal(), mustDecrypt(t, instance, ciphertext).Marshal()) {
+		t.Fatal("Delegated wildcard key does not decrypt")
+	}
+	if _, err = delegated.Delegate(rand.Reader, params, Pattern{org, big.NewInt(21), reports, nil}); err != ErrPatternMismatch {
+		t.Fatal("Delegated outside the pattern")
+	}
+}
+
+func TestWildcardEncrypt(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := []*big.Int{big.NewInt(1), big.NewInt(20), big.NewInt(3)}
+	key, err := KeyGenFromMaster(rand.Reader, params, master, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := NewMessage()
+	ciphertext, err := WildcardEncrypt(rand.Reader, params, Pattern{big.NewInt(1), nil, big.NewInt(3)}, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	instance, err := ciphertext.For(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(message.Marshal(), mustDecrypt(t, key, instance).Marshal()) {
+		t.Fatal("Matching key does not decrypt wildcard ciphertext")
+	}
+	if _, err = ciphertext.For([]*big.Int{big.NewInt(2), big.NewInt(20), big.NewInt(3)}); err != ErrPatternMismatch {
+		t.Fatal("Instantiated wildcard ciphertext for a non-matching identity")
+	}
+}
+
+func TestWildcardKeyChecks(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, component := range []*big.Int{big.NewInt(0), new(big.Int).Set(bn256.Order)} {
+		if _, err = WildcardKeyGen(rand.Reader, params, master, Pattern{component, nil}); !errors.Is(err, ErrInvalidID) {
+			t.Fatal("Generated a wildcard key for a component out of range")
+		}
+	}
+
+	key, err := WildcardKeyGen(rand.Reader, params, master, Pattern{big.NewInt(1), nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key.ParamsFingerprint, params.Fingerprint()) {
+		t.Fatal("Wildcard key is not bound to its parameters")
+	}
+	other, _, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := []*big.Int{big.NewInt(1), big.NewInt(2)}
+	instance, err := key.KeyFor(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := Encrypt(rand.Reader, other, id, NewMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Decrypt(instance, ciphertext); !errors.Is(err, ErrCurveMismatch) {
+		t.Fatal("Wildcard key was used with other parameters")
+	}
+
+	wildcard, err := WildcardEncrypt(rand.Reader, params, Pattern{big.NewInt(1), nil}, NewMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, invalid := range [][]*big.Int{
+		{big.NewInt(1), nil},
+		{big.NewInt(1), big.NewInt(0)},
+		{big.NewInt(1), new(big.Int).Set(bn256.Order)},
+	} {
+		if key.Pattern.Matches(invalid) {
+			t.Fatal("Pattern matched an invalid identity")
+		}
+		if _, err = key.KeyFor(invalid); !errors.Is(err, ErrInvalidID) {
+			t.Fatal("Wildcard key instantiated for an invalid identity")
+		}
+		if _, err = wildcard.For(invalid); !errors.Is(err, ErrInvalidID) {
+			t.Fatal("Wildcard ciphertext instantiated for an invalid identity")
+		}
+	}
+}
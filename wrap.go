@@ -0,0 +1,39 @@
+package hibe_sm9
+
+import (
+	"io"
+	"math/big"
+)
+
+// wrapDomain is authenticated with every wrapped key, so that a wrapped key
+// cannot be passed off as an ordinary EncryptBytes message, or vice versa.
+var wrapDomain = []byte("HIBE-WRAPPED-KEY")
+
+var errWrappedKey = wrapError(ErrMalformedCiphertext, "hibe: wrapped key does not contain a private key")
+
+// WrapKeyFor encrypts a newly issued private key to the HIBE identity of the
+// device that will use it, so that a PKG can deliver the key over an untrusted
+// channel. The device recovers the key with UnwrapKey and the key of its own
+// identity, which it obtained once, out of band, when it was enrolled. The
+// key is encrypted as with EncryptBytes, with its encoding as the plaintext.
+func WrapKeyFor(random io.Reader, params *Params, childKey *PrivateKey, deviceID []*big.Int) ([]byte, error) {
+	encoded := childKey.Marshal()
+	defer zeroizeBytes(encoded)
+	return encryptBytes(random, params, deviceID, encoded, wrapDomain)
+}
+
+// UnwrapKey recovers the private key wrapped by WrapKeyFor, using the key of
+// the device identity. The private key is not checked against the
+// parameters; use Validate for that.
+func UnwrapKey(deviceKey *PrivateKey, wrapped []byte) (*PrivateKey, error) {
+	encoded, err := decryptBytes(deviceKey, wrapped, wrapDomain)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroizeBytes(encoded)
+	key, ok := new(PrivateKey).Unmarshal(encoded)
+	if !ok {
+		return nil, errWrappedKey
+	}
+	return key, nil
+}
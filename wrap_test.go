@@ -0,0 +1,54 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestWrapKey(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deviceID := []*big.Int{big.NewInt(99), big.NewInt(1)}
+	deviceKey, err := KeyGenFromMaster(rand.Reader, params, master, deviceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	childKey, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wrapped, err := WrapKeyFor(rand.Reader, params, childKey, deviceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unwrapped, err := UnwrapKey(deviceKey, wrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !unwrapped.Equal(childKey) || !bytes.Equal(unwrapped.ParamsFingerprint, childKey.ParamsFingerprint) {
+		t.Fatal("Unwrapped key differs")
+	}
+
+	// Only the device can unwrap
+	if _, err = UnwrapKey(childKey, wrapped); !errors.Is(err, ErrDecryptFailed) {
+		t.Fatal("Key was unwrapped with the wrong device key")
+	}
+
+	// Wrapped keys and ordinary messages are not interchangeable
+	if _, err = DecryptBytes(deviceKey, wrapped); err == nil {
+		t.Fatal("Wrapped key was decrypted as a message")
+	}
+	message, err := EncryptBytes(rand.Reader, params, deviceID, childKey.Marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = UnwrapKey(deviceKey, message); err == nil {
+		t.Fatal("Message was unwrapped as a key")
+	}
+}
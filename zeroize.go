@@ -0,0 +1,92 @@
+package hibe_sm9
+
+import (
+	"golang.org/x/crypto/bn256"
+	"math/big"
+)
+
+// Scrub elements with full-width coordinates. bn256 does not expose the
+// coordinates of its elements, but ScalarMult by one copies the coordinates of
+// its argument into the existing limbs of the receiver, so copying one of
+// these over a secret element overwrites it in place.
+var (
+	scrubG1 = new(bn256.G1).ScalarBaseMult(HashToZp([]byte("HIBE-ZEROIZE-G1")))
+	scrubG2 = new(bn256.G2).ScalarBaseMult(HashToZp([]byte("HIBE-ZEROIZE-G2")))
+	scrubGT = new(bn256.GT).ScalarMult(gtBase, HashToZp([]byte("HIBE-ZEROIZE-GT")))
+)
+
+// zeroizeScalar overwrites the limbs of k and sets it to zero.
+func zeroizeScalar(k *big.Int) {
+	if k == nil {
+		return
+	}
+	limbs := k.Bits()
+	for i := range limbs {
+		limbs[i] = 0
+	}
+	k.SetInt64(0)
+}
+
+// zeroizeBytes overwrites b with zeros.
+func zeroizeBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// zeroizeG1 overwrites the coordinates of p with those of an unrelated point.
+func zeroizeG1(p *bn256.G1) {
+	if p != nil {
+		p.ScalarMult(scrubG1, big.NewInt(1))
+	}
+}
+
+// zeroizeG2 overwrites the coordinates of p with those of an unrelated point.
+func zeroizeG2(p *bn256.G2) {
+	if p != nil {
+		p.ScalarMult(scrubG2, big.NewInt(1))
+	}
+}
+
+// zeroizeGT overwrites the coefficients of e with those of an unrelated
+// element.
+func zeroizeGT(e *bn256.GT) {
+	if e != nil {
+		e.ScalarMult(scrubGT, big.NewInt(1))
+	}
+}
+
+// Zeroize overwrites the group elements of the private key and removes them
+// from the key, which must not be used afterwards. Copies made by Marshal,
+// and temporaries inside bn256 itself, are not covered; callers that need
+// those wiped must wipe the encodings themselves.
+func (privkey *PrivateKey) Zeroize() {
+	zeroizeG1(privkey.A0)
+	zeroizeG2(privkey.A1)
+	zeroizeG1(privkey.A1Hat)
+	for _, bi := range privkey.B {
+		zeroizeG1(bi)
+	}
+	privkey.A0, privkey.A1, privkey.A1Hat, privkey.B = nil, nil, nil, nil
+}
+
+// ZeroizeMasterKey overwrites the master key, which must not be used
+// afterwards. MasterKey is a pointer type, so the caller's variable still
+// points to the (now meaningless) element.
+func ZeroizeMasterKey(master MasterKey) {
+	zeroizeG1(master)
+}
+
+// Zeroize overwrites the master key share, which must not be used afterwards.
+func (share *MasterKeyShare) Zeroize() {
+	zeroizeG1(share.Share)
+	share.Share = nil
+}
+
+// Zeroize overwrites the private key share, which must not be used
+// afterwards.
+func (share *PrivateKeyShare) Zeroize() {
+	if share.Key != nil {
+		share.Key.Zeroize()
+	}
+}
@@ -0,0 +1,54 @@
+package hibe_sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"golang.org/x/crypto/bn256"
+	"math/big"
+	"testing"
+)
+
+func TestZeroizeScalar(t *testing.T) {
+	k, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 256))
+	if err != nil {
+		t.Fatal(err)
+	}
+	limbs := k.Bits()
+	zeroizeScalar(k)
+	if k.Sign() != 0 {
+		t.Fatal("Scalar is not zero")
+	}
+	for _, limb := range limbs[:cap(limbs)] {
+		if limb != 0 {
+			t.Fatal("Scalar limbs were not overwritten")
+		}
+	}
+}
+
+func TestZeroizePrivateKey(t *testing.T) {
+	params, master, err := Setup(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := KeyGenFromMaster(rand.Reader, params, master, LINEAR_HIERARCHY[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a0, a1, b0 := key.A0, key.A1, key.B[0]
+	encodedA0, encodedA1, encodedB0 := a0.Marshal(), a1.Marshal(), b0.Marshal()
+	key.Zeroize()
+
+	if key.A0 != nil || key.A1 != nil || key.B != nil {
+		t.Fatal("Zeroized key still references its elements")
+	}
+	if bytes.Equal(a0.Marshal(), encodedA0) || bytes.Equal(a1.Marshal(), encodedA1) || bytes.Equal(b0.Marshal(), encodedB0) {
+		t.Fatal("Key elements were not overwritten")
+	}
+
+	encodedMaster := (*bn256.G1)(master).Marshal()
+	ZeroizeMasterKey(master)
+	if bytes.Equal((*bn256.G1)(master).Marshal(), encodedMaster) {
+		t.Fatal("Master key was not overwritten")
+	}
+}